@@ -0,0 +1,95 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/artifact"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/utils/errutils"
+)
+
+type exportOptions struct {
+	blueprint string
+	output    string
+	artifact.ExportOptions
+}
+
+func NewArtifactExportCommand() *cobra.Command {
+	o := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Build the offline bundle of a blueprint.",
+		Long: `Build a bundle of the packages and binaries the blueprint needs, for hosts
+without network access. The packages are downloaded with their dependencies on
+a builder host of each architecture, the builders of the artifacts section of
+the blueprint, or this machine if it has the architecture. The bundle is a
+gzipped tarball with a manifest and the checksums of all files.`,
+		Example: `  peta artifact export -b blueprint.yml -o peta-bundle.tar.gz
+  peta artifact export -b blueprint.yml --arch amd64,arm64`,
+		Run: func(cmd *cobra.Command, args []string) {
+			errutils.CheckErr(RunExport(o))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.output, "output", "o", "peta-bundle.tar.gz", "Path of the bundle")
+	fs.StringSliceVar(&o.Archs, "arch", nil, "Architectures of the bundle, default is those of the blueprint hosts")
+
+	return cmd
+}
+
+func RunExport(o *exportOptions) (err error) {
+	b, err := types.LoadBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	// the bundle is written next to the output and renamed when complete.
+	tmp := o.output + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	o.Output = os.Stdout
+	m, err := artifact.Export(signals.SetupSignalHandler(), b, f, o.ExportOptions)
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, o.output); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "bundle %s: %d files for %v\n", o.output, len(m.Files), m.Archs())
+	return nil
+}
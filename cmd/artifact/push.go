@@ -0,0 +1,99 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/artifact"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/utils/errutils"
+)
+
+type pushOptions struct {
+	blueprint string
+	selector  string
+	bundle    string
+	artifact.PushOptions
+}
+
+func NewArtifactPushCommand() *cobra.Command {
+	o := &pushOptions{}
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Push an offline bundle to the hosts of a blueprint.",
+		Long: `Copy the files of a bundle built by peta artifact export to the hosts of a
+blueprint, each host gets the files of its architecture. The packages are set up
+as a local repository preferred over the others. Hosts which already have the
+same files are left as they are.`,
+		Example: `  peta artifact push -b blueprint.yml -f peta-bundle.tar.gz
+  peta artifact push -b blueprint.yml -f peta-bundle.tar.gz --selector role=replica`,
+		Run: func(cmd *cobra.Command, args []string) {
+			errutils.CheckErr(RunPush(o))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	fs.StringVarP(&o.bundle, "file", "f", "peta-bundle.tar.gz", "Path of the bundle")
+	fs.StringVar(&o.Dir, "dir", artifact.DefaultDir, "Directory of the bundle on the hosts")
+	fs.IntVar(&o.Concurrency, "concurrency", runner.DefaultConcurrency, "Maximum number of hosts pushed at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", runner.DefaultTimeout, "Timeout of pushing to each host")
+
+	return cmd
+}
+
+func RunPush(o *pushOptions) error {
+	selector, err := labels.Parse(o.selector)
+	if err != nil {
+		return err
+	}
+
+	b, err := types.LoadBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	hosts := b.SelectHosts(selector)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host matches selector %q", o.selector)
+	}
+
+	o.Output = os.Stdout
+	results, err := artifact.Push(signals.SetupSignalHandler(), o.bundle, hosts, o.PushOptions)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := artifact.PrintReport(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failed := artifact.Failed(results); failed > 0 {
+		return fmt.Errorf("failed to push the bundle to %d of %d hosts", failed, len(results))
+	}
+
+	return nil
+}
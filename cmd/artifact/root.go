@@ -0,0 +1,35 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import "github.com/spf13/cobra"
+
+func NewArtifactCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "artifact",
+		Short: "Build and distribute offline bundles of a blueprint.",
+		Long:  ``,
+	}
+}
+
+func RegisterCommands(parent *cobra.Command) {
+	cmd := NewArtifactCommand()
+	parent.AddCommand(cmd)
+	cmd.AddCommand(NewArtifactExportCommand())
+	cmd.AddCommand(NewArtifactPushCommand())
+}
@@ -0,0 +1,76 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package host
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/tuning"
+	"peta.io/peta/pkg/utils/errutils"
+)
+
+type driftOptions struct {
+	blueprint string
+	selector  string
+	tuning.Options
+}
+
+func NewHostDriftCommand() *cobra.Command {
+	o := &driftOptions{}
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Report where the hosts of a blueprint drifted from their tuning profiles.",
+		Long: `Compare the hosts of a blueprint with their tuning profiles, see peta host tune,
+without changing them. Every setting which differs is reported, the command
+fails if any host drifted.`,
+		Example: `  peta host drift -b blueprint.yml
+  peta host drift -b blueprint.yml --profile postgres-host`,
+		Run: func(cmd *cobra.Command, args []string) {
+			errutils.CheckErr(RunDrift(o))
+		},
+	}
+
+	addTuningFlags(cmd, &o.blueprint, &o.selector, &o.Options)
+
+	return cmd
+}
+
+func RunDrift(o *driftOptions) error {
+	b, hosts, err := selectTuningHosts(o.blueprint, o.selector)
+	if err != nil {
+		return err
+	}
+
+	results := tuning.Check(signals.SetupSignalHandler(), b, hosts, o.Options)
+
+	if err := tuning.PrintDrifts(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failed := tuning.Failed(results); failed > 0 {
+		return fmt.Errorf("failed to check %d of %d hosts", failed, len(results))
+	}
+	if drifted := tuning.Drifted(results); drifted > 0 {
+		return fmt.Errorf("%d of %d hosts drifted from their tuning profiles", drifted, len(results))
+	}
+
+	return nil
+}
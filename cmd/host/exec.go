@@ -0,0 +1,114 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package host
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/utils/errutils"
+)
+
+type execOptions struct {
+	blueprint string
+	selector  string
+	dbURL     string
+	runner.Options
+}
+
+func NewHostExecCommand() *cobra.Command {
+	o := &execOptions{}
+	cmd := &cobra.Command{
+		Use:   "exec [flags] -- <command>",
+		Short: "Run a command on the hosts of a blueprint.",
+		Long: `Run a command on the hosts of a blueprint in parallel.
+The output of each host is prefixed with its name, a summary of the exit codes
+and durations is printed when all hosts are done. With --db-url the transcripts
+of the command are recorded, see peta host history.`,
+		Example: `  peta host exec -b blueprint.yml --selector role=replica -- systemctl status postgresql`,
+		Run: func(cmd *cobra.Command, args []string) {
+			errutils.CheckErr(RunExec(o, args))
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	fs.IntVar(&o.Concurrency, "concurrency", runner.DefaultConcurrency, "Maximum number of hosts running the command at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", runner.DefaultTimeout, "Timeout of the command on each host")
+	fs.StringVar(&o.dbURL, persistence.URL, "", "Database connection url to record the transcripts of the command")
+
+	return cmd
+}
+
+func RunExec(o *execOptions, args []string) error {
+	if len(args) == 0 {
+		return errors.New("command is required, like: peta host exec -- uptime")
+	}
+
+	selector, err := labels.Parse(o.selector)
+	if err != nil {
+		return err
+	}
+
+	b, err := types.LoadBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	hosts := b.SelectHosts(selector)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host matches selector %q", o.selector)
+	}
+
+	ctx := signals.SetupSignalHandler()
+	if o.dbURL != "" {
+		store, closeStore, err := openStore(ctx, o.dbURL)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		operation := transcript.NewOperationID()
+		ctx = transcript.WithRecorder(transcript.WithOperation(ctx, operation), store)
+		_, _ = fmt.Fprintf(os.Stdout, "operation %s\n", operation)
+	}
+
+	o.Output = os.Stdout
+	results := runner.Run(ctx, hosts, strings.Join(args, " "), o.Options)
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := runner.PrintSummary(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failed := runner.Failed(results); failed > 0 {
+		return fmt.Errorf("command failed on %d of %d hosts", failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package host
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/tuning"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/errutils"
+)
+
+type tuneOptions struct {
+	blueprint string
+	selector  string
+	tuning.Options
+}
+
+func NewHostTuneCommand() *cobra.Command {
+	o := &tuneOptions{}
+	cmd := &cobra.Command{
+		Use:   "tune",
+		Short: "Apply the tuning profiles of the hosts of a blueprint.",
+		Long: `Apply the tuning profiles of the hosts of a blueprint: sysctl values, limits,
+transparent hugepage, io scheduler and kernel modules. The settings are applied
+now and persisted under /etc so they survive a reboot, limits apply to new
+sessions. The profiles of the hosts are set in the tuning section of the
+blueprint, or with --profile. Hosts in sync with their profiles are left as they
+are. The built-in profiles are ` + tuning.PostgresHost + `.`,
+		Example: `  peta host tune -b blueprint.yml
+  peta host tune -b blueprint.yml --selector role=replica --profile postgres-host`,
+		Run: func(cmd *cobra.Command, args []string) {
+			errutils.CheckErr(RunTune(o))
+		},
+	}
+
+	addTuningFlags(cmd, &o.blueprint, &o.selector, &o.Options)
+
+	return cmd
+}
+
+func addTuningFlags(cmd *cobra.Command, blueprint, selector *string, o *tuning.Options) {
+	fs := cmd.Flags()
+	fs.StringVarP(blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	fs.StringSliceVar(&o.Profiles, "profile", nil, "Tuning profiles of the hosts, default is those of the blueprint")
+	fs.IntVar(&o.Concurrency, "concurrency", runner.DefaultConcurrency, "Maximum number of hosts handled at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", runner.DefaultTimeout, "Timeout of each host")
+}
+
+func selectTuningHosts(blueprint, selector string) (*types.Blueprint, []component.Host, error) {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := types.LoadBlueprint(blueprint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts := b.SelectHosts(s)
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("no host matches selector %q", selector)
+	}
+	return b, hosts, nil
+}
+
+func RunTune(o *tuneOptions) error {
+	b, hosts, err := selectTuningHosts(o.blueprint, o.selector)
+	if err != nil {
+		return err
+	}
+
+	o.Output = os.Stdout
+	results := tuning.Apply(signals.SetupSignalHandler(), b, hosts, o.Options)
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := tuning.PrintSummary(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failed := tuning.Failed(results); failed > 0 {
+		return fmt.Errorf("failed to tune %d of %d hosts", failed, len(results))
+	}
+
+	return nil
+}
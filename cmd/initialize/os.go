@@ -14,59 +14,81 @@
  *  You should have received a copy of the GNU Affero General Public License
  *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
  */
-
 package initialize
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
-	"peta.io/peta/pkg/clients/ssh"
-	"peta.io/peta/pkg/log"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/osinit"
+	"peta.io/peta/pkg/runner"
 	"peta.io/peta/pkg/server/options"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/types"
 )
 
-func NewInitOSCommand(o *options.APIServerOptions) *cobra.Command {
+type osOptions struct {
+	blueprint string
+	selector  string
+	osinit.Options
+}
+
+func NewInitOSCommand(_ *options.APIServerOptions) *cobra.Command {
+	o := &osOptions{}
 	cmd := &cobra.Command{
 		Use:   "os",
-		Short: "Start the peta admin server.",
-		Long:  ``,
+		Short: "Prepare the operating system of the blueprint hosts.",
+		Long: `Prepare every host of a blueprint: set the hostname and the /etc/hosts entries
+of all hosts, configure time sync, disable swap, load kernel modules, apply
+sysctl settings and create service users. The settings are read from the os
+section of the blueprint spec. Every step is idempotent, hosts already prepared
+are reported ok.`,
+		Example: `  peta init os -b blueprint.yml
+  peta init os -b blueprint.yml --selector role=replica`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return Run()
+			return Run(o)
 		},
 		SilenceUsage: true,
 	}
 
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	fs.IntVar(&o.Concurrency, "concurrency", runner.DefaultConcurrency, "Maximum number of hosts prepared at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", runner.DefaultTimeout, "Timeout of preparing each host")
+
 	return cmd
 }
 
-func Run() error {
+func Run(o *osOptions) error {
+	selector, err := labels.Parse(o.selector)
+	if err != nil {
+		return err
+	}
 
-	// private key for root: "/Users/zhouleyan/.ssh/id_ed25519"
-	// "zly2104718987" for "zhouleyan"
-	client, err := ssh.New(
-		"root",
-		"10.1.1.111",
-		22,
-		"123456",
-		"",
-		"",
-		"",
-		0,
-		true,
-		true,
-	)
+	b, err := types.LoadBlueprint(o.blueprint)
 	if err != nil {
 		return err
 	}
 
-	output, err := client.Run("ip a")
+	hosts := b.SelectHosts(selector)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host matches selector %q", o.selector)
+	}
 
-	if err != nil {
-		log.Errorf(string(output))
+	o.Output = os.Stdout
+	results := osinit.Run(signals.SetupSignalHandler(), b, hosts, o.Options)
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := osinit.PrintReport(os.Stdout, results); err != nil {
 		return err
 	}
-	fmt.Println(string(output))
+
+	if failed := osinit.Failed(results); failed > 0 {
+		return fmt.Errorf("failed to prepare %d of %d hosts", failed, len(results))
+	}
 
 	return nil
 }
@@ -0,0 +1,95 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package initialize
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/preflight"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/types"
+)
+
+type preflightOptions struct {
+	blueprint string
+	selector  string
+	preflight.Options
+}
+
+func NewInitPreflightCommand() *cobra.Command {
+	o := &preflightOptions{Options: preflight.NewOptions()}
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check the blueprint hosts before installing.",
+		Long: `Check every host of a blueprint and report pass, warn or fail for each check:
+ssh reachability, root rights, os support, free ports, free disk space, clock
+skew between hosts, peer name resolution and mtu consistency.`,
+		Example: `  peta init preflight -b blueprint.yml
+  peta init preflight -b blueprint.yml --port 5432 --port 8008 --min-disk /data=50`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunPreflight(o)
+		},
+		SilenceUsage: true,
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	AddPreflightFlags(fs, &o.Options)
+
+	return cmd
+}
+
+// AddPreflightFlags adds the flags of the checks to fs.
+func AddPreflightFlags(fs *pflag.FlagSet, o *preflight.Options) {
+	fs.IntVar(&o.Concurrency, "concurrency", o.Concurrency, "Maximum number of hosts checked at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", o.Timeout, "Timeout of the checks on each host")
+	fs.IntSliceVar(&o.Ports, "port", o.Ports, "Ports which must be free on every host")
+	fs.StringToInt64Var(&o.Disks, "min-disk", o.Disks, "Minimum free space in GiB of directories, like /data=50")
+	fs.DurationVar(&o.MaxClockSkew, "max-clock-skew", o.MaxClockSkew, "Clock difference between hosts above which the check fails")
+}
+
+func RunPreflight(o *preflightOptions) error {
+	selector, err := labels.Parse(o.selector)
+	if err != nil {
+		return err
+	}
+
+	b, err := types.LoadBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	hosts := b.SelectHosts(selector)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host matches selector %q", o.selector)
+	}
+
+	report := preflight.Run(signals.SetupSignalHandler(), b, hosts, o.Options)
+	if err := preflight.PrintReport(os.Stdout, report); err != nil {
+		return err
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("preflight checks failed")
+	}
+	return nil
+}
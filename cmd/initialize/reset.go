@@ -0,0 +1,117 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package initialize
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"peta.io/peta/pkg/artifact"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/osinit"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/signals"
+	"peta.io/peta/pkg/types"
+)
+
+type resetOptions struct {
+	blueprint string
+	selector  string
+	confirm   bool
+	osinit.ResetOptions
+}
+
+func NewInitResetCommand() *cobra.Command {
+	o := &resetOptions{}
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Reset the blueprint hosts to their state before peta.",
+		Long: `Reset the hosts of a blueprint for reuse: stop and disable the services of
+the components and remove their packages, remove the service users peta
+created, the repositories and bundles, the links and masquerade rules, the files written by
+peta init os and peta host tune, and restore the sysctl values peta changed.
+The hostname, swap and time zone are left as they are.
+
+Nothing is changed without --confirm, the actions are listed instead. The data
+and config directories of the components and the homes of the service users
+are only deleted with --delete-data.`,
+		Example: `  peta init reset -b blueprint.yml
+  peta init reset -b blueprint.yml --confirm
+  peta init reset -b blueprint.yml --confirm --delete-data`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunReset(o)
+		},
+		SilenceUsage: true,
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.StringVarP(&o.selector, "selector", "l", "", "Label selector of the hosts, like role=replica,env!=dev")
+	fs.BoolVar(&o.confirm, "confirm", false, "Reset the hosts, the actions are only listed without it")
+	fs.BoolVar(&o.DeleteData, "delete-data", false, "Also delete the data and config directories and the homes of the service users")
+	fs.StringVar(&o.ArtifactsDir, "artifacts-dir", artifact.DefaultDir, "Directory of the bundles on the hosts")
+	fs.IntVar(&o.Concurrency, "concurrency", runner.DefaultConcurrency, "Maximum number of hosts reset at the same time")
+	fs.DurationVar(&o.Timeout, "timeout", runner.DefaultTimeout, "Timeout of resetting each host")
+
+	return cmd
+}
+
+func RunReset(o *resetOptions) error {
+	selector, err := labels.Parse(o.selector)
+	if err != nil {
+		return err
+	}
+
+	b, err := types.LoadBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	hosts := b.SelectHosts(selector)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no host matches selector %q", o.selector)
+	}
+
+	o.DryRun = !o.confirm
+	if o.DryRun {
+		results := osinit.Reset(signals.SetupSignalHandler(), b, hosts, o.ResetOptions)
+		if err := osinit.PrintActions(os.Stdout, results); err != nil {
+			return err
+		}
+		if failed := osinit.Failed(results); failed > 0 {
+			return fmt.Errorf("failed to check %d of %d hosts", failed, len(results))
+		}
+		_, _ = fmt.Fprintln(os.Stdout, "\nDry run, nothing was changed. Run again with --confirm to reset the hosts.")
+		return nil
+	}
+
+	o.Output = os.Stdout
+	results := osinit.Reset(signals.SetupSignalHandler(), b, hosts, o.ResetOptions)
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := osinit.PrintReport(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failed := osinit.Failed(results); failed > 0 {
+		return fmt.Errorf("failed to reset %d of %d hosts", failed, len(results))
+	}
+
+	return nil
+}
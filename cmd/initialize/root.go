@@ -42,4 +42,6 @@ func RegisterCommands(parent *cobra.Command) {
 
 	parent.AddCommand(cmd)
 	cmd.AddCommand(NewInitOSCommand(o))
+	cmd.AddCommand(NewInitPreflightCommand())
+	cmd.AddCommand(NewInitResetCommand())
 }
@@ -18,12 +18,15 @@
 package pg
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"peta.io/peta/cmd/initialize"
 	"peta.io/peta/pkg/log"
+	"peta.io/peta/pkg/preflight"
+	"peta.io/peta/pkg/signals"
 	"peta.io/peta/pkg/types"
 	"peta.io/peta/pkg/utils/errutils"
 )
@@ -32,38 +35,72 @@ const (
 	defaultBlueprintName = "blueprint"
 )
 
+type createOptions struct {
+	blueprint       string
+	ignorePreflight bool
+	preflight       preflight.Options
+}
+
 func NewPGCreateCommand() *cobra.Command {
-	blueprint := ""
+	o := &createOptions{preflight: preflight.NewOptions()}
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create Postgres instance.",
-		Long:  ``,
+		Long: `Create Postgres instance on the hosts of a blueprint.
+The preflight checks of peta init preflight run first, a failed check stops
+the creation unless --ignore-preflight is given.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			errutils.CheckErr(Run(blueprint))
+			errutils.CheckErr(Run(o))
 		},
 	}
 
-	cmd.Flags().StringVarP(&blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs := cmd.Flags()
+	fs.StringVarP(&o.blueprint, "blueprint", "b", "blueprint.yml", "Specify a blueprint file")
+	fs.BoolVar(&o.ignorePreflight, "ignore-preflight", false, "Create even if preflight checks fail")
+	initialize.AddPreflightFlags(fs, &o.preflight)
 
 	return cmd
 }
 
-func Run(blueprint string) error {
-	b, err := loadFromBlueprint(blueprint)
+func Run(o *createOptions) error {
+	b, err := loadFromBlueprint(o.blueprint)
+	if err != nil {
+		return err
+	}
+
+	if err := runPreflight(b, o); err != nil {
+		return err
+	}
+
 	log.Infoln(b)
-	return err
+	return nil
 }
 
-func loadFromBlueprint(blueprint string) (b *types.Blueprint, err error) {
-	b = &types.Blueprint{}
-	fp, err := filepath.Abs(blueprint)
-	if err != nil {
-		return nil, err
+func runPreflight(b *types.Blueprint, o *createOptions) error {
+	hosts := b.Hosts()
+	if len(hosts) == 0 {
+		return errors.New("no host in the enabled components of the blueprint")
+	}
+
+	report := preflight.Run(signals.SetupSignalHandler(), b, hosts, o.preflight)
+	if err := preflight.PrintReport(os.Stdout, report); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(os.Stdout)
+
+	if !report.Failed() {
+		return nil
+	}
+	if o.ignorePreflight {
+		log.Warnf("preflight checks failed, ignored by --ignore-preflight")
+		return nil
 	}
+	return errors.New("preflight checks failed, fix them or pass --ignore-preflight")
+}
 
-	_, err = os.Open(fp)
+func loadFromBlueprint(blueprint string) (b *types.Blueprint, err error) {
+	b, err = types.LoadBlueprint(blueprint)
 	if err != nil {
-		err = fmt.Errorf("unable to open the given blueprint file: %w", err)
 		log.Errorln(err)
 		return nil, err
 	}
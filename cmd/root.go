@@ -23,6 +23,8 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"peta.io/peta/cmd/artifact"
+	"peta.io/peta/cmd/host"
 	"peta.io/peta/cmd/initialize"
 	"peta.io/peta/cmd/pg"
 	"peta.io/peta/cmd/serve"
@@ -69,6 +71,8 @@ func RegisterCommandRecursive(cmd *cobra.Command) {
 	serve.RegisterCommands(cmd)
 	version.RegisterCommands(cmd)
 	pg.RegisterCommands(cmd)
+	host.RegisterCommands(cmd)
+	artifact.RegisterCommands(cmd)
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
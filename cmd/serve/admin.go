@@ -67,7 +67,7 @@ func Run(ctx context.Context, o *options.APIServerOptions) error {
 		return err
 	}
 
-	if errors.Is(apiServer.Run(ctx), http.ErrServerClosed) {
+	if err = apiServer.Run(ctx); errors.Is(err, http.ErrServerClosed) {
 		return nil
 	}
 
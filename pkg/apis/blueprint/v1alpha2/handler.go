@@ -0,0 +1,128 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"context"
+	"slices"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+type handler struct {
+	store *rest.Store[types.Blueprint, *types.Blueprint]
+}
+
+// NewStore returns the store of the blueprints in the database of p, blueprints
+// belong to a namespace and so do the hosts they list.
+func NewStore(p persistence.Persister) *rest.Store[types.Blueprint, *types.Blueprint] {
+	return rest.NewStore[types.Blueprint](p, "blueprints", true).WithAdmission(keepCredentials)
+}
+
+// keepCredentials keeps the passwords and private keys of the hosts and jump hosts
+// updated without them. The credentials are never served, so the blueprints read
+// and written back have none. They are kept for the hosts still reached at the same
+// address as the same user only, so they can't be sent to other servers.
+func keepCredentials(_ context.Context, obj, old rest.Object) error {
+	if old == nil {
+		return nil
+	}
+	stored := make(map[hostKey]component.Host)
+	for _, c := range old.(*types.Blueprint).Spec.Components {
+		for _, h := range c.Hosts {
+			stored[hostKey{c.Name, h.Name, h.Address, h.Port, h.User}] = h
+		}
+	}
+
+	b := obj.(*types.Blueprint)
+	for i := range b.Spec.Components {
+		c := &b.Spec.Components[i]
+		for j := range c.Hosts {
+			h := &c.Hosts[j]
+			s, ok := stored[hostKey{c.Name, h.Name, h.Address, h.Port, h.User}]
+			if !ok {
+				continue
+			}
+			keep(&h.Password, s.Password)
+			keep(&h.PrivateKey, s.PrivateKey)
+			if h.Become != nil && s.Become != nil && h.Become.User == s.Become.User {
+				keep(&h.Become.Password, s.Become.Password)
+			}
+			for k := range h.JumpHosts {
+				jump := &h.JumpHosts[k]
+				for _, sj := range s.JumpHosts {
+					if jump.Address == sj.Address && jump.Port == sj.Port && jump.User == sj.User {
+						keep(&jump.Password, sj.Password)
+						keep(&jump.PrivateKey, sj.PrivateKey)
+						break
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hostKey is what a host keeps its credentials by: its component, its name, and
+// where and as who it is logged in.
+type hostKey struct {
+	component, name, address string
+	port                     int
+	user                     string
+}
+
+// keep sets the empty credential to the stored one.
+func keep(credential *string, stored string) {
+	if *credential == "" {
+		*credential = stored
+	}
+}
+
+// redactCredentials clears the passwords and private keys of the hosts and jump
+// hosts of the blueprints served, on copies of their components and hosts.
+func redactCredentials(b *types.Blueprint) {
+	b.Spec.Components = slices.Clone(b.Spec.Components)
+	for i := range b.Spec.Components {
+		c := &b.Spec.Components[i]
+		c.Hosts = slices.Clone(c.Hosts)
+		for j := range c.Hosts {
+			h := &c.Hosts[j]
+			h.Password, h.PrivateKey = "", ""
+			if h.Become != nil {
+				become := *h.Become
+				become.Password = ""
+				h.Become = &become
+			}
+			h.JumpHosts = slices.Clone(h.JumpHosts)
+			for k := range h.JumpHosts {
+				h.JumpHosts[k].Password, h.JumpHosts[k].PrivateKey = "", ""
+			}
+		}
+	}
+}
+
+func NewHandler(store *rest.Store[types.Blueprint, *types.Blueprint]) apis.Handler {
+	return &handler{store: store}
+}
+
+func NewFakeHandler() apis.Handler {
+	return &handler{store: NewStore(nil)}
+}
@@ -0,0 +1,89 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"context"
+	"testing"
+
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+func blueprint(hosts ...component.Host) *types.Blueprint {
+	return &types.Blueprint{Spec: types.Spec{Components: []component.Component{{Name: "db", Hosts: hosts}}}}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	stored := blueprint(component.Host{
+		Name: "node1", Address: "10.0.0.31", Password: "pw", PrivateKey: "key",
+		Become:    &component.Become{User: "root", Password: "sudo"},
+		JumpHosts: []component.JumpHost{{Address: "10.0.0.1", Password: "jump", PrivateKey: "jump key"}},
+	})
+	served := *stored
+	redactCredentials(&served)
+
+	h := served.Spec.Components[0].Hosts[0]
+	if h.Password != "" || h.PrivateKey != "" || h.Become.Password != "" || h.JumpHosts[0].Password != "" || h.JumpHosts[0].PrivateKey != "" {
+		t.Errorf("served the credentials of %+v", h)
+	}
+	if h.Become.User != "root" || h.JumpHosts[0].Address != "10.0.0.1" {
+		t.Errorf("served %+v, want the host without its credentials only", h)
+	}
+	s := stored.Spec.Components[0].Hosts[0]
+	if s.Password != "pw" || s.PrivateKey != "key" || s.Become.Password != "sudo" || s.JumpHosts[0].Password != "jump" {
+		t.Errorf("the stored host %+v lost its credentials", s)
+	}
+}
+
+func TestKeepCredentials(t *testing.T) {
+	old := blueprint(component.Host{
+		Name: "node1", Address: "10.0.0.31", User: "admin", Password: "pw",
+		Become:    &component.Become{Password: "sudo"},
+		JumpHosts: []component.JumpHost{{Address: "10.0.0.1", PrivateKey: "jump key"}},
+	})
+	tests := []struct {
+		name string
+		host component.Host
+		want string
+	}{
+		{name: "same host", host: component.Host{Name: "node1", Address: "10.0.0.31", User: "admin"}, want: "pw"},
+		{name: "new password", host: component.Host{Name: "node1", Address: "10.0.0.31", User: "admin", Password: "new"}, want: "new"},
+		{name: "other address", host: component.Host{Name: "node1", Address: "10.9.9.9", User: "admin"}},
+		{name: "other user", host: component.Host{Name: "node1", Address: "10.0.0.31", User: "root"}},
+	}
+	for _, tt := range tests {
+		b := blueprint(tt.host)
+		if err := keepCredentials(context.Background(), b, old); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.Spec.Components[0].Hosts[0].Password; got != tt.want {
+			t.Errorf("%s: got the password %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	b := blueprint(component.Host{
+		Name: "node1", Address: "10.0.0.31", User: "admin",
+		Become:    &component.Become{},
+		JumpHosts: []component.JumpHost{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}},
+	})
+	_ = keepCredentials(context.Background(), b, old)
+	h := b.Spec.Components[0].Hosts[0]
+	if h.Become.Password != "sudo" || h.JumpHosts[0].PrivateKey != "jump key" || h.JumpHosts[1].PrivateKey != "" {
+		t.Errorf("got the host %+v, want the become password and the key of the first jump host kept", h)
+	}
+}
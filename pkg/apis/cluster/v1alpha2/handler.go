@@ -0,0 +1,57 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"context"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/persistence"
+)
+
+type handler struct {
+	store *rest.Store[Cluster, *Cluster]
+}
+
+// NewStore returns the store of the member clusters in the database of p, the
+// handler and the proxy of the members share it.
+func NewStore(p persistence.Persister) *rest.Store[Cluster, *Cluster] {
+	return rest.NewStore[Cluster](p, "clusters", false).WithAdmission(keepBearerToken)
+}
+
+// keepBearerToken keeps the bearer token of the clusters updated without one. The
+// token is never served, so the clusters read and written back have none.
+func keepBearerToken(_ context.Context, obj, old rest.Object) error {
+	if c := obj.(*Cluster); old != nil && c.Spec.BearerToken == "" {
+		c.Spec.BearerToken = old.(*Cluster).Spec.BearerToken
+	}
+	return nil
+}
+
+// redactBearerToken clears the bearer token of the clusters served.
+func redactBearerToken(c *Cluster) {
+	c.Spec.BearerToken = ""
+}
+
+func NewHandler(store *rest.Store[Cluster, *Cluster]) apis.Handler {
+	return &handler{store: store}
+}
+
+func NewFakeHandler() apis.Handler {
+	return &handler{store: NewStore(nil)}
+}
@@ -0,0 +1,52 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBearerTokenWriteOnly(t *testing.T) {
+	old := &Cluster{Spec: ClusterSpec{Endpoint: "https://10.0.0.2:9443", BearerToken: "secret"}}
+
+	c := &Cluster{Spec: ClusterSpec{Endpoint: "https://10.0.0.3:9443"}}
+	if err := keepBearerToken(context.Background(), c, old); err != nil {
+		t.Fatal(err)
+	}
+	if c.Spec.BearerToken != "secret" {
+		t.Errorf("the update without a token has the token %q, want the stored one", c.Spec.BearerToken)
+	}
+
+	c = &Cluster{Spec: ClusterSpec{BearerToken: "rotated"}}
+	_ = keepBearerToken(context.Background(), c, old)
+	if c.Spec.BearerToken != "rotated" {
+		t.Errorf("the update with a token has the token %q, want rotated", c.Spec.BearerToken)
+	}
+
+	c = &Cluster{}
+	_ = keepBearerToken(context.Background(), c, nil)
+	if c.Spec.BearerToken != "" {
+		t.Errorf("the created cluster without a token has the token %q", c.Spec.BearerToken)
+	}
+
+	served := *old
+	redactBearerToken(&served)
+	if served.Spec.BearerToken != "" || old.Spec.BearerToken != "secret" {
+		t.Errorf("served the token %q, the stored one is %q", served.Spec.BearerToken, old.Spec.BearerToken)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/rest"
+)
+
+const (
+	GroupName = "cluster.peta.io"
+)
+
+var GroupVersion = apis.GroupVersion{
+	Group:   GroupName,
+	Version: "v1alpha2",
+}
+
+func (h *handler) AddToContainer(container *restful.Container) error {
+	ws := apis.NewWebService(GroupVersion)
+
+	rest.NewHandler(h.store).
+		WithFields(map[string]func(c *Cluster) string{
+			"spec.endpoint": func(c *Cluster) string { return c.Spec.Endpoint },
+			"status.phase":  func(c *Cluster) string { return string(c.Status.Phase) },
+		}).
+		WithRedaction(redactBearerToken).
+		AddToWebService(ws, "cluster", apis.TagMultiCluster)
+
+	container.Add(ws)
+	return nil
+}
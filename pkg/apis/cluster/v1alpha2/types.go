@@ -0,0 +1,98 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/types"
+)
+
+// ClusterPhase is the health of a member cluster as seen by the server fronting it.
+type ClusterPhase string
+
+const (
+	// ClusterPending members have not been probed yet.
+	ClusterPending ClusterPhase = "Pending"
+	// ClusterReady members answered their last probe.
+	ClusterReady ClusterPhase = "Ready"
+	// ClusterNotReady members failed their last probe, requests to them are refused.
+	ClusterNotReady ClusterPhase = "NotReady"
+)
+
+// clusterName is a DNS label, the names are parts of the paths of the proxied requests.
+var clusterName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// Cluster is another PETA server registered as a member, the requests to
+// /clusters/{name}/... are forwarded to it.
+type Cluster struct {
+	types.TypeMeta   `json:",inline"`
+	types.ObjectMeta `json:"metadata"`
+
+	Spec   ClusterSpec   `json:"spec"`
+	Status ClusterStatus `json:"status,omitempty"`
+}
+
+// ClusterSpec is how the member is reached.
+type ClusterSpec struct {
+	// Endpoint is the URL of the member server, like https://10.0.0.2:9443.
+	Endpoint string `json:"endpoint"`
+	// BearerToken authenticates the forwarded requests to the member, the requests
+	// are authorized by the fronting server before they are forwarded. It is write
+	// only: it is never served, and updates without it keep the stored one.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// CAData is the PEM of the certificates verifying the member's, the system
+	// ones are used if empty.
+	CAData string `json:"caData,omitempty"`
+	// InsecureSkipTLSVerify doesn't verify the certificate of the member.
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// ClusterStatus is the health of the member, kept by the server probing it.
+type ClusterStatus struct {
+	Phase ClusterPhase `json:"phase,omitempty"`
+	// Message is why the last probe failed.
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is when the phase last changed.
+	LastTransitionTime *time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// Validate checks the name and the endpoint of the cluster.
+func (c *Cluster) Validate() error {
+	var causes []apis.StatusCause
+	if !clusterName.MatchString(c.Name) {
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: "metadata.name",
+			Message: "must be a lowercase DNS label"})
+	}
+	if u, err := url.Parse(c.Spec.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: "spec.endpoint",
+			Message: fmt.Sprintf("%q is not an http or https URL", c.Spec.Endpoint)})
+	}
+	if c.Spec.CAData != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(c.Spec.CAData)) {
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: "spec.caData",
+			Message: "contains no PEM certificates"})
+	}
+	if len(causes) > 0 {
+		return apis.NewInvalid("clusters", c.Name, causes...)
+	}
+	return nil
+}
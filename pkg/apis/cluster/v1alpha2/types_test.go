@@ -0,0 +1,67 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"testing"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/types"
+)
+
+func TestClusterValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		caData   string
+		fields   []string
+	}{
+		{name: "site-1", endpoint: "https://10.0.0.2:9443"},
+		{name: "site-1", endpoint: "http://peta.example.com/prefix"},
+		{name: "Site_1", endpoint: "https://10.0.0.2:9443", fields: []string{"metadata.name"}},
+		{name: "site-1", endpoint: "10.0.0.2:9443", fields: []string{"spec.endpoint"}},
+		{name: "site-1", endpoint: "ftp://10.0.0.2", fields: []string{"spec.endpoint"}},
+		{name: "-site", endpoint: "", caData: "not a certificate", fields: []string{"metadata.name", "spec.endpoint", "spec.caData"}},
+	}
+	for _, tt := range tests {
+		c := &Cluster{
+			ObjectMeta: types.ObjectMeta{Name: tt.name},
+			Spec:       ClusterSpec{Endpoint: tt.endpoint, CAData: tt.caData},
+		}
+		err := c.Validate()
+		if len(tt.fields) == 0 {
+			if err != nil {
+				t.Errorf("Validate(%q, %q) = %v, want nil", tt.name, tt.endpoint, err)
+			}
+			continue
+		}
+		if !apis.IsInvalid(err) {
+			t.Errorf("Validate(%q, %q) = %v, want an invalid error", tt.name, tt.endpoint, err)
+			continue
+		}
+		causes := err.(*apis.StatusError).Status().Details.Causes
+		if len(causes) != len(tt.fields) {
+			t.Errorf("Validate(%q, %q) causes = %v, want the fields %v", tt.name, tt.endpoint, causes, tt.fields)
+			continue
+		}
+		for i, cause := range causes {
+			if cause.Field != tt.fields[i] {
+				t.Errorf("Validate(%q, %q) cause %d is of %q, want %q", tt.name, tt.endpoint, i, cause.Field, tt.fields[i])
+			}
+		}
+	}
+}
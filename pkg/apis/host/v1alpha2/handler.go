@@ -0,0 +1,344 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package v1alpha2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/gofrs/uuid"
+	"peta.io/peta/pkg/apis"
+	blueprintv1alpha2 "peta.io/peta/pkg/apis/blueprint/v1alpha2"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/clients/ssh"
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/watch"
+)
+
+type handler struct {
+	Storage     persistence.Storage
+	blueprints  *rest.Store[types.Blueprint, *types.Blueprint]
+	transcripts *watch.Broadcaster
+	// execEnabled installs the exec route, which runs commands with the credentials
+	// of the blueprints.
+	execEnabled bool
+}
+
+// ExecRequest runs a command on the hosts of a blueprint matching the selector.
+type ExecRequest struct {
+	// Blueprint is the name of the blueprint of the namespace listing the hosts.
+	Blueprint      string `json:"blueprint"`
+	Selector       string `json:"selector,omitempty"`
+	Command        string `json:"command"`
+	Concurrency    int    `json:"concurrency,omitempty"`
+	TimeoutSeconds int64  `json:"timeoutSeconds,omitempty"`
+}
+
+// ExecResponse holds the result of each selected host.
+type ExecResponse struct {
+	// OperationID links the transcripts of the commands run on the hosts.
+	OperationID string          `json:"operationId"`
+	Results     []runner.Result `json:"results"`
+	Failed      int             `json:"failed"`
+}
+
+// NewHandler returns the handler running commands on the hosts of the blueprints of
+// the store if exec is true, the transcripts of the commands are kept in s. Commands
+// should only be run for the clients authenticated and authorized.
+func NewHandler(s persistence.Storage, blueprints *rest.Store[types.Blueprint, *types.Blueprint], exec bool) apis.Handler {
+	return &handler{Storage: s, blueprints: blueprints, transcripts: watch.NewBroadcaster(watch.DefaultHistorySize), execEnabled: exec}
+}
+
+func NewFakeHandler() apis.Handler {
+	return &handler{blueprints: blueprintv1alpha2.NewStore(nil), transcripts: watch.NewBroadcaster(watch.DefaultHistorySize), execEnabled: true}
+}
+
+// broadcastRecorder records the transcripts in the namespace, and sends them without
+// their output to the watches.
+type broadcastRecorder struct {
+	transcript.Recorder
+	b         *watch.Broadcaster
+	namespace string
+}
+
+func (r broadcastRecorder) Record(ctx context.Context, t *transcript.Transcript) error {
+	t.Namespace = r.namespace
+	if err := r.Recorder.Record(ctx, t); err != nil {
+		return err
+	}
+	event := *t
+	event.Output = ""
+	r.b.Action(watch.Added, &event)
+	return nil
+}
+
+func (h *handler) exec(request *restful.Request, response *restful.Response) {
+	exec := &ExecRequest{}
+	if err := request.ReadEntity(exec); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	if exec.Blueprint == "" {
+		apis.HandleBadRequest(response, request, errors.New("blueprint is required"))
+		return
+	}
+	if exec.Command == "" {
+		apis.HandleBadRequest(response, request, errors.New("command is required"))
+		return
+	}
+
+	selector, err := labels.Parse(exec.Selector)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	// the hosts, their credentials and jump hosts are the ones kept by the server, never the client's.
+	namespace := request.PathParameter("namespace")
+	b, err := h.blueprints.Get(request.Request.Context(), namespace, exec.Blueprint)
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	hosts := b.SelectHosts(selector)
+	if err := checkHosts(b.Name, hosts); err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+
+	operation := transcript.NewOperationID()
+	ctx := transcript.WithOperation(request.Request.Context(), operation)
+	ctx = transcript.WithRecorder(ctx, broadcastRecorder{Recorder: transcript.NewStore(h.Storage), b: h.transcripts, namespace: namespace})
+
+	results := runner.Run(ctx, hosts, exec.Command, runner.Options{
+		Concurrency: exec.Concurrency,
+		Timeout:     time.Duration(exec.TimeoutSeconds) * time.Second,
+		KeepOutput:  true,
+	})
+
+	_ = response.WriteAsJson(ExecResponse{OperationID: operation, Results: results, Failed: runner.Failed(results)})
+}
+
+// checkHosts refuses the hosts of the blueprint the server does not run commands on
+// for clients, local hosts would run them as the server process.
+func checkHosts(blueprint string, hosts []component.Host) error {
+	var causes []apis.StatusCause
+	for _, h := range hosts {
+		if executor.IsLocal(&h) {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("host %s has a local connection, commands run on it with the CLI only", h.Name)})
+			continue
+		}
+		if ssh.HostKeyPolicy(h.HostKeyPolicy) == ssh.HostKeyInsecure {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("host %s has the insecure host key policy", h.Name)})
+		}
+	}
+	if len(causes) > 0 {
+		return apis.NewInvalid("blueprints", blueprint, causes...)
+	}
+	return nil
+}
+
+func (h *handler) listTranscripts(request *restful.Request, response *restful.Response) {
+	if watch.IsWatch(request) {
+		h.watchTranscripts(request, response)
+		return
+	}
+
+	o, err := listOptions(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	q, err := query.ParseQueryParameter(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	// the latest first, unless asked otherwise
+	if q.SortBy == "" {
+		q.SortBy = "startedAt"
+		q.Ascending = request.QueryParameter(query.ParameterAscending) == "true"
+	}
+
+	version := h.transcripts.ResourceVersion()
+	var list *query.List[transcript.Transcript]
+	if q.SortBy == "startedAt" && q.SelectsBy() {
+		list, err = h.pageTranscripts(request.Request.Context(), o, q)
+	} else {
+		// the latest transcripts are selected and sorted by the other fields in memory
+		o.Limit = maxListedTranscripts
+		var transcripts []transcript.Transcript
+		if transcripts, err = transcript.NewStore(h.Storage).List(request.Request.Context(), o); err == nil {
+			if list, err = query.Apply(transcripts, q, transcriptAccessor); err != nil {
+				err = apis.NewBadRequest(err.Error())
+			}
+		}
+	}
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	list.Metadata.ResourceVersion = version
+	_ = response.WriteAsJson(list)
+}
+
+// pageTranscripts returns the page of the transcripts of o in the order of their start
+// time, the database selects and pages them. The pages are of the limit of q, of at
+// most maxListedTranscripts transcripts.
+func (h *handler) pageTranscripts(ctx context.Context, o transcript.ListOptions, q *query.Query) (*query.List[transcript.Transcript], error) {
+	o.Ascending = q.Ascending
+	o.Limit = maxListedTranscripts
+	if q.Limit > 0 {
+		o.Limit = min(q.Limit, maxListedTranscripts)
+	}
+
+	store := transcript.NewStore(h.Storage)
+	total, err := store.Count(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	value, key, err := q.Seek()
+	if err != nil {
+		return nil, apis.NewBadRequest(err.Error())
+	}
+	following := total
+	if q.Continue != "" {
+		startedAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, apis.NewBadRequest(query.ErrInvalidContinue.Error())
+		}
+		id, err := uuid.FromString(key)
+		if err != nil {
+			return nil, apis.NewBadRequest(query.ErrInvalidContinue.Error())
+		}
+		o.After = &transcript.Cursor{StartedAt: startedAt, ID: id}
+		if following, err = store.Count(ctx, o); err != nil {
+			return nil, err
+		}
+	}
+
+	transcripts, err := store.List(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	return query.Page(transcripts, q, transcriptAccessor, total, following-len(transcripts)), nil
+}
+
+// maxListedTranscripts is the maximum size of the pages of transcripts, and the number
+// of the latest transcripts sorted or selected by other fields than startedAt.
+const maxListedTranscripts = 10000
+
+var transcriptAccessor = query.Accessor[transcript.Transcript]{
+	Key: func(t transcript.Transcript) string { return t.ID.String() },
+	Fields: map[string]func(t transcript.Transcript) string{
+		"host":        func(t transcript.Transcript) string { return t.Host },
+		"operationId": func(t transcript.Transcript) string { return t.OperationID },
+		"user":        func(t transcript.Transcript) string { return t.User },
+		"exitCode":    func(t transcript.Transcript) string { return strconv.Itoa(t.ExitCode) },
+		"startedAt":   func(t transcript.Transcript) string { return t.StartedAt.UTC().Format(time.RFC3339Nano) },
+	},
+}
+
+func (h *handler) watchTranscripts(request *restful.Request, response *restful.Response) {
+	o, err := listOptions(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	filter := func(e watch.Event) bool {
+		t := e.Object.(*transcript.Transcript)
+		return (o.Namespace == "" || t.Namespace == o.Namespace) &&
+			(o.Host == "" || t.Host == o.Host) && (o.OperationID == "" || t.OperationID == o.OperationID)
+	}
+	watch.Serve(request, response, h.transcripts, filter, func(ctx context.Context) ([]interface{}, error) {
+		transcripts, err := transcript.NewStore(h.Storage).List(ctx, o)
+		objects := make([]interface{}, len(transcripts))
+		for i := range transcripts {
+			objects[i] = &transcripts[i]
+		}
+		return objects, err
+	})
+}
+
+// listOptions returns the options of the namespace of the path and the query
+// parameters of the request.
+func listOptions(request *restful.Request) (transcript.ListOptions, error) {
+	o := transcript.ListOptions{
+		Namespace:   request.PathParameter("namespace"),
+		Host:        request.QueryParameter("host"),
+		OperationID: request.QueryParameter("operation"),
+	}
+
+	if limit := request.QueryParameter("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return o, err
+		}
+		o.Limit = n
+	}
+
+	if since := request.QueryParameter("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return o, err
+		}
+		o.Since = t
+	}
+	return o, nil
+}
+
+func (h *handler) getTranscript(request *restful.Request, response *restful.Response) {
+	id, err := uuid.FromString(request.PathParameter("id"))
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	// the transcripts of the other namespaces are not found
+	t, err := transcript.NewStore(h.Storage).Get(request.Request.Context(), id)
+	if err == nil && t.Namespace != request.PathParameter("namespace") {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		apis.HandleNotFound(response, request, err)
+		return
+	}
+	if err != nil {
+		apis.HandleInternalError(response, request, err)
+		return
+	}
+
+	_ = response.WriteAsJson(t)
+}
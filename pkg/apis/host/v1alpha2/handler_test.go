@@ -0,0 +1,100 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package v1alpha2
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/watch"
+)
+
+func TestCheckHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    component.Host
+		invalid bool
+	}{
+		{name: "ssh", host: component.Host{Name: "node1", Address: "10.0.0.31"}},
+		{name: "ssh to localhost", host: component.Host{Name: "node1", Address: "localhost", Connection: "ssh"}},
+		{name: "local connection", host: component.Host{Name: "node1", Address: "10.0.0.31", Connection: "local"}, invalid: true},
+		{name: "localhost", host: component.Host{Name: "node1", Address: "localhost"}, invalid: true},
+		{name: "insecure host key policy", host: component.Host{Name: "node1", Address: "10.0.0.31", HostKeyPolicy: "insecure"}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHosts("db", []component.Host{tt.host})
+			if tt.invalid != apis.IsInvalid(err) {
+				t.Errorf("checkHosts() = %v, want invalid %v", err, tt.invalid)
+			}
+		})
+	}
+}
+
+type memoryRecorder []transcript.Transcript
+
+func (m *memoryRecorder) Record(_ context.Context, t *transcript.Transcript) error {
+	*m = append(*m, *t)
+	return nil
+}
+
+func TestBroadcastRecorderNamespace(t *testing.T) {
+	var recorded memoryRecorder
+	b := watch.NewBroadcaster(watch.DefaultHistorySize)
+	w, err := b.Watch("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	r := broadcastRecorder{Recorder: &recorded, b: b, namespace: "team-a"}
+	if err := r.Record(context.Background(), &transcript.Transcript{Host: "node1", Output: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || recorded[0].Namespace != "team-a" {
+		t.Fatalf("recorded %+v, want a transcript of the namespace team-a", recorded)
+	}
+	e := <-w.ResultChan()
+	if sent := e.Object.(*transcript.Transcript); sent.Namespace != "team-a" || sent.Output != "" {
+		t.Errorf("sent %+v, want the transcript of the namespace team-a without its output", sent)
+	}
+}
+
+func TestExecRoute(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		container := restful.NewContainer()
+		h := NewHandler(nil, nil, enabled)
+		if err := h.AddToContainer(container); err != nil {
+			t.Fatal(err)
+		}
+		installed := false
+		for _, route := range container.RegisteredWebServices()[0].Routes() {
+			installed = installed || strings.HasSuffix(route.Path, "/exec")
+		}
+		if installed != enabled {
+			t.Errorf("exec enabled %v: the exec route is installed %v", enabled, installed)
+		}
+	}
+}
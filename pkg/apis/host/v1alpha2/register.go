@@ -0,0 +1,106 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package v1alpha2
+
+import (
+	"net/http"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/watch"
+)
+
+const (
+	GroupName = "host.peta.io"
+)
+
+var GroupVersion = apis.GroupVersion{
+	Group:   GroupName,
+	Version: "v1alpha2",
+}
+
+func (h *handler) AddToContainer(container *restful.Container) error {
+	ws := apis.NewWebService(GroupVersion)
+
+	if h.execEnabled {
+		ws.Route(ws.POST("/namespaces/{namespace}/exec").
+			Doc("run a command on hosts").
+			Operation("hosts-exec").
+			Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagHostOperations}).
+			Notes("Run a command on the hosts of a blueprint of the namespace matching the selector with bounded concurrency, "+
+				"the route is installed only if authentication and authorization are enabled").
+			Param(ws.PathParameter("namespace", "Name of the namespace")).
+			Reads(ExecRequest{}).
+			Returns(http.StatusOK, apis.StatusOK, ExecResponse{}).
+			To(h.exec))
+	}
+
+	// the transcripts of the namespace, and of all the namespaces for the cluster admins
+	h.addTranscriptListRoutes(ws, "/namespaces/{namespace}/transcripts", "hosts-transcripts",
+		ws.PathParameter("namespace", "Name of the namespace"))
+	h.addTranscriptListRoutes(ws, "/transcripts", "hosts-transcripts-all-namespaces")
+
+	ws.Route(ws.GET("/namespaces/{namespace}/transcripts/{id}").
+		Doc("get a command transcript").
+		Operation("hosts-transcripts-get").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagHostOperations}).
+		Notes("Get a transcript of the namespace including the output of the command").
+		Param(ws.PathParameter("namespace", "Name of the namespace")).
+		Param(ws.PathParameter("id", "Transcript id")).
+		Returns(http.StatusOK, apis.StatusOK, transcript.Transcript{}).
+		To(h.getTranscript))
+
+	container.Add(ws)
+	return nil
+}
+
+func (h *handler) addTranscriptListRoutes(ws *restful.WebService, path, operation string, params ...*restful.Parameter) {
+	list := ws.GET(path).
+		Doc("list command transcripts").
+		Operation(operation+"-list").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagHostOperations}).
+		Notes("List the latest transcripts of the commands run on hosts without their output, the latest first by default").
+		Param(ws.QueryParameter("host", "Name of the host")).
+		Param(ws.QueryParameter("operation", "Operation id returned by exec")).
+		Param(ws.QueryParameter("since", "Only transcripts started after the RFC 3339 time")).
+		Returns(http.StatusOK, apis.StatusOK, query.List[transcript.Transcript]{}).
+		To(h.listTranscripts)
+	for _, p := range append(append(query.Parameters(ws), watch.Parameters(ws)...), params...) {
+		list.Param(p)
+	}
+	ws.Route(list)
+
+	watchTranscripts := ws.GET("/watch"+path).
+		Doc("watch command transcripts").
+		Operation(operation+"-watch").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagHostOperations}).
+		Notes("Stream the transcripts of the commands as they finish, without their output").
+		Param(ws.QueryParameter("host", "Name of the host")).
+		Param(ws.QueryParameter("operation", "Operation id returned by exec")).
+		Param(ws.QueryParameter("since", "Only transcripts started after the RFC 3339 time, for the initial list")).
+		Param(ws.QueryParameter("limit", "Maximum number of transcripts of the initial list").DataType("integer")).
+		Returns(http.StatusOK, apis.StatusOK, watch.Event{}).
+		To(h.watchTranscripts)
+	for _, p := range append(watch.Parameters(ws)[1:], params...) {
+		watchTranscripts.Param(p)
+	}
+	ws.Route(watchTranscripts)
+}
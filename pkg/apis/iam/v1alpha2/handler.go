@@ -14,18 +14,31 @@
  *  You should have received a copy of the GNU Affero General Public License
  *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
  */
-
 package v1alpha2
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/emicklei/go-restful/v3"
 	"github.com/gofrs/uuid"
 	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
 	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/server/authorization"
+	"peta.io/peta/pkg/server/authorization/rbac"
+	apirequest "peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/watch"
 )
 
 type handler struct {
-	Storage persistence.Storage
+	Storage    persistence.Storage
+	Authorizer authorization.Authorizer
+	// store broadcasts the changes of the roles and role bindings, the ones of the
+	// deleted workspaces and namespaces too.
+	store *rbac.Store
 }
 
 type User struct {
@@ -33,12 +46,24 @@ type User struct {
 	name string
 }
 
-func NewHandler(s persistence.Storage) apis.Handler {
-	return &handler{Storage: s}
+// AccessReview is the answer to whether the user may perform a request.
+type AccessReview struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func NewHandler(s persistence.Storage, a authorization.Authorizer, store *rbac.Store) apis.Handler {
+	return &handler{
+		Storage:    s,
+		Authorizer: a,
+		store:      store,
+	}
 }
 
 func NewFakeHandler() apis.Handler {
-	return &handler{}
+	return &handler{
+		store: rbac.NewStore(nil),
+	}
 }
 
 func (h *handler) listUsers(request *restful.Request, response *restful.Response) {
@@ -50,3 +75,290 @@ func (h *handler) listUsers(request *restful.Request, response *restful.Response
 	}
 	_ = response.WriteAsJson(map[string]string{"v": "ok"})
 }
+
+func (h *handler) canI(request *restful.Request, response *restful.Response) {
+	user, _ := apirequest.UserFrom(request.Request.Context())
+	a := authorization.Attributes{
+		User:        user,
+		Verb:        request.QueryParameter("verb"),
+		APIGroup:    request.QueryParameter("group"),
+		Resource:    request.QueryParameter("resource"),
+		Subresource: request.QueryParameter("subresource"),
+		Name:        request.QueryParameter("name"),
+		Cluster:     request.QueryParameter("cluster"),
+		Workspace:   request.QueryParameter("workspace"),
+		Namespace:   request.QueryParameter("namespace"),
+		Path:        request.QueryParameter("path"),
+	}
+	if a.Verb == "" {
+		apis.HandleBadRequest(response, request, errors.New("verb is required"))
+		return
+	}
+	switch {
+	case a.Resource != "" && a.Path != "":
+		apis.HandleBadRequest(response, request, errors.New("resource and path are mutually exclusive"))
+		return
+	case a.Resource != "":
+		a.IsResourceRequest = true
+		a.Scope = rbac.Location{Cluster: a.Cluster, Workspace: a.Workspace, Namespace: a.Namespace}.Scope()
+	case a.Path != "":
+	default:
+		apis.HandleBadRequest(response, request, errors.New("resource or path is required"))
+		return
+	}
+
+	decision, reason, err := h.Authorizer.Authorize(request.Request.Context(), a)
+	if err != nil && decision != authorization.DecisionAllow {
+		apis.HandleInternalError(response, request, err)
+		return
+	}
+	review := AccessReview{Allowed: decision == authorization.DecisionAllow, Reason: reason}
+	if !review.Allowed && review.Reason == "" {
+		review.Reason = "no role grants the request"
+	}
+	_ = response.WriteAsJson(review)
+}
+
+var roleAccessor = query.Accessor[rbac.Role]{
+	Key: func(r rbac.Role) string { return r.Name },
+	Fields: map[string]func(r rbac.Role) string{
+		"name":      func(r rbac.Role) string { return r.Name },
+		"createdAt": func(r rbac.Role) string { return r.CreatedAt.UTC().Format(time.RFC3339) },
+	},
+}
+
+var roleBindingAccessor = query.Accessor[rbac.RoleBinding]{
+	Key: func(b rbac.RoleBinding) string { return b.Name },
+	Fields: map[string]func(b rbac.RoleBinding) string{
+		"name":      func(b rbac.RoleBinding) string { return b.Name },
+		"roleKind":  func(b rbac.RoleBinding) string { return b.RoleKind },
+		"roleName":  func(b rbac.RoleBinding) string { return b.RoleName },
+		"createdAt": func(b rbac.RoleBinding) string { return b.CreatedAt.UTC().Format(time.RFC3339) },
+	},
+}
+
+// location returns the location of the roles and bindings of the request, global
+// for cluster roles and cluster role bindings.
+func location(request *restful.Request) rbac.Location {
+	return rbac.Location{
+		Workspace: request.PathParameter("workspace"),
+		Namespace: request.PathParameter("namespace"),
+	}
+}
+
+func (h *handler) listRoles(request *restful.Request, response *restful.Response) {
+	if watch.IsWatch(request) {
+		h.watchRoles(request, response)
+		return
+	}
+	q, err := query.ParseQueryParameter(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	version := h.store.RoleBroadcaster().ResourceVersion()
+	roles, err := h.store.ListRoles(request.Request.Context(), location(request))
+	if err != nil {
+		apis.HandleInternalError(response, request, err)
+		return
+	}
+	list, err := query.Apply(roles, q, roleAccessor)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	list.Metadata.ResourceVersion = version
+	_ = response.WriteAsJson(list)
+}
+
+func (h *handler) getRole(request *restful.Request, response *restful.Response) {
+	role, err := h.store.GetRole(request.Request.Context(), location(request), request.PathParameter("name"))
+	if err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(role)
+}
+
+func (h *handler) createRole(request *restful.Request, response *restful.Response) {
+	role := &rbac.Role{}
+	if err := request.ReadEntity(role); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	role.Location = location(request)
+	if err := role.Validate(); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.CreateRole(request.Request.Context(), role); err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(role)
+}
+
+func (h *handler) updateRole(request *restful.Request, response *restful.Response) {
+	role := &rbac.Role{}
+	if err := request.ReadEntity(role); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := checkName(request, &role.Name); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	role.Location = location(request)
+	if err := role.Validate(); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.UpdateRole(request.Request.Context(), role); err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(role)
+}
+
+func (h *handler) deleteRole(request *restful.Request, response *restful.Response) {
+	_, err := h.store.DeleteRole(request.Request.Context(), location(request), request.PathParameter("name"))
+	if err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(map[string]string{"status": apis.StatusOK})
+}
+
+func (h *handler) watchRoles(request *restful.Request, response *restful.Response) {
+	l := location(request)
+	watch.Serve(request, response, h.store.RoleBroadcaster(),
+		func(e watch.Event) bool { return e.Object.(*rbac.Role).Location == l },
+		func(ctx context.Context) ([]interface{}, error) {
+			roles, err := h.store.ListRoles(ctx, l)
+			objects := make([]interface{}, len(roles))
+			for i := range roles {
+				objects[i] = &roles[i]
+			}
+			return objects, err
+		})
+}
+
+func (h *handler) listRoleBindings(request *restful.Request, response *restful.Response) {
+	if watch.IsWatch(request) {
+		h.watchRoleBindings(request, response)
+		return
+	}
+	q, err := query.ParseQueryParameter(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	version := h.store.RoleBindingBroadcaster().ResourceVersion()
+	bindings, err := h.store.ListRoleBindings(request.Request.Context(), location(request))
+	if err != nil {
+		apis.HandleInternalError(response, request, err)
+		return
+	}
+	list, err := query.Apply(bindings, q, roleBindingAccessor)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	list.Metadata.ResourceVersion = version
+	_ = response.WriteAsJson(list)
+}
+
+func (h *handler) getRoleBinding(request *restful.Request, response *restful.Response) {
+	binding, err := h.store.GetRoleBinding(request.Request.Context(), location(request), request.PathParameter("name"))
+	if err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(binding)
+}
+
+func (h *handler) watchRoleBindings(request *restful.Request, response *restful.Response) {
+	l := location(request)
+	watch.Serve(request, response, h.store.RoleBindingBroadcaster(),
+		func(e watch.Event) bool { return e.Object.(*rbac.RoleBinding).Location == l },
+		func(ctx context.Context) ([]interface{}, error) {
+			bindings, err := h.store.ListRoleBindings(ctx, l)
+			objects := make([]interface{}, len(bindings))
+			for i := range bindings {
+				objects[i] = &bindings[i]
+			}
+			return objects, err
+		})
+}
+
+func (h *handler) createRoleBinding(request *restful.Request, response *restful.Response) {
+	binding := &rbac.RoleBinding{}
+	if err := request.ReadEntity(binding); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	binding.Location = location(request)
+	if err := binding.Validate(); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.CreateRoleBinding(request.Request.Context(), binding); err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(binding)
+}
+
+func (h *handler) updateRoleBinding(request *restful.Request, response *restful.Response) {
+	binding := &rbac.RoleBinding{}
+	if err := request.ReadEntity(binding); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := checkName(request, &binding.Name); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	binding.Location = location(request)
+	if err := binding.Validate(); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.UpdateRoleBinding(request.Request.Context(), binding); err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(binding)
+}
+
+func (h *handler) deleteRoleBinding(request *restful.Request, response *restful.Response) {
+	_, err := h.store.DeleteRoleBinding(request.Request.Context(), location(request), request.PathParameter("name"))
+	if err != nil {
+		handleStoreError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(map[string]string{"status": apis.StatusOK})
+}
+
+// checkName sets the empty name of the body to the name of the path, they must be equal otherwise.
+func checkName(request *restful.Request, name *string) error {
+	path := request.PathParameter("name")
+	if *name == "" {
+		*name = path
+	}
+	if *name != path {
+		return fmt.Errorf("name %q of the body does not match %q of the path", *name, path)
+	}
+	return nil
+}
+
+func handleStoreError(response *restful.Response, request *restful.Request, err error) {
+	switch {
+	case errors.Is(err, rbac.ErrNotFound):
+		apis.HandleNotFound(response, request, err)
+	case errors.Is(err, rbac.ErrAlreadyExists):
+		apis.HandleAlreadyExists(response, request, err)
+	default:
+		apis.HandleInternalError(response, request, err)
+	}
+}
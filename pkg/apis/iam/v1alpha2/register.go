@@ -18,9 +18,14 @@
 package v1alpha2
 
 import (
+	"net/http"
+
 	restfulspec "github.com/emicklei/go-restful-openapi/v2"
 	"github.com/emicklei/go-restful/v3"
 	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/server/authorization/rbac"
+	"peta.io/peta/pkg/watch"
 )
 
 const (
@@ -42,6 +47,106 @@ func (h *handler) AddToContainer(container *restful.Container) error {
 		Notes("list PETA users").
 		To(h.listUsers))
 
+	ws.Route(ws.GET("/can-i").
+		Doc("check an access").
+		Operation("can-i").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagAccessControl}).
+		Notes("Check whether the current user may perform the verb on the resource, or on the non-resource path").
+		Param(ws.QueryParameter("verb", "Verb like get, list or create, the lowercase http method for paths").Required(true)).
+		Param(ws.QueryParameter("group", "API group of the resource")).
+		Param(ws.QueryParameter("resource", "Resource like hosts")).
+		Param(ws.QueryParameter("subresource", "Subresource of the resource")).
+		Param(ws.QueryParameter("name", "Name of the object")).
+		Param(ws.QueryParameter("cluster", "Cluster of the resource")).
+		Param(ws.QueryParameter("workspace", "Workspace of the resource")).
+		Param(ws.QueryParameter("namespace", "Namespace of the resource")).
+		Param(ws.QueryParameter("path", "Non-resource path like /metrics")).
+		Returns(http.StatusOK, apis.StatusOK, AccessReview{}).
+		To(h.canI))
+
+	locations := []struct {
+		prefix    string
+		operation string
+		params    []*restful.Parameter
+	}{
+		{prefix: "", operation: "cluster"},
+		{prefix: "/workspaces/{workspace}", operation: "workspace",
+			params: []*restful.Parameter{ws.PathParameter("workspace", "Name of the workspace")}},
+		{prefix: "/namespaces/{namespace}", operation: "namespace",
+			params: []*restful.Parameter{ws.PathParameter("namespace", "Name of the namespace")}},
+	}
+	for _, l := range locations {
+		roles, bindings := l.prefix+"/roles", l.prefix+"/rolebindings"
+		if l.prefix == "" {
+			roles, bindings = "/clusterroles", "/clusterrolebindings"
+		}
+		addObjectRoutes(ws, roles, l.operation+"-roles", "role", l.params, rbac.Role{}, query.List[rbac.Role]{},
+			h.listRoles, h.watchRoles, h.getRole, h.createRole, h.updateRole, h.deleteRole)
+		addObjectRoutes(ws, bindings, l.operation+"-rolebindings", "role binding", l.params, rbac.RoleBinding{}, query.List[rbac.RoleBinding]{},
+			h.listRoleBindings, h.watchRoleBindings, h.getRoleBinding, h.createRoleBinding, h.updateRoleBinding, h.deleteRoleBinding)
+	}
+
 	container.Add(ws)
 	return nil
 }
+
+// addObjectRoutes adds the routes to list, watch, get, create, update and delete the objects of the path.
+func addObjectRoutes(ws *restful.WebService, path, operation, kind string, params []*restful.Parameter, sample, samples interface{},
+	list, watchList, get, create, update, del restful.RouteFunction) {
+	name := ws.PathParameter("name", "Name of the "+kind)
+	listRoute := ws.GET(path).
+		Doc("list "+kind+"s").
+		Operation(operation+"-list").
+		Returns(http.StatusOK, apis.StatusOK, samples).
+		To(list)
+	watchRoute := ws.GET("/watch"+path).
+		Doc("watch "+kind+"s").
+		Operation(operation+"-watch").
+		Notes("Stream the changes of the "+kind+"s as JSON events, one per line").
+		Returns(http.StatusOK, apis.StatusOK, watch.Event{}).
+		To(watchList)
+	for _, p := range query.Parameters(ws) {
+		listRoute.Param(p)
+	}
+	for i, p := range watch.Parameters(ws) {
+		listRoute.Param(p)
+		// the watch route does not need the watch parameter
+		if i > 0 {
+			watchRoute.Param(p)
+		}
+	}
+	routes := []*restful.RouteBuilder{
+		listRoute,
+		watchRoute,
+		ws.POST(path).
+			Doc("create a "+kind).
+			Operation(operation+"-create").
+			Reads(sample).
+			Returns(http.StatusOK, apis.StatusOK, sample).
+			To(create),
+		ws.GET(path+"/{name}").
+			Doc("get a "+kind).
+			Operation(operation+"-get").
+			Param(name).
+			Returns(http.StatusOK, apis.StatusOK, sample).
+			To(get),
+		ws.PUT(path+"/{name}").
+			Doc("update a "+kind).
+			Operation(operation+"-update").
+			Param(name).
+			Reads(sample).
+			Returns(http.StatusOK, apis.StatusOK, sample).
+			To(update),
+		ws.DELETE(path + "/{name}").
+			Doc("delete a " + kind).
+			Operation(operation + "-delete").
+			Param(name).
+			To(del),
+	}
+	for _, route := range routes {
+		for _, p := range params {
+			route.Param(p)
+		}
+		ws.Route(route.Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagAccessControl}))
+	}
+}
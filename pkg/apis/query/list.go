@@ -0,0 +1,251 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"peta.io/peta/pkg/types"
+)
+
+// ListMeta is the metadata of lists.
+type ListMeta struct {
+	// ResourceVersion is the version of the list, watches from it get the later changes.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Continue is the token of the next page, empty on the last page.
+	Continue string `json:"continue,omitempty"`
+	// RemainingItemCount is the number of objects after the page.
+	RemainingItemCount int `json:"remainingItemCount,omitempty"`
+	// TotalItems is the number of objects selected, of all the pages.
+	TotalItems int `json:"totalItems"`
+}
+
+// List is the envelope of the objects of list responses.
+type List[T any] struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []T      `json:"items"`
+}
+
+// Accessor tells how objects of a type are selected and sorted.
+type Accessor[T any] struct {
+	// Key identifies an object in the list, objects are sorted by key when the sort
+	// values are equal.
+	Key func(obj T) string
+	// Labels returns the labels of an object, nil if objects have no labels.
+	Labels func(obj T) map[string]string
+	// Fields are the indexed fields objects are selected and sorted by, like
+	// metadata.name, and their values.
+	Fields map[string]func(obj T) string
+}
+
+// ObjectAccessor returns the accessor of the objects with types.ObjectMeta, their
+// indexed fields are metadata.name, metadata.namespace, metadata.creationTimestamp
+// and the extra fields.
+func ObjectAccessor[T interface{ GetObjectMeta() *types.ObjectMeta }](extra map[string]func(obj T) string) Accessor[T] {
+	fields := map[string]func(obj T) string{
+		"metadata.name":      func(obj T) string { return obj.GetObjectMeta().Name },
+		"metadata.namespace": func(obj T) string { return obj.GetObjectMeta().Namespace },
+		"metadata.creationTimestamp": func(obj T) string {
+			if t := obj.GetObjectMeta().CreationTimestamp; t != nil {
+				return t.UTC().Format(time.RFC3339)
+			}
+			return ""
+		},
+	}
+	maps.Copy(fields, extra)
+	return Accessor[T]{
+		Key: func(obj T) string {
+			meta := obj.GetObjectMeta()
+			return meta.Namespace + "/" + meta.Name
+		},
+		Labels: func(obj T) map[string]string { return obj.GetObjectMeta().Labels },
+		Fields: fields,
+	}
+}
+
+// Apply returns the page of q of the objects of items selected by q, sorted the way
+// q asks. The errors are of invalid queries.
+func Apply[T any](items []T, q *Query, a Accessor[T]) (*List[T], error) {
+	for _, r := range q.FieldSelector {
+		if _, ok := a.Fields[r.Key]; !ok {
+			return nil, fmt.Errorf("field %q is not indexed, the fields are %s", r.Key, strings.Join(slices.Sorted(maps.Keys(a.Fields)), ", "))
+		}
+	}
+	sortValue := func(T) string { return "" }
+	if q.SortBy != "" {
+		var ok bool
+		if sortValue, ok = a.Fields[q.SortBy]; !ok {
+			return nil, fmt.Errorf("can't sort by %q, the fields are %s", q.SortBy, strings.Join(slices.Sorted(maps.Keys(a.Fields)), ", "))
+		}
+	}
+
+	type entry struct {
+		obj        T
+		value, key string
+	}
+	selected := make([]entry, 0, len(items))
+	for _, obj := range items {
+		if !q.LabelSelector.Empty() {
+			var l map[string]string
+			if a.Labels != nil {
+				l = a.Labels(obj)
+			}
+			if !q.LabelSelector.Matches(l) {
+				continue
+			}
+		}
+		if !matchFields(obj, q, a) {
+			continue
+		}
+		selected = append(selected, entry{obj: obj, value: sortValue(obj), key: a.Key(obj)})
+	}
+
+	compare := func(value, key string, e entry) int {
+		c := cmp.Or(compareValues(value, e.value), strings.Compare(key, e.key))
+		if !q.Ascending {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(selected, func(x, y entry) int { return compare(x.value, x.key, y) })
+
+	current := q.token()
+	start := 0
+	if q.Continue != "" {
+		t, err := q.continued()
+		if err != nil {
+			return nil, err
+		}
+		// the page starts after the last object of the previous one, even if it is gone
+		start, _ = slices.BinarySearchFunc(selected, t, func(e entry, t token) int {
+			if compare(t.Value, t.Key, e) < 0 {
+				return 1
+			}
+			return -1
+		})
+	}
+
+	end := len(selected)
+	if q.Limit > 0 {
+		end = min(start+q.Limit, end)
+	}
+	list := &List[T]{
+		Metadata: ListMeta{TotalItems: len(selected)},
+		Items:    make([]T, 0, end-start),
+	}
+	for _, e := range selected[start:end] {
+		list.Items = append(list.Items, e.obj)
+	}
+	if end < len(selected) {
+		current.Value, current.Key = selected[end-1].value, selected[end-1].key
+		list.Metadata.Continue = current.encode()
+		list.Metadata.RemainingItemCount = len(selected) - end
+	}
+	return list, nil
+}
+
+// SelectsBy returns true if q selects the objects by the fields only, not by labels
+// or other fields.
+func (q *Query) SelectsBy(fields ...string) bool {
+	if !q.LabelSelector.Empty() {
+		return false
+	}
+	for _, r := range q.FieldSelector {
+		if !slices.Contains(fields, r.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+// Seek returns the sort value and key of the last object of the page before the one
+// q asks for, empty for the first page. It is for the lists whose database selects,
+// sorts and pages the objects itself, see Page.
+func (q *Query) Seek() (value, key string, err error) {
+	if q.Continue == "" {
+		return "", "", nil
+	}
+	t, err := q.continued()
+	if err != nil {
+		return "", "", err
+	}
+	return t.Value, t.Key, nil
+}
+
+// Page returns the list of the page of objects items, selected, sorted and paged by
+// the database the way q asks, like Apply would: total is the number of objects
+// selected by q and remaining the number of them after the page.
+func Page[T any](items []T, q *Query, a Accessor[T], total, remaining int) *List[T] {
+	list := &List[T]{
+		Metadata: ListMeta{TotalItems: total},
+		Items:    items,
+	}
+	if remaining > 0 && len(items) > 0 {
+		last := items[len(items)-1]
+		current := q.token()
+		current.Key = a.Key(last)
+		if q.SortBy != "" {
+			current.Value = a.Fields[q.SortBy](last)
+		}
+		list.Metadata.Continue = current.encode()
+		list.Metadata.RemainingItemCount = remaining
+	}
+	return list
+}
+
+// token returns the continue token of q, without the position in the list.
+func (q *Query) token() token {
+	return token{SortBy: q.SortBy, Ascending: q.Ascending, Query: q.LabelSelector.String() + "|" + q.FieldSelector.String()}
+}
+
+// continued returns the continue token of q, it fails if the token is of another query.
+func (q *Query) continued() (token, error) {
+	t, err := decodeToken(q.Continue)
+	if err != nil {
+		return t, err
+	}
+	current := q.token()
+	if t.SortBy != current.SortBy || t.Ascending != current.Ascending || t.Query != current.Query {
+		return t, ErrInvalidContinue
+	}
+	return t, nil
+}
+
+func matchFields[T any](obj T, q *Query, a Accessor[T]) bool {
+	for _, r := range q.FieldSelector {
+		if !r.Matches(map[string]string{r.Key: a.Fields[r.Key](obj)}) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares the values as numbers if they both are.
+func compareValues(x, y string) int {
+	if i, err := strconv.ParseInt(x, 10, 64); err == nil {
+		if j, err := strconv.ParseInt(y, 10, 64); err == nil {
+			return cmp.Compare(i, j)
+		}
+	}
+	return strings.Compare(x, y)
+}
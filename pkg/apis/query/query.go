@@ -0,0 +1,143 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package query selects, sorts and pages the objects of list requests the same way
+// for all API groups.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/labels"
+)
+
+const (
+	ParameterLimit         = "limit"
+	ParameterContinue      = "continue"
+	ParameterLabelSelector = "labelSelector"
+	ParameterFieldSelector = "fieldSelector"
+	ParameterSortBy        = "sortBy"
+	ParameterAscending     = "ascending"
+)
+
+// MaxLimit is the maximum number of objects of a page.
+const MaxLimit = 1000
+
+// ErrInvalidContinue is returned for continue tokens not of the list they continue.
+var ErrInvalidContinue = errors.New("invalid continue token, list again without it")
+
+// Query is how the objects of a list request are selected, sorted and paged.
+type Query struct {
+	// Limit is the maximum number of objects returned, all of them if 0.
+	Limit int
+	// Continue is the token of the previous page, to get the next one.
+	Continue      string
+	LabelSelector labels.Selector
+	// FieldSelector is equality-based, like metadata.name=a,status!=ready.
+	FieldSelector labels.Selector
+	// SortBy is the field the objects are sorted by, their key if empty.
+	SortBy    string
+	Ascending bool
+}
+
+// New returns the query of everything in ascending order.
+func New() *Query {
+	return &Query{
+		LabelSelector: labels.Everything(),
+		FieldSelector: labels.Everything(),
+		Ascending:     true,
+	}
+}
+
+// ParseQueryParameter returns the query of the parameters of the request.
+func ParseQueryParameter(request *restful.Request) (*Query, error) {
+	q := New()
+	var err error
+
+	if limit := request.QueryParameter(ParameterLimit); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer", ParameterLimit)
+		}
+		q.Limit = min(q.Limit, MaxLimit)
+	}
+
+	if q.LabelSelector, err = labels.Parse(request.QueryParameter(ParameterLabelSelector)); err != nil {
+		return nil, err
+	}
+	if q.FieldSelector, err = labels.Parse(request.QueryParameter(ParameterFieldSelector)); err != nil {
+		return nil, err
+	}
+	for _, r := range q.FieldSelector {
+		switch r.Operator {
+		case labels.Equals, labels.DoubleEquals, labels.NotEquals:
+		default:
+			return nil, fmt.Errorf("field selector %q is not =, == or !=", r)
+		}
+	}
+
+	q.SortBy = request.QueryParameter(ParameterSortBy)
+	if ascending := request.QueryParameter(ParameterAscending); ascending != "" {
+		if q.Ascending, err = strconv.ParseBool(ascending); err != nil {
+			return nil, fmt.Errorf("%s must be true or false", ParameterAscending)
+		}
+	}
+	q.Continue = request.QueryParameter(ParameterContinue)
+	return q, nil
+}
+
+// Parameters returns the query parameters of the list routes.
+func Parameters(ws *restful.WebService) []*restful.Parameter {
+	return []*restful.Parameter{
+		ws.QueryParameter(ParameterLimit, "Maximum number of objects, the rest is got with the continue token of the list").DataType("integer"),
+		ws.QueryParameter(ParameterContinue, "Token of the previous page of the list, the other parameters must not change"),
+		ws.QueryParameter(ParameterLabelSelector, "Label selector, like env=prod,zone in (a,b),!arm"),
+		ws.QueryParameter(ParameterFieldSelector, "Field selector on the indexed fields, like metadata.name=a"),
+		ws.QueryParameter(ParameterSortBy, "Indexed field to sort by, like metadata.creationTimestamp"),
+		ws.QueryParameter(ParameterAscending, "Sort in ascending order, true by default").DataType("boolean"),
+	}
+}
+
+// token is the position of the continue token in the list.
+type token struct {
+	SortBy    string `json:"s,omitempty"`
+	Ascending bool   `json:"a"`
+	Query     string `json:"q"`
+	// Value and Key are the sort value and key of the last object of the page.
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+func (t token) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeToken(s string) (token, error) {
+	t := token{}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, ErrInvalidContinue
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, ErrInvalidContinue
+	}
+	return t, nil
+}
@@ -0,0 +1,187 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package query
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/types"
+)
+
+type host struct {
+	types.ObjectMeta
+	CPUs string
+}
+
+func hosts() []*host {
+	var hosts []*host
+	for _, h := range []struct{ name, env, cpus string }{
+		{"e", "prod", "16"}, {"a", "dev", "4"}, {"d", "prod", "8"}, {"b", "test", "8"}, {"c", "prod", "64"},
+	} {
+		hosts = append(hosts, &host{ObjectMeta: types.ObjectMeta{Name: h.name, Labels: map[string]string{"env": h.env}}, CPUs: h.cpus})
+	}
+	return hosts
+}
+
+var accessor = ObjectAccessor(map[string]func(h *host) string{"cpus": func(h *host) string { return h.CPUs }})
+
+func parse(t *testing.T, query string) *Query {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "/hosts?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseQueryParameter(restful.NewRequest(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func names(list *List[*host]) []string {
+	var names []string
+	for _, h := range list.Items {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"a", "b", "c", "d", "e"}},
+		{query: "ascending=false", want: []string{"e", "d", "c", "b", "a"}},
+		{query: "labelSelector=env%3Dprod", want: []string{"c", "d", "e"}},
+		{query: "labelSelector=env+in+(dev,test)", want: []string{"a", "b"}},
+		{query: "fieldSelector=cpus%3D8", want: []string{"b", "d"}},
+		{query: "fieldSelector=metadata.name!%3Da,cpus!%3D8", want: []string{"c", "e"}},
+		{query: "sortBy=cpus", want: []string{"a", "b", "d", "e", "c"}},
+		{query: "sortBy=cpus&ascending=false&labelSelector=env%3Dprod", want: []string{"c", "e", "d"}},
+	}
+	for _, tt := range tests {
+		list, err := Apply(hosts(), parse(t, tt.query), accessor)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := names(list); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+		if list.Metadata.TotalItems != len(tt.want) || list.Metadata.Continue != "" {
+			t.Errorf("%s: got metadata %+v", tt.query, list.Metadata)
+		}
+	}
+
+	for _, query := range []string{"fieldSelector=spec.os%3Dlinux", "sortBy=labels", "fieldSelector=cpus"} {
+		req, _ := http.NewRequest(http.MethodGet, "/hosts?"+query, nil)
+		q, err := ParseQueryParameter(restful.NewRequest(req))
+		if err == nil {
+			_, err = Apply(hosts(), q, accessor)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestApplyPages(t *testing.T) {
+	q := parse(t, "limit=2&sortBy=cpus&ascending=false")
+	all := hosts()
+	var got []string
+	for pages := 1; ; pages++ {
+		list, err := Apply(all, q, accessor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, names(list)...)
+		if pages == 1 && (list.Metadata.TotalItems != 5 || list.Metadata.RemainingItemCount != 3) {
+			t.Errorf("got metadata %+v", list.Metadata)
+		}
+		if list.Metadata.Continue == "" {
+			break
+		}
+		q.Continue = list.Metadata.Continue
+		if pages == 1 {
+			// the next page starts after the last object seen, even if it is gone
+			all = slices.DeleteFunc(all, func(h *host) bool { return h.Name == "e" })
+			all = append(all, &host{ObjectMeta: types.ObjectMeta{Name: "f"}, CPUs: "1"})
+		}
+	}
+	if want := []string{"c", "e", "d", "b", "a", "f"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	q.SortBy = ""
+	if _, err := Apply(all, q, accessor); !errors.Is(err, ErrInvalidContinue) {
+		t.Errorf("continue with another query: got %v", err)
+	}
+	q.Continue = "invalid"
+	if _, err := Apply(all, q, accessor); !errors.Is(err, ErrInvalidContinue) {
+		t.Errorf("invalid continue: got %v", err)
+	}
+}
+
+func TestPage(t *testing.T) {
+	q := parse(t, "limit=2&sortBy=cpus")
+	all := hosts()
+
+	list := Page(all[:2], q, accessor, 5, 3)
+	if list.Metadata.TotalItems != 5 || list.Metadata.RemainingItemCount != 3 || list.Metadata.Continue == "" {
+		t.Fatalf("got metadata %+v", list.Metadata)
+	}
+
+	q.Continue = list.Metadata.Continue
+	value, key, err := q.Seek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := all[1]; value != last.CPUs || key != "/"+last.Name {
+		t.Errorf("Seek() = %q, %q, want the sort value and key of %s", value, key, last.Name)
+	}
+
+	q.Ascending = false
+	if _, _, err := q.Seek(); !errors.Is(err, ErrInvalidContinue) {
+		t.Errorf("seek with another query: got %v", err)
+	}
+
+	if list := Page(all[2:], q, accessor, 5, 0); list.Metadata.Continue != "" {
+		t.Errorf("the last page has a continue token")
+	}
+}
+
+func TestSelectsBy(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "", want: true},
+		{query: "fieldSelector=metadata.name%3Da", want: true},
+		{query: "fieldSelector=metadata.name%3Da,cpus%3D8", want: false},
+		{query: "labelSelector=env%3Dprod", want: false},
+	}
+	for _, tt := range tests {
+		if got := parse(t, tt.query).SelectsBy("metadata.name", "metadata.namespace"); got != tt.want {
+			t.Errorf("%s: SelectsBy() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/watch"
+)
+
+// maxPatchSize is the maximum size of patches.
+const maxPatchSize = 1 << 20
+
+// Handler serves the objects of a store.
+type Handler[T any, PT interface {
+	*T
+	Object
+}] struct {
+	store    *Store[T, PT]
+	accessor query.Accessor[PT]
+	redact   func(obj PT)
+}
+
+// NewHandler returns a handler of the objects of store.
+func NewHandler[T any, PT interface {
+	*T
+	Object
+}](store *Store[T, PT]) *Handler[T, PT] {
+	return &Handler[T, PT]{store: store, accessor: query.ObjectAccessor[PT](nil)}
+}
+
+// WithFields adds the indexed fields lists of the objects can be selected and sorted
+// by, besides the ones of their metadata.
+func (h *Handler[T, PT]) WithFields(fields map[string]func(obj PT) string) *Handler[T, PT] {
+	h.accessor = query.ObjectAccessor(fields)
+	return h
+}
+
+// WithRedaction clears the write-only fields of the objects served, like secrets,
+// with redact. It is given copies of the stored objects, it must set their fields
+// rather than change what they share with them, like maps and slices.
+func (h *Handler[T, PT]) WithRedaction(redact func(obj PT)) *Handler[T, PT] {
+	h.redact = redact
+	return h
+}
+
+// AddToWebService adds the routes to list, watch, get, create, update, patch and
+// delete the objects to ws. Namespaced objects are under /namespaces/{namespace},
+// and are listed and watched across namespaces too.
+func (h *Handler[T, PT]) AddToWebService(ws *restful.WebService, kind string, tags ...string) {
+	resource := h.store.Resource()
+	path := "/" + resource
+	var params []*restful.Parameter
+	if h.store.Namespaced() {
+		path = "/namespaces/{namespace}/" + resource
+		params = append(params, ws.PathParameter("namespace", "Name of the namespace"))
+		h.addListRoutes(ws, "/"+resource, resource+"-all-namespaces", kind, tags, nil)
+	}
+	h.addListRoutes(ws, path, resource, kind, tags, params)
+
+	name := ws.PathParameter("name", "Name of the "+kind)
+	routes := []*restful.RouteBuilder{
+		ws.POST(path).
+			Doc("create a "+kind).
+			Operation(resource+"-create").
+			Reads(*new(T)).
+			Returns(http.StatusOK, apis.StatusOK, *new(T)).
+			To(h.Create),
+		ws.GET(path+"/{name}").
+			Doc("get a "+kind).
+			Operation(resource+"-get").
+			Param(name).
+			Returns(http.StatusOK, apis.StatusOK, *new(T)).
+			To(h.Get),
+		ws.PUT(path+"/{name}").
+			Doc("update a "+kind).
+			Operation(resource+"-update").
+			Notes("Replace the "+kind+", the update conflicts if metadata.resourceVersion is not the latest").
+			Param(name).
+			Reads(*new(T)).
+			Returns(http.StatusOK, apis.StatusOK, *new(T)).
+			Returns(http.StatusConflict, "the "+kind+" was changed since it was read", apis.Status{}).
+			To(h.Update),
+		ws.PATCH(path+"/{name}").
+			Doc("patch a "+kind).
+			Operation(resource+"-patch").
+			Notes("Patch the "+kind+" with a JSON merge patch or a JSON patch").
+			Consumes(apis.MimeMergePatchJson, apis.MimeJsonPatchJson).
+			Param(name).
+			Reads(map[string]interface{}{}).
+			Returns(http.StatusOK, apis.StatusOK, *new(T)).
+			Returns(http.StatusConflict, "the "+kind+" was changed since it was read", apis.Status{}).
+			To(h.Patch),
+		ws.DELETE(path+"/{name}").
+			Doc("delete a "+kind).
+			Operation(resource+"-delete").
+			Param(name).
+			Returns(http.StatusOK, apis.StatusOK, *new(T)).
+			To(h.Delete),
+	}
+	for _, route := range routes {
+		for _, p := range params {
+			route.Param(p)
+		}
+		ws.Route(route.Metadata(restfulspec.KeyOpenAPITags, tags))
+	}
+}
+
+func (h *Handler[T, PT]) addListRoutes(ws *restful.WebService, path, operation, kind string, tags []string, params []*restful.Parameter) {
+	list := ws.GET(path).
+		Doc("list "+kind+"s").
+		Operation(operation+"-list").
+		Returns(http.StatusOK, apis.StatusOK, query.List[T]{}).
+		To(h.List)
+	watchList := ws.GET("/watch"+path).
+		Doc("watch "+kind+"s").
+		Operation(operation+"-watch").
+		Notes("Stream the changes of the "+kind+"s as JSON events, one per line").
+		Returns(http.StatusOK, apis.StatusOK, watch.Event{}).
+		To(h.Watch)
+	for _, p := range query.Parameters(ws) {
+		list.Param(p)
+	}
+	for i, p := range append(watch.Parameters(ws), params...) {
+		list.Param(p)
+		// the watch route does not need the watch parameter
+		if i > 0 {
+			watchList.Param(p)
+		}
+	}
+	ws.Route(list.Metadata(restfulspec.KeyOpenAPITags, tags))
+	ws.Route(watchList.Metadata(restfulspec.KeyOpenAPITags, tags))
+}
+
+// List writes the objects of the namespace of the path selected, sorted and paged by
+// the query, or watches them with ?watch=true.
+func (h *Handler[T, PT]) List(request *restful.Request, response *restful.Response) {
+	if watch.IsWatch(request) {
+		h.Watch(request, response)
+		return
+	}
+	q, err := query.ParseQueryParameter(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	// the version is got first, watches from it may get changes of the list again but
+	// miss none.
+	b, err := h.store.Broadcaster(request.Request.Context())
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	version := b.ResourceVersion()
+
+	list, err := h.list(request.Request.Context(), request.PathParameter("namespace"), q)
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	list.Metadata.ResourceVersion = version
+	for i := range list.Items {
+		list.Items[i] = h.served(list.Items[i])
+	}
+	_ = response.WriteAsJson(list)
+}
+
+// list returns the objects of the namespace selected, sorted and paged by q. The
+// database does it for the queries of the order of the objects by namespace and name
+// selecting them by metadata.namespace and metadata.name, so large lists are paged
+// without loading every object. The objects of the other queries, selecting by labels
+// or sorting by other fields, are all loaded for the query to be applied.
+func (h *Handler[T, PT]) list(ctx context.Context, namespace string, q *query.Query) (*query.List[PT], error) {
+	if q.SortBy == "" && q.SelectsBy("metadata.namespace", "metadata.name") {
+		_, after, err := q.Seek()
+		if err != nil {
+			return nil, apis.NewBadRequest(err.Error())
+		}
+		objects, total, remaining, err := h.store.Select(ctx, Selection{
+			Namespace:  namespace,
+			Fields:     q.FieldSelector,
+			After:      after,
+			Descending: !q.Ascending,
+			Limit:      q.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return query.Page(objects, q, h.accessor, total, remaining), nil
+	}
+
+	objects, err := h.store.List(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	list, err := query.Apply(objects, q, h.accessor)
+	if err != nil {
+		return nil, apis.NewBadRequest(err.Error())
+	}
+	return list, nil
+}
+
+// Watch streams the changes of the objects of the namespace of the path.
+func (h *Handler[T, PT]) Watch(request *restful.Request, response *restful.Response) {
+	b, err := h.store.Broadcaster(request.Request.Context())
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	namespace := request.PathParameter("namespace")
+	var filter watch.FilterFunc
+	if namespace != "" {
+		filter = func(e watch.Event) bool { return e.Object.(PT).GetObjectMeta().Namespace == namespace }
+	}
+	var convert watch.ConvertFunc
+	if h.redact != nil {
+		convert = func(obj interface{}) interface{} { return h.served(obj.(PT)) }
+	}
+	watch.ServeConverted(request, response, b, filter, convert, func(ctx context.Context) ([]interface{}, error) {
+		objects, err := h.store.List(ctx, namespace)
+		items := make([]interface{}, len(objects))
+		for i := range objects {
+			items[i] = objects[i]
+		}
+		return items, err
+	})
+}
+
+// Get writes the object of the path.
+func (h *Handler[T, PT]) Get(request *restful.Request, response *restful.Response) {
+	obj, err := h.store.Get(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(h.served(obj))
+}
+
+// Create saves the object of the body.
+func (h *Handler[T, PT]) Create(request *restful.Request, response *restful.Response) {
+	obj := PT(new(T))
+	if err := request.ReadEntity(obj); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := checkNamespace(request, obj); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.Create(request.Request.Context(), obj); err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(h.served(obj))
+}
+
+// Update replaces the object of the path with the body.
+func (h *Handler[T, PT]) Update(request *restful.Request, response *restful.Response) {
+	obj := PT(new(T))
+	if err := request.ReadEntity(obj); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := checkNamespace(request, obj); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := checkName(request, obj); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if err := h.store.Update(request.Request.Context(), obj); err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(h.served(obj))
+}
+
+// Patch applies the patch of the body to the object of the path.
+func (h *Handler[T, PT]) Patch(request *restful.Request, response *restful.Response) {
+	patchType, _, err := mime.ParseMediaType(request.HeaderParameter("Content-Type"))
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	patch, err := io.ReadAll(io.LimitReader(request.Request.Body, maxPatchSize+1))
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	if len(patch) > maxPatchSize {
+		apis.HandleBadRequest(response, request, fmt.Errorf("the patch is larger than %d bytes", maxPatchSize))
+		return
+	}
+
+	obj, err := h.store.Patch(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"), patchType, patch)
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(h.served(obj))
+}
+
+// Delete deletes the object of the path and writes it.
+func (h *Handler[T, PT]) Delete(request *restful.Request, response *restful.Response) {
+	obj, err := h.store.Delete(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(h.served(obj))
+}
+
+// served returns obj as it is served, a redacted copy if the handler has write-only fields.
+func (h *Handler[T, PT]) served(obj PT) PT {
+	if h.redact == nil {
+		return obj
+	}
+	c := PT(new(T))
+	*c = *obj
+	h.redact(c)
+	return c
+}
+
+// checkNamespace sets the empty namespace of obj to the namespace of the path, they
+// must be equal otherwise.
+func checkNamespace(request *restful.Request, obj Object) error {
+	meta, path := obj.GetObjectMeta(), request.PathParameter("namespace")
+	if meta.Namespace == "" {
+		meta.Namespace = path
+	}
+	if meta.Namespace != path {
+		return fmt.Errorf("namespace %q of the body does not match %q of the path", meta.Namespace, path)
+	}
+	return nil
+}
+
+// checkName sets the empty name of obj to the name of the path, they must be equal otherwise.
+func checkName(request *restful.Request, obj Object) error {
+	meta, path := obj.GetObjectMeta(), request.PathParameter("name")
+	if meta.Name == "" {
+		meta.Name = path
+	}
+	if meta.Name != path {
+		return fmt.Errorf("name %q of the body does not match %q of the path", meta.Name, path)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"peta.io/peta/pkg/apis"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, want: `{"a":["c","d"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":"b"}`, patch: `{"a":{"b":"c"}}`, want: `{"a":{"b":"c"}}`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+	}
+	for _, tt := range tests {
+		got, err := ApplyPatch(apis.MimeMergePatchJson, []byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("patch %s: %v", tt.patch, err)
+			continue
+		}
+		assertJSON(t, tt.patch, got, tt.want)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+		wantErr          bool
+	}{
+		{doc: `{"a":1}`, patch: `[{"op":"add","path":"/b","value":2}]`, want: `{"a":1,"b":2}`},
+		{doc: `{"a":[1,3]}`, patch: `[{"op":"add","path":"/a/1","value":2}]`, want: `{"a":[1,2,3]}`},
+		{doc: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/-","value":2}]`, want: `{"a":[1,2]}`},
+		{doc: `{"a":{"b":1,"c":2}}`, patch: `[{"op":"remove","path":"/a/b"}]`, want: `{"a":{"c":2}}`},
+		{doc: `{"a":[1,2,3]}`, patch: `[{"op":"remove","path":"/a/0"}]`, want: `{"a":[2,3]}`},
+		{doc: `{"a":1}`, patch: `[{"op":"replace","path":"/a","value":null}]`, want: `{"a":null}`},
+		{doc: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a/b","path":"/c"}]`, want: `{"a":{},"c":1}`},
+		{doc: `{"a":{"b":1}}`, patch: `[{"op":"copy","from":"/a","path":"/c"}]`, want: `{"a":{"b":1},"c":{"b":1}}`},
+		{doc: `{"a/b":1,"m~n":2}`, patch: `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, want: `{}`},
+		{doc: `{"a":"1"}`, patch: `[{"op":"test","path":"/a","value":"1"},{"op":"add","path":"/b","value":2}]`, want: `{"a":"1","b":2}`},
+		{doc: `{"a":1}`, patch: `[{"op":"replace","path":"","value":[1]}]`, want: `[1]`},
+		{doc: `{"a":"1"}`, patch: `[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":"2"}]`, wantErr: true},
+		{doc: `{"a":1}`, patch: `[{"op":"replace","path":"/b","value":2}]`, wantErr: true},
+		{doc: `{"a":1}`, patch: `[{"op":"remove","path":"/b"}]`, wantErr: true},
+		{doc: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/2","value":2}]`, wantErr: true},
+		{doc: `{"a":[1,2]}`, patch: `[{"op":"remove","path":"/a/01"}]`, wantErr: true},
+		{doc: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a","path":"/a/c"}]`, wantErr: true},
+		{doc: `{"a":1}`, patch: `[{"op":"add","path":"/b"}]`, wantErr: true},
+		{doc: `{"a":1}`, patch: `[{"op":"merge","path":"/a"}]`, wantErr: true},
+		{doc: `{"a":1}`, patch: `{"op":"add"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ApplyPatch(apis.MimeJsonPatchJson, []byte(tt.doc), []byte(tt.patch))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("patch %s: got %s, want an error", tt.patch, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("patch %s: %v", tt.patch, err)
+			continue
+		}
+		assertJSON(t, tt.patch, got, tt.want)
+	}
+}
+
+func assertJSON(t *testing.T, patch string, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("patch %s: got %s, want %s", patch, got, want)
+	}
+}
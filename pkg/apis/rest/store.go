@@ -0,0 +1,572 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package rest keeps API objects in the database and serves them, so API groups
+// don't have to write their own database access.
+package rest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/watch"
+)
+
+// errConflict is the cause of the conflicts of writes of objects changed since they
+// were read.
+var errConflict = errors.New("the object has been modified, apply the changes to the latest version and try again")
+
+// Object is an API object, like the types embedding types.ObjectMeta.
+type Object interface {
+	GetObjectMeta() *types.ObjectMeta
+}
+
+// AdmitFunc checks an object against the objects it refers to before it is created
+// or updated, old is nil for creates. Its errors are returned as they are.
+type AdmitFunc func(ctx context.Context, obj, old Object) error
+
+// validator is implemented by the objects checking themselves before they are saved.
+type validator interface {
+	Validate() error
+}
+
+// record is the row of an object, the object is kept as JSON.
+type record struct {
+	ID              uuid.UUID `db:"id"`
+	Resource        string    `db:"resource"`
+	Namespace       string    `db:"namespace"`
+	Name            string    `db:"name"`
+	ResourceVersion int64     `db:"resource_version"`
+	Data            string    `db:"data"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
+}
+
+// TableName overrides the table name used by pop.
+func (r record) TableName() string {
+	return "objects"
+}
+
+// counter is the row of the resource version shared by all the objects.
+type counter struct {
+	ID      int   `db:"id"`
+	Version int64 `db:"version"`
+}
+
+// TableName overrides the table name used by pop.
+func (c counter) TableName() string {
+	return "resource_versions"
+}
+
+// Store keeps the objects of a resource in the database. Every write takes the
+// next resource version, and updates of an older version than the stored one fail
+// with a conflict. The errors of missing, taken, invalid and conflicting objects are
+// apis.StatusError.
+type Store[T any, PT interface {
+	*T
+	Object
+}] struct {
+	persister  persistence.Persister
+	resource   string
+	namespaced bool
+	admit      []AdmitFunc
+
+	// mu orders the writes, so their events are sent in the order of their versions.
+	mu          sync.Mutex
+	started     bool
+	broadcaster *watch.Broadcaster
+}
+
+// NewStore returns a store of the objects of the resource in the database of p,
+// the objects of namespaced resources belong to a namespace.
+func NewStore[T any, PT interface {
+	*T
+	Object
+}](p persistence.Persister, resource string, namespaced bool) *Store[T, PT] {
+	return &Store[T, PT]{
+		persister:   p,
+		resource:    resource,
+		namespaced:  namespaced,
+		broadcaster: watch.NewBroadcaster(watch.DefaultHistorySize),
+	}
+}
+
+// WithAdmission adds the checks of the objects written to the store.
+func (s *Store[T, PT]) WithAdmission(admit ...AdmitFunc) *Store[T, PT] {
+	s.admit = append(s.admit, admit...)
+	return s
+}
+
+// Resource returns the name of the resource of the objects.
+func (s *Store[T, PT]) Resource() string {
+	return s.resource
+}
+
+// Namespaced returns true if the objects belong to a namespace.
+func (s *Store[T, PT]) Namespaced() bool {
+	return s.namespaced
+}
+
+// Get returns the object of the namespace with the name.
+func (s *Store[T, PT]) Get(ctx context.Context, namespace, name string) (PT, error) {
+	r, err := s.get(s.conn(ctx), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(r)
+}
+
+// List returns the objects of the namespace ordered by name, all of them if the
+// namespace is empty.
+func (s *Store[T, PT]) List(ctx context.Context, namespace string) ([]PT, error) {
+	q := s.conn(ctx).Where("resource = ?", s.resource)
+	if namespace != "" {
+		q = q.Where("namespace = ?", namespace)
+	}
+	var records []record
+	if err := q.Order("namespace, name").All(&records); err != nil {
+		return nil, err
+	}
+	objects := make([]PT, 0, len(records))
+	for i := range records {
+		obj, err := s.decode(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// Selection is a page of the objects of a store selected by their namespace and name,
+// in the order of their namespace and name.
+type Selection struct {
+	// Namespace is the namespace of the objects, all of them if empty.
+	Namespace string
+	// Fields selects the objects by metadata.namespace and metadata.name.
+	Fields labels.Selector
+	// After is the namespace/name key of the last object of the previous page.
+	After      string
+	Descending bool
+	// Limit is the maximum number of objects, all of them if 0.
+	Limit int
+}
+
+// selectionColumns are the columns of the fields of selections.
+var selectionColumns = map[string]string{
+	"metadata.namespace": "namespace",
+	"metadata.name":      "name",
+}
+
+// Select returns the objects of the page of sel, the database selects and pages them
+// so only the objects of the page are loaded. total is the number of the objects
+// selected over all the pages and remaining the number after the page.
+func (s *Store[T, PT]) Select(ctx context.Context, sel Selection) (objects []PT, total, remaining int, err error) {
+	q := s.conn(ctx).Where("resource = ?", s.resource)
+	if sel.Namespace != "" {
+		q = q.Where("namespace = ?", sel.Namespace)
+	}
+	for _, r := range sel.Fields {
+		column, ok := selectionColumns[r.Key]
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("can't select %s by %q", s.resource, r.Key)
+		}
+		switch r.Operator {
+		case labels.Equals, labels.DoubleEquals:
+			q = q.Where(column+" = ?", r.Values[0])
+		case labels.NotEquals:
+			q = q.Where(column+" <> ?", r.Values[0])
+		default:
+			return nil, 0, 0, fmt.Errorf("can't select %s by %q", s.resource, r)
+		}
+	}
+	if total, err = q.Count(&record{}); err != nil {
+		return nil, 0, 0, err
+	}
+
+	order, next := "namespace, name", ">"
+	if sel.Descending {
+		order, next = "namespace DESC, name DESC", "<"
+	}
+	following := total
+	if sel.After != "" {
+		namespace, name, _ := strings.Cut(sel.After, "/")
+		q = q.Where("(namespace "+next+" ? OR (namespace = ? AND name "+next+" ?))", namespace, namespace, name)
+		if following, err = q.Count(&record{}); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	if sel.Limit > 0 {
+		q = q.Limit(sel.Limit)
+	}
+
+	var records []record
+	if err := q.Order(order).All(&records); err != nil {
+		return nil, 0, 0, err
+	}
+	objects = make([]PT, 0, len(records))
+	for i := range records {
+		obj, err := s.decode(&records[i])
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, total, following - len(objects), nil
+}
+
+// Create saves the new object obj and sets its uid, resource version and creation time.
+func (s *Store[T, PT]) Create(ctx context.Context, obj PT) error {
+	if err := s.validate(obj); err != nil {
+		return err
+	}
+	if err := s.admitted(ctx, obj, nil); err != nil {
+		return err
+	}
+	meta := obj.GetObjectMeta()
+	_, err := s.write(ctx, watch.Added, func(tx *pop.Connection, version int64) (PT, error) {
+		exists, err := s.where(tx, meta.Namespace, meta.Name).Exists(&record{})
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, apis.NewAlreadyExists(s.resource, meta.Name)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		meta.UID = uuid.Must(uuid.NewV4()).String()
+		meta.ResourceVersion = strconv.FormatInt(version, 10)
+		meta.CreationTimestamp = &now
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return obj, tx.Create(&record{
+			ID:              uuid.FromStringOrNil(meta.UID),
+			Resource:        s.resource,
+			Namespace:       meta.Namespace,
+			Name:            meta.Name,
+			ResourceVersion: version,
+			Data:            string(data),
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	})
+	return err
+}
+
+// Update replaces the object of the namespace and name of obj. The update fails with
+// a conflict if obj has a resource version other than the stored one, it is
+// unconditional without a resource version.
+func (s *Store[T, PT]) Update(ctx context.Context, obj PT) error {
+	if err := s.validate(obj); err != nil {
+		return err
+	}
+	meta := obj.GetObjectMeta()
+	_, err := s.write(ctx, watch.Modified, func(tx *pop.Connection, version int64) (PT, error) {
+		old, err := s.get(tx, meta.Namespace, meta.Name)
+		if err != nil {
+			return nil, err
+		}
+		if meta.ResourceVersion != "" && meta.ResourceVersion != strconv.FormatInt(old.ResourceVersion, 10) {
+			return nil, apis.NewConflict(s.resource, meta.Name, errConflict)
+		}
+		if err := s.admittedOver(ctx, obj, old); err != nil {
+			return nil, err
+		}
+		return obj, s.save(tx, old, obj, version)
+	})
+	return err
+}
+
+// Patch applies the patch of the patch type, like apis.MimeMergePatchJson, to the
+// object of the namespace with the name and returns the result. Patches setting a
+// resource version other than the stored one fail with a conflict.
+func (s *Store[T, PT]) Patch(ctx context.Context, namespace, name, patchType string, patch []byte) (PT, error) {
+	return s.write(ctx, watch.Modified, func(tx *pop.Connection, version int64) (PT, error) {
+		old, err := s.get(tx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		patched, err := ApplyPatch(patchType, []byte(old.Data), patch)
+		if err != nil {
+			return nil, apis.NewBadRequest(err.Error())
+		}
+
+		obj := PT(new(T))
+		if err := json.Unmarshal(patched, obj); err != nil {
+			return nil, apis.NewBadRequest(fmt.Sprintf("the patched %s %q can't be decoded: %v", s.resource, name, err))
+		}
+		meta := obj.GetObjectMeta()
+		var causes []apis.StatusCause
+		if meta.Namespace != namespace {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: "metadata.namespace", Message: "can't be changed"})
+		}
+		if meta.Name != name {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: "metadata.name", Message: "can't be changed"})
+		}
+		if len(causes) > 0 {
+			return nil, apis.NewInvalid(s.resource, name, causes...)
+		}
+		if meta.ResourceVersion != strconv.FormatInt(old.ResourceVersion, 10) {
+			return nil, apis.NewConflict(s.resource, name, errConflict)
+		}
+		if err := s.validate(obj); err != nil {
+			return nil, err
+		}
+		if err := s.admittedOver(ctx, obj, old); err != nil {
+			return nil, err
+		}
+		return obj, s.save(tx, old, obj, version)
+	})
+}
+
+// Delete deletes and returns the object of the namespace with the name.
+func (s *Store[T, PT]) Delete(ctx context.Context, namespace, name string) (PT, error) {
+	return s.write(ctx, watch.Deleted, func(tx *pop.Connection, version int64) (PT, error) {
+		old, err := s.get(tx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := s.decode(old)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectMeta().ResourceVersion = strconv.FormatInt(version, 10)
+		return obj, tx.Destroy(old)
+	})
+}
+
+// ListNamespaces returns the namespaces having objects, it is for namespaced resources.
+func (s *Store[T, PT]) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	err := s.conn(ctx).RawQuery("SELECT DISTINCT namespace FROM objects WHERE resource = ? ORDER BY namespace", s.resource).All(&namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// DeleteNamespace deletes the objects of the namespace one by one, so every deletion
+// has its event, and returns how many were deleted.
+func (s *Store[T, PT]) DeleteNamespace(ctx context.Context, namespace string) (int, error) {
+	var records []record
+	if err := s.conn(ctx).Where("resource = ?", s.resource).Where("namespace = ?", namespace).All(&records); err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, r := range records {
+		if _, err := s.Delete(ctx, namespace, r.Name); err != nil {
+			if apis.IsNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Broadcaster returns the broadcaster of the changes of the objects, the versions
+// of its events are the resource versions of the objects.
+func (s *Store[T, PT]) Broadcaster(ctx context.Context) (*watch.Broadcaster, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.startLocked(ctx); err != nil {
+		return nil, err
+	}
+	return s.broadcaster, nil
+}
+
+// startLocked starts the broadcaster at the current version, so the watches can't
+// resume from the versions of before the store was created.
+func (s *Store[T, PT]) startLocked(ctx context.Context) error {
+	if s.started {
+		return nil
+	}
+	c := &counter{}
+	if err := s.conn(ctx).Find(c, 1); err != nil {
+		return err
+	}
+	s.broadcaster.Advance(uint64(c.Version))
+	s.started = true
+	return nil
+}
+
+// write runs fn in a transaction with the next resource version, and sends the
+// event of type t of the object it returns.
+func (s *Store[T, PT]) write(ctx context.Context, t watch.EventType, fn func(tx *pop.Connection, version int64) (PT, error)) (PT, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.startLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	var obj PT
+	var version int64
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		tx = tx.WithContext(ctx)
+		// the update locks the counter, writes of all resources are serialized.
+		if err := tx.RawQuery("UPDATE resource_versions SET version = version + 1 WHERE id = 1").Exec(); err != nil {
+			return err
+		}
+		c := &counter{}
+		if err := tx.Find(c, 1); err != nil {
+			return err
+		}
+		version = c.Version
+
+		var err error
+		obj, err = fn(tx, version)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.broadcaster.ActionAt(t, obj, uint64(version))
+	return obj, nil
+}
+
+// save replaces the object of the record old with obj at version, unless the record
+// changed since it was read.
+func (s *Store[T, PT]) save(tx *pop.Connection, old *record, obj PT, version int64) error {
+	meta := obj.GetObjectMeta()
+	created := old.CreatedAt.UTC()
+	meta.UID = old.ID.String()
+	meta.ResourceVersion = strconv.FormatInt(version, 10)
+	meta.CreationTimestamp = &created
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	n, err := tx.RawQuery("UPDATE objects SET resource_version = ?, data = ?, updated_at = ? WHERE id = ? AND resource_version = ?",
+		version, string(data), time.Now().UTC(), old.ID, old.ResourceVersion).ExecWithCount()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apis.NewConflict(s.resource, meta.Name, errConflict)
+	}
+	return nil
+}
+
+func (s *Store[T, PT]) get(c *pop.Connection, namespace, name string) (*record, error) {
+	r := &record{}
+	err := s.where(c, namespace, name).First(r)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apis.NewNotFound(s.resource, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Store[T, PT]) where(c *pop.Connection, namespace, name string) *pop.Query {
+	return c.Where("resource = ?", s.resource).
+		Where("namespace = ?", namespace).
+		Where("name = ?", name)
+}
+
+// decode returns the object of r, the columns win over the metadata of the data.
+func (s *Store[T, PT]) decode(r *record) (PT, error) {
+	obj := PT(new(T))
+	if err := json.Unmarshal([]byte(r.Data), obj); err != nil {
+		return nil, fmt.Errorf("%s %q: %w", s.resource, r.Name, err)
+	}
+	meta := obj.GetObjectMeta()
+	created := r.CreatedAt.UTC()
+	meta.Namespace, meta.Name = r.Namespace, r.Name
+	meta.UID = r.ID.String()
+	meta.ResourceVersion = strconv.FormatInt(r.ResourceVersion, 10)
+	meta.CreationTimestamp = &created
+	return obj, nil
+}
+
+// validate returns an invalid error with the problems of obj, if any. The errors of
+// the objects checking themselves are returned as they are if they are of a status,
+// like the invalid errors of their fields.
+func (s *Store[T, PT]) validate(obj PT) error {
+	meta := obj.GetObjectMeta()
+	var causes []apis.StatusCause
+	if meta.Name == "" {
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueRequired, Field: "metadata.name", Message: "is required"})
+	}
+	switch {
+	case s.namespaced && meta.Namespace == "":
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueRequired, Field: "metadata.namespace", Message: "is required"})
+	case !s.namespaced && meta.Namespace != "":
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueNotSupported, Field: "metadata.namespace", Message: s.resource + " are not namespaced"})
+	}
+	if len(causes) > 0 {
+		return apis.NewInvalid(s.resource, meta.Name, causes...)
+	}
+
+	if v, ok := any(obj).(validator); ok {
+		if err := v.Validate(); err != nil {
+			var statusErr *apis.StatusError
+			if errors.As(err, &statusErr) {
+				return err
+			}
+			return apis.NewInvalid(s.resource, meta.Name, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Message: err.Error()})
+		}
+	}
+	return nil
+}
+
+// admitted runs the admission checks of obj replacing old, which is nil for creates.
+func (s *Store[T, PT]) admitted(ctx context.Context, obj, old PT) error {
+	var previous Object
+	if old != nil {
+		previous = old
+	}
+	for _, admit := range s.admit {
+		if err := admit(ctx, obj, previous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// admittedOver runs the admission checks of obj replacing the stored record old.
+func (s *Store[T, PT]) admittedOver(ctx context.Context, obj PT, old *record) error {
+	if len(s.admit) == 0 {
+		return nil
+	}
+	previous, err := s.decode(old)
+	if err != nil {
+		return err
+	}
+	return s.admitted(ctx, obj, previous)
+}
+
+func (s *Store[T, PT]) conn(ctx context.Context) *pop.Connection {
+	return s.persister.GetConnection().WithContext(ctx)
+}
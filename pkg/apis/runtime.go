@@ -30,9 +30,6 @@ import (
 	"peta.io/peta/pkg/log"
 )
 
-// Avoid emitting errors that look like valid HTML. Quotes are okay.
-var sanitizer = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;")
-
 const (
 	APIRootPath = "/apis"
 )
@@ -107,28 +104,46 @@ func HandleConflict(response *restful.Response, req *restful.Request, err error)
 	handle(http.StatusConflict, response, req, err)
 }
 
+// HandleAlreadyExists writes http.StatusConflict of the objects created with a name
+// that is taken.
+func HandleAlreadyExists(response *restful.Response, req *restful.Request, err error) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		status := StatusFor(http.StatusConflict, err)
+		status.Reason = StatusReasonAlreadyExists
+		err = &StatusError{ErrStatus: status}
+	}
+	handle(http.StatusConflict, response, req, err)
+}
+
+func HandleGone(response *restful.Response, req *restful.Request, err error) {
+	handle(http.StatusGone, response, req, err)
+}
+
+// HandleRestError writes the status of err, the status code is the one of the
+// StatusError or restful.ServiceError of err, http.StatusInternalServerError otherwise.
 func HandleRestError(response *restful.Response, req *restful.Request, err error) {
-	var statusCode int
-	var t restful.ServiceError
-	switch {
-	case errors.As(err, &t):
-		statusCode = t.Code
-	default:
-		statusCode = http.StatusInternalServerError
+	var serviceErr restful.ServiceError
+	if errors.As(err, &serviceErr) {
+		for k, v := range serviceErr.Header {
+			response.Header()[k] = v
+		}
+		err = &StatusError{ErrStatus: StatusFor(serviceErr.Code, errors.New(serviceErr.Message))}
 	}
-	handle(statusCode, response, req, err)
+	handle(http.StatusInternalServerError, response, req, err)
 }
 
+// handle writes the status of err, with the status code of the StatusError err
+// wraps if any.
 func handle(statusCode int, response *restful.Response, req *restful.Request, err error) {
 	_, fn, line, _ := runtime.Caller(2)
 	log.Errorf("%s:%d %v", fn, line, err)
-	http.Error(response, sanitizer.Replace(err.Error()), statusCode)
+	WriteStatus(response, StatusFor(statusCode, err))
 }
 
 // InternalError renders a simple internal error
 func InternalError(w http.ResponseWriter, req *http.Request, err error) {
-	http.Error(w, sanitizer.Replace(fmt.Sprintf("Internal Server Error: %q: %v", req.RequestURI, err)),
-		http.StatusInternalServerError)
+	WriteStatus(w, NewInternalError(fmt.Errorf("%q: %w", req.RequestURI, err)).Status())
 	HandleError(err)
 }
 
@@ -0,0 +1,291 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// StatusReason is why a request failed, clients branch on it rather than on messages.
+type StatusReason string
+
+const (
+	StatusReasonUnknown              StatusReason = ""
+	StatusReasonBadRequest           StatusReason = "BadRequest"
+	StatusReasonUnauthorized         StatusReason = "Unauthorized"
+	StatusReasonForbidden            StatusReason = "Forbidden"
+	StatusReasonNotFound             StatusReason = "NotFound"
+	StatusReasonMethodNotAllowed     StatusReason = "MethodNotAllowed"
+	StatusReasonNotAcceptable        StatusReason = "NotAcceptable"
+	StatusReasonAlreadyExists        StatusReason = "AlreadyExists"
+	StatusReasonConflict             StatusReason = "Conflict"
+	StatusReasonGone                 StatusReason = "Gone"
+	StatusReasonUnsupportedMediaType StatusReason = "UnsupportedMediaType"
+	StatusReasonInvalid              StatusReason = "Invalid"
+	StatusReasonTooManyRequests      StatusReason = "TooManyRequests"
+	StatusReasonInternalError        StatusReason = "InternalError"
+	StatusReasonServiceUnavailable   StatusReason = "ServiceUnavailable"
+)
+
+// CauseType is the kind of problem of a field of an invalid object.
+type CauseType string
+
+const (
+	CauseTypeFieldValueRequired     CauseType = "FieldValueRequired"
+	CauseTypeFieldValueInvalid      CauseType = "FieldValueInvalid"
+	CauseTypeFieldValueNotSupported CauseType = "FieldValueNotSupported"
+)
+
+const (
+	StatusKind    = "Status"
+	StatusFailure = "Failure"
+)
+
+// Status is the body of failed requests.
+type Status struct {
+	Kind string `json:"kind"`
+	// Status is Failure.
+	Status  string       `json:"status"`
+	Code    int          `json:"code"`
+	Reason  StatusReason `json:"reason,omitempty"`
+	Message string       `json:"message,omitempty"`
+	// Details tells more of the object of the failure, if any.
+	Details *StatusDetails `json:"details,omitempty"`
+}
+
+// StatusDetails is the object of a failure and the causes of the failure.
+type StatusDetails struct {
+	Name              string        `json:"name,omitempty"`
+	Group             string        `json:"group,omitempty"`
+	Kind              string        `json:"kind,omitempty"`
+	Causes            []StatusCause `json:"causes,omitempty"`
+	RetryAfterSeconds int           `json:"retryAfterSeconds,omitempty"`
+}
+
+// StatusCause is a problem of a field, like a missing name.
+type StatusCause struct {
+	Type    CauseType `json:"reason,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// Field is the path of the field, like metadata.name.
+	Field string `json:"field,omitempty"`
+}
+
+// StatusError is an error written as its status by the Handle functions.
+type StatusError struct {
+	ErrStatus Status
+}
+
+func (e *StatusError) Error() string {
+	return e.ErrStatus.Message
+}
+
+// Status returns the status of the error.
+func (e *StatusError) Status() Status {
+	return e.ErrStatus
+}
+
+func newStatusError(code int, reason StatusReason, message string, details *StatusDetails) *StatusError {
+	return &StatusError{ErrStatus: Status{
+		Kind:    StatusKind,
+		Status:  StatusFailure,
+		Code:    code,
+		Reason:  reason,
+		Message: message,
+		Details: details,
+	}}
+}
+
+// NewBadRequest returns an error of a request that is malformed.
+func NewBadRequest(message string) *StatusError {
+	return newStatusError(http.StatusBadRequest, StatusReasonBadRequest, message, nil)
+}
+
+// NewUnauthorized returns an error of a request without valid credentials.
+func NewUnauthorized(message string) *StatusError {
+	return newStatusError(http.StatusUnauthorized, StatusReasonUnauthorized, message, nil)
+}
+
+// NewForbidden returns an error of a request the user is not allowed to make.
+func NewForbidden(kind, name string, err error) *StatusError {
+	message := fmt.Sprintf("forbidden: %v", err)
+	switch {
+	case kind != "" && name != "":
+		message = fmt.Sprintf("%s %q is forbidden: %v", kind, name, err)
+	case kind != "":
+		message = fmt.Sprintf("%s is forbidden: %v", kind, err)
+	}
+	return newStatusError(http.StatusForbidden, StatusReasonForbidden, message, &StatusDetails{Kind: kind, Name: name})
+}
+
+// NewNotFound returns an error of an object that does not exist.
+func NewNotFound(kind, name string) *StatusError {
+	return newStatusError(http.StatusNotFound, StatusReasonNotFound, fmt.Sprintf("%s %q not found", kind, name),
+		&StatusDetails{Kind: kind, Name: name})
+}
+
+// NewAlreadyExists returns an error of an object created with a name that is taken.
+func NewAlreadyExists(kind, name string) *StatusError {
+	return newStatusError(http.StatusConflict, StatusReasonAlreadyExists, fmt.Sprintf("%s %q already exists", kind, name),
+		&StatusDetails{Kind: kind, Name: name})
+}
+
+// NewConflict returns an error of a write of an object that changed since it was read.
+func NewConflict(kind, name string, err error) *StatusError {
+	return newStatusError(http.StatusConflict, StatusReasonConflict, fmt.Sprintf("operation cannot be fulfilled on %s %q: %v", kind, name, err),
+		&StatusDetails{Kind: kind, Name: name})
+}
+
+// NewGone returns an error of a resource that is no longer available.
+func NewGone(message string) *StatusError {
+	return newStatusError(http.StatusGone, StatusReasonGone, message, nil)
+}
+
+// NewInvalid returns an error of an object that can't be saved, for the causes.
+func NewInvalid(kind, name string, causes ...StatusCause) *StatusError {
+	message := fmt.Sprintf("%s %q is invalid", kind, name)
+	for i, cause := range causes {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		if cause.Field != "" {
+			message += sep + cause.Field + ": " + cause.Message
+		} else {
+			message += sep + cause.Message
+		}
+	}
+	return newStatusError(http.StatusUnprocessableEntity, StatusReasonInvalid, message,
+		&StatusDetails{Kind: kind, Name: name, Causes: causes})
+}
+
+// NewTooManyRequests returns an error of a request to retry after the seconds.
+func NewTooManyRequests(message string, retryAfterSeconds int) *StatusError {
+	return newStatusError(http.StatusTooManyRequests, StatusReasonTooManyRequests, message,
+		&StatusDetails{RetryAfterSeconds: retryAfterSeconds})
+}
+
+// NewServiceUnavailable returns an error of a service that can't serve the request
+// for now.
+func NewServiceUnavailable(message string) *StatusError {
+	return newStatusError(http.StatusServiceUnavailable, StatusReasonServiceUnavailable, message, nil)
+}
+
+// NewInternalError returns an error of the server.
+func NewInternalError(err error) *StatusError {
+	return newStatusError(http.StatusInternalServerError, StatusReasonInternalError,
+		fmt.Sprintf("internal error occurred: %v", err), nil)
+}
+
+// reasonForCode returns the reason of the errors with the status code but no status.
+func reasonForCode(code int) StatusReason {
+	switch code {
+	case http.StatusBadRequest:
+		return StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		return StatusReasonUnauthorized
+	case http.StatusForbidden:
+		return StatusReasonForbidden
+	case http.StatusNotFound:
+		return StatusReasonNotFound
+	case http.StatusMethodNotAllowed:
+		return StatusReasonMethodNotAllowed
+	case http.StatusNotAcceptable:
+		return StatusReasonNotAcceptable
+	case http.StatusConflict:
+		return StatusReasonConflict
+	case http.StatusGone:
+		return StatusReasonGone
+	case http.StatusUnsupportedMediaType:
+		return StatusReasonUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return StatusReasonInvalid
+	case http.StatusTooManyRequests:
+		return StatusReasonTooManyRequests
+	case http.StatusServiceUnavailable:
+		return StatusReasonServiceUnavailable
+	}
+	if code >= http.StatusInternalServerError {
+		return StatusReasonInternalError
+	}
+	return StatusReasonUnknown
+}
+
+// StatusFor returns the status of err, the status code is the one of the StatusError
+// err wraps if any.
+func StatusFor(code int, err error) Status {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status()
+	}
+	return Status{
+		Kind:    StatusKind,
+		Status:  StatusFailure,
+		Code:    code,
+		Reason:  reasonForCode(code),
+		Message: err.Error(),
+	}
+}
+
+// ReasonForError returns the reason of err, unknown if it is not a StatusError.
+func ReasonForError(err error) StatusReason {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.ErrStatus.Reason
+	}
+	return StatusReasonUnknown
+}
+
+// IsNotFound returns true if err is of an object that does not exist.
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == StatusReasonNotFound
+}
+
+// IsAlreadyExists returns true if err is of an object whose name is taken.
+func IsAlreadyExists(err error) bool {
+	return ReasonForError(err) == StatusReasonAlreadyExists
+}
+
+// IsConflict returns true if err is of a write of an object changed since it was read.
+func IsConflict(err error) bool {
+	return ReasonForError(err) == StatusReasonConflict
+}
+
+// IsInvalid returns true if err is of an object that can't be saved.
+func IsInvalid(err error) bool {
+	return ReasonForError(err) == StatusReasonInvalid
+}
+
+// WriteStatus writes the status as the JSON body of the response, and its retry
+// delay if any.
+func WriteStatus(w http.ResponseWriter, status Status) {
+	if status.Details != nil && status.Details.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(status.Details.RetryAfterSeconds))
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, status.Message, status.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status.Code)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,81 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+func TestHandleStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(*restful.Response, *restful.Request, error)
+		err    error
+		code   int
+		reason StatusReason
+	}{
+		{name: "plain", handle: HandleNotFound, err: errors.New("gone fishing"), code: http.StatusNotFound, reason: StatusReasonNotFound},
+		{name: "typed", handle: HandleBadRequest, err: NewConflict("hosts", "a", errors.New("changed")), code: http.StatusConflict, reason: StatusReasonConflict},
+		{name: "wrapped", handle: HandleInternalError, err: fmt.Errorf("saving: %w", NewAlreadyExists("hosts", "a")), code: http.StatusConflict, reason: StatusReasonAlreadyExists},
+		{name: "already exists", handle: HandleAlreadyExists, err: errors.New("taken"), code: http.StatusConflict, reason: StatusReasonAlreadyExists},
+		{name: "service error", handle: HandleRestError, err: restful.NewError(http.StatusUnsupportedMediaType, "415: Unsupported Media Type"), code: http.StatusUnsupportedMediaType, reason: StatusReasonUnsupportedMediaType},
+		{name: "unknown error", handle: HandleRestError, err: errors.New("boom"), code: http.StatusInternalServerError, reason: StatusReasonInternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+			tt.handle(restful.NewResponse(rec), req, tt.err)
+
+			status := Status{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatalf("%v: %s", err, rec.Body)
+			}
+			if rec.Code != tt.code || status.Code != tt.code || status.Reason != tt.reason || status.Kind != StatusKind || status.Status != StatusFailure {
+				t.Errorf("got %d %+v", rec.Code, status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("got content type %q", ct)
+			}
+		})
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	err := NewInvalid("hosts", "a",
+		StatusCause{Type: CauseTypeFieldValueRequired, Field: "spec.address", Message: "is required"},
+		StatusCause{Type: CauseTypeFieldValueInvalid, Message: "the port must be positive"})
+	if want := `hosts "a" is invalid: spec.address: is required, the port must be positive`; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+	if !IsInvalid(fmt.Errorf("creating: %w", err)) || IsNotFound(err) || len(err.Status().Details.Causes) != 2 {
+		t.Errorf("got %+v", err.Status())
+	}
+
+	rec := httptest.NewRecorder()
+	WriteStatus(rec, NewTooManyRequests("slow down", 3).Status())
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3" {
+		t.Errorf("got %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"fmt"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/persistence"
+)
+
+type handler struct {
+	workspaces *rest.Store[Workspace, *Workspace]
+	namespaces *rest.Store[Namespace, *Namespace]
+	accessor   query.Accessor[*Namespace]
+}
+
+// namespaceFields are the fields namespaces are selected and sorted by.
+var namespaceFields = map[string]func(n *Namespace) string{
+	"spec.workspace": func(n *Namespace) string { return n.Spec.Workspace },
+}
+
+// NewWorkspaceStore returns the store of the workspaces in the database of p.
+func NewWorkspaceStore(p persistence.Persister) *rest.Store[Workspace, *Workspace] {
+	return rest.NewStore[Workspace](p, "workspaces", false)
+}
+
+// NewNamespaceStore returns the store of the namespaces in the database of p.
+func NewNamespaceStore(p persistence.Persister) *rest.Store[Namespace, *Namespace] {
+	return rest.NewStore[Namespace](p, "namespaces", false)
+}
+
+func NewHandler(workspaces *rest.Store[Workspace, *Workspace], namespaces *rest.Store[Namespace, *Namespace]) apis.Handler {
+	return &handler{
+		workspaces: workspaces,
+		namespaces: namespaces,
+		accessor:   query.ObjectAccessor(namespaceFields),
+	}
+}
+
+func NewFakeHandler() apis.Handler {
+	return NewHandler(NewWorkspaceStore(nil), NewNamespaceStore(nil))
+}
+
+// listWorkspaceNamespaces writes the namespaces of the workspace of the path.
+func (h *handler) listWorkspaceNamespaces(request *restful.Request, response *restful.Response) {
+	q, err := query.ParseQueryParameter(request)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	ctx, workspace := request.Request.Context(), request.PathParameter("workspace")
+	if _, err := h.workspaces.Get(ctx, "", workspace); err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	b, err := h.namespaces.Broadcaster(ctx)
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	version := b.ResourceVersion()
+
+	namespaces, err := h.namespaces.List(ctx, "")
+	if err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	items := make([]*Namespace, 0, len(namespaces))
+	for _, n := range namespaces {
+		if n.Spec.Workspace == workspace {
+			items = append(items, n)
+		}
+	}
+	list, err := query.Apply(items, q, h.accessor)
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	list.Metadata.ResourceVersion = version
+	_ = response.WriteAsJson(list)
+}
+
+// createWorkspaceNamespace creates the namespace of the body in the workspace of the path.
+func (h *handler) createWorkspaceNamespace(request *restful.Request, response *restful.Response) {
+	n := &Namespace{}
+	if err := request.ReadEntity(n); err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	workspace := request.PathParameter("workspace")
+	if n.Spec.Workspace == "" {
+		n.Spec.Workspace = workspace
+	}
+	if n.Spec.Workspace != workspace {
+		apis.HandleBadRequest(response, request, fmt.Errorf("workspace %q of the body does not match %q of the path", n.Spec.Workspace, workspace))
+		return
+	}
+	if err := h.namespaces.Create(request.Request.Context(), n); err != nil {
+		apis.HandleRestError(response, request, err)
+		return
+	}
+	_ = response.WriteAsJson(n)
+}
@@ -0,0 +1,68 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"net/http"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/query"
+	"peta.io/peta/pkg/apis/rest"
+)
+
+const (
+	GroupName = "tenant.peta.io"
+)
+
+var GroupVersion = apis.GroupVersion{
+	Group:   GroupName,
+	Version: "v1alpha2",
+}
+
+func (h *handler) AddToContainer(container *restful.Container) error {
+	ws := apis.NewWebService(GroupVersion)
+
+	rest.NewHandler(h.workspaces).AddToWebService(ws, "workspace", apis.TagTenancy)
+	rest.NewHandler(h.namespaces).WithFields(namespaceFields).AddToWebService(ws, "namespace", apis.TagTenancy)
+
+	list := ws.GET("/workspaces/{workspace}/namespaces").
+		Doc("list the namespaces of a workspace").
+		Operation("workspace-namespaces-list").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagTenancy}).
+		Param(ws.PathParameter("workspace", "Name of the workspace")).
+		Returns(http.StatusOK, apis.StatusOK, query.List[Namespace]{}).
+		To(h.listWorkspaceNamespaces)
+	for _, p := range query.Parameters(ws) {
+		list.Param(p)
+	}
+	ws.Route(list)
+
+	ws.Route(ws.POST("/workspaces/{workspace}/namespaces").
+		Doc("create a namespace in a workspace").
+		Operation("workspace-namespaces-create").
+		Notes("Create a namespace owned by the workspace, spec.workspace defaults to the one of the path").
+		Metadata(restfulspec.KeyOpenAPITags, []string{apis.TagTenancy}).
+		Param(ws.PathParameter("workspace", "Name of the workspace")).
+		Reads(Namespace{}).
+		Returns(http.StatusOK, apis.StatusOK, Namespace{}).
+		To(h.createWorkspaceNamespace))
+
+	container.Add(ws)
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"fmt"
+	"regexp"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/authorization/rbac"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/types"
+)
+
+// WorkspaceRole is what the members of a workspace may do in it.
+type WorkspaceRole string
+
+const (
+	// WorkspaceAdmin members manage the workspace, its members and its namespaces.
+	WorkspaceAdmin WorkspaceRole = "admin"
+	// WorkspaceMember members manage the objects of the namespaces of the workspace.
+	WorkspaceMember WorkspaceRole = "member"
+	// WorkspaceViewer members read the workspace, its namespaces and their objects.
+	WorkspaceViewer WorkspaceRole = "viewer"
+)
+
+// dnsLabel is the form of the names of workspaces and namespaces, they are parts of paths.
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// Workspace is the tenant of a team, it owns namespaces and the team members are
+// its members. Deleting a workspace deletes its namespaces.
+type Workspace struct {
+	types.TypeMeta   `json:",inline"`
+	types.ObjectMeta `json:"metadata"`
+
+	Spec WorkspaceSpec `json:"spec"`
+}
+
+type WorkspaceSpec struct {
+	Description string   `json:"description,omitempty"`
+	Members     []Member `json:"members,omitempty"`
+}
+
+// Member is a user or group with a role in a workspace.
+type Member struct {
+	// Kind is User or Group.
+	Kind string        `json:"kind"`
+	Name string        `json:"name"`
+	Role WorkspaceRole `json:"role"`
+}
+
+// Namespace holds the namespaced objects of a workspace, like blueprints. Deleting
+// a namespace deletes its objects and the roles and role bindings in it.
+type Namespace struct {
+	types.TypeMeta   `json:",inline"`
+	types.ObjectMeta `json:"metadata"`
+
+	Spec NamespaceSpec `json:"spec"`
+}
+
+type NamespaceSpec struct {
+	// Workspace owns the namespace, it can't be changed.
+	Workspace string `json:"workspace"`
+}
+
+// Validate checks the name and the members of the workspace.
+func (w *Workspace) Validate() error {
+	causes := nameCauses(w.Name)
+	seen := map[Member]bool{}
+	for i, m := range w.Spec.Members {
+		field := fmt.Sprintf("spec.members[%d]", i)
+		if m.Kind != rbac.KindUser && m.Kind != rbac.KindGroup {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueNotSupported, Field: field + ".kind",
+				Message: fmt.Sprintf("must be %s or %s", rbac.KindUser, rbac.KindGroup)})
+		}
+		if m.Name == "" {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueRequired, Field: field + ".name", Message: "is required"})
+		}
+		switch m.Role {
+		case WorkspaceAdmin, WorkspaceMember, WorkspaceViewer:
+		default:
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueNotSupported, Field: field + ".role",
+				Message: fmt.Sprintf("must be %s, %s or %s", WorkspaceAdmin, WorkspaceMember, WorkspaceViewer)})
+		}
+		key := Member{Kind: m.Kind, Name: m.Name}
+		if seen[key] {
+			causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid, Field: field,
+				Message: fmt.Sprintf("%s %q is a member twice", m.Kind, m.Name)})
+		}
+		seen[key] = true
+	}
+	if len(causes) > 0 {
+		return apis.NewInvalid("workspaces", w.Name, causes...)
+	}
+	return nil
+}
+
+// RoleOf returns the role of user in the workspace, the highest one of the user and
+// its groups, and false if it isn't a member.
+func (w *Workspace) RoleOf(user *request.User) (WorkspaceRole, bool) {
+	var role WorkspaceRole
+	found := false
+	for _, m := range w.Spec.Members {
+		if !m.matches(user) {
+			continue
+		}
+		if !found || m.Role.rank() > role.rank() {
+			role, found = m.Role, true
+		}
+	}
+	return role, found
+}
+
+func (m Member) matches(user *request.User) bool {
+	switch m.Kind {
+	case rbac.KindUser:
+		return m.Name == user.Name
+	case rbac.KindGroup:
+		for _, group := range user.Groups {
+			if m.Name == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r WorkspaceRole) rank() int {
+	switch r {
+	case WorkspaceAdmin:
+		return 3
+	case WorkspaceMember:
+		return 2
+	case WorkspaceViewer:
+		return 1
+	}
+	return 0
+}
+
+// Validate checks the name and the workspace of the namespace.
+func (n *Namespace) Validate() error {
+	causes := nameCauses(n.Name)
+	if n.Spec.Workspace == "" {
+		causes = append(causes, apis.StatusCause{Type: apis.CauseTypeFieldValueRequired, Field: "spec.workspace", Message: "is required"})
+	}
+	if len(causes) > 0 {
+		return apis.NewInvalid("namespaces", n.Name, causes...)
+	}
+	return nil
+}
+
+func nameCauses(name string) []apis.StatusCause {
+	if name == "" || dnsLabel.MatchString(name) {
+		return nil
+	}
+	return []apis.StatusCause{{Type: apis.CauseTypeFieldValueInvalid, Field: "metadata.name", Message: "must be a lowercase DNS label"}}
+}
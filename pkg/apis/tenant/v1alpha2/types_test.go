@@ -0,0 +1,82 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package v1alpha2
+
+import (
+	"testing"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/types"
+)
+
+func TestWorkspaceRoleOf(t *testing.T) {
+	w := &Workspace{Spec: WorkspaceSpec{Members: []Member{
+		{Kind: "User", Name: "alice", Role: WorkspaceViewer},
+		{Kind: "Group", Name: "ops", Role: WorkspaceAdmin},
+		{Kind: "Group", Name: "dev", Role: WorkspaceMember},
+	}}}
+	tests := []struct {
+		user *request.User
+		role WorkspaceRole
+		ok   bool
+	}{
+		{user: &request.User{Name: "alice"}, role: WorkspaceViewer, ok: true},
+		{user: &request.User{Name: "alice", Groups: []string{"dev"}}, role: WorkspaceMember, ok: true},
+		{user: &request.User{Name: "bob", Groups: []string{"dev", "ops"}}, role: WorkspaceAdmin, ok: true},
+		{user: &request.User{Name: "ops"}},
+	}
+	for _, tt := range tests {
+		role, ok := w.RoleOf(tt.user)
+		if role != tt.role || ok != tt.ok {
+			t.Errorf("RoleOf(%+v) = %q, %v, want %q, %v", tt.user, role, ok, tt.role, tt.ok)
+		}
+	}
+}
+
+func TestWorkspaceValidate(t *testing.T) {
+	valid := &Workspace{
+		ObjectMeta: types.ObjectMeta{Name: "team-a"},
+		Spec:       WorkspaceSpec{Members: []Member{{Kind: "User", Name: "alice", Role: WorkspaceAdmin}}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	invalid := &Workspace{
+		ObjectMeta: types.ObjectMeta{Name: "Team_A"},
+		Spec: WorkspaceSpec{Members: []Member{
+			{Kind: "User", Name: "alice", Role: WorkspaceAdmin},
+			{Kind: "User", Name: "alice", Role: WorkspaceViewer},
+			{Kind: "Robot", Name: "", Role: "owner"},
+		}},
+	}
+	err := invalid.Validate()
+	if !apis.IsInvalid(err) {
+		t.Fatalf("Validate() = %v, want an invalid error", err)
+	}
+	want := []string{"metadata.name", "spec.members[1]", "spec.members[2].kind", "spec.members[2].name", "spec.members[2].role"}
+	causes := err.(*apis.StatusError).Status().Details.Causes
+	if len(causes) != len(want) {
+		t.Fatalf("Validate() causes = %v, want the fields %v", causes, want)
+	}
+	for i, cause := range causes {
+		if cause.Field != want[i] {
+			t.Errorf("cause %d is of %q, want %q", i, cause.Field, want[i])
+		}
+	}
+}
@@ -30,4 +30,12 @@ const (
 	TagNonResourceAPI = "NonResource APIs"
 
 	TagConfigurations = "Configurations"
+
+	TagHostOperations = "Host Operations"
+
+	TagAccessControl = "Access Control"
+
+	TagMultiCluster = "Multi-Cluster"
+
+	TagTenancy = "Tenancy"
 )
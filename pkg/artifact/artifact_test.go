@@ -0,0 +1,143 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeArch(t *testing.T) {
+	for arch, want := range map[string]string{
+		"x86_64":  "amd64",
+		"aarch64": "arm64",
+		"arm64":   "arm64",
+		"ppc64le": "ppc64le",
+	} {
+		if got := NormalizeArch(arch); got != want {
+			t.Errorf("NormalizeArch(%q) = %q, want %q", arch, got, want)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	for p, content := range map[string]string{
+		"amd64/packages/curl_8.5.0_amd64.deb": "curl amd64",
+		"amd64/packages/Packages.gz":          "index",
+		"arm64/bin/etcd/etcd":                 "etcd arm64",
+	} {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := scanFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{Blueprint: "test", CreatedAt: time.Now().UTC(), Managers: map[string]string{"amd64": "apt", "arm64": "apt"}, Files: files}
+
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, dir, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bundle, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	read, err := ReadBundle(bundle, func(f *File, content io.Reader) error {
+		b, err := io.ReadAll(content)
+		got[f.Path] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["arm64/bin/etcd/etcd"] != "etcd arm64" {
+		t.Errorf("ReadBundle() files = %v", got)
+	}
+	if archs := read.Archs(); len(archs) != 2 || archs[0] != "amd64" {
+		t.Errorf("Archs() = %v", archs)
+	}
+	if sums := string(read.Checksums("arm64")); !strings.HasSuffix(sums, "  bin/etcd/etcd\n") {
+		t.Errorf("Checksums(arm64) = %q", sums)
+	}
+
+	// the per architecture tarballs have their files relative to the architecture.
+	m, archives, err := splitBundle(bundle, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := tarNames(t, archives["amd64"])
+	if strings.Join(names, ",") != "packages/Packages.gz,packages/curl_8.5.0_amd64.deb,SHA256SUMS" {
+		t.Errorf("amd64 archive = %v", names)
+	}
+
+	// a file changed after the manifest was written is rejected.
+	if err := os.WriteFile(filepath.Join(dir, "amd64/packages/Packages.gz"), []byte("INDEX"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := WriteBundle(&buf, dir, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bundle, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(bundle, nil); err == nil {
+		t.Error("ReadBundle() of a tampered bundle succeeded")
+	}
+}
+
+func tarNames(t *testing.T, p string) []string {
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"strings"
+
+	"peta.io/peta/pkg/pkgmgr"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+// DefaultPostgresVersion is the major version of postgres components without one.
+const DefaultPostgresVersion = "16"
+
+// basePackages are needed on every host, like by peta init os.
+var basePackages = []string{"chrony", "tar", "curl"}
+
+// Requirements returns the packages and repositories the enabled components of the
+// blueprint need with the package manager.
+func Requirements(b *types.Blueprint, manager string) ([]string, []*pkgmgr.Repository) {
+	packages := append([]string(nil), basePackages...)
+	var repos []*pkgmgr.Repository
+	seen := map[string]bool{}
+
+	for _, c := range b.Spec.Components {
+		if !c.Enabled {
+			continue
+		}
+		switch c.Type {
+		case "postgres":
+			version := DefaultPostgresVersion
+			if cfg, ok := c.Config.(*component.PostgresConfig); ok && cfg.Version != "" {
+				version = cfg.Version
+			}
+			major, _, _ := strings.Cut(version, ".")
+			packages = append(packages, postgresPackages(manager, major)...)
+			if r := pkgmgr.PGDG(manager, major); r != nil && !seen[r.Name] {
+				seen[r.Name] = true
+				repos = append(repos, r)
+			}
+		}
+	}
+
+	if b.Spec.Artifacts != nil {
+		packages = append(packages, b.Spec.Artifacts.Packages...)
+	}
+
+	return unique(packages), repos
+}
+
+func postgresPackages(manager, major string) []string {
+	switch manager {
+	case pkgmgr.Apt:
+		// contrib modules are part of the server package since 10.
+		return []string{"postgresql-" + major, "postgresql-client-" + major}
+	case pkgmgr.Apk:
+		return []string{"postgresql" + major, "postgresql" + major + "-contrib"}
+	default:
+		return []string{"postgresql" + major + "-server", "postgresql" + major, "postgresql" + major + "-contrib"}
+	}
+}
+
+func unique(s []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
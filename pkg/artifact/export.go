@@ -0,0 +1,291 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/pkgmgr"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// ExportOptions of building a bundle.
+type ExportOptions struct {
+	// Archs are the architectures of the bundle, default is those of the blueprint hosts.
+	Archs []string
+	// Output receives the progress, nil discards it.
+	Output io.Writer
+}
+
+// Export builds the bundle of the blueprint and writes it to w.
+func Export(ctx context.Context, b *types.Blueprint, w io.Writer, o ExportOptions) (*Manifest, error) {
+	archs := o.Archs
+	if len(archs) == 0 {
+		archs = blueprintArchs(b)
+	}
+	for i := range archs {
+		archs[i] = NormalizeArch(archs[i])
+	}
+	archs = unique(archs)
+
+	output := o.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	staging, err := os.MkdirTemp("", "peta-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	m := &Manifest{Blueprint: b.Name, CreatedAt: time.Now().UTC(), Managers: map[string]string{}}
+	for _, arch := range archs {
+		dir := filepath.Join(staging, arch)
+		_, _ = fmt.Fprintf(output, "[%s] downloading packages\n", arch)
+		manager, err := exportPackages(ctx, b, arch, filepath.Join(dir, KindPackage))
+		if err != nil {
+			return nil, fmt.Errorf("failed to export the packages of %s: %w", arch, err)
+		}
+		m.Managers[arch] = manager
+
+		if b.Spec.Artifacts != nil {
+			for _, bin := range b.Spec.Artifacts.Binaries {
+				_, _ = fmt.Fprintf(output, "[%s] downloading %s\n", arch, bin.Name)
+				if err := downloadBinary(ctx, bin, arch, filepath.Join(dir, KindBinary, bin.Name)); err != nil {
+					return nil, fmt.Errorf("failed to download %s for %s: %w", bin.Name, arch, err)
+				}
+			}
+		}
+	}
+
+	if m.Files, err = scanFiles(staging); err != nil {
+		return nil, err
+	}
+	if err := WriteBundle(w, staging, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// blueprintArchs returns the architectures of the blueprint hosts, the one of the
+// current machine if none is given.
+func blueprintArchs(b *types.Blueprint) []string {
+	var archs []string
+	for _, h := range b.Hosts() {
+		if h.Arch != "" {
+			archs = append(archs, h.Arch)
+		}
+	}
+	if len(archs) == 0 {
+		archs = append(archs, runtime.GOARCH)
+	}
+	return archs
+}
+
+// builder returns the host downloading the packages of the architecture.
+func builder(b *types.Blueprint, arch string) (*component.Host, error) {
+	if b.Spec.Artifacts != nil {
+		for i := range b.Spec.Artifacts.Builders {
+			if h := &b.Spec.Artifacts.Builders[i]; NormalizeArch(h.Arch) == arch {
+				return h, nil
+			}
+		}
+	}
+	if runtime.GOARCH == arch {
+		return &component.Host{Name: "local", Connection: executor.ConnectionLocal}, nil
+	}
+	return nil, fmt.Errorf("no builder of %s in the artifacts of the blueprint", arch)
+}
+
+// exportPackages downloads the packages of the blueprint on the builder of the
+// architecture, creates their repository index and copies them to dir.
+func exportPackages(ctx context.Context, b *types.Blueprint, arch, dir string) (string, error) {
+	h, err := builder(b, arch)
+	if err != nil {
+		return "", err
+	}
+
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = e.Close() }()
+
+	f, err := e.Facts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if got := NormalizeArch(f.Arch); got != arch {
+		return "", fmt.Errorf("builder %s is %s, not %s", executor.Name(h), got, arch)
+	}
+
+	m, err := pkgmgr.Detect(ctx, e)
+	if err != nil {
+		return "", err
+	}
+
+	packages, repos := Requirements(b, m.Name())
+	for _, r := range repos {
+		if _, err := m.AddRepository(ctx, r); err != nil {
+			return "", err
+		}
+	}
+	if err := m.Refresh(ctx); err != nil {
+		return "", err
+	}
+
+	out, err := executor.Output(ctx, e, "mktemp -d /tmp/peta-export.XXXXXX")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a directory on %s: %w", executor.Name(h), err)
+	}
+	remote := strings.TrimSpace(strings.ReplaceAll(string(out), "\r", ""))
+	defer func() { _, _ = executor.Output(context.WithoutCancel(ctx), e, "rm -rf "+shellutils.Quote(remote)) }()
+
+	pkgDir := path.Join(remote, KindPackage)
+	if err := m.Download(ctx, pkgDir, packages...); err != nil {
+		return "", err
+	}
+	if err := m.CreateIndex(ctx, pkgDir); err != nil {
+		return "", err
+	}
+
+	// the tarball is downloaded as a file since command output may go through a pty.
+	archive := path.Join(remote, "packages.tar.gz")
+	if out, err := executor.Output(ctx, e, fmt.Sprintf("tar -C %s -czf %s .",
+		shellutils.Quote(pkgDir), shellutils.Quote(archive))); err != nil {
+		return "", fmt.Errorf("failed to archive the packages: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	var buf bytes.Buffer
+	if err := e.Download(ctx, archive, &buf); err != nil {
+		return "", err
+	}
+	if err := extract(&buf, dir); err != nil {
+		return "", err
+	}
+
+	return m.Name(), nil
+}
+
+// extract extracts the regular files of a gzipped tarball into dir.
+func extract(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid path %q in archive", hdr.Name)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(dst, tr, 0644); err != nil {
+			return err
+		}
+	}
+}
+
+// downloadBinary downloads the binary of the architecture into dir.
+func downloadBinary(ctx context.Context, bin types.Binary, arch, dir string) error {
+	tmpl, err := template.New(bin.Name).Option("missingkey=error").Parse(bin.URL)
+	if err != nil {
+		return err
+	}
+	var u strings.Builder
+	if err := tmpl.Execute(&u, map[string]string{"Name": bin.Name, "Version": bin.Version, "Arch": arch}); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u.String(), resp.Status)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, path.Base(req.URL.Path))
+	h := sha256.New()
+	if err := writeFile(dst, io.TeeReader(resp.Body, h), 0755); err != nil {
+		return err
+	}
+
+	if want := bin.SHA256[arch]; want != "" {
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, want) {
+			return fmt.Errorf("checksum mismatch of %s: %s, want %s", u.String(), sum, want)
+		}
+	}
+	return nil
+}
+
+func writeFile(dst string, r io.Reader, mode os.FileMode) (err error) {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+	}()
+	_, err = io.Copy(f, r)
+	return err
+}
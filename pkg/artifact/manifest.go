@@ -0,0 +1,293 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package artifact builds offline bundles of what a blueprint needs and sets them
+// up as local repositories on hosts without network access.
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files at the root of a bundle, the manifest is the first file of the tarball.
+const (
+	ManifestFile  = "manifest.json"
+	ChecksumsFile = "SHA256SUMS"
+)
+
+// Kinds of the files of a bundle, by their directory under the architecture.
+const (
+	KindPackage = "packages"
+	KindBinary  = "bin"
+)
+
+// Manifest describes the files of a bundle.
+type Manifest struct {
+	Blueprint string    `json:"blueprint,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Managers are the package managers of the packages of each architecture.
+	Managers map[string]string `json:"managers"`
+	Files    []File            `json:"files"`
+}
+
+// File is a file of a bundle, the path starts with its architecture, like
+// amd64/packages/curl_7.81.0_amd64.deb.
+type File struct {
+	Path   string `json:"path"`
+	Arch   string `json:"arch"`
+	Kind   string `json:"kind"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Archs returns the architectures of the bundle.
+func (m *Manifest) Archs() []string {
+	archs := make([]string, 0, len(m.Managers))
+	for arch := range m.Managers {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// Checksums returns the sha256sum lines of the files of an architecture relative to
+// its directory, or of all files if arch is empty.
+func (m *Manifest) Checksums(arch string) []byte {
+	var b bytes.Buffer
+	for _, f := range m.Files {
+		p := f.Path
+		if arch != "" {
+			if f.Arch != arch {
+				continue
+			}
+			p = strings.TrimPrefix(p, arch+"/")
+		}
+		fmt.Fprintf(&b, "%s  %s\n", f.SHA256, p)
+	}
+	return b.Bytes()
+}
+
+// NormalizeArch returns the GOARCH name of the architectures of uname -m.
+func NormalizeArch(arch string) string {
+	switch arch = strings.ToLower(arch); arch {
+	case "x86_64", "x64":
+		return "amd64"
+	case "aarch64", "armv8", "arm64v8":
+		return "arm64"
+	case "ppc64le", "s390x", "riscv64", "loong64":
+		return arch
+	case "loongarch64":
+		return "loong64"
+	}
+	return arch
+}
+
+// WriteBundle writes the files of dir as a gzipped tarball to w, led by the manifest
+// and the checksums of all files.
+func WriteBundle(w io.Writer, dir string, m *Manifest) error {
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, f := range []struct {
+		name    string
+		content []byte
+	}{{ManifestFile, manifest}, {ChecksumsFile, m.Checksums("")}} {
+		if err := writeTarFile(tw, f.name, bytes.NewReader(f.content), int64(len(f.content)), 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range m.Files {
+		if err := addTarFile(tw, filepath.Join(dir, filepath.FromSlash(f.Path)), f.Path); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ReadBundle verifies the bundle at path against its manifest and returns it.
+// The files are passed to fn if not nil, after each has been verified.
+func ReadBundle(p string, fn func(f *File, content io.Reader) error) (*Manifest, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %w", p, err)
+	}
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != ManifestFile {
+		return nil, fmt.Errorf("invalid bundle %s: %s is not the first file", p, ManifestFile)
+	}
+	m := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of bundle %s: %w", p, err)
+	}
+
+	files := map[string]*File{}
+	for i := range m.Files {
+		f := &m.Files[i]
+		// the files are extracted on hosts, they must stay in the bundle directory.
+		if path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path || strings.HasPrefix(f.Path, "../") ||
+			!strings.HasPrefix(f.Path, f.Arch+"/") {
+			return nil, fmt.Errorf("invalid path %q in the manifest of bundle %s", f.Path, p)
+		}
+		files[f.Path] = f
+	}
+
+	seen := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle %s: %w", p, err)
+		}
+		if hdr.Name == ChecksumsFile || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		f, ok := files[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s of bundle %s is not in the manifest", hdr.Name, p)
+		}
+
+		// the content is verified before fn sees it.
+		var buf bytes.Buffer
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(h, &buf), tr); err != nil {
+			return nil, err
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch of %s in bundle %s: %s, want %s", f.Path, p, sum, f.SHA256)
+		}
+		seen[f.Path] = true
+
+		if fn != nil {
+			if err := fn(f, &buf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, f := range m.Files {
+		if !seen[f.Path] {
+			return nil, fmt.Errorf("%s is missing in bundle %s", f.Path, p)
+		}
+	}
+
+	return m, nil
+}
+
+// scanFiles returns the files under the architecture directories of dir.
+func scanFiles(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.SplitN(rel, "/", 3)
+		if len(parts) < 3 {
+			return fmt.Errorf("unexpected file %s in bundle", rel)
+		}
+
+		sum, size, err := checksum(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: rel, Arch: parts[0], Kind: parts[1], Size: size, SHA256: sum})
+		return nil
+	})
+	return files, err
+}
+
+func checksum(p string) (string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func addTarFile(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, name, f, fi.Size(), int64(fi.Mode().Perm()))
+}
+
+func writeTarFile(tw *tar.Writer, name string, r io.Reader, size, mode int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     path.Clean(name),
+		Mode:     mode,
+		Size:     size,
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
@@ -0,0 +1,285 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/pkgmgr"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// DefaultDir is the directory of the bundle on the hosts.
+const DefaultDir = "/opt/peta/artifacts"
+
+// LocalRepositoryName is the name of the repository of the bundle packages on the hosts.
+const LocalRepositoryName = "peta-local"
+
+// PushOptions of pushing a bundle to hosts.
+type PushOptions struct {
+	// Dir is the directory of the bundle on the hosts, default is DefaultDir.
+	Dir string
+	// Concurrency is the maximum number of hosts pushed at the same time.
+	Concurrency int
+	// Timeout bounds the connection and the push to each host.
+	Timeout time.Duration
+	// Output receives a line for each host when it is done, nil discards them.
+	Output io.Writer
+}
+
+// PushResult is the result of pushing a bundle to a host.
+type PushResult struct {
+	Host    string
+	Arch    string
+	Changed bool
+	Error   string
+}
+
+// Push copies the files of the bundle matching the architecture of each host to
+// the hosts and sets their packages up as a local repository. Hosts which already
+// have the same files are left as they are.
+func Push(ctx context.Context, bundle string, hosts []component.Host, o PushOptions) ([]PushResult, error) {
+	if o.Dir == "" {
+		o.Dir = DefaultDir
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = runner.DefaultTimeout
+	}
+	var output io.Writer = io.Discard
+	if o.Output != nil {
+		output = &syncWriter{w: o.Output}
+	}
+
+	staging, err := os.MkdirTemp("", "peta-push-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	m, archives, err := splitBundle(bundle, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PushResult, len(hosts))
+	runner.ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		r := pushToHost(ctx, h, m, archives, o)
+		if r.Error != "" {
+			_, _ = fmt.Fprintf(output, "[%s] %s\n", r.Host, r.Error)
+		} else {
+			_, _ = fmt.Fprintf(output, "[%s] %s, changed: %t\n", r.Host, r.Arch, r.Changed)
+		}
+		results[i] = r
+	})
+	return results, nil
+}
+
+// splitBundle verifies the bundle and writes a gzipped tarball for each of its
+// architectures to dir, with the files and checksums relative to the architecture.
+func splitBundle(bundle, dir string) (*Manifest, map[string]string, error) {
+	archives := map[string]string{}
+	writers := map[string]*tar.Writer{}
+	var closers []io.Closer
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			_ = closers[i].Close()
+		}
+	}()
+
+	m, err := ReadBundle(bundle, func(f *File, content io.Reader) error {
+		tw, ok := writers[f.Arch]
+		if !ok {
+			p := filepath.Join(dir, f.Arch+".tar.gz")
+			file, err := os.Create(p)
+			if err != nil {
+				return err
+			}
+			gw := gzip.NewWriter(file)
+			tw = tar.NewWriter(gw)
+			closers = append(closers, file, gw, tw)
+			writers[f.Arch], archives[f.Arch] = tw, p
+		}
+		return writeTarFile(tw, strings.TrimPrefix(f.Path, f.Arch+"/"), content, f.Size, tarMode(f))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for arch, tw := range writers {
+		sums := m.Checksums(arch)
+		if err := writeTarFile(tw, ChecksumsFile, bytes.NewReader(sums), int64(len(sums)), 0644); err != nil {
+			return nil, nil, err
+		}
+	}
+	// the writers are closed in reverse order to flush the tarballs.
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return nil, nil, err
+		}
+	}
+	closers = nil
+
+	return m, archives, nil
+}
+
+func tarMode(f *File) int64 {
+	if f.Kind == KindBinary {
+		return 0755
+	}
+	return 0644
+}
+
+func pushToHost(ctx context.Context, h *component.Host, m *Manifest, archives map[string]string, o PushOptions) PushResult {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	r := PushResult{Host: executor.Name(h)}
+	if err := push(ctx, h, m, archives, o.Dir, &r); err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func push(ctx context.Context, h *component.Host, m *Manifest, archives map[string]string, dir string, r *PushResult) error {
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = e.Close() }()
+
+	f, err := e.Facts(ctx)
+	if err != nil {
+		return err
+	}
+	r.Arch = NormalizeArch(f.Arch)
+	archive, ok := archives[r.Arch]
+	if !ok {
+		return fmt.Errorf("bundle has no files for %s", r.Arch)
+	}
+
+	pm, err := pkgmgr.Detect(ctx, e)
+	if err != nil {
+		return err
+	}
+	if want := m.Managers[r.Arch]; want != "" && want != pm.Name() {
+		return fmt.Errorf("bundle packages of %s are for %s, host uses %s", r.Arch, want, pm.Name())
+	}
+
+	qdir := shellutils.Quote(dir)
+	verify := fmt.Sprintf("cd %s && sha256sum -c --quiet %s", qdir, ChecksumsFile)
+
+	var current bytes.Buffer
+	synced := e.Download(ctx, path.Join(dir, ChecksumsFile), &current) == nil &&
+		bytes.Equal(current.Bytes(), m.Checksums(r.Arch))
+	if synced {
+		_, err := executor.Output(ctx, e, verify)
+		synced = err == nil
+	}
+
+	if !synced {
+		if err := upload(ctx, e, archive, dir, verify); err != nil {
+			return err
+		}
+		r.Changed = true
+	}
+
+	changed, err := pm.AddRepository(ctx, pkgmgr.LocalRepository(pm.Name(), LocalRepositoryName, path.Join(dir, KindPackage)))
+	if err != nil {
+		return err
+	}
+	r.Changed = r.Changed || changed
+	return nil
+}
+
+// upload replaces the files in dir by those of the archive and verifies them.
+func upload(ctx context.Context, e executor.Executor, archive, dir, verify string) error {
+	out, err := executor.Output(ctx, e, "mktemp /tmp/peta-artifacts.XXXXXX")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file: %w", err)
+	}
+	tmp := strings.TrimSpace(strings.ReplaceAll(string(out), "\r", ""))
+	defer func() { _, _ = executor.Output(context.WithoutCancel(ctx), e, "rm -f "+shellutils.Quote(tmp)) }()
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	if err := e.Upload(ctx, file, tmp, 0600); err != nil {
+		return err
+	}
+
+	qdir := shellutils.Quote(dir)
+	cmd := fmt.Sprintf("mkdir -p %[1]s && rm -rf %[1]s/%[2]s %[1]s/%[3]s %[1]s/%[4]s && tar -C %[1]s -xzf %[5]s && %[6]s",
+		qdir, KindPackage, KindBinary, ChecksumsFile, shellutils.Quote(tmp), verify)
+	if out, err := executor.Output(ctx, e, cmd); err != nil {
+		return fmt.Errorf("failed to extract the bundle: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// PrintReport writes the results as a table.
+func PrintReport(w io.Writer, results []PushResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tARCH\tCHANGED\tERROR")
+	for _, r := range results {
+		arch := r.Arch
+		if arch == "" {
+			arch = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", r.Host, arch, r.Changed, r.Error)
+	}
+	return tw.Flush()
+}
+
+// Failed returns the number of hosts the bundle was not pushed to.
+func Failed(results []PushResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
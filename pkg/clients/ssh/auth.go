@@ -18,11 +18,19 @@
 package ssh
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
+// EnvAuthSock is the environment variable holding the ssh-agent socket.
+const EnvAuthSock = "SSH_AUTH_SOCK"
+
 // Auth represents ssh auth method.
 type Auth []ssh.AuthMethod
 
@@ -83,3 +91,182 @@ func GetSignerForRawKey(privateKey []byte, passphrase string) (ssh.Signer, error
 
 	return signer, err
 }
+
+// Credentials holds what may be used to authenticate a connection.
+//
+// The server is offered the public keys first: the certificates, the private keys
+// and then the identities of the ssh-agent, password authentication is tried last.
+// The ssh client tries each auth method only once, so all public keys are offered
+// within a single method.
+type Credentials struct {
+	Password string
+	// PrivateKeyPath is the private key file, a certificate next to it named like
+	// <PrivateKeyPath>-cert.pub is used as well, like OpenSSH does.
+	PrivateKeyPath string
+	// PrivateKey is the PEM encoded private key.
+	PrivateKey string
+	// CertificatePath is the OpenSSH user certificate signed for the private key.
+	CertificatePath string
+	// AgentSocket is the ssh-agent socket, default is the value of SSH_AUTH_SOCK.
+	AgentSocket string
+}
+
+// Empty returns true if there are no credentials and no ssh-agent to use.
+func (c *Credentials) Empty() bool {
+	return len(c.Password) == 0 && len(c.PrivateKeyPath) == 0 && len(c.PrivateKey) == 0 && len(c.agentSocket()) == 0
+}
+
+func (c *Credentials) agentSocket() string {
+	if len(c.AgentSocket) > 0 {
+		return c.AgentSocket
+	}
+	return os.Getenv(EnvAuthSock)
+}
+
+// Auth returns the auth methods of the credentials.
+func (c *Credentials) Auth() (Auth, error) {
+	var keys []ssh.Signer
+
+	if len(c.PrivateKeyPath) > 0 {
+		signer, err := GetSigner(c.PrivateKeyPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("private key parse failed: %w", err)
+		}
+		keys = append(keys, signer)
+	}
+
+	if len(c.PrivateKey) > 0 {
+		signer, err := GetSignerForRawKey([]byte(c.PrivateKey), "")
+		if err != nil {
+			return nil, fmt.Errorf("private key parse failed: %w", err)
+		}
+		keys = append(keys, signer)
+	}
+
+	certFile := c.CertificatePath
+	if len(certFile) == 0 && len(c.PrivateKeyPath) > 0 {
+		if _, err := os.Stat(c.PrivateKeyPath + "-cert.pub"); err == nil {
+			certFile = c.PrivateKeyPath + "-cert.pub"
+		}
+	}
+
+	if len(certFile) > 0 {
+		certs, err := certSigners(certFile, keys)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(certs, keys...)
+	}
+
+	auth := Auth{}
+
+	if socket := c.agentSocket(); len(keys) > 0 || len(socket) > 0 {
+		auth = append(auth, publicKeys(keys, socket))
+	}
+
+	if len(c.Password) > 0 {
+		auth = append(auth, Password(c.Password))
+	}
+
+	return auth, nil
+}
+
+// publicKeys offers the given keys and then the identities of the ssh-agent listening on socket, if any.
+func publicKeys(keys []ssh.Signer, socket string) ssh.AuthMethod {
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		if len(socket) == 0 {
+			return keys, nil
+		}
+		agentSigners, err := AgentSigners(socket)
+		if err != nil {
+			// the agent is only a fallback, the other methods are still worth a try.
+			return keys, nil
+		}
+		return append(append([]ssh.Signer(nil), keys...), agentSigners...), nil
+	})
+}
+
+// certSigners returns signers presenting the certificate for the keys it was signed for.
+func certSigners(certFile string, keys []ssh.Signer) ([]ssh.Signer, error) {
+	cert, err := ParseCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []ssh.Signer
+	for _, key := range keys {
+		signer, err := ssh.NewCertSigner(cert, key)
+		if err != nil {
+			// the certificate was signed for another key.
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(signers) == 0 && len(keys) > 0 {
+		return nil, fmt.Errorf("certificate %s does not match the private key", certFile)
+	}
+
+	return signers, nil
+}
+
+// ParseCertificate reads an OpenSSH user certificate, like id_ed25519-cert.pub.
+func ParseCertificate(certFile string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s parse failed: %w", certFile, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certFile)
+	}
+
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s is not a user certificate", certFile)
+	}
+
+	return cert, nil
+}
+
+// agents holds the connections to ssh-agents by socket, they are kept open since
+// the agent signs during authentication of every new connection.
+var agents sync.Map
+
+// AgentSigners returns the identities of the ssh-agent listening on socket, including certificates.
+func AgentSigners(socket string) ([]ssh.Signer, error) {
+	if v, ok := agents.Load(socket); ok {
+		signers, err := v.(agent.ExtendedAgent).Signers()
+		if err == nil {
+			return signers, nil
+		}
+		// the agent may have been restarted, reconnect once.
+		agents.Delete(socket)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+
+	client := agent.NewClient(conn)
+	if v, loaded := agents.LoadOrStore(socket, client); loaded {
+		_ = conn.Close()
+		client = v.(agent.ExtendedAgent)
+	}
+
+	signers, err := client.Signers()
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("ssh-agent has no identities")
+	}
+
+	return signers, nil
+}
@@ -0,0 +1,143 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func newTestKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, signer
+}
+
+func writeTestKey(t *testing.T, dir string, key ed25519.PrivateKey) string {
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func writeTestCert(t *testing.T, file string, pub ssh.PublicKey) {
+	_, ca := newTestKey(t)
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"root"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCredentialsCertificate(t *testing.T) {
+	t.Setenv(EnvAuthSock, "")
+	dir := t.TempDir()
+	key, signer := newTestKey(t)
+	keyFile := writeTestKey(t, dir, key)
+
+	// a certificate next to the key is picked up.
+	writeTestCert(t, keyFile+"-cert.pub", signer.PublicKey())
+	certs, err := certSigners(keyFile+"-cert.pub", []ssh.Signer{signer})
+	if err != nil || len(certs) != 1 {
+		t.Fatalf("certSigners got %d signers, err %v", len(certs), err)
+	}
+	if _, ok := certs[0].PublicKey().(*ssh.Certificate); !ok {
+		t.Errorf("expected a certificate signer")
+	}
+
+	c := &Credentials{PrivateKeyPath: keyFile}
+	auth, err := c.Auth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auth) != 1 {
+		t.Errorf("expected a single public key method, got %d", len(auth))
+	}
+
+	// a certificate of another key is rejected.
+	_, other := newTestKey(t)
+	writeTestCert(t, filepath.Join(dir, "other-cert.pub"), other.PublicKey())
+	c.CertificatePath = filepath.Join(dir, "other-cert.pub")
+	if _, err := c.Auth(); err == nil {
+		t.Errorf("expected certificate mismatch error")
+	}
+}
+
+func TestAgentSigners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	keyring := agent.NewKeyring()
+	key, _ := newTestKey(t)
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+
+	t.Setenv(EnvAuthSock, socket)
+	c := &Credentials{}
+	if c.Empty() {
+		t.Fatal("credentials with an ssh-agent should not be empty")
+	}
+
+	signers, err := AgentSigners(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 1 {
+		t.Errorf("expected 1 agent identity, got %d", len(signers))
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// BecomeMethod is the privilege escalation method used to run remote commands.
+type BecomeMethod string
+
+const (
+	BecomeSudo BecomeMethod = "sudo"
+	BecomeSu   BecomeMethod = "su"
+
+	DefaultBecomeUser = "root"
+)
+
+// Become holds the privilege escalation settings of a connection.
+// The password is only ever written to the stdin of the remote command when the
+// escalation tool prompts for it, it never appears in a command line.
+type Become struct {
+	Method   BecomeMethod
+	User     string
+	Password string
+}
+
+func (b *Become) user() string {
+	if b.User == "" {
+		return DefaultBecomeUser
+	}
+	return b.User
+}
+
+// Wrap returns cmd wrapped with the escalation tool and the prompt which will be
+// answered with the password, the prompt is empty if no password is needed.
+func (b *Become) Wrap(cmd string) (string, string, error) {
+	inner := shellutils.Join("/bin/sh", "-c", cmd)
+
+	switch b.Method {
+	case BecomeSudo, "":
+		if b.Password == "" {
+			return fmt.Sprintf("sudo -n -H -u %s %s", shellutils.Quote(b.user()), inner), "", nil
+		}
+		prompt, err := newPrompt()
+		if err != nil {
+			return "", "", err
+		}
+		return fmt.Sprintf("sudo -S -H -p %s -u %s %s", shellutils.Quote(prompt), shellutils.Quote(b.user()), inner), prompt, nil
+	case BecomeSu:
+		// su has no way to customize the prompt, force the C locale so that it is predictable.
+		return fmt.Sprintf("LC_ALL=C su - %s -c %s", shellutils.Quote(b.user()), shellutils.Quote(inner)), "Password:", nil
+	default:
+		return "", "", fmt.Errorf("unsupported become method: %s", b.Method)
+	}
+}
+
+func newPrompt() (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[peta-become-%s]:", id), nil
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// PromptWriter collects the output of a command run with privilege escalation,
+// answers the password prompt once and keeps both the prompt and the password
+// out of the collected output.
+type PromptWriter struct {
+	mu       sync.Mutex
+	prompt   []byte
+	password []byte
+	stdin    io.Writer
+	answered bool
+	pending  []byte
+	out      io.Writer
+}
+
+func NewPromptWriter(out, stdin io.Writer, prompt, password string) *PromptWriter {
+	return &PromptWriter{
+		prompt:   []byte(prompt),
+		password: []byte(password),
+		stdin:    stdin,
+		out:      out,
+		answered: prompt == "",
+	}
+}
+
+func (w *PromptWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.answered {
+		return len(p), w.write(p)
+	}
+
+	w.pending = append(w.pending, p...)
+	i := bytes.Index(w.pending, w.prompt)
+	if i < 0 {
+		// The prompt may arrive in pieces, hold back as many bytes as could be its beginning.
+		keep := len(w.prompt) - 1
+		if len(w.pending) > keep {
+			if err := w.write(w.pending[:len(w.pending)-keep]); err != nil {
+				return 0, err
+			}
+			w.pending = append([]byte(nil), w.pending[len(w.pending)-keep:]...)
+		}
+		return len(p), nil
+	}
+
+	w.answered = true
+	if _, err := w.stdin.Write(append(append([]byte(nil), w.password...), '\n')); err != nil {
+		return 0, err
+	}
+	rest := append(append([]byte(nil), w.pending[:i]...), w.pending[i+len(w.prompt):]...)
+	w.pending = nil
+	return len(p), w.write(rest)
+}
+
+// Flush writes the bytes held back while waiting for the prompt.
+func (w *PromptWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.write(w.pending)
+	w.pending = nil
+	return err
+}
+
+func (w *PromptWriter) write(p []byte) error {
+	if len(w.password) > 0 {
+		p = bytes.ReplaceAll(p, w.password, []byte("********"))
+	}
+	_, err := w.out.Write(p)
+	return err
+}
@@ -0,0 +1,69 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPromptWriter(t *testing.T) {
+	prompt := "[peta-become-0123456789abcdef]:"
+	chunks := []string{"Last login\r\n[peta-become-0123", "456789abcdef]:", "\r\nsecret ok\r\n"}
+
+	var out, stdin bytes.Buffer
+	w := NewPromptWriter(&out, &stdin, prompt, "secret")
+	for _, c := range chunks {
+		if _, err := w.Write([]byte(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdin.String() != "secret\n" {
+		t.Errorf("stdin got %q, want %q", stdin.String(), "secret\n")
+	}
+	if strings.Contains(out.String(), prompt) || strings.Contains(out.String(), "secret") {
+		t.Errorf("output leaks prompt or password: %q", out.String())
+	}
+	if want := "Last login\r\n\r\n******** ok\r\n"; out.String() != want {
+		t.Errorf("output got %q, want %q", out.String(), want)
+	}
+}
+
+func TestBecomeWrap(t *testing.T) {
+	b := &Become{Method: BecomeSudo, Password: "secret"}
+	cmd, prompt, err := b.Wrap("systemctl restart postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(cmd, "secret") {
+		t.Errorf("password in command line: %s", cmd)
+	}
+	if !strings.Contains(cmd, prompt) || !strings.HasPrefix(cmd, "sudo -S") {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+
+	b = &Become{Method: BecomeSudo}
+	if cmd, prompt, _ = b.Wrap("id"); prompt != "" || !strings.HasPrefix(cmd, "sudo -n") {
+		t.Errorf("unexpected passwordless command: %s", cmd)
+	}
+}
@@ -18,10 +18,14 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -29,6 +33,7 @@ import (
 	"golang.org/x/crypto/ssh"
 	"peta.io/peta/pkg/log"
 	"peta.io/peta/pkg/utils/iputils"
+	"peta.io/peta/pkg/utils/shellutils"
 )
 
 // DefaultTimeout is the timeout of ssh client connection.
@@ -42,6 +47,9 @@ type Client struct {
 	mu sync.Mutex
 	*ssh.Client
 	Config *Config
+
+	tunnelsMu sync.Mutex
+	tunnels   map[*Tunnel]struct{}
 }
 
 // Config for SSH Client.
@@ -53,26 +61,38 @@ type Config struct {
 	Timeout        time.Duration
 	Callback       ssh.HostKeyCallback
 	BannerCallback ssh.BannerCallback
+	// Become enables privilege escalation for commands and uploads, nil runs everything as User.
+	Become *Become
+	// JumpHosts are connected in order to reach Addr, like ProxyJump of OpenSSH.
+	JumpHosts []*Config
+
+	// identity distinguishes configs of the same user and address which authenticate
+	// or verify the host key differently, connections to jump hosts are shared only
+	// between configs of the same identity.
+	identity string
 }
 
-// New starts a new ssh connection, the host public key must be in known hosts.
+// New starts a new ssh connection, the host public key is verified by the policy
+// against the known hosts file.
 func New(
 	user, addr string,
 	port uint,
 	passwd, privateKey, privateKeyRaw, knowFile string,
 	timeout time.Duration,
-	knownHostCheck, askAddKnownHost bool,
+	policy HostKeyPolicy,
 ) (*Client, error) {
 
-	config, err := createConfig(user, addr, port, passwd, privateKey, privateKeyRaw, timeout)
+	config, err := createConfig(user, addr, port, Credentials{
+		Password:       passwd,
+		PrivateKeyPath: privateKey,
+		PrivateKey:     privateKeyRaw,
+	}, timeout)
 	if err != nil {
 		return nil, err
 	}
 
-	if knownHostCheck {
-		config.Callback = VerifyHost(knowFile, askAddKnownHost)
-	} else {
-		config.Callback = ssh.InsecureIgnoreHostKey()
+	if err = config.verifyHostKeys(policy, knowFile, nil); err != nil {
+		return nil, err
 	}
 
 	c, err := NewConn(config)
@@ -91,17 +111,31 @@ func NewConn(config *Config) (c *Client, err error) {
 }
 
 // Dial starts a client connection to SSH server based on config.
+// Connections to the jump hosts of the config are shared with other clients.
 func Dial(proto string, c *Config) (*ssh.Client, error) {
-	return ssh.Dial(proto, net.JoinHostPort(c.Addr, fmt.Sprint(c.Port)), &ssh.ClientConfig{
+	if len(c.JumpHosts) > 0 {
+		return dialJump(c)
+	}
+	return ssh.Dial(proto, c.address(), c.clientConfig())
+}
+
+func (c *Config) address() string {
+	return net.JoinHostPort(c.Addr, fmt.Sprint(c.Port))
+}
+
+func (c *Config) clientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
 		User:            c.User,
 		Auth:            c.Auth,
 		Timeout:         c.Timeout,
 		HostKeyCallback: c.Callback,
 		BannerCallback:  c.BannerCallback,
-	})
+	}
 }
 
-func (c *Client) session() (*ssh.Session, error) {
+// session opens a new session, a pty is requested for interactive commands
+// only since it would mangle binary data written to stdin.
+func (c *Client) session(pty bool) (*ssh.Session, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -114,60 +148,261 @@ func (c *Client) session() (*ssh.Session, error) {
 		return nil, err
 	}
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          1,
-		ssh.TTY_OP_ISPEED: 14400,
-		ssh.TTY_OP_OSPEED: 14400,
+	if pty {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+
+		if err = session.RequestPty("xterm", 100, 50, modes); err != nil {
+			_ = session.Close()
+			return nil, err
+		}
 	}
 
-	err = session.RequestPty("xterm", 100, 50, modes)
+	if err := session.Setenv("LANG", "en_US.UTF-8"); err != nil {
+		log.Debugf("failed to set LANG to en_US.UTF-8. (Error: %v)", err)
+	}
+
+	return session, nil
+}
+
+func closeSession(session *ssh.Session, err *error) {
+	dErr := session.Close()
+	if dErr != nil && dErr != io.EOF && *err == nil {
+		*err = dErr
+	}
+}
+
+// Run runs cmd on the remote host and returns its combined output, the command
+// is wrapped with the become method of the config if any.
+func (c *Client) Run(cmd string) ([]byte, error) {
+	var out bytes.Buffer
+	err := c.RunContext(context.Background(), cmd, &out)
+	return out.Bytes(), err
+}
+
+// RunContext runs cmd on the remote host and streams its combined output to out
+// as it is produced, the command is interrupted when ctx is done. The exit status
+// of a failed command is reported as *ssh.ExitError.
+func (c *Client) RunContext(ctx context.Context, cmd string, out io.Writer) (err error) {
+	session, err := c.session(true)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer closeSession(session, &err)
+
+	cmd = strings.TrimSpace(cmd)
+	w := &syncWriter{w: out}
+	session.Stdout = w
+	session.Stderr = w
+
+	var pw *PromptWriter
+	if c.Config.Become != nil {
+		var prompt string
+		if cmd, prompt, err = c.Config.Become.Wrap(cmd); err != nil {
+			return err
+		}
+
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			return err
+		}
+
+		pw = NewPromptWriter(out, stdin, prompt, c.Config.Become.Password)
+		session.Stdout = pw
+		session.Stderr = pw
 	}
 
-	log.Infof("failed to set LANG to en_US.UTF-8. (Error: %v)", err)
-	log.Errorf("failed to set LANG to en_US.UTF-8. (Error: %v)", errors.New("12345"))
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
 
-	if err := session.Setenv("LANG", "en_US.UTF-8"); err != nil {
-		log.Infof("failed to set LANG to en_US.UTF-8. (Error: %v)", err)
-		log.Errorf("failed to set LANG to en_US.UTF-8. (Error: %v)", err)
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// not every server supports signals, closing the session hangs up the pty anyway.
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		<-done
+		err = ctx.Err()
 	}
 
-	return session, nil
+	if pw != nil {
+		if fErr := pw.Flush(); fErr != nil && err == nil {
+			err = fErr
+		}
+	}
+
+	return err
 }
 
-func (c *Client) Run(cmd string) (stdout []byte, err error) {
-	session, err := c.session()
+// syncWriter serializes the writes of stdout and stderr.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// Upload writes the content of src to dst on the remote host with the given mode.
+// With a become method the content is first staged in a private temporary directory
+// of the login user and then installed to dst as the become user, so dst may be in
+// a directory the login user can not write to. A become user other than root is
+// granted access to the staged file with an ACL, the modes are never widened.
+func (c *Client) Upload(src io.Reader, dst string, mode os.FileMode) error {
+	if c.Config.Become == nil {
+		return c.write(src, dst, mode)
+	}
+
+	dir, err := c.stagingDir("upload")
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to stage %s: %w", dst, err)
 	}
-	defer func(session *ssh.Session) {
-		dErr := session.Close()
-		if dErr != nil && dErr != io.EOF && err == nil {
-			err = dErr
+	defer c.removeStagingDir(dir)
+
+	tmp := path.Join(dir, "content")
+	if err := c.write(src, tmp, 0600); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", dst, err)
+	}
+
+	if user := c.Config.Become.user(); user != DefaultBecomeUser {
+		grant := fmt.Sprintf("setfacl -m %s %s && setfacl -m %s %s",
+			shellutils.Quote("u:"+user+":x"), shellutils.Quote(dir),
+			shellutils.Quote("u:"+user+":r"), shellutils.Quote(tmp))
+		if output, err := c.run(grant); err != nil {
+			return fmt.Errorf("failed to grant %s access to the staged %s: %w: %s",
+				user, dst, err, strings.TrimSpace(string(output)))
 		}
-	}(session)
+	}
 
-	//err = session.Start(strings.TrimSpace(cmd))
-	//if err != nil {
-	//	return nil, err
-	//}
-	//
-	//err = session.Wait()
-	//if err != nil {
-	//	fmt.Println(err)
-	//}
+	install := fmt.Sprintf("install -m %04o %s %s", mode.Perm(), shellutils.Quote(tmp), shellutils.Quote(dst))
+	if output, err := c.Run(install); err != nil {
+		return fmt.Errorf("failed to install %s: %w: %s", dst, err, strings.TrimSpace(string(output)))
+	}
 
-	return session.CombinedOutput(strings.TrimSpace(cmd))
+	return nil
+}
+
+// Download streams the content of src on the remote host to dst. With a become
+// method src is first copied by the become user to a private temporary directory
+// of the login user, so src may be a file the login user can not read. A become
+// user other than root is granted access to the directory with an ACL and grants
+// the login user read access to the copy in turn, the modes are never widened.
+func (c *Client) Download(src string, dst io.Writer) error {
+	if c.Config.Become == nil {
+		return c.read(src, dst)
+	}
+
+	dir, err := c.stagingDir("download")
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", src, err)
+	}
+	defer c.removeStagingDir(dir)
+
+	tmp := path.Join(dir, "content")
+	stage := fmt.Sprintf("install -m 0600 -o %s %s %s",
+		shellutils.Quote(c.Config.User), shellutils.Quote(src), shellutils.Quote(tmp))
+	if user := c.Config.Become.user(); user != DefaultBecomeUser {
+		grant := fmt.Sprintf("setfacl -m %s %s", shellutils.Quote("u:"+user+":wx"), shellutils.Quote(dir))
+		if output, err := c.run(grant); err != nil {
+			return fmt.Errorf("failed to grant %s access to the staging directory of %s: %w: %s",
+				user, src, err, strings.TrimSpace(string(output)))
+		}
+		stage = fmt.Sprintf("install -m 0600 %s %s && setfacl -m %s %s",
+			shellutils.Quote(src), shellutils.Quote(tmp),
+			shellutils.Quote("u:"+c.Config.User+":r"), shellutils.Quote(tmp))
+	}
+	if output, err := c.Run(stage); err != nil {
+		return fmt.Errorf("failed to stage %s: %w: %s", src, err, strings.TrimSpace(string(output)))
+	}
+
+	return c.read(tmp, dst)
+}
+
+// stagingDir creates a temporary directory only the login user can access.
+func (c *Client) stagingDir(kind string) (string, error) {
+	output, err := c.run(fmt.Sprintf("umask 077 && mktemp -d /tmp/.peta-%s-XXXXXXXX", kind))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// removeStagingDir removes a directory created by stagingDir, the login user owns
+// it so the files staged by the become user can be removed as well.
+func (c *Client) removeStagingDir(dir string) {
+	if output, err := c.run(fmt.Sprintf("rm -rf %s", shellutils.Quote(dir))); err != nil {
+		log.Warnf("failed to remove %s: %v: %s", dir, err, strings.TrimSpace(string(output)))
+	}
+}
+
+// read streams src into dst as the login user.
+func (c *Client) read(src string, dst io.Writer) (err error) {
+	session, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer closeSession(session, &err)
+
+	var stderr bytes.Buffer
+	session.Stdout = dst
+	session.Stderr = &stderr
+
+	if err := session.Run(fmt.Sprintf("cat %s", shellutils.Quote(src))); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// write streams src into dst as the login user.
+func (c *Client) write(src io.Reader, dst string, mode os.FileMode) (err error) {
+	session, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	defer closeSession(session, &err)
+
+	var stderr bytes.Buffer
+	session.Stdin = src
+	session.Stderr = &stderr
+
+	cmd := fmt.Sprintf("umask 077 && cat > %s && chmod %04o %s",
+		shellutils.Quote(dst), mode.Perm(), shellutils.Quote(dst))
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// run runs cmd as the login user regardless of the become method.
+func (c *Client) run(cmd string) (output []byte, err error) {
+	session, err := c.session(false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSession(session, &err)
+
+	return session.CombinedOutput(cmd)
 }
 
 func createConfig(
 	user,
 	addr string,
 	port uint,
-	passwd,
-	privateKey,
-	privateKeyRaw string,
+	credentials Credentials,
 	timeout time.Duration,
 ) (*Config, error) {
 	c := &Config{}
@@ -184,8 +419,8 @@ func createConfig(
 		return nil, fmt.Errorf("address is an invalid ip or domain address: %s", addr)
 	}
 
-	if len(passwd) == 0 && len(privateKey) == 0 && len(privateKeyRaw) == 0 {
-		return nil, errors.New("private key or private key is required")
+	if credentials.Empty() {
+		return nil, errors.New("password, private key or ssh-agent is required")
 	}
 
 	c.User = user
@@ -194,33 +429,19 @@ func createConfig(
 
 	c.Port = setSSHPort(port)
 
+	c.Timeout = timeout
 	if timeout == 0 {
 		c.Timeout = DefaultTimeout
 	}
 
-	auth := Auth{}
-
-	if len(passwd) > 0 {
-		auth = append(auth, Password(passwd))
-	}
-
-	if len(privateKey) > 0 {
-		keyAuth, err := Key(privateKey, "")
-		if err != nil {
-			return nil, fmt.Errorf("private key parse failed: %w", err)
-		}
-		auth = append(auth, keyAuth)
-	}
-
-	if len(privateKey) == 0 && len(privateKeyRaw) > 0 {
-		keyAuth, err := RawKey(privateKey, "")
-		if err != nil {
-			return nil, fmt.Errorf("private key parse failed: %w", err)
-		}
-		auth = append(auth, keyAuth)
+	auth, err := credentials.Auth()
+	if err != nil {
+		return nil, err
 	}
 
 	c.Auth = auth
+	c.identity = digest(credentials.Password, credentials.PrivateKeyPath, credentials.PrivateKey,
+		credentials.CertificatePath, credentials.agentSocket())
 
 	return c, nil
 }
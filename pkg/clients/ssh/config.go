@@ -0,0 +1,128 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"peta.io/peta/pkg/types/component"
+)
+
+// NewForHost starts a new ssh connection to a blueprint host.
+func NewForHost(h *component.Host) (*Client, error) {
+	config, err := NewConfigForHost(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(config)
+}
+
+// NewConfigForHost returns the config to connect to a blueprint host through its jump hosts.
+func NewConfigForHost(h *component.Host) (*Config, error) {
+	var timeout time.Duration
+	if h.Timeout != nil {
+		timeout = time.Duration(*h.Timeout) * time.Second
+	}
+
+	config, err := createConfig(h.User, h.Address, uint(h.Port), Credentials{
+		Password:        h.Password,
+		PrivateKeyPath:  h.PrivateKeyPath,
+		PrivateKey:      h.PrivateKey,
+		CertificatePath: h.CertificatePath,
+		AgentSocket:     h.AgentSocket,
+	}, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("host %s: %w", h.Name, err)
+	}
+	if err = config.verifyHostKeys(hostKeyPolicy(h.HostKeyPolicy, h.HostKeyFingerprints), "", h.HostKeyFingerprints); err != nil {
+		return nil, fmt.Errorf("host %s: %w", h.Name, err)
+	}
+
+	if h.Become != nil {
+		config.Become = &Become{
+			Method:   BecomeMethod(h.Become.Method),
+			User:     h.Become.User,
+			Password: h.Become.Password,
+		}
+	}
+
+	for _, j := range h.JumpHosts {
+		jump, err := createConfig(j.User, j.Address, uint(j.Port), Credentials{
+			Password:        j.Password,
+			PrivateKeyPath:  j.PrivateKeyPath,
+			PrivateKey:      j.PrivateKey,
+			CertificatePath: j.CertificatePath,
+			AgentSocket:     j.AgentSocket,
+		}, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s of host %s: %w", j.Address, h.Name, err)
+		}
+		policy, err := jumpHostKeyPolicy(h, j.HostKeyFingerprints)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s of host %s: %w", j.Address, h.Name, err)
+		}
+		if err = jump.verifyHostKeys(policy, "", j.HostKeyFingerprints); err != nil {
+			return nil, fmt.Errorf("jump host %s of host %s: %w", j.Address, h.Name, err)
+		}
+		config.JumpHosts = append(config.JumpHosts, jump)
+	}
+
+	return config, nil
+}
+
+// hostKeyPolicy returns the policy of a host, hosts with pinned fingerprints default to the pinned policy.
+func hostKeyPolicy(policy string, fingerprints []string) HostKeyPolicy {
+	if policy != "" {
+		return HostKeyPolicy(policy)
+	}
+	if len(fingerprints) > 0 {
+		return HostKeyPinned
+	}
+	return DefaultHostKeyPolicy
+}
+
+// jumpHostKeyPolicy returns the policy of a jump host of h with the fingerprints, it
+// follows the policy of h. The jump hosts of pinned hosts need fingerprints of their
+// own, so pinned hosts are never reached through unverified bastions.
+func jumpHostKeyPolicy(h *component.Host, fingerprints []string) (HostKeyPolicy, error) {
+	if hostKeyPolicy(h.HostKeyPolicy, h.HostKeyFingerprints) == HostKeyPinned && len(fingerprints) == 0 {
+		return "", errors.New("the jump hosts of hosts with the pinned host key policy require fingerprints")
+	}
+	return hostKeyPolicy(h.HostKeyPolicy, fingerprints), nil
+}
+
+// verifyHostKeys sets the host key callback of the config by the policy.
+func (c *Config) verifyHostKeys(policy HostKeyPolicy, knownFile string, fingerprints []string) (err error) {
+	if c.Callback, err = HostKeyCallback(policy, knownFile, fingerprints); err != nil {
+		return err
+	}
+	c.identity = digest(c.identity, string(policy), knownFile, strings.Join(fingerprints, ","))
+	return nil
+}
+
+// digest returns a hash of parts, so that secrets are not kept around in clear text.
+func digest(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"testing"
+
+	"peta.io/peta/pkg/types/component"
+)
+
+func TestJumpHostKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		host         component.Host
+		fingerprints []string
+		want         HostKeyPolicy
+		wantErr      bool
+	}{
+		{name: "default", want: DefaultHostKeyPolicy},
+		{name: "own fingerprints", fingerprints: []string{"SHA256:jump"}, want: HostKeyPinned},
+		{name: "pinned with fingerprints", host: component.Host{HostKeyPolicy: "pinned"}, fingerprints: []string{"SHA256:jump"}, want: HostKeyPinned},
+		{name: "pinned without fingerprints", host: component.Host{HostKeyPolicy: "pinned"}, wantErr: true},
+		{name: "pinned by fingerprints", host: component.Host{HostKeyFingerprints: []string{"SHA256:target"}}, wantErr: true},
+		{name: "strict", host: component.Host{HostKeyPolicy: "strict"}, want: HostKeyStrict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jumpHostKeyPolicy(&tt.host, tt.fingerprints)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("jumpHostKeyPolicy() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewConfigForHostPinnedJump(t *testing.T) {
+	for _, policy := range []string{"pinned", ""} {
+		h := &component.Host{
+			Name:                "node1",
+			Address:             "10.0.0.31",
+			User:                "root",
+			Password:            "secret",
+			HostKeyPolicy:       policy,
+			HostKeyFingerprints: []string{"SHA256:target"},
+			JumpHosts: []component.JumpHost{
+				{Address: "10.0.0.1", User: "jump", Password: "secret"},
+			},
+		}
+		if _, err := NewConfigForHost(h); err == nil {
+			t.Errorf("policy %q: a pinned host is reached through a jump host without fingerprints", policy)
+		}
+
+		h.JumpHosts[0].HostKeyFingerprints = []string{"SHA256:jump"}
+		config, err := NewConfigForHost(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(config.JumpHosts) != 1 || config.JumpHosts[0].Callback == nil {
+			t.Errorf("jump host has no host key callback")
+		}
+	}
+}
+
+func TestNewConfigForHostJumpAgentSocket(t *testing.T) {
+	jumpIdentity := func(h *component.Host) string {
+		t.Helper()
+		config, err := NewConfigForHost(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.JumpHosts[0].identity
+	}
+	host := func(agent, jumpAgent string) *component.Host {
+		return &component.Host{
+			Name: "node1", Address: "10.0.0.31", User: "root", Password: "secret", AgentSocket: agent,
+			JumpHosts: []component.JumpHost{{Address: "10.0.0.1", User: "jump", AgentSocket: jumpAgent}},
+		}
+	}
+
+	if jumpIdentity(host("/run/target.sock", "/run/jump.sock")) != jumpIdentity(host("", "/run/jump.sock")) {
+		t.Error("the jump host uses the agent of its host")
+	}
+	if jumpIdentity(host("", "/run/jump.sock")) == jumpIdentity(host("", "/run/other.sock")) {
+		t.Error("the jump host does not use its own agent")
+	}
+}
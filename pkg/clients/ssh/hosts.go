@@ -18,19 +18,65 @@
 package ssh
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
+	"peta.io/peta/pkg/log"
 )
 
+// HostKeyPolicy decides which host keys are trusted, none of them prompts.
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict trusts the keys in the known hosts file only.
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyAcceptNew trusts and records the key of an unknown host on first use,
+	// a key different from the recorded one is rejected.
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	// HostKeyPinned trusts the keys with the given fingerprints only.
+	HostKeyPinned HostKeyPolicy = "pinned"
+	// HostKeyInsecure trusts any key, for lab environments only.
+	HostKeyInsecure HostKeyPolicy = "insecure"
+
+	DefaultHostKeyPolicy = HostKeyAcceptNew
+)
+
+// ErrHostKeyChanged is returned when the key of a host differs from the one recorded for it.
+var ErrHostKeyChanged = errors.New("host key has changed, it may be a man-in-the-middle attack")
+
+// knownHostsMu serializes the writers of the known hosts files.
+var knownHostsMu sync.Mutex
+
+// HostKeyCallback returns the callback verifying host keys by the policy, fingerprints
+// are only used by the pinned policy, an empty knownFile is the file of the current user.
+func HostKeyCallback(policy HostKeyPolicy, knownFile string, fingerprints []string) (ssh.HostKeyCallback, error) {
+	switch policy {
+	case HostKeyStrict:
+		return VerifyHost(knownFile, false), nil
+	case HostKeyAcceptNew, "":
+		return VerifyHost(knownFile, true), nil
+	case HostKeyPinned:
+		if len(fingerprints) == 0 {
+			return nil, errors.New("pinned host key policy requires at least one fingerprint")
+		}
+		return PinnedHost(fingerprints), nil
+	case HostKeyInsecure:
+		return func(host string, remote net.Addr, key ssh.PublicKey) error {
+			log.Warnf("host key of %s is not verified, fingerprint: %s", host, ssh.FingerprintSHA256(key))
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported host key policy: %s", policy)
+	}
+}
+
 func KnownHosts(file string) (ssh.HostKeyCallback, error) {
 	return knownhosts.New(file)
 }
@@ -46,31 +92,63 @@ func DefaultKnownHostsPath() (string, error) {
 	return filepath.Join(home, ".ssh", "known_hosts"), nil
 }
 
-// VerifyHost check new connections public keys if the key not trusted you should return an error.
-func VerifyHost(knownFile string, askAddKnownHost bool) ssh.HostKeyCallback {
+// VerifyHost checks the public keys of new connections against a known hosts file, the
+// key of an unknown host is recorded if acceptNew is true. A key which differs from the
+// recorded one is always rejected.
+func VerifyHost(knownFile string, acceptNew bool) ssh.HostKeyCallback {
 	return func(host string, remote net.Addr, key ssh.PublicKey) error {
 		hostFound, err := CheckKnownHost(host, remote, key, knownFile)
 
-		var keyErr *knownhosts.KeyError
-
 		// Host in known hosts but key mismatch!
 		// Maybe because of MAN IN THE MIDDLE ATTACK!
 		if hostFound && err != nil {
+			var keyErr *knownhosts.KeyError
 			if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
-				return AddKnownHost(host, remote, key, knownFile, askAddKnownHost)
+				return fmt.Errorf("%w: %s presented %s %s, known hosts has %s",
+					ErrHostKeyChanged, host, key.Type(), ssh.FingerprintSHA256(key), wantedFingerprints(keyErr))
 			}
 			return err
 		}
 
 		// public key not found.
-		if !hostFound && err != nil && errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
-			return AddKnownHost(host, remote, key, knownFile, askAddKnownHost)
+		if !hostFound && err != nil {
+			var keyErr *knownhosts.KeyError
+			if !errors.As(err, &keyErr) {
+				return err
+			}
+			if !acceptNew {
+				return fmt.Errorf("unknown host %s, fingerprint: %s", host, ssh.FingerprintSHA256(key))
+			}
+			log.Infof("adding %s to known hosts, fingerprint: %s", host, ssh.FingerprintSHA256(key))
+			return AddKnownHost(host, remote, key, knownFile)
 		}
 
 		return err
 	}
 }
 
+// PinnedHost trusts the keys matching one of the fingerprints, in the SHA256:... format of ssh-keygen -l.
+func PinnedHost(fingerprints []string) ssh.HostKeyCallback {
+	return func(host string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		for _, f := range fingerprints {
+			if strings.TrimSpace(f) == fingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s presented %s %s, which is not pinned", ErrHostKeyChanged, host, key.Type(), fingerprint)
+	}
+}
+
+func wantedFingerprints(keyErr *knownhosts.KeyError) string {
+	fingerprints := make([]string, 0, len(keyErr.Want))
+	for _, want := range keyErr.Want {
+		fingerprints = append(fingerprints, fmt.Sprintf("%s %s (%s:%d)",
+			want.Key.Type(), ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+	}
+	return strings.Join(fingerprints, ", ")
+}
+
 // CheckKnownHost checks is host in a known hosts file.
 // It returns the host found in a known_hosts file and error, if the host found in
 // a known_hosts file and error not nil that means public key mismatch.
@@ -85,6 +163,11 @@ func CheckKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile s
 		knownFile = path
 	}
 
+	// A missing file knows no host.
+	if _, err := os.Stat(knownFile); errors.Is(err, os.ErrNotExist) {
+		return false, &knownhosts.KeyError{}
+	}
+
 	// Get host key callback.
 	callback, err := KnownHosts(knownFile)
 
@@ -114,7 +197,7 @@ func CheckKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile s
 }
 
 // AddKnownHost add a host to a known hosts file.
-func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile string, askAddKnownHost bool) (err error) {
+func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile string) (err error) {
 	if knownFile == "" {
 		path, err := DefaultKnownHostsPath()
 		if err != nil {
@@ -124,6 +207,13 @@ func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile str
 		knownFile = path
 	}
 
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(knownFile), 0700); err != nil {
+		return err
+	}
+
 	f, err := os.OpenFile(knownFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 	if err != nil {
 		return err
@@ -143,29 +233,7 @@ func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile str
 		addresses = append(addresses, hostNormalized)
 	}
 
-	if askAddKnownHost {
-		if askIsHostTrusted(host, key) == false {
-			return errors.New("you typed no, aborted")
-		}
-	}
-
 	_, err = f.WriteString(knownhosts.Line(addresses, key) + "\n")
 
 	return err
 }
-
-func askIsHostTrusted(host string, key ssh.PublicKey) bool {
-
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Printf("Unknown Host: %s \nFingerprint: %s \n", host, ssh.FingerprintSHA256(key))
-	fmt.Print("Would you like to add it? type yes or no: ")
-
-	a, err := reader.ReadString('\n')
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return strings.ToLower(strings.TrimSpace(a)) == "yes"
-}
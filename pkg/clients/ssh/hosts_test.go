@@ -0,0 +1,78 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyPolicies(t *testing.T) {
+	knownFile := filepath.Join(t.TempDir(), "known_hosts")
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.31"), Port: 22}
+	host := "10.0.0.31:22"
+	_, key := newTestKey(t)
+	_, other := newTestKey(t)
+
+	strict, err := HostKeyCallback(HostKeyStrict, knownFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strict(host, remote, key.PublicKey()); err == nil {
+		t.Errorf("strict policy accepted an unknown host")
+	}
+	if _, err := os.Stat(knownFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("strict policy must not write known hosts")
+	}
+
+	acceptNew, err := HostKeyCallback(HostKeyAcceptNew, knownFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acceptNew(host, remote, key.PublicKey()); err != nil {
+		t.Errorf("accept-new policy rejected an unknown host: %v", err)
+	}
+	if err := strict(host, remote, key.PublicKey()); err != nil {
+		t.Errorf("strict policy rejected a known host: %v", err)
+	}
+	if err := acceptNew(host, remote, other.PublicKey()); !errors.Is(err, ErrHostKeyChanged) {
+		t.Errorf("accept-new policy got %v for a changed key, want %v", err, ErrHostKeyChanged)
+	}
+	if err := strict(host, remote, other.PublicKey()); !errors.Is(err, ErrHostKeyChanged) {
+		t.Errorf("strict policy got %v for a changed key, want %v", err, ErrHostKeyChanged)
+	}
+
+	if _, err := HostKeyCallback(HostKeyPinned, knownFile, nil); err == nil {
+		t.Errorf("pinned policy without fingerprints should be rejected")
+	}
+	pinned, err := HostKeyCallback(HostKeyPinned, "", []string{ssh.FingerprintSHA256(key.PublicKey())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pinned(host, remote, key.PublicKey()); err != nil {
+		t.Errorf("pinned policy rejected the pinned key: %v", err)
+	}
+	if err := pinned(host, remote, other.PublicKey()); !errors.Is(err, ErrHostKeyChanged) {
+		t.Errorf("pinned policy got %v for another key, want %v", err, ErrHostKeyChanged)
+	}
+}
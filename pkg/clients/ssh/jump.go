@@ -0,0 +1,216 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"peta.io/peta/pkg/log"
+)
+
+// jumps holds the connections to jump hosts, they are shared by every target
+// behind the same chain of jump hosts.
+var jumps = newJumpPool()
+
+type jumpPool struct {
+	mu      sync.Mutex
+	conns   map[string]*jumpConn
+	dialing map[string]*jumpDial
+}
+
+type jumpConn struct {
+	client  *ssh.Client
+	refs    int
+	release func()
+}
+
+// jumpDial is a connection to a jump host in progress, concurrent users of the
+// same chain wait for it instead of connecting again.
+type jumpDial struct {
+	done chan struct{}
+	err  error
+}
+
+func newJumpPool() *jumpPool {
+	return &jumpPool{
+		conns:   map[string]*jumpConn{},
+		dialing: map[string]*jumpDial{},
+	}
+}
+
+// chainKey identifies a chain of jump hosts, like user@addr:port#identity,user@addr:port#identity.
+// Hops authenticating or verifying the host key differently never share a connection.
+func chainKey(chain []*Config) string {
+	keys := make([]string, 0, len(chain))
+	for _, c := range chain {
+		identity := c.identity
+		if identity == "" {
+			// configs built by hand can not be told apart, they are not shared.
+			identity = fmt.Sprintf("%p", c)
+		}
+		keys = append(keys, fmt.Sprintf("%s@%s#%s", c.User, c.address(), identity))
+	}
+	return strings.Join(keys, ",")
+}
+
+// acquire returns a connection to the last host of chain and the function to release it,
+// the connection is closed when it is released by all of its users. The pool is not
+// locked while connecting, so a slow jump host only delays the users of its chain.
+func (p *jumpPool) acquire(chain []*Config) (*ssh.Client, func(), error) {
+	key := chainKey(chain)
+
+	for {
+		p.mu.Lock()
+		if conn, ok := p.conns[key]; ok {
+			conn.refs++
+			p.mu.Unlock()
+			return conn.client, p.releaseFunc(key, conn), nil
+		}
+
+		if d, ok := p.dialing[key]; ok {
+			p.mu.Unlock()
+			<-d.done
+			if d.err != nil {
+				return nil, nil, d.err
+			}
+			// the connection may have been released in the meantime, look it up again.
+			continue
+		}
+
+		d := &jumpDial{done: make(chan struct{})}
+		p.dialing[key] = d
+		p.mu.Unlock()
+
+		conn, err := p.dial(chain)
+
+		p.mu.Lock()
+		delete(p.dialing, key)
+		if err == nil {
+			p.conns[key] = conn
+		}
+		p.mu.Unlock()
+		d.err = err
+		close(d.done)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// forget the connection if the jump host goes away, so that the next user reconnects.
+		go func() {
+			_ = conn.client.Wait()
+			p.mu.Lock()
+			if p.conns[key] == conn {
+				delete(p.conns, key)
+			}
+			p.mu.Unlock()
+		}()
+
+		hop := chain[len(chain)-1]
+		log.Debugf("connected to jump host %s@%s", hop.User, hop.address())
+		return conn.client, p.releaseFunc(key, conn), nil
+	}
+}
+
+// dial connects to the last host of chain through the shared connections to the others.
+func (p *jumpPool) dial(chain []*Config) (*jumpConn, error) {
+	hop := chain[len(chain)-1]
+
+	var (
+		client  *ssh.Client
+		release = func() {}
+		err     error
+	)
+	if len(chain) == 1 {
+		client, err = ssh.Dial("tcp", hop.address(), hop.clientConfig())
+	} else {
+		var parent *ssh.Client
+		if parent, release, err = p.acquire(chain[:len(chain)-1]); err != nil {
+			return nil, err
+		}
+		client, err = dialThrough(parent, hop)
+	}
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.address(), err)
+	}
+
+	return &jumpConn{client: client, refs: 1, release: release}, nil
+}
+
+func (p *jumpPool) releaseFunc(key string, conn *jumpConn) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			conn.refs--
+			closing := conn.refs == 0
+			if closing && p.conns[key] == conn {
+				delete(p.conns, key)
+			}
+			p.mu.Unlock()
+
+			if closing {
+				_ = conn.client.Close()
+				conn.release()
+			}
+		})
+	}
+}
+
+// dialThrough connects to c through an established connection, like ProxyJump of OpenSSH.
+func dialThrough(jump *ssh.Client, c *Config) (*ssh.Client, error) {
+	addr := c.address()
+	conn, err := jump.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, c.clientConfig())
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(ncc, chans, reqs), nil
+}
+
+// dialJump connects to c through its jump hosts, the shared jump connections are
+// released when the returned connection is closed.
+func dialJump(c *Config) (*ssh.Client, error) {
+	jump, release, err := jumps.acquire(c.JumpHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dialThrough(jump, c)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	go func() {
+		_ = client.Wait()
+		release()
+	}()
+
+	return client, nil
+}
@@ -0,0 +1,300 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an ssh server accepting a single password, it forwards
+// direct-tcpip channels so that it can be used as a jump host.
+type testServer struct {
+	addr        string
+	fingerprint string
+	conns       atomic.Int32
+	open        atomic.Int32
+}
+
+func newTestServer(t *testing.T, password string) *testServer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &testServer{addr: l.Addr().String(), fingerprint: ssh.FingerprintSHA256(signer.PublicKey())}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	s.conns.Add(1)
+	s.open.Add(1)
+	defer s.open.Add(-1)
+	go ssh.DiscardRequests(reqs)
+
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			_ = ch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &target); err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, creqs, err := ch.Accept()
+		if err != nil {
+			_ = upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(creqs)
+		go func() {
+			_, _ = io.Copy(channel, upstream)
+			_ = channel.CloseWrite()
+		}()
+		go func() {
+			_, _ = io.Copy(upstream, channel)
+			_ = upstream.Close()
+		}()
+	}
+	_ = sconn.Close()
+}
+
+func (s *testServer) config(t *testing.T, password string) *Config {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	c, err := createConfig("peta", host, uint(p), Credentials{Password: password}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.verifyHostKeys(HostKeyPinned, "", []string{s.fingerprint}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func (p *jumpPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+func TestDialJumpChain(t *testing.T) {
+	first := newTestServer(t, "first")
+	second := newTestServer(t, "second")
+	target := newTestServer(t, "target")
+
+	c := target.config(t, "target")
+	c.JumpHosts = []*Config{first.config(t, "first"), second.config(t, "second")}
+
+	client, err := Dial("tcp", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.conns.Load() != 1 || second.conns.Load() != 1 || target.conns.Load() != 1 {
+		t.Errorf("connections = %d, %d, %d, want one to each host",
+			first.conns.Load(), second.conns.Load(), target.conns.Load())
+	}
+
+	_ = client.Close()
+	waitFor(t, "the jump connections to be released", func() bool {
+		return jumps.size() == 0 && first.open.Load() == 0 && second.open.Load() == 0
+	})
+}
+
+func TestJumpPoolShares(t *testing.T) {
+	jump := newTestServer(t, "secret")
+	p := newJumpPool()
+
+	chain := []*Config{jump.config(t, "secret")}
+	a, releaseA, err := p.acquire(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, releaseB, err := p.acquire([]*Config{jump.config(t, "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != b {
+		t.Error("configs of the same identity should share the connection")
+	}
+	if got := jump.conns.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+
+	releaseA()
+	releaseA()
+	if p.size() != 1 {
+		t.Fatal("the connection should be kept while it is in use")
+	}
+
+	releaseB()
+	if p.size() != 0 {
+		t.Fatal("the connection should be forgotten once released by every user")
+	}
+	waitFor(t, "the jump connection to be closed", func() bool { return jump.open.Load() == 0 })
+}
+
+func TestJumpPoolIdentity(t *testing.T) {
+	jump := newTestServer(t, "secret")
+	p := newJumpPool()
+
+	_, release, err := p.acquire([]*Config{jump.config(t, "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, _, err := p.acquire([]*Config{jump.config(t, "wrong")}); err == nil {
+		t.Error("a wrong password should not get the authenticated connection")
+	}
+
+	insecure := jump.config(t, "secret")
+	if err := insecure.verifyHostKeys(HostKeyStrict, "/nonexistent/known_hosts", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.acquire([]*Config{insecure}); err == nil {
+		t.Error("another host key policy should not get the verified connection")
+	}
+
+	if got := jump.conns.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+}
+
+func TestJumpPoolDialsConcurrently(t *testing.T) {
+	// the slow jump host accepts connections but never answers the handshake.
+	slow, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = slow.Close() }()
+	stalledConns := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := slow.Accept(); err == nil {
+			stalledConns <- conn
+		}
+		close(stalledConns)
+	}()
+
+	jump := newTestServer(t, "secret")
+	p := newJumpPool()
+
+	stalled := jump.config(t, "secret")
+	stalled.Addr, stalled.Port = "127.0.0.1", uint(slow.Addr().(*net.TCPAddr).Port)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, _ = p.acquire([]*Config{stalled})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	_, release, err := p.acquire([]*Config{jump.config(t, "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("acquire took %s, it should not wait for another jump host", elapsed)
+	}
+
+	// the handshake has no timeout, hang up to let the stalled dial fail.
+	_ = slow.Close()
+	for conn := range stalledConns {
+		_ = conn.Close()
+	}
+	wg.Wait()
+}
@@ -32,8 +32,7 @@ func TestSSHRun(t *testing.T) {
 		"",
 		"",
 		0,
-		true,
-		false,
+		HostKeyAcceptNew,
 	)
 	if err != nil {
 		t.Fatal(fmt.Errorf("connect error: %w", err))
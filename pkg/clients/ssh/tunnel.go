@@ -0,0 +1,344 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"peta.io/peta/pkg/log"
+)
+
+// SOCKS5 protocol constants, see RFC 1928.
+const (
+	socksVersion        = 0x05
+	socksNoAuth         = 0x00
+	socksNoAcceptable   = 0xff
+	socksCmdConnect     = 0x01
+	socksAtypIPv4       = 0x01
+	socksAtypDomain     = 0x03
+	socksAtypIPv6       = 0x04
+	socksSucceeded      = 0x00
+	socksHostFailure    = 0x04
+	socksCmdNotSupport  = 0x07
+	socksAtypNotSupport = 0x08
+)
+
+// Tunnel forwards the connections accepted by a local listener through the
+// ssh connection, it is closed with the Client that opened it.
+type Tunnel struct {
+	listener net.Listener
+	dial     func(conn net.Conn) (net.Conn, error)
+	client   *Client
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+// DefaultLocalAddr is the address tunnels listen on when none is given, a random
+// port of the loopback interface.
+const DefaultLocalAddr = "127.0.0.1:0"
+
+// Forward listens on localAddr and forwards every connection to remoteAddr as seen
+// from the remote host, like ssh -L. An empty localAddr is DefaultLocalAddr, use
+// Tunnel.Addr to find the port. Like OpenSSH, a localAddr without a host listens
+// on the loopback interface, the connections are not authenticated so other
+// interfaces have to be asked for explicitly, like "0.0.0.0:8080".
+func (c *Client) Forward(localAddr, remoteAddr string) (*Tunnel, error) {
+	return c.listen(localAddr, func(net.Conn) (net.Conn, error) {
+		return c.Client.Dial("tcp", remoteAddr)
+	})
+}
+
+// DynamicForward listens on localAddr as a SOCKS5 proxy connecting through the
+// remote host, like ssh -D. Only the CONNECT command without authentication is
+// supported, so anyone reaching localAddr can connect through the remote host:
+// it listens on the loopback interface like Forward, unless asked otherwise.
+func (c *Client) DynamicForward(localAddr string) (*Tunnel, error) {
+	return c.listen(localAddr, c.socksConnect)
+}
+
+// DialContext connects to addr from the remote host, it can be used as the dialer
+// of higher-level clients such as database drivers.
+func (c *Client) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return c.Client.DialContext(ctx, network, addr)
+}
+
+// listenAddr returns the address to listen on for localAddr, the loopback interface
+// if localAddr has no host.
+func listenAddr(localAddr string) (string, error) {
+	if localAddr == "" {
+		return DefaultLocalAddr, nil
+	}
+	host, port, err := net.SplitHostPort(localAddr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Close closes the tunnels of the client and the ssh connection.
+func (c *Client) Close() error {
+	c.closeTunnels()
+	return c.Client.Close()
+}
+
+func (c *Client) listen(localAddr string, dial func(conn net.Conn) (net.Conn, error)) (*Tunnel, error) {
+	addr, err := listenAddr(localAddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{
+		listener: l,
+		dial:     dial,
+		client:   c,
+		conns:    map[net.Conn]struct{}{},
+		done:     make(chan struct{}),
+	}
+
+	c.tunnelsMu.Lock()
+	if c.tunnels == nil {
+		c.tunnels = map[*Tunnel]struct{}{}
+		// tunnels are useless once the connection is gone.
+		go func() {
+			_ = c.Client.Wait()
+			c.closeTunnels()
+		}()
+	}
+	c.tunnels[t] = struct{}{}
+	c.tunnelsMu.Unlock()
+
+	go t.serve()
+	return t, nil
+}
+
+func (c *Client) closeTunnels() {
+	c.tunnelsMu.Lock()
+	tunnels := make([]*Tunnel, 0, len(c.tunnels))
+	for t := range c.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	c.tunnelsMu.Unlock()
+
+	for _, t := range tunnels {
+		_ = t.Close()
+	}
+}
+
+// Addr returns the local address of the tunnel.
+func (t *Tunnel) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+// Done is closed when the tunnel is closed.
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// Close stops listening and closes the forwarded connections.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	conns := t.conns
+	t.conns = nil
+	t.mu.Unlock()
+
+	t.client.tunnelsMu.Lock()
+	delete(t.client.tunnels, t)
+	t.client.tunnelsMu.Unlock()
+
+	err := t.listener.Close()
+	for conn := range conns {
+		_ = conn.Close()
+	}
+	close(t.done)
+	return err
+}
+
+func (t *Tunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("ssh tunnel %s: %v", t.Addr(), err)
+				_ = t.Close()
+			}
+			return
+		}
+		go t.handle(conn)
+	}
+}
+
+func (t *Tunnel) handle(conn net.Conn) {
+	if !t.track(conn) {
+		_ = conn.Close()
+		return
+	}
+	defer t.untrack(conn)
+
+	remote, err := t.dial(conn)
+	if err != nil {
+		log.Debugf("ssh tunnel %s: %v", t.Addr(), err)
+		return
+	}
+	if !t.track(remote) {
+		_ = remote.Close()
+		return
+	}
+	defer t.untrack(remote)
+
+	pipe(conn, remote)
+}
+
+// track registers conn to be closed with the tunnel, false if already closed.
+func (t *Tunnel) track(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+func (t *Tunnel) untrack(conn net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+	_ = conn.Close()
+}
+
+// pipe copies between a and b until either side is done.
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+}
+
+// socksConnect negotiates a SOCKS5 CONNECT request on conn and dials its target
+// through the ssh connection.
+func (c *Client) socksConnect(conn net.Conn) (net.Conn, error) {
+	// greeting: VER NMETHODS METHODS
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+	if head[0] != socksVersion {
+		return nil, fmt.Errorf("unsupported socks version %d", head[0])
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return nil, err
+	}
+	if method == socksNoAcceptable {
+		return nil, errors.New("socks client requires authentication")
+	}
+
+	// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return nil, err
+	}
+	if req[1] != socksCmdConnect {
+		_ = socksReply(conn, socksCmdNotSupport)
+		return nil, fmt.Errorf("unsupported socks command %d", req[1])
+	}
+
+	var host string
+	switch req[3] {
+	case socksAtypIPv4, socksAtypIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if req[3] == socksAtypIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = ip.String()
+	case socksAtypDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return nil, err
+		}
+		domain := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, err
+		}
+		host = string(domain)
+	default:
+		_ = socksReply(conn, socksAtypNotSupport)
+		return nil, fmt.Errorf("unsupported socks address type %d", req[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	remote, err := c.Client.Dial("tcp", addr)
+	if err != nil {
+		_ = socksReply(conn, socksHostFailure)
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		_ = remote.Close()
+		return nil, err
+	}
+	return remote, nil
+}
+
+// socksReply answers a request, the bound address is not meaningful through ssh.
+func socksReply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socksVersion, rep, 0x00, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,95 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestSocksConnectRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   []byte
+		reply []byte
+	}{
+		{
+			name:  "authentication required",
+			req:   []byte{socksVersion, 1, 0x02},
+			reply: []byte{socksVersion, socksNoAcceptable},
+		},
+		{
+			name: "bind command",
+			req:  []byte{socksVersion, 1, socksNoAuth, socksVersion, 0x02, 0x00, socksAtypIPv4, 127, 0, 0, 1, 0, 80},
+			reply: []byte{socksVersion, socksNoAuth,
+				socksVersion, socksCmdNotSupport, 0x00, socksAtypIPv4, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := (&Client{}).socksConnect(server)
+				_ = server.Close()
+				errCh <- err
+			}()
+
+			go func() { _, _ = client.Write(tt.req) }()
+			got, _ := io.ReadAll(client)
+			if !bytes.Equal(got, tt.reply) {
+				t.Errorf("reply = %v, want %v", got, tt.reply)
+			}
+			if err := <-errCh; err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		localAddr string
+		want      string
+	}{
+		{localAddr: "", want: DefaultLocalAddr},
+		{localAddr: ":0", want: "127.0.0.1:0"},
+		{localAddr: ":8080", want: "127.0.0.1:8080"},
+		{localAddr: "0.0.0.0:1080", want: "0.0.0.0:1080"},
+		{localAddr: "[::1]:1080", want: "[::1]:1080"},
+	}
+
+	for _, tt := range tests {
+		got, err := listenAddr(tt.localAddr)
+		if err != nil {
+			t.Fatalf("listenAddr(%q): %v", tt.localAddr, err)
+		}
+		if got != tt.want {
+			t.Errorf("listenAddr(%q) = %q, want %q", tt.localAddr, got, tt.want)
+		}
+	}
+
+	if _, err := listenAddr("8080"); err == nil {
+		t.Error("an address without a port should be rejected")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"peta.io/peta/pkg/types/component"
+)
+
+const (
+	ConnectionSSH   = "ssh"
+	ConnectionLocal = "local"
+)
+
+// Executor runs commands and transfers files on a host.
+type Executor interface {
+	// Run runs cmd with /bin/sh and streams its combined output to out.
+	Run(ctx context.Context, cmd string, out io.Writer) error
+	// Upload writes the content of src to dst with the given mode.
+	Upload(ctx context.Context, src io.Reader, dst string, mode os.FileMode) error
+	// Download streams the content of src to dst.
+	Download(ctx context.Context, src string, dst io.Writer) error
+	// Facts returns what is known about the host.
+	Facts(ctx context.Context) (*Facts, error)
+	// DialContext connects to addr as seen from the host, for clients such as
+	// database drivers reaching services that only listen internally.
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	Close() error
+}
+
+// New returns the executor of a host, hosts with the local connection or the
+// address localhost run on the current machine, the others through ssh.
+func New(h *component.Host) (Executor, error) {
+	if IsLocal(h) {
+		return NewLocal(h), nil
+	}
+	return NewSSH(h)
+}
+
+// NewContext is like New, giving up connecting when ctx is done. The commands
+// are recorded if ctx has a transcript recorder.
+func NewContext(ctx context.Context, h *component.Host) (Executor, error) {
+	if IsLocal(h) {
+		return recordContext(ctx, NewLocal(h), h), nil
+	}
+	e, err := NewSSHContext(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return recordContext(ctx, e, h), nil
+}
+
+// IsLocal returns true if the commands of the host run on the current machine.
+func IsLocal(h *component.Host) bool {
+	switch h.Connection {
+	case ConnectionLocal:
+		return true
+	case ConnectionSSH:
+		return false
+	default:
+		return h.Address == "localhost"
+	}
+}
+
+// Output runs cmd and returns its combined output.
+func Output(ctx context.Context, e Executor, cmd string) ([]byte, error) {
+	var out bytes.Buffer
+	err := e.Run(ctx, cmd, &out)
+	return out.Bytes(), err
+}
+
+// ExitStatus returns the exit status of the command which returned err, 0 if
+// err is nil and -1 if the command did not exit normally.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	var statusErr interface{ ExitStatus() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.ExitStatus()
+	}
+
+	return -1
+}
+
+// Name returns the name of a host, its address if it has none.
+func Name(h *component.Host) string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Address
+}
+
+func trimOutput(out []byte) string {
+	return strings.TrimSpace(string(out))
+}
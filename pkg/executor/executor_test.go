@@ -0,0 +1,113 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"peta.io/peta/pkg/types/component"
+)
+
+func TestLocalExecutor(t *testing.T) {
+	ctx := context.Background()
+	h := &component.Host{Name: "local", Address: "localhost"}
+	if !IsLocal(h) {
+		t.Fatal("localhost should be local")
+	}
+
+	e, err := New(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = e.Close() }()
+
+	out, err := Output(ctx, e, "echo hello; echo oops >&2; exit 3")
+	if ExitStatus(err) != 3 {
+		t.Errorf("exit status got %d, want 3 (%v)", ExitStatus(err), err)
+	}
+	if !strings.Contains(string(out), "hello") || !strings.Contains(string(out), "oops") {
+		t.Errorf("unexpected output: %q", out)
+	}
+
+	dst := filepath.Join(t.TempDir(), "file")
+	if err := e.Upload(ctx, strings.NewReader("content"), dst, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(dst); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("unexpected uploaded file: %v %v", fi, err)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Download(ctx, dst, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "content" {
+		t.Errorf("downloaded %q, want %q", buf.String(), "content")
+	}
+
+	facts, err := e.Facts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if facts.Hostname == "" || facts.Arch == "" {
+		t.Errorf("incomplete facts: %+v", facts)
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	facts, err := parseFacts("pg-node1\r\nx86_64\r\n5.14.0\r\nNAME=\"Rocky Linux\"\r\nID=\"rocky\"\r\nID_LIKE=\"rhel centos fedora\"\r\nVERSION_ID=\"9.4\"\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Facts{Hostname: "pg-node1", Arch: "x86_64", Kernel: "5.14.0", OS: "rocky", OSLike: "rhel centos fedora", OSVersion: "9.4"}
+	if *facts != want {
+		t.Errorf("got %+v, want %+v", *facts, want)
+	}
+}
+
+func TestLocalUploadBecome(t *testing.T) {
+	// a fake sudo records the modes of the staged upload before running the command
+	bin, tmp := t.TempDir(), t.TempDir()
+	modes := filepath.Join(bin, "modes")
+	sudo := "#!/bin/sh\nstat -c %a \"$TMPDIR\"/.peta-upload-* \"$TMPDIR\"/.peta-upload-*/content > " + modes + "\nshift 4\nexec \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(sudo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TMPDIR", tmp)
+
+	e := NewLocal(&component.Host{Become: &component.Become{}})
+	dst := filepath.Join(t.TempDir(), "file")
+	if err := e.Upload(context.Background(), strings.NewReader("secret"), dst, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "secret" {
+		t.Errorf("uploaded %q, %v", b, err)
+	}
+	if b, err := os.ReadFile(modes); err != nil || string(b) != "700\n600\n" {
+		t.Errorf("staged the upload with the modes %q, %v, want 700 and 600", b, err)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(tmp, ".peta-upload-*")); len(staged) > 0 {
+		t.Errorf("left %v", staged)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Facts are what is known about a host.
+type Facts struct {
+	Hostname string `json:"hostname"`
+	// Arch is the machine hardware name, like x86_64 or aarch64.
+	Arch   string `json:"arch"`
+	Kernel string `json:"kernel"`
+	// OS is the ID of /etc/os-release, like ubuntu or rocky.
+	OS string `json:"os"`
+	// OSLike is the ID_LIKE of /etc/os-release, like "rhel centos fedora".
+	OSLike    string `json:"osLike,omitempty"`
+	OSVersion string `json:"osVersion"`
+	OSName    string `json:"osName,omitempty"`
+}
+
+const factsScript = `uname -n; uname -m; uname -r; cat /etc/os-release 2>/dev/null || true`
+
+// gatherFacts collects the facts of the host e runs on.
+func gatherFacts(ctx context.Context, e Executor) (*Facts, error) {
+	out, err := Output(ctx, e, factsScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather facts: %w: %s", err, trimOutput(out))
+	}
+	return parseFacts(string(out))
+}
+
+func parseFacts(out string) (*Facts, error) {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("unexpected facts output: %q", out)
+	}
+
+	f := &Facts{
+		Hostname: lines[0],
+		Arch:     lines[1],
+		Kernel:   lines[2],
+	}
+
+	release := ParseOSRelease(strings.Join(lines[3:], "\n"))
+	f.OS = release["ID"]
+	f.OSLike = release["ID_LIKE"]
+	f.OSVersion = release["VERSION_ID"]
+	f.OSName = release["PRETTY_NAME"]
+
+	return f, nil
+}
+
+// ParseOSRelease parses the KEY=value lines of /etc/os-release.
+func ParseOSRelease(content string) map[string]string {
+	release := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		release[key] = strings.Trim(value, `"'`)
+	}
+	return release
+}
@@ -0,0 +1,195 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"peta.io/peta/pkg/clients/ssh"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+var _ Executor = &localExecutor{}
+
+// localExecutor runs on the current machine, as the current user unless the host has a become method.
+type localExecutor struct {
+	become *ssh.Become
+}
+
+// NewLocal returns the executor of the current machine.
+func NewLocal(h *component.Host) Executor {
+	e := &localExecutor{}
+	if h != nil && h.Become != nil {
+		e.become = &ssh.Become{
+			Method:   ssh.BecomeMethod(h.Become.Method),
+			User:     h.Become.User,
+			Password: h.Become.Password,
+		}
+	}
+	return e
+}
+
+func (e *localExecutor) Run(ctx context.Context, cmd string, out io.Writer) error {
+	w := &syncWriter{w: out}
+	return e.run(ctx, cmd, w, w)
+}
+
+// run runs cmd with the become method if any, the password prompt is expected on stderr.
+func (e *localExecutor) run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	if e.become == nil {
+		c := exec.CommandContext(ctx, "/bin/sh", "-c", cmd)
+		c.Stdout = stdout
+		c.Stderr = stderr
+		return c.Run()
+	}
+
+	if e.become.Method == ssh.BecomeSu {
+		return errors.New("become method su requires a terminal, use sudo for local hosts")
+	}
+
+	wrapped, prompt, err := e.become.Wrap(cmd)
+	if err != nil {
+		return err
+	}
+
+	c := exec.CommandContext(ctx, "/bin/sh", "-c", wrapped)
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return err
+	}
+	pw := ssh.NewPromptWriter(stderr, stdin, prompt, e.become.Password)
+	if stdout == stderr {
+		c.Stdout = pw
+	} else {
+		c.Stdout = stdout
+	}
+	c.Stderr = pw
+
+	err = c.Run()
+	if fErr := pw.Flush(); fErr != nil && err == nil {
+		err = fErr
+	}
+	return err
+}
+
+// Upload writes the content of src to dst with the given mode. With a become method
+// the content is first staged in a private temporary directory of the current user
+// and then installed to dst as the become user. A become user other than root is
+// granted access to the staged file with an ACL, the modes are never widened.
+func (e *localExecutor) Upload(ctx context.Context, src io.Reader, dst string, mode os.FileMode) error {
+	if e.become == nil {
+		return writeFile(src, dst, mode)
+	}
+
+	// MkdirTemp creates the directory with the mode 0700
+	dir, err := os.MkdirTemp("", ".peta-upload-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmp := filepath.Join(dir, "content")
+	if err := writeFile(src, tmp, 0600); err != nil {
+		return err
+	}
+
+	if user := e.become.User; user != "" && user != ssh.DefaultBecomeUser {
+		for _, acl := range [][2]string{{"u:" + user + ":x", dir}, {"u:" + user + ":r", tmp}} {
+			if out, err := exec.CommandContext(ctx, "setfacl", "-m", acl[0], acl[1]).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to grant %s access to the staged %s: %w: %s", user, dst, err, trimOutput(out))
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	install := fmt.Sprintf("install -m %04o %s %s", mode.Perm(), shellutils.Quote(tmp), shellutils.Quote(dst))
+	if err := e.run(ctx, install, &out, &out); err != nil {
+		return fmt.Errorf("failed to install %s: %w: %s", dst, err, trimOutput(out.Bytes()))
+	}
+	return nil
+}
+
+func (e *localExecutor) Download(ctx context.Context, src string, dst io.Writer) error {
+	if e.become == nil {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(dst, f)
+		return err
+	}
+
+	var stderr bytes.Buffer
+	if err := e.run(ctx, fmt.Sprintf("cat %s", shellutils.Quote(src)), dst, &stderr); err != nil {
+		return fmt.Errorf("%w: %s", err, trimOutput(stderr.Bytes()))
+	}
+	return nil
+}
+
+func (e *localExecutor) Facts(ctx context.Context) (*Facts, error) {
+	return gatherFacts(ctx, e)
+}
+
+func (e *localExecutor) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+func (e *localExecutor) Close() error {
+	return nil
+}
+
+func writeFile(src io.Reader, dst string, mode os.FileMode) (err error) {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := f.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+
+	if _, err = io.Copy(f, src); err != nil {
+		return err
+	}
+	// the mode of an existing file is not changed by OpenFile.
+	return f.Chmod(mode.Perm())
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
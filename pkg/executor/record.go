@@ -0,0 +1,119 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"peta.io/peta/pkg/log"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types/component"
+)
+
+// recordingExecutor records a transcript of every command run by the wrapped executor.
+type recordingExecutor struct {
+	Executor
+	host     *component.Host
+	recorder transcript.Recorder
+	secrets  []string
+}
+
+// WithRecorder returns e recording the commands run on h with r.
+func WithRecorder(e Executor, h *component.Host, r transcript.Recorder) Executor {
+	secrets := []string{h.Password}
+	if h.Become != nil {
+		secrets = append(secrets, h.Become.Password)
+	}
+	for _, j := range h.JumpHosts {
+		secrets = append(secrets, j.Password)
+	}
+	return &recordingExecutor{Executor: e, host: h, recorder: r, secrets: secrets}
+}
+
+func (e *recordingExecutor) Run(ctx context.Context, cmd string, out io.Writer) error {
+	t := &transcript.Transcript{
+		OperationID: transcript.OperationFrom(ctx),
+		Host:        Name(e.host),
+		Address:     e.host.Address,
+		User:        e.host.User,
+		Command:     transcript.Redact(cmd, e.secrets...),
+		StartedAt:   time.Now(),
+	}
+	if e.host.Become != nil {
+		t.BecomeUser = e.host.Become.User
+	}
+
+	tail := &tailBuffer{max: transcript.MaxOutputSize}
+	err := e.Executor.Run(ctx, cmd, io.MultiWriter(out, tail))
+
+	t.FinishedAt = time.Now()
+	t.ExitCode = ExitStatus(err)
+	if err != nil && t.ExitCode < 0 {
+		t.Error = transcript.Redact(err.Error(), e.secrets...)
+	}
+	t.Output, t.Truncated = tail.String()
+	t.Output = transcript.Redact(t.Output, e.secrets...)
+
+	// the command is not failed by the audit, the context may already be done.
+	if rErr := e.recorder.Record(context.WithoutCancel(ctx), t); rErr != nil {
+		log.Errorf("failed to record transcript of %s on %s: %v", t.Command, t.Host, rErr)
+	}
+
+	return err
+}
+
+// recordContext wraps e with the recorder of ctx if any.
+func recordContext(ctx context.Context, e Executor, h *component.Host) Executor {
+	if r := transcript.RecorderFrom(ctx); r != nil {
+		return WithRecorder(e, h, r)
+	}
+	return e
+}
+
+// tailBuffer keeps the last max bytes written.
+type tailBuffer struct {
+	mu        sync.Mutex
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	// trim lazily to copy less often.
+	if len(b.buf) > 2*b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the kept output and whether the beginning was dropped.
+func (b *tailBuffer) String() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf) > b.max {
+		return string(b.buf[len(b.buf)-b.max:]), true
+	}
+	return string(b.buf), b.truncated
+}
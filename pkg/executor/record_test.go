@@ -0,0 +1,69 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types/component"
+)
+
+type memoryRecorder []*transcript.Transcript
+
+func (m *memoryRecorder) Record(_ context.Context, t *transcript.Transcript) error {
+	*m = append(*m, t)
+	return nil
+}
+
+func TestRecordingExecutor(t *testing.T) {
+	var rec memoryRecorder
+	h := &component.Host{Name: "local", Address: "localhost", User: "peta", Password: "hunter2"}
+	ctx := transcript.WithRecorder(transcript.WithOperation(context.Background(), "op-1"), &rec)
+
+	e, err := NewContext(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = e.Close() }()
+
+	err = e.Run(ctx, "echo hunter2; head -c 70000 /dev/zero | tr '\\0' x; exit 2", io.Discard)
+	if ExitStatus(err) != 2 {
+		t.Fatalf("exit status got %d, want 2 (%v)", ExitStatus(err), err)
+	}
+
+	if len(rec) != 1 {
+		t.Fatalf("got %d transcripts, want 1", len(rec))
+	}
+	got := rec[0]
+	if got.OperationID != "op-1" || got.Host != "local" || got.User != "peta" || got.ExitCode != 2 {
+		t.Errorf("unexpected transcript: %+v", got)
+	}
+	if strings.Contains(got.Command, "hunter2") || !strings.HasPrefix(got.Command, "echo ******;") {
+		t.Errorf("secret not redacted: %q", got.Command)
+	}
+	if !got.Truncated || len(got.Output) != transcript.MaxOutputSize {
+		t.Errorf("output not truncated: %d bytes, truncated %v", len(got.Output), got.Truncated)
+	}
+	if got.FinishedAt.Before(got.StartedAt) {
+		t.Errorf("finished %v before started %v", got.FinishedAt, got.StartedAt)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"peta.io/peta/pkg/clients/ssh"
+	"peta.io/peta/pkg/types/component"
+)
+
+var _ Executor = &sshExecutor{}
+
+// sshExecutor runs on a remote host through ssh.
+type sshExecutor struct {
+	client *ssh.Client
+}
+
+// NewSSH connects to the host with ssh.
+func NewSSH(h *component.Host) (Executor, error) {
+	client, err := ssh.NewForHost(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", Name(h), err)
+	}
+	return &sshExecutor{client: client}, nil
+}
+
+// NewSSHContext connects to the host with ssh, giving up when ctx is done.
+func NewSSHContext(ctx context.Context, h *component.Host) (Executor, error) {
+	type dialed struct {
+		e   Executor
+		err error
+	}
+
+	ch := make(chan dialed, 1)
+	go func() {
+		e, err := NewSSH(h)
+		ch <- dialed{e, err}
+	}()
+
+	select {
+	case d := <-ch:
+		return d.e, d.err
+	case <-ctx.Done():
+		// close the connection if it is established after all.
+		go func() {
+			if d := <-ch; d.err == nil {
+				_ = d.e.Close()
+			}
+		}()
+		return nil, fmt.Errorf("failed to connect to %s: %w", Name(h), ctx.Err())
+	}
+}
+
+func (e *sshExecutor) Run(ctx context.Context, cmd string, out io.Writer) error {
+	return e.client.RunContext(ctx, cmd, out)
+}
+
+func (e *sshExecutor) Upload(_ context.Context, src io.Reader, dst string, mode os.FileMode) error {
+	return e.client.Upload(src, dst, mode)
+}
+
+func (e *sshExecutor) Download(_ context.Context, src string, dst io.Writer) error {
+	return e.client.Download(src, dst)
+}
+
+func (e *sshExecutor) Facts(ctx context.Context) (*Facts, error) {
+	return gatherFacts(ctx, e)
+}
+
+func (e *sshExecutor) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return e.client.DialContext(ctx, network, addr)
+}
+
+func (e *sshExecutor) Close() error {
+	return e.client.Close()
+}
@@ -0,0 +1,229 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Operator is the relation between a label and its values in a requirement.
+type Operator string
+
+const (
+	Equals       Operator = "="
+	DoubleEquals Operator = "=="
+	NotEquals    Operator = "!="
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+// Requirement is a single condition of a selector, like env=prod.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Matches returns true if the labels satisfy the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, DoubleEquals:
+		return ok && value == r.Values[0]
+	case NotEquals:
+		return !ok || value != r.Values[0]
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case In:
+		return ok && slices.Contains(r.Values, value)
+	case NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	default:
+		return r.Key + string(r.Operator) + r.Values[0]
+	}
+}
+
+// Selector selects objects by their labels, all the requirements must be satisfied.
+// An empty selector selects everything.
+type Selector []Requirement
+
+// Everything returns a selector matching all labels.
+func Everything() Selector {
+	return Selector{}
+}
+
+// Matches returns true if the labels satisfy all the requirements of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns true if the selector has no requirement.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parse parses a comma separated list of requirements, like
+// "env=prod,role!=replica,ssd,!arm,zone in (a,b),arch notin (arm64)".
+func Parse(selector string) (Selector, error) {
+	s := Selector{}
+	parts, err := split(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// split splits the selector at the commas outside of the value sets.
+func split(selector string) ([]string, error) {
+	var parts []string
+	start, open := 0, false
+	for i, c := range selector {
+		switch c {
+		case '(':
+			if open {
+				return nil, fmt.Errorf("unexpected '(' in selector %q", selector)
+			}
+			open = true
+		case ')':
+			if !open {
+				return nil, fmt.Errorf("unexpected ')' in selector %q", selector)
+			}
+			open = false
+		case ',':
+			if !open {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if open {
+		return nil, fmt.Errorf("missing ')' in selector %q", selector)
+	}
+	return append(parts, selector[start:]), nil
+}
+
+// parseSet parses the set-based requirements, like "zone in (a,b)", found is false
+// for the other requirements.
+func parseSet(part string) (r Requirement, found bool, err error) {
+	head, values, found := strings.Cut(part, "(")
+	if !found {
+		return Requirement{}, false, nil
+	}
+	values, found = strings.CutSuffix(strings.TrimSpace(values), ")")
+	fields := strings.Fields(head)
+	if !found || len(fields) != 2 || (fields[1] != string(In) && fields[1] != string(NotIn)) {
+		return Requirement{}, true, fmt.Errorf("invalid requirement %q", part)
+	}
+	if err := validateKey(fields[0]); err != nil {
+		return Requirement{}, true, err
+	}
+
+	r = Requirement{Key: fields[0], Operator: Operator(fields[1])}
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			r.Values = append(r.Values, value)
+		}
+	}
+	if len(r.Values) == 0 {
+		return Requirement{}, true, fmt.Errorf("requirement %q has no values", part)
+	}
+	return r, true, nil
+}
+
+func parseRequirement(part string) (Requirement, error) {
+	if r, found, err := parseSet(part); found {
+		return r, err
+	}
+
+	for _, op := range []Operator{NotEquals, DoubleEquals, Equals} {
+		if key, value, found := strings.Cut(part, string(op)); found {
+			key = strings.TrimSpace(key)
+			if err := validateKey(key); err != nil {
+				return Requirement{}, err
+			}
+			return Requirement{Key: key, Operator: op, Values: []string{strings.TrimSpace(value)}}, nil
+		}
+	}
+
+	if key, found := strings.CutPrefix(part, "!"); found {
+		key = strings.TrimSpace(key)
+		if err := validateKey(key); err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+
+	if err := validateKey(part); err != nil {
+		return Requirement{}, err
+	}
+	return Requirement{Key: part, Operator: Exists}, nil
+}
+
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("label key must not be empty")
+	}
+	if strings.ContainsAny(key, " =!(),") {
+		return fmt.Errorf("invalid label key: %q", key)
+	}
+	return nil
+}
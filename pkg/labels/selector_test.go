@@ -0,0 +1,70 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package labels
+
+import "testing"
+
+func TestSelector(t *testing.T) {
+	hostLabels := map[string]string{"role": "replica", "env": "prod", "ssd": ""}
+
+	for name, test := range map[string]struct {
+		selector string
+		expected bool
+	}{
+		"empty":          {selector: "", expected: true},
+		"equals":         {selector: "role=replica", expected: true},
+		"double equals":  {selector: "role==replica", expected: true},
+		"not equals":     {selector: "role!=primary", expected: true},
+		"missing key":    {selector: "zone!=a", expected: true},
+		"exists":         {selector: "ssd", expected: true},
+		"does not exist": {selector: "!arm", expected: true},
+		"all":            {selector: "role=replica, env=prod", expected: true},
+		"one mismatch":   {selector: "role=replica,env=dev", expected: false},
+		"absent":         {selector: "arm", expected: false},
+		"in":             {selector: "env in (dev, prod)", expected: true},
+		"not in":         {selector: "env notin (dev,test)", expected: true},
+		"in mismatch":    {selector: "env in (dev),role=replica", expected: false},
+		"in missing key": {selector: "zone in (a)", expected: false},
+		"notin missing":  {selector: "zone notin (a,b),env=prod", expected: true},
+		"notin mismatch": {selector: "role=replica,env notin (prod)", expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse(test.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := s.Matches(hostLabels); actual != test.expected {
+				t.Errorf("%q matches got %v, want %v", test.selector, actual, test.expected)
+			}
+		})
+	}
+
+	for _, selector := range []string{"=prod", "env in (a", "env in a)", "env in ()", "env within (a)", "in (a)", "env in (a) b"} {
+		if _, err := Parse(selector); err == nil {
+			t.Errorf("expected error for %q", selector)
+		}
+	}
+
+	s, err := Parse("zone in (a, b),env!=dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); got != "zone in (a,b),env!=dev" {
+		t.Errorf("got %q", got)
+	}
+}
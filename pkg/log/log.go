@@ -89,6 +89,10 @@ func Setup() {
 }
 
 func init() {
+	// log to stderr until Setup is called, e.g. in tests.
+	log.FieldLogger = logrus.StandardLogger()
+	log.Flush = func() {}
+
 	commandLine.BoolVar(&log.Verbosity, "v", false, "If true, allows Debug() and Trace() to be logged")
 	commandLine.BoolVar(&log.Verbosity, "verbosity", false, "If true, allows Debug() and Trace() to be logged")
 	commandLine.BoolVar(&log.FullTimestamp, "full-timestamp", true, "If true, enable logging the full timestamp")
@@ -203,6 +203,7 @@ func ensureBridge(h netlinksafe.Handle, brName string, mtu int, promiscMode, vla
 	linkAttrs := netlink.NewLinkAttrs()
 	linkAttrs.Name = brName
 	linkAttrs.MTU = mtu
+	linkAttrs.Alias = network.LinkAlias
 	br := &netlink.Bridge{
 		LinkAttrs: linkAttrs,
 	}
@@ -213,7 +214,8 @@ func ensureBridge(h netlinksafe.Handle, brName string, mtu int, promiscMode, vla
 	err := h.LinkAdd(br)
 	if err != nil {
 		if errors.Is(err, unix.EEXIST) {
-			// Modify the exist bridge
+			// Modify the exist bridge, it keeps its alias since peta did not create it
+			br.Alias = ""
 			err := h.LinkModify(br)
 			if err != nil {
 				return nil, fmt.Errorf("could not modify %q: %w", brName, err)
@@ -25,6 +25,9 @@ import (
 	"os"
 )
 
+// LinkAlias is the alias of the links created by peta, peta init reset removes them.
+const LinkAlias = "peta"
+
 // IPNet is like net.IPNet but adds JSON marshalling and unmarshalling
 type IPNet net.IPNet
 
@@ -22,6 +22,7 @@ import (
 	"runtime"
 
 	"github.com/vishvananda/netlink"
+	"peta.io/peta/pkg/network"
 	"peta.io/peta/pkg/network/ip"
 	"peta.io/peta/pkg/network/netlinksafe"
 )
@@ -73,6 +74,7 @@ func CreateVlan(h netlinksafe.Handle, ifName string, c *Conf) (*netlink.Vlan, er
 	linkAttrs.MTU = c.MTU
 	linkAttrs.Name = ifName
 	linkAttrs.ParentIndex = m.Attrs().Index
+	linkAttrs.Alias = network.LinkAlias
 
 	v := &netlink.Vlan{
 		LinkAttrs: linkAttrs,
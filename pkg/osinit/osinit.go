@@ -0,0 +1,237 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package osinit prepares the hosts of a blueprint to run the components.
+package osinit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+// Status of a step on a host.
+type Status string
+
+const (
+	// StatusOK means the host was already in the desired state.
+	StatusOK      Status = "ok"
+	StatusChanged Status = "changed"
+	StatusFailed  Status = "failed"
+	// StatusSkipped means the step did not run since a previous step failed.
+	StatusSkipped Status = "skipped"
+	// StatusPending means a dry run found actions to run.
+	StatusPending Status = "pending"
+)
+
+// changedMarker is printed by the script of a step when it changed the host.
+const changedMarker = "__PETA_CHANGED__"
+
+// actionMarker leads the lines of the script of a step reporting an action.
+const actionMarker = "__PETA_ACTION__"
+
+// Step is an idempotent change applied to a host by a shell script.
+type Step struct {
+	Name   string
+	Script string
+}
+
+// StepResult is the outcome of a step on a host.
+type StepResult struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	// Actions are the commands the step ran, or would run in a dry run.
+	Actions []string `json:"actions,omitempty"`
+}
+
+// Result is the outcome of all steps on a host.
+type Result struct {
+	Host  string       `json:"host"`
+	Error string       `json:"error,omitempty"`
+	Steps []StepResult `json:"steps"`
+}
+
+// Failed returns true if the host could not be reached or a step failed.
+func (r *Result) Failed() bool {
+	if r.Error != "" {
+		return true
+	}
+	for _, s := range r.Steps {
+		if s.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Options of preparing hosts.
+type Options struct {
+	// Concurrency is the maximum number of hosts prepared at the same time.
+	Concurrency int
+	// Timeout bounds the connection and all steps on each host.
+	Timeout time.Duration
+	// Output receives a line for each step when it is done, nil discards them.
+	Output io.Writer
+}
+
+// Run applies the steps of the blueprint to the hosts and returns the results in the order of hosts.
+// The hosts of the blueprint which are not selected are still added to /etc/hosts.
+func Run(ctx context.Context, b *types.Blueprint, hosts []component.Host, o Options) []Result {
+	if o.Timeout <= 0 {
+		o.Timeout = runner.DefaultTimeout
+	}
+
+	var output io.Writer = io.Discard
+	if o.Output != nil {
+		output = &syncWriter{w: o.Output}
+	}
+
+	entries := HostsEntries(b.Hosts())
+	results := make([]Result, len(hosts))
+	runner.ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		results[i] = runOnHost(ctx, h, Steps(h, entries, b.OS()), o.Timeout, output)
+	})
+
+	return results
+}
+
+func runOnHost(ctx context.Context, h *component.Host, steps []Step, timeout time.Duration, output io.Writer) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r := Result{Host: executor.Name(h)}
+
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		r.Error = err.Error()
+		_, _ = fmt.Fprintf(output, "[%s] %v\n", r.Host, err)
+		return r
+	}
+	defer func() { _ = e.Close() }()
+
+	failed := false
+	for _, step := range steps {
+		if failed {
+			r.Steps = append(r.Steps, StepResult{Name: step.Name, Status: StatusSkipped})
+			continue
+		}
+
+		sr := runStep(ctx, e, step)
+		r.Steps = append(r.Steps, sr)
+		failed = sr.Status == StatusFailed
+
+		if sr.Error != "" {
+			_, _ = fmt.Fprintf(output, "[%s] %s: %s: %s\n", r.Host, sr.Name, sr.Status, sr.Error)
+		} else {
+			_, _ = fmt.Fprintf(output, "[%s] %s: %s\n", r.Host, sr.Name, sr.Status)
+		}
+	}
+
+	return r
+}
+
+func runStep(ctx context.Context, e executor.Executor, step Step) StepResult {
+	start := time.Now()
+	var out bytes.Buffer
+	err := e.Run(ctx, step.Script, &out)
+
+	r := StepResult{Name: step.Name, Status: StatusOK, Duration: time.Since(start)}
+
+	output := strings.ReplaceAll(out.String(), "\r", "")
+	for _, line := range strings.Split(output, "\n") {
+		if action, ok := strings.CutPrefix(line, actionMarker+" "); ok {
+			r.Actions = append(r.Actions, action)
+		}
+	}
+	if strings.Contains(output, changedMarker) {
+		r.Status = StatusChanged
+	} else if len(r.Actions) > 0 {
+		r.Status = StatusPending
+	}
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+		if msg := lastLine(output); msg != "" {
+			r.Error = msg
+		}
+	}
+
+	return r
+}
+
+// lastLine returns the last non-empty line which is not the changed marker.
+func lastLine(output string) string {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && line != changedMarker && !strings.HasPrefix(line, actionMarker) {
+			return line
+		}
+	}
+	return ""
+}
+
+// PrintReport writes a table of the status of each step on each host.
+func PrintReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tSTEP\tSTATUS\tDURATION\tERROR")
+	for _, r := range results {
+		if r.Error != "" {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t%s\t-\t%s\n", r.Host, StatusFailed, r.Error)
+			continue
+		}
+		for _, s := range r.Steps {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				r.Host, s.Name, s.Status, s.Duration.Round(time.Millisecond), s.Error)
+		}
+	}
+	return tw.Flush()
+}
+
+// Failed returns the number of hosts which were not fully prepared.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
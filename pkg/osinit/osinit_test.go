@@ -0,0 +1,165 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package osinit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+func TestHostsEntries(t *testing.T) {
+	hosts := []component.Host{
+		{Name: "pg-1.example.com", Address: "10.0.0.1", InternalAddress: "192.168.0.1"},
+		{Name: "pg-2", Address: "10.0.0.2"},
+		{Name: "local", Address: "localhost"},
+		{Address: "10.0.0.4"},
+	}
+	want := []string{"192.168.0.1 pg-1.example.com pg-1", "10.0.0.2 pg-2"}
+	if got := HostsEntries(hosts); !reflect.DeepEqual(got, want) {
+		t.Errorf("HostsEntries() = %v, want %v", got, want)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	off := false
+	c := types.DefaultOSConfig()
+	c.DisableSwap = &off
+
+	var names []string
+	for _, s := range Steps(&component.Host{Name: "pg-1"}, []string{"10.0.0.1 pg-1"}, c) {
+		names = append(names, s.Name)
+	}
+	want := []string{"hostname", "hosts", "time-sync", "kernel-modules", "sysctl", "users"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("steps = %v, want %v", names, want)
+	}
+
+	if s := hostnameStep("Not_Valid"); runStep(context.Background(), executor.NewLocal(nil), s).Status != StatusFailed {
+		t.Error("invalid hostname should fail")
+	}
+}
+
+func TestHostsStepIdempotent(t *testing.T) {
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	defer func() { hostsFile = "/etc/hosts" }()
+
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	e := executor.NewLocal(nil)
+	for i, want := range []Status{StatusChanged, StatusOK} {
+		if r := runStep(ctx, e, hostsStep([]string{"10.0.0.1 pg-1"})); r.Status != want {
+			t.Errorf("run %d: status %s (%s), want %s", i, r.Status, r.Error, want)
+		}
+	}
+
+	// a changed entry replaces the block.
+	if r := runStep(ctx, e, hostsStep([]string{"10.0.0.2 pg-1"})); r.Status != StatusChanged {
+		t.Errorf("status %s (%s), want %s", r.Status, r.Error, StatusChanged)
+	}
+
+	got, _ := os.ReadFile(hostsFile)
+	want := "127.0.0.1 localhost\n# BEGIN peta\n10.0.0.2 pg-1\n# END peta\n"
+	if string(got) != want {
+		t.Errorf("hosts file = %q, want %q", got, want)
+	}
+}
+
+func TestResetSteps(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile = filepath.Join(dir, "hosts")
+	modulesFile = filepath.Join(dir, "modules.conf")
+	defer func() { hostsFile, modulesFile = "/etc/hosts", "/etc/modules-load.d/peta.conf" }()
+
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1 localhost\n# BEGIN peta\n10.0.0.1 pg-1\n# END peta\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(modulesFile, []byte("8021q\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	e := executor.NewLocal(nil)
+
+	// a dry run lists the actions without running them.
+	var r StepResult
+	for _, s := range ResetSteps(&types.Blueprint{}, ResetOptions{DryRun: true}) {
+		if s.Name == "files" {
+			r = runStep(ctx, e, s)
+		}
+	}
+	if r.Status != StatusPending || len(r.Actions) != 2 {
+		t.Fatalf("dry run: status %s (%s), actions %v", r.Status, r.Error, r.Actions)
+	}
+	if _, err := os.Stat(modulesFile); err != nil {
+		t.Errorf("dry run removed %s", modulesFile)
+	}
+
+	for i, want := range []Status{StatusChanged, StatusOK} {
+		if r := runStep(ctx, e, filesResetStep()); r.Status != want {
+			t.Errorf("run %d: status %s (%s), want %s", i, r.Status, r.Error, want)
+		}
+	}
+	got, _ := os.ReadFile(hostsFile)
+	if string(got) != "127.0.0.1 localhost\n" {
+		t.Errorf("hosts file = %q", got)
+	}
+	if _, err := os.Stat(modulesFile); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed", modulesFile)
+	}
+}
+
+func TestResetUsers(t *testing.T) {
+	usersFile = filepath.Join(t.TempDir(), "users.created")
+	defer func() { usersFile = "/var/lib/peta/users.created" }()
+
+	ctx := context.Background()
+	e := executor.NewLocal(nil)
+	usersStep := func() Step {
+		for _, s := range ResetSteps(&types.Blueprint{}, ResetOptions{DryRun: true}) {
+			if s.Name == "users" {
+				return s
+			}
+		}
+		t.Fatal("no users step")
+		return Step{}
+	}
+
+	if r := runStep(ctx, e, usersStep()); r.Status != StatusOK || len(r.Actions) != 0 {
+		t.Errorf("without users created: status %s (%s), actions %v", r.Status, r.Error, r.Actions)
+	}
+
+	// root is the primary group of root, it is kept.
+	if err := os.WriteFile(usersFile, []byte("user root\ngroup root\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := runStep(ctx, e, usersStep())
+	want := []string{"userdel root", "rm -f " + usersFile}
+	if r.Status != StatusPending || !reflect.DeepEqual(r.Actions, want) {
+		t.Errorf("dry run: status %s (%s), actions %v, want %v", r.Status, r.Error, r.Actions, want)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package osinit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"peta.io/peta/pkg/network"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/tuning"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+	"peta.io/peta/pkg/utils/sysctl"
+)
+
+// ResetOptions of resetting hosts.
+type ResetOptions struct {
+	Options
+	// DryRun reports the actions of each step without running them.
+	DryRun bool
+	// DeleteData deletes the data and config directories of the components and the
+	// homes of the service users, they are kept otherwise.
+	DeleteData bool
+	// ArtifactsDir is the directory peta artifact push copied bundles to.
+	ArtifactsDir string
+}
+
+// Directories of the components, deleted with ResetOptions.DeleteData.
+var dataDirs = map[string][]string{
+	"postgres": {"/var/lib/postgresql", "/var/lib/pgsql", "/etc/postgresql", "/etc/postgresql-common"},
+}
+
+// Units and packages of the components, as shell patterns.
+var (
+	componentUnits    = map[string]string{"postgres": "postgresql*"}
+	componentPackages = map[string]string{"postgres": "postgresql*"}
+)
+
+// Repositories added by peta, as shell patterns of their names.
+var repositories = []string{"peta-local", "pgdg*"}
+
+// masqueradeTable is the nftables table of the masquerade rules, shared with the
+// CNI plugins the network code derives from.
+const masqueradeTable = "cni_plugins_masquerade"
+
+// Reset reverts the hosts to their state before peta init os and the component
+// installs and returns the results in the order of hosts. The hostname, swap and
+// time zone are left as they are.
+func Reset(ctx context.Context, b *types.Blueprint, hosts []component.Host, o ResetOptions) []Result {
+	if o.Timeout <= 0 {
+		o.Timeout = runner.DefaultTimeout
+	}
+
+	var output io.Writer = io.Discard
+	if o.Output != nil {
+		output = &syncWriter{w: o.Output}
+	}
+
+	steps := ResetSteps(b, o)
+	results := make([]Result, len(hosts))
+	runner.ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		results[i] = runOnHost(ctx, h, steps, o.Timeout, output)
+	})
+
+	return results
+}
+
+// ResetSteps returns the steps resetting the hosts of the blueprint in order.
+func ResetSteps(b *types.Blueprint, o ResetOptions) []Step {
+	var units, packages, dirs []string
+	for _, c := range b.Spec.Components {
+		if p, ok := componentUnits[c.Type]; ok {
+			units = append(units, p)
+		}
+		if p, ok := componentPackages[c.Type]; ok {
+			packages = append(packages, p)
+		}
+		dirs = append(dirs, dataDirs[c.Type]...)
+	}
+	units, packages, dirs = uniqueStrings(units), uniqueStrings(packages), uniqueStrings(dirs)
+
+	var steps []Step
+	if len(units) > 0 {
+		steps = append(steps, servicesResetStep(units))
+	}
+	if len(packages) > 0 {
+		steps = append(steps, packagesResetStep(packages, o.DeleteData))
+	}
+	if o.DeleteData && len(dirs) > 0 {
+		steps = append(steps, dataResetStep(dirs))
+	}
+	steps = append(steps,
+		usersResetStep(o.DeleteData),
+		repositoriesResetStep(o.ArtifactsDir),
+		networkResetStep(),
+		sysctlResetStep(),
+		filesResetStep(),
+	)
+
+	if o.DryRun {
+		for i := range steps {
+			steps[i].Script = "dry_run=1\n" + steps[i].Script
+		}
+	}
+	return steps
+}
+
+func servicesResetStep(patterns []string) Step {
+	return Step{Name: "services", Script: script(fmt.Sprintf(`units=$( { systemctl list-unit-files --no-legend --plain %[1]s; systemctl list-units --all --no-legend --plain %[1]s; } 2>/dev/null |
+	awk '{ print $1 }' | grep -v '@\.service$' | sort -u)
+for u in $units; do
+	if systemctl is-active --quiet "$u" || [ "$(systemctl is-enabled "$u" 2>/dev/null)" = enabled ]; then
+		act systemctl disable --now "$u"
+	fi
+done
+`, shellutils.Join(patterns...)))}
+}
+
+// packagesResetStep removes the packages, their configuration too with purge.
+func packagesResetStep(patterns []string, purge bool) Step {
+	apt := "remove"
+	if purge {
+		apt = "purge"
+	}
+	return Step{Name: "packages", Script: script(fmt.Sprintf(`if command -v dpkg-query >/dev/null 2>&1; then
+	pkgs=$(dpkg-query -W -f '${db:Status-Status} ${Package}\n' %[1]s 2>/dev/null | awk '$1 == "installed" { print $2 }')
+	[ -z "$pkgs" ] || act env DEBIAN_FRONTEND=noninteractive apt-get %[2]s -y -q $pkgs
+elif command -v rpm >/dev/null 2>&1; then
+	pkgs=$(rpm -qa --qf '%%{NAME}\n' %[1]s)
+	if [ -n "$pkgs" ]; then
+		if command -v dnf >/dev/null 2>&1; then
+			act dnf remove -y -q $pkgs
+		elif command -v zypper >/dev/null 2>&1; then
+			act zypper --non-interactive --quiet remove $pkgs
+		else
+			act yum remove -y -q $pkgs
+		fi
+	fi
+elif command -v apk >/dev/null 2>&1; then
+	pkgs=$(apk info 2>/dev/null | while read -r n; do
+		for p in %[1]s; do
+			case "$n" in $p) echo "$n"; break ;; esac
+		done
+	done)
+	[ -z "$pkgs" ] || act apk del -q $pkgs
+fi
+`, shellutils.Join(patterns...), apt))}
+}
+
+func dataResetStep(dirs []string) Step {
+	return Step{Name: "data", Script: script(fmt.Sprintf(`for d in %s; do
+	[ ! -e "$d" ] || act rm -rf "$d"
+done
+`, shellutils.Join(dirs...)))}
+}
+
+// usersResetStep removes the users and groups created by peta, the ones which existed
+// before are kept. Groups are kept while they are the primary group of a user.
+func usersResetStep(deleteHome bool) Step {
+	userdel := "userdel"
+	if deleteHome {
+		userdel = "userdel -r"
+	}
+
+	return Step{Name: "users", Script: script(fmt.Sprintf(`[ -f %[1]s ] || exit 0
+for u in $(awk '$1 == "user" { print $2 }' %[1]s); do
+	if id -u "$u" >/dev/null 2>&1; then
+		act %[2]s "$u"
+	fi
+done
+for g in $(awk '$1 == "group" { print $2 }' %[1]s); do
+	gid=$(getent group "$g" | cut -d: -f3)
+	if [ -n "$gid" ] && ! getent passwd | awk -F: -v gid="$gid" '$4 == gid { f = 1 } END { exit !f }'; then
+		act groupdel "$g"
+	fi
+done
+act rm -f %[1]s
+`, shellutils.Quote(usersFile), userdel))}
+}
+
+func repositoriesResetStep(artifactsDir string) Step {
+	var files []string
+	for _, name := range repositories {
+		files = append(files,
+			"/etc/apt/sources.list.d/"+name+".list",
+			"/etc/apt/preferences.d/"+name,
+			"/etc/apt/keyrings/"+name+".asc",
+			"/etc/yum.repos.d/"+name+".repo",
+			"/etc/zypp/repos.d/"+name+".repo",
+			"/etc/apk/keys/"+name+".rsa.pub",
+		)
+	}
+
+	body := fmt.Sprintf(`for f in %s; do
+	[ ! -e "$f" ] || act rm -f "$f"
+done
+`, strings.Join(files, " "))
+	if artifactsDir != "" {
+		dir := strings.TrimSuffix(artifactsDir, "/")
+		body += fmt.Sprintf(`dir=%s
+if grep -qs "^file://$dir/" /etc/apk/repositories; then
+	act sed -i "\#^file://$dir/#d" /etc/apk/repositories
+fi
+[ ! -e "$dir" ] || act rm -rf "$dir"
+`, shellutils.Quote(dir))
+	}
+	return Step{Name: "repositories", Script: script(body)}
+}
+
+// networkResetStep removes the links with the peta alias and the masquerade rules.
+func networkResetStep() Step {
+	return Step{Name: "network", Script: script(fmt.Sprintf(`if command -v ip >/dev/null 2>&1; then
+	for l in $(ip -o link show | awk -F': ' '/ alias %[1]s( |$)/ { split($2, n, "@"); print n[1] }'); do
+		act ip link delete "$l"
+	done
+fi
+for t in iptables ip6tables; do
+	command -v $t >/dev/null 2>&1 || continue
+	$t -t nat -S POSTROUTING 2>/dev/null | grep -- '-j PETA-' | sed 's/^-A /-D /' | while IFS= read -r rule; do
+		eval "act $t -t nat $rule"
+	done
+	for c in $($t -t nat -S 2>/dev/null | awk '$1 == "-N" && $2 ~ /^PETA-/ { print $2 }'); do
+		act $t -t nat -F "$c"
+		act $t -t nat -X "$c"
+	done
+done
+# the table is kept if CNI plugins may use it.
+if command -v nft >/dev/null 2>&1 && nft list table inet %[2]s >/dev/null 2>&1 && [ -z "$(ls -A /etc/cni/net.d 2>/dev/null)" ]; then
+	act nft delete table inet %[2]s
+fi
+`, network.LinkAlias, masqueradeTable))}
+}
+
+// sysctlResetStep removes the sysctl files of peta and restores the values the
+// variables had before peta changed them.
+func sysctlResetStep() Step {
+	return Step{Name: "sysctl", Script: script(fmt.Sprintf(`for f in %[1]s; do
+	[ ! -e "$f" ] || act rm -f "$f"
+done
+if [ -f %[2]s ]; then
+	while IFS= read -r line; do
+		act sysctl -qw "${line%%%% = *}=${line#* = }"
+	done < %[2]s
+	act rm -f %[2]s
+fi
+`, shellutils.Join(sysctlFile, tuning.SysctlFile), sysctl.BackupFile))}
+}
+
+// filesResetStep removes the other files and blocks written by peta init os and
+// peta host tune.
+func filesResetStep() Step {
+	files := []string{modulesFile, timesyncFile}
+	for _, f := range tuning.ManagedFiles() {
+		// the sysctl step removes it.
+		if f != tuning.SysctlFile {
+			files = append(files, f)
+		}
+	}
+	return Step{Name: "files", Script: script(fmt.Sprintf(`for f in %[1]s; do
+	[ ! -e "$f" ] || act rm -f "$f"
+done
+for f in %[2]s /etc/chrony.conf /etc/chrony/chrony.conf; do
+	if grep -qx %[3]s "$f" 2>/dev/null; then
+		act remove_block "$f"
+	fi
+done
+`, shellutils.Join(files...), shellutils.Quote(hostsFile), shellutils.Quote(blockBegin)))}
+}
+
+// PrintActions writes a table of the actions of each step on each host.
+func PrintActions(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tSTEP\tACTION")
+	for _, r := range results {
+		if r.Error != "" {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t%s: %s\n", r.Host, StatusFailed, r.Error)
+			continue
+		}
+		n := 0
+		for _, s := range r.Steps {
+			if s.Status == StatusFailed {
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%s: %s\n", r.Host, s.Name, StatusFailed, s.Error)
+			}
+			for _, a := range s.Actions {
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Host, s.Name, a)
+				n++
+			}
+		}
+		if n == 0 {
+			_, _ = fmt.Fprintf(tw, "%s\t-\tnothing to reset\n", r.Host)
+		}
+	}
+	return tw.Flush()
+}
+
+func uniqueStrings(s []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
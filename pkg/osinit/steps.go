@@ -0,0 +1,333 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package osinit
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+	"peta.io/peta/pkg/utils/sysctl"
+)
+
+// Files changed on the hosts.
+var (
+	hostsFile    = "/etc/hosts"
+	modulesFile  = "/etc/modules-load.d/peta.conf"
+	sysctlFile   = "/etc/sysctl.d/99-peta.conf"
+	timesyncFile = "/etc/systemd/timesyncd.conf.d/peta.conf"
+	// usersFile lists the users and groups created by peta, like "user postgres",
+	// so that the reset removes those only.
+	usersFile = "/var/lib/peta/users.created"
+)
+
+// blockBegin and blockEnd delimit the lines managed by peta in shared files.
+const (
+	blockBegin = "# BEGIN peta"
+	blockEnd   = "# END peta"
+)
+
+var hostnameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// prelude defines the helpers of the step scripts:
+// changed reports the host was changed,
+// act <command> reports the command and runs it unless dry_run is 1,
+// ensure_file <path> <mode> <content> writes a file if its content differs,
+// ensure_block <path> <content> replaces the peta block of a file if it differs,
+// remove_block <path> removes the peta block of a file,
+// save_sysctl <name> keeps the value of a variable before peta first changes it,
+// record <path> <line> adds a line to a file unless it has it.
+// ensure_file and ensure_block set updated to 1 if they wrote the file.
+var prelude = `set -e
+changed() { echo ` + changedMarker + `; }
+act() {
+	echo "` + actionMarker + ` $*"
+	[ "${dry_run:-0}" != 1 ] || return 0
+	"$@"
+	changed
+}
+ensure_file() {
+	updated=0
+	if [ ! -f "$1" ] || [ "$(cat "$1")" != "$3" ]; then
+		mkdir -p "$(dirname "$1")"
+		printf '%s\n' "$3" > "$1"
+		chmod "$2" "$1"
+		updated=1
+		changed
+	fi
+}
+ensure_block() {
+	updated=0
+	touch "$1"
+	if [ "$(sed -n '/^` + blockBegin + `$/,/^` + blockEnd + `$/p' "$1")" != "$2" ]; then
+		tmp=$(mktemp)
+		sed '/^` + blockBegin + `$/,/^` + blockEnd + `$/d' "$1" > "$tmp"
+		printf '%s\n' "$2" >> "$tmp"
+		# keep the inode, the file may be bind mounted.
+		cat "$tmp" > "$1"
+		rm -f "$tmp"
+		updated=1
+		changed
+	fi
+}
+remove_block() {
+	tmp=$(mktemp)
+	sed '/^` + blockBegin + `$/,/^` + blockEnd + `$/d' "$1" > "$tmp"
+	cat "$tmp" > "$1"
+	rm -f "$tmp"
+}
+save_sysctl() {
+	mkdir -p "$(dirname ` + sysctl.BackupFile + `)"
+	awk -F ' = ' -v k="$1" '$1 == k { f = 1 } END { exit !f }' ` + sysctl.BackupFile + ` 2>/dev/null ||
+		printf '%s = %s\n' "$1" "$(sysctl -n "$1" | tr -s '\t ' '  ')" >> ` + sysctl.BackupFile + `
+}
+record() {
+	mkdir -p "$(dirname "$1")"
+	grep -qsxF "$2" "$1" || printf '%s\n' "$2" >> "$1"
+}
+`
+
+// Steps returns the steps preparing h in order, entries are the /etc/hosts lines
+// of all hosts of the blueprint.
+func Steps(h *component.Host, entries []string, c *types.OSConfig) []Step {
+	var steps []Step
+	if types.Enabled(c.ManageHostname) && h.Name != "" {
+		steps = append(steps, hostnameStep(h.Name))
+	}
+	if types.Enabled(c.ManageHosts) && len(entries) > 0 {
+		steps = append(steps, hostsStep(entries))
+	}
+	steps = append(steps, timeSyncStep(c.NTPServers, c.Timezone))
+	if types.Enabled(c.DisableSwap) {
+		steps = append(steps, swapStep())
+	}
+	if len(c.KernelModules) > 0 {
+		steps = append(steps, kernelModulesStep(c.KernelModules))
+	}
+	if len(c.Sysctl) > 0 {
+		steps = append(steps, sysctlStep(c.Sysctl))
+	}
+	if len(c.Users) > 0 {
+		steps = append(steps, usersStep(c.Users))
+	}
+	return steps
+}
+
+// HostsEntries returns the /etc/hosts lines of the named hosts, resolving their
+// names to the internal address if any.
+func HostsEntries(hosts []component.Host) []string {
+	var entries []string
+	for _, h := range hosts {
+		addr := h.InternalAddress
+		if addr == "" {
+			addr = h.Address
+		}
+		if h.Name == "" || addr == "" || executor.IsLocal(&h) {
+			continue
+		}
+		names := []string{h.Name}
+		if short, _, ok := strings.Cut(h.Name, "."); ok {
+			names = append(names, short)
+		}
+		entries = append(entries, addr+" "+strings.Join(names, " "))
+	}
+	return entries
+}
+
+func script(body string) string {
+	return prelude + body
+}
+
+func hostnameStep(name string) Step {
+	if !hostnameRegexp.MatchString(name) || len(name) > 253 {
+		return Step{Name: "hostname", Script: fmt.Sprintf("echo %s >&2; exit 1",
+			shellutils.Quote(fmt.Sprintf("invalid hostname %q", name)))}
+	}
+	return Step{Name: "hostname", Script: script(fmt.Sprintf(`want=%s
+if [ "$(hostname)" != "$want" ]; then
+	if command -v hostnamectl >/dev/null 2>&1; then
+		hostnamectl set-hostname "$want"
+	else
+		printf '%%s\n' "$want" > /etc/hostname
+		hostname "$want"
+	fi
+	changed
+fi
+`, shellutils.Quote(name)))}
+}
+
+func hostsStep(entries []string) Step {
+	block := strings.Join(append(append([]string{blockBegin}, entries...), blockEnd), "\n")
+	return Step{Name: "hosts", Script: script(fmt.Sprintf("ensure_block %s %s\n",
+		shellutils.Quote(hostsFile), shellutils.Quote(block)))}
+}
+
+func timeSyncStep(servers []string, timezone string) Step {
+	var chrony, timesyncd string
+	if len(servers) > 0 {
+		lines := []string{blockBegin}
+		for _, s := range servers {
+			lines = append(lines, "server "+s+" iburst")
+		}
+		lines = append(lines, blockEnd)
+		chrony = fmt.Sprintf(`	ensure_block "$conf" %s
+	[ "$updated" = 0 ] || systemctl restart "$unit"
+`, shellutils.Quote(strings.Join(lines, "\n")))
+		timesyncd = fmt.Sprintf(`	ensure_file %s 0644 %s
+	[ "$updated" = 0 ] || systemctl restart systemd-timesyncd
+`, shellutils.Quote(timesyncFile), shellutils.Quote("[Time]\nNTP="+strings.Join(servers, " ")))
+	}
+
+	body := fmt.Sprintf(`if command -v chronyd >/dev/null 2>&1; then
+	unit=chronyd
+	systemctl cat chronyd >/dev/null 2>&1 || unit=chrony
+	conf=/etc/chrony.conf
+	[ -f /etc/chrony/chrony.conf ] && conf=/etc/chrony/chrony.conf
+%s	if ! systemctl is-enabled --quiet "$unit" || ! systemctl is-active --quiet "$unit"; then
+		systemctl enable --now "$unit"
+		changed
+	fi
+elif systemctl cat systemd-timesyncd >/dev/null 2>&1; then
+%s	if [ "$(timedatectl show -p NTP --value)" != yes ]; then
+		timedatectl set-ntp true
+		changed
+	fi
+else
+	echo "neither chrony nor systemd-timesyncd is installed" >&2
+	exit 1
+fi
+`, chrony, timesyncd)
+
+	if timezone != "" {
+		body += fmt.Sprintf(`tz=%s
+if [ "$(timedatectl show -p Timezone --value)" != "$tz" ]; then
+	timedatectl set-timezone "$tz"
+	changed
+fi
+`, shellutils.Quote(timezone))
+	}
+
+	return Step{Name: "time-sync", Script: script(body)}
+}
+
+func swapStep() Step {
+	return Step{Name: "swap", Script: script(`if [ "$(wc -l < /proc/swaps)" -gt 1 ]; then
+	swapoff -a
+	changed
+fi
+if grep -Eq '^[^#]\S*\s+\S+\s+swap\s' /etc/fstab; then
+	sed -i.peta.bak -E 's@^([^#]\S*\s+\S+\s+swap\s.*)$@#\1@' /etc/fstab
+	changed
+fi
+`)}
+}
+
+func kernelModulesStep(modules []string) Step {
+	var b strings.Builder
+	for _, m := range modules {
+		fmt.Fprintf(&b, `if [ ! -d /sys/module/%[1]s ]; then
+	modprobe %[1]s
+	changed
+fi
+`, shellutils.Quote(m))
+	}
+	fmt.Fprintf(&b, "ensure_file %s 0644 %s\n",
+		shellutils.Quote(modulesFile), shellutils.Quote(strings.Join(modules, "\n")))
+	return Step{Name: "kernel-modules", Script: script(b.String())}
+}
+
+func sysctlStep(params map[string]string) Step {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	var lines []string
+	for _, k := range keys {
+		// sysctl separates the fields of multi-value parameters with tabs.
+		v := strings.Join(strings.Fields(params[k]), " ")
+		lines = append(lines, k+" = "+v)
+		fmt.Fprintf(&b, `if [ "$(sysctl -n %[1]s | tr -s '\t ' '  ')" != %[2]s ]; then
+	save_sysctl %[1]s
+	sysctl -qw %[3]s
+	changed
+fi
+`, shellutils.Quote(k), shellutils.Quote(v), shellutils.Quote(k+"="+v))
+	}
+	fmt.Fprintf(&b, "ensure_file %s 0644 %s\n",
+		shellutils.Quote(sysctlFile), shellutils.Quote(strings.Join(lines, "\n")))
+	return Step{Name: "sysctl", Script: script(b.String())}
+}
+
+func usersStep(users []types.ServiceUser) Step {
+	var b strings.Builder
+	for _, u := range users {
+		group := u.Group
+		if group == "" {
+			group = u.Name
+		}
+
+		groupadd := []string{"groupadd", "-r"}
+		if u.GID > 0 {
+			groupadd = append(groupadd, "-g", fmt.Sprint(u.GID))
+		}
+		groupadd = append(groupadd, group)
+
+		useradd := []string{"useradd", "-r", "-g", group}
+		if u.UID > 0 {
+			useradd = append(useradd, "-u", fmt.Sprint(u.UID))
+		}
+		if u.Home != "" {
+			useradd = append(useradd, "-d", u.Home, "-m")
+		}
+		if u.Shell != "" {
+			useradd = append(useradd, "-s", u.Shell)
+		}
+		useradd = append(useradd, u.Name)
+
+		// the users and groups which existed before are not recorded, the reset keeps them.
+		fmt.Fprintf(&b, `if ! getent group %[1]s >/dev/null; then
+	%[2]s
+	record %[5]s %[6]s
+	changed
+fi
+if ! id -u %[3]s >/dev/null 2>&1; then
+	%[4]s
+	record %[5]s %[7]s
+	changed
+fi
+`, shellutils.Quote(group), shellutils.Join(groupadd...), shellutils.Quote(u.Name), shellutils.Join(useradd...),
+			shellutils.Quote(usersFile), shellutils.Quote("group "+group), shellutils.Quote("user "+u.Name))
+		if u.UID > 0 {
+			fmt.Fprintf(&b, `name=%[1]s
+if [ "$(id -u "$name")" != %[2]d ]; then
+	echo "user $name exists with uid $(id -u "$name") instead of %[2]d" >&2
+	exit 1
+fi
+`, shellutils.Quote(u.Name), u.UID)
+		}
+	}
+	return Step{Name: "users", Script: script(b.String())}
+}
@@ -20,6 +20,7 @@ package persistence
 import (
 	"context"
 	"embed"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -86,6 +87,9 @@ func New(ctx context.Context, options *Options) (Storage, error) {
 		if err := p.PingContext(ctx); err != nil {
 			return nil, err
 		}
+		if err := p.MigrateUp(); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
 	}
 
 	return p, nil
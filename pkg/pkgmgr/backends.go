@@ -0,0 +1,228 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pkgmgr
+
+import (
+	"fmt"
+	"strings"
+
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// backend are the commands of a package manager.
+type backend struct {
+	name string
+	// query prints the installed packages of the names in the format read by parse.
+	query   func(names []string) string
+	parse   func(out string) map[string]string
+	spec    func(p Package) string
+	install func(specs []string) string
+	remove  func(names []string) string
+	refresh string
+	// refreshRepository updates the package index of a repository only, the other
+	// repositories may not be reachable.
+	refreshRepository func(name string) string
+	// repository returns the files of a repository.
+	repository func(r *Repository) ([]file, error)
+	// download downloads packages with their dependencies into a directory.
+	download func(dir string, names []string) string
+	// index creates the metadata of a repository of the packages in a directory
+	// with the index tools.
+	index      func(dir string) string
+	indexTools []Package
+}
+
+var backends = map[string]*backend{
+	Apt: {
+		name: Apt,
+		query: func(names []string) string {
+			return "dpkg-query -W -f '${Package} ${Version} ${db:Status-Status}\\n' " + shellutils.Join(names...) + " 2>/dev/null || true"
+		},
+		parse: func(out string) map[string]string {
+			installed := map[string]string{}
+			for _, line := range lines(out) {
+				if fields := strings.Fields(line); len(fields) == 3 && fields[2] == "installed" {
+					installed[fields[0]] = fields[1]
+				}
+			}
+			return installed
+		},
+		spec: func(p Package) string {
+			if p.Version == "" {
+				return p.Name
+			}
+			return p.Name + "=" + p.Version + "*"
+		},
+		install: func(specs []string) string {
+			return "DEBIAN_FRONTEND=noninteractive apt-get install -y -q -o Dpkg::Options::=--force-confold " + shellutils.Join(specs...)
+		},
+		remove: func(names []string) string {
+			return "DEBIAN_FRONTEND=noninteractive apt-get remove -y -q " + shellutils.Join(names...)
+		},
+		refresh: "apt-get update -q",
+		refreshRepository: func(name string) string {
+			return "apt-get update -q -o Dir::Etc::sourcelist=" + shellutils.Quote("sources.list.d/"+name+".list") +
+				" -o Dir::Etc::sourceparts=- -o APT::Get::List-Cleanup=0"
+		},
+		repository: aptRepository,
+		download: func(dir string, names []string) string {
+			// apt-get download does not resolve dependencies, virtual packages are left out.
+			return fmt.Sprintf(`cd %s && apt-get download -q $(apt-cache depends --recurse --no-recommends --no-suggests `+
+				`--no-conflicts --no-breaks --no-replaces --no-enhances %s | grep '^[a-zA-Z0-9]' | sort -u)`,
+				shellutils.Quote(dir), shellutils.Join(names...))
+		},
+		index: func(dir string) string {
+			return fmt.Sprintf("cd %s && dpkg-scanpackages --multiversion . /dev/null > Packages && gzip -9kf Packages",
+				shellutils.Quote(dir))
+		},
+		indexTools: []Package{{Name: "dpkg-dev"}},
+	},
+	Dnf: {
+		name:  Dnf,
+		query: rpmQuery,
+		parse: parseFields,
+		spec:  rpmSpec,
+		install: func(specs []string) string {
+			return "dnf install -y -q " + shellutils.Join(specs...)
+		},
+		remove: func(names []string) string {
+			return "dnf remove -y -q " + shellutils.Join(names...)
+		},
+		refresh: "dnf makecache -q",
+		refreshRepository: func(name string) string {
+			return "dnf makecache -q --disablerepo='*' --enablerepo=" + shellutils.Quote(name)
+		},
+		repository: yumRepository,
+		download: func(dir string, names []string) string {
+			return fmt.Sprintf("dnf download -q --resolve --alldeps --destdir %s %s", shellutils.Quote(dir), shellutils.Join(names...))
+		},
+		index:      createrepo,
+		indexTools: []Package{{Name: "createrepo_c"}, {Name: "dnf-plugins-core"}},
+	},
+	Yum: {
+		name:  Yum,
+		query: rpmQuery,
+		parse: parseFields,
+		spec:  rpmSpec,
+		install: func(specs []string) string {
+			return "yum install -y -q " + shellutils.Join(specs...)
+		},
+		remove: func(names []string) string {
+			return "yum remove -y -q " + shellutils.Join(names...)
+		},
+		refresh: "yum makecache -q",
+		refreshRepository: func(name string) string {
+			return "yum makecache -q --disablerepo='*' --enablerepo=" + shellutils.Quote(name)
+		},
+		repository: yumRepository,
+		download: func(dir string, names []string) string {
+			return fmt.Sprintf("yumdownloader -q --resolve --destdir %s %s", shellutils.Quote(dir), shellutils.Join(names...))
+		},
+		index:      createrepo,
+		indexTools: []Package{{Name: "createrepo"}, {Name: "yum-utils"}},
+	},
+	Zypper: {
+		name:  Zypper,
+		query: rpmQuery,
+		parse: parseFields,
+		spec: func(p Package) string {
+			if p.Version == "" {
+				return p.Name
+			}
+			return p.Name + ">=" + p.Version
+		},
+		install: func(specs []string) string {
+			return "zypper --non-interactive --quiet install " + shellutils.Join(specs...)
+		},
+		remove: func(names []string) string {
+			return "zypper --non-interactive --quiet remove " + shellutils.Join(names...)
+		},
+		refresh: "zypper --non-interactive --quiet --gpg-auto-import-keys refresh",
+		refreshRepository: func(name string) string {
+			return "zypper --non-interactive --quiet --gpg-auto-import-keys refresh " + shellutils.Quote(name)
+		},
+		repository: zypperRepository,
+		download: func(dir string, names []string) string {
+			// a dry run installation downloads the missing dependencies into the package cache.
+			return fmt.Sprintf("zypper --non-interactive --pkg-cache-dir %s install --download-only --force %s",
+				shellutils.Quote(dir), shellutils.Join(names...))
+		},
+		index:      createrepo,
+		indexTools: []Package{{Name: "createrepo_c"}},
+	},
+	Apk: {
+		name: Apk,
+		query: func(names []string) string {
+			return `for n in ` + shellutils.Join(names...) + `; do v=$(apk info -e -v "$n" 2>/dev/null) && echo "$n ${v#"$n"-}"; done; true`
+		},
+		parse: parseFields,
+		spec: func(p Package) string {
+			if p.Version == "" {
+				return p.Name
+			}
+			return p.Name + "~" + p.Version
+		},
+		install: func(specs []string) string {
+			return "apk add -q " + shellutils.Join(specs...)
+		},
+		remove: func(names []string) string {
+			return "apk del -q " + shellutils.Join(names...)
+		},
+		refresh: "apk update -q",
+		refreshRepository: func(string) string {
+			return "apk update -q"
+		},
+		repository: apkRepository,
+		download: func(dir string, names []string) string {
+			return fmt.Sprintf("apk fetch -q -R -o %s %s", shellutils.Quote(dir), shellutils.Join(names...))
+		},
+	},
+}
+
+// createrepo creates the metadata of an rpm repository, the packages may be in subdirectories.
+func createrepo(dir string) string {
+	return fmt.Sprintf("cd %s && { command -v createrepo_c >/dev/null 2>&1 && createrepo_c -q . || createrepo -q .; }",
+		shellutils.Quote(dir))
+}
+
+func rpmQuery(names []string) string {
+	return "rpm -q --qf '%{NAME} %{VERSION}-%{RELEASE}\\n' " + shellutils.Join(names...) + " 2>/dev/null || true"
+}
+
+func rpmSpec(p Package) string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + "-" + p.Version + "*"
+}
+
+// parseFields parses lines of a name and a version, skipping the others like
+// "package x is not installed" of rpm.
+func parseFields(out string) map[string]string {
+	installed := map[string]string{}
+	for _, line := range lines(out) {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			installed[fields[0]] = fields[1]
+		}
+	}
+	return installed
+}
+
+func lines(out string) []string {
+	return strings.Split(strings.ReplaceAll(out, "\r", ""), "\n")
+}
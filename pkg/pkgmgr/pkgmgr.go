@@ -0,0 +1,274 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package pkgmgr installs packages and adds repositories on hosts with the package
+// manager of their distribution.
+package pkgmgr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// Names of the supported package managers.
+const (
+	Apt    = "apt"
+	Dnf    = "dnf"
+	Yum    = "yum"
+	Zypper = "zypper"
+	Apk    = "apk"
+)
+
+// Package is a package to install, any version is accepted if Version is empty,
+// otherwise the installed version must start with it, like 16 or 16.2.
+type Package struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// Result lists the packages changed by an operation, empty if the host was
+// already in the desired state.
+type Result struct {
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Manager manages the packages of a host.
+type Manager interface {
+	// Name is the package manager, like apt or dnf.
+	Name() string
+	// Installed returns the installed version of each package, the packages which
+	// are not installed are left out.
+	Installed(ctx context.Context, names ...string) (map[string]string, error)
+	// Install installs the packages which are missing or of another version.
+	Install(ctx context.Context, pkgs ...Package) (*Result, error)
+	// Remove removes the packages which are installed.
+	Remove(ctx context.Context, names ...string) (*Result, error)
+	// Refresh updates the package index.
+	Refresh(ctx context.Context) error
+	// AddRepository adds or updates the repository and refreshes its package index
+	// if it changed.
+	AddRepository(ctx context.Context, r *Repository) (changed bool, err error)
+	// Download downloads the packages and all their dependencies into dir, for
+	// hosts without the dependencies installed.
+	Download(ctx context.Context, dir string, names ...string) error
+	// CreateIndex makes dir a repository of the packages in it, the tools needed
+	// are installed first.
+	CreateIndex(ctx context.Context, dir string) error
+}
+
+// families maps the os ID and ID_LIKE of /etc/os-release to package managers,
+// rpm distributions use dnf if it is installed and yum otherwise.
+var families = map[string]string{
+	"debian":        Apt,
+	"ubuntu":        Apt,
+	"rhel":          Dnf,
+	"fedora":        Dnf,
+	"centos":        Dnf,
+	"rocky":         Dnf,
+	"almalinux":     Dnf,
+	"ol":            Dnf,
+	"openEuler":     Dnf,
+	"kylin":         Dnf,
+	"suse":          Zypper,
+	"opensuse":      Zypper,
+	"sles":          Zypper,
+	"opensuse-leap": Zypper,
+	"alpine":        Apk,
+}
+
+// Detect returns the package manager of the host e runs on.
+func Detect(ctx context.Context, e executor.Executor) (Manager, error) {
+	f, err := e.Facts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := family(f)
+	if name == "" {
+		return nil, fmt.Errorf("unsupported os %s %s", f.OS, f.OSVersion)
+	}
+	if name == Dnf {
+		if _, err := executor.Output(ctx, e, "command -v dnf"); err != nil {
+			name = Yum
+		}
+	}
+
+	return New(name, e)
+}
+
+// family returns the package manager of the os of the facts, empty if unknown.
+func family(f *executor.Facts) string {
+	if name, ok := families[f.OS]; ok {
+		return name
+	}
+	for _, like := range strings.Fields(f.OSLike) {
+		if name, ok := families[like]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// New returns the package manager with the name on the host e runs on.
+func New(name string, e executor.Executor) (Manager, error) {
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported package manager %q", name)
+	}
+	return &manager{backend: b, e: e}, nil
+}
+
+// manager runs the commands of a backend.
+type manager struct {
+	*backend
+	e executor.Executor
+}
+
+func (m *manager) Name() string {
+	return m.name
+}
+
+func (m *manager) Installed(ctx context.Context, names ...string) (map[string]string, error) {
+	if len(names) == 0 {
+		return map[string]string{}, nil
+	}
+	out, err := m.run(ctx, m.query(names))
+	if err != nil {
+		return nil, err
+	}
+	installed := m.parse(out)
+	// managers may report packages providing the names.
+	for name := range installed {
+		if !slices.Contains(names, name) {
+			delete(installed, name)
+		}
+	}
+	return installed, nil
+}
+
+func (m *manager) Install(ctx context.Context, pkgs ...Package) (*Result, error) {
+	names := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		names = append(names, p.Name)
+	}
+	installed, err := m.Installed(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{}
+	var specs []string
+	for _, p := range pkgs {
+		if v, ok := installed[p.Name]; ok && matchVersion(v, p.Version) {
+			continue
+		}
+		r.Changed = append(r.Changed, p.Name)
+		specs = append(specs, m.spec(p))
+	}
+	if len(specs) == 0 {
+		return r, nil
+	}
+
+	if _, err := m.run(ctx, m.install(specs)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (m *manager) Remove(ctx context.Context, names ...string) (*Result, error) {
+	installed, err := m.Installed(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{}
+	for _, name := range names {
+		if _, ok := installed[name]; ok {
+			r.Changed = append(r.Changed, name)
+		}
+	}
+	if len(r.Changed) == 0 {
+		return r, nil
+	}
+
+	if _, err := m.run(ctx, m.remove(r.Changed)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (m *manager) Refresh(ctx context.Context) error {
+	_, err := m.run(ctx, m.refresh)
+	return err
+}
+
+func (m *manager) Download(ctx context.Context, dir string, names ...string) error {
+	if m.download == nil {
+		return fmt.Errorf("%s can not download packages", m.name)
+	}
+	_, err := m.run(ctx, fmt.Sprintf("mkdir -p %s && %s", shellutils.Quote(dir), m.download(dir, names)))
+	return err
+}
+
+func (m *manager) CreateIndex(ctx context.Context, dir string) error {
+	if m.index == nil {
+		return fmt.Errorf("%s can not create repositories", m.name)
+	}
+	if _, err := m.Install(ctx, m.indexTools...); err != nil {
+		return err
+	}
+	_, err := m.run(ctx, m.index(dir))
+	return err
+}
+
+func (m *manager) run(ctx context.Context, cmd string) (string, error) {
+	out, err := executor.Output(ctx, m.e, cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", m.name, err, lastLines(string(out), 5))
+	}
+	return string(out), nil
+}
+
+// matchVersion returns true if the installed version is the wanted one or a
+// more precise version of it, like 16.2-1.pgdg22.04+1 for 16.2.
+func matchVersion(installed, want string) bool {
+	if want == "" || installed == want {
+		return true
+	}
+	if !strings.HasPrefix(installed, want) {
+		// the epoch of rpm and deb versions is not given usually.
+		if _, v, ok := strings.Cut(installed, ":"); ok {
+			return matchVersion(v, want)
+		}
+		return false
+	}
+	next := installed[len(want)]
+	return next < '0' || next > '9'
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(s, "\r", "")), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
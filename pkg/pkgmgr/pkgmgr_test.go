@@ -0,0 +1,187 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"peta.io/peta/pkg/executor"
+)
+
+// fakeExecutor answers commands by prefix and keeps files in memory.
+type fakeExecutor struct {
+	outputs map[string]string
+	files   map[string]string
+	ran     []string
+}
+
+func (f *fakeExecutor) Run(_ context.Context, cmd string, out io.Writer) error {
+	f.ran = append(f.ran, cmd)
+	for prefix, output := range f.outputs {
+		if strings.HasPrefix(cmd, prefix) {
+			_, _ = io.WriteString(out, output)
+			return nil
+		}
+	}
+	if strings.HasPrefix(cmd, "[ ! -e ") {
+		for p := range f.files {
+			if strings.Contains(cmd, " "+p+" ") {
+				_, _ = io.WriteString(out, "exists\n")
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeExecutor) Upload(_ context.Context, src io.Reader, dst string, _ os.FileMode) error {
+	b, err := io.ReadAll(src)
+	f.files[dst] = string(b)
+	return err
+}
+
+func (f *fakeExecutor) Download(_ context.Context, src string, dst io.Writer) error {
+	content, ok := f.files[src]
+	if !ok {
+		return errors.New("no such file")
+	}
+	_, err := io.WriteString(dst, content)
+	return err
+}
+
+func (f *fakeExecutor) Facts(context.Context) (*executor.Facts, error) {
+	return &executor.Facts{OS: "ubuntu", OSVersion: "22.04"}, nil
+}
+
+func (f *fakeExecutor) DialContext(context.Context, string, string) (net.Conn, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeExecutor) Close() error { return nil }
+
+func TestFamily(t *testing.T) {
+	tests := map[string]executor.Facts{
+		Apt:    {OS: "ubuntu"},
+		Dnf:    {OS: "rocky", OSLike: "rhel centos fedora"},
+		Zypper: {OS: "sles"},
+		Apk:    {OS: "alpine"},
+		"":     {OS: "arch"},
+	}
+	for want, f := range tests {
+		if got := family(&f); got != want {
+			t.Errorf("family(%s) = %q, want %q", f.OS, got, want)
+		}
+	}
+	if got := family(&executor.Facts{OS: "linuxmint", OSLike: "ubuntu debian"}); got != Apt {
+		t.Errorf("family(linuxmint) = %q, want %q", got, Apt)
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	tests := []struct {
+		installed, want string
+		match           bool
+	}{
+		{"16.2-1.pgdg22.04+1", "16", true},
+		{"16.2-1.pgdg22.04+1", "16.2", true},
+		{"16.20-1", "16.2", false},
+		{"1:16.2-1", "16.2", true},
+		{"15.6-1", "16", false},
+		{"15.6-1", "", true},
+	}
+	for _, tt := range tests {
+		if got := matchVersion(tt.installed, tt.want); got != tt.match {
+			t.Errorf("matchVersion(%q, %q) = %v, want %v", tt.installed, tt.want, got, tt.match)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"dpkg-query": "curl 7.81.0-1ubuntu1 installed\npostgresql-16 16.2-1.pgdg22.04+1 config-files\n",
+	}}
+	m, err := New(Apt, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := m.Install(context.Background(), Package{Name: "curl"}, Package{Name: "postgresql-16", Version: "16.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Changed, []string{"postgresql-16"}) {
+		t.Errorf("changed = %v, want [postgresql-16]", r.Changed)
+	}
+	if last := e.ran[len(e.ran)-1]; !strings.HasSuffix(last, "apt-get install -y -q -o Dpkg::Options::=--force-confold 'postgresql-16=16.2*'") {
+		t.Errorf("unexpected install command %q", last)
+	}
+
+	e.ran = nil
+	if r, err := m.Install(context.Background(), Package{Name: "curl"}); err != nil || len(r.Changed) != 0 || len(e.ran) != 1 {
+		t.Errorf("installed package should not change: %v %v %v", r, err, e.ran)
+	}
+}
+
+func TestAddRepository(t *testing.T) {
+	e := &fakeExecutor{
+		outputs: map[string]string{". /etc/os-release": "jammy\n"},
+		files:   map[string]string{},
+	}
+	m, _ := New(Apt, e)
+
+	for i, want := range []bool{true, false} {
+		changed, err := m.AddRepository(context.Background(), PGDG(Apt, "16"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != want {
+			t.Errorf("run %d: changed %v, want %v", i, changed, want)
+		}
+	}
+
+	wantFiles := map[string]string{
+		"/etc/apt/sources.list.d/pgdg.list": "deb [signed-by=/etc/apt/keyrings/pgdg.asc] https://apt.postgresql.org/pub/repos/apt jammy-pgdg main\n",
+		"/etc/apt/preferences.d/pgdg":       "Package: *\nPin: origin apt.postgresql.org\nPin-Priority: 600\n",
+	}
+	if !reflect.DeepEqual(e.files, wantFiles) {
+		t.Errorf("files = %v, want %v", e.files, wantFiles)
+	}
+}
+
+func TestAddApkRepository(t *testing.T) {
+	e := &fakeExecutor{files: map[string]string{"/etc/apk/repositories": "https://dl-cdn.alpinelinux.org/alpine/v3.20/main\n"}}
+	m, _ := New(Apk, e)
+
+	r := &Repository{Name: "community", URL: "https://dl-cdn.alpinelinux.org/alpine/v3.20/community"}
+	for i, want := range []bool{true, false} {
+		if changed, err := m.AddRepository(context.Background(), r); err != nil || changed != want {
+			t.Errorf("run %d: changed %v (%v), want %v", i, changed, err, want)
+		}
+	}
+
+	want := "https://dl-cdn.alpinelinux.org/alpine/v3.20/main\nhttps://dl-cdn.alpinelinux.org/alpine/v3.20/community\n"
+	if got := e.files["/etc/apk/repositories"]; got != want {
+		t.Errorf("repositories = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pkgmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// codename is replaced by the VERSION_CODENAME of /etc/os-release in the suite
+// of apt repositories, like {codename}-pgdg.
+const codename = "{codename}"
+
+var repositoryNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Repository is a third-party package repository.
+type Repository struct {
+	// Name identifies the repository and names its files.
+	Name string `json:"name" yaml:"name"`
+	// URL is the base url of the repository, rpm variables like $releasever are kept.
+	URL string `json:"url" yaml:"url"`
+	// Suite and Components are the distribution and components of apt repositories,
+	// like {codename}-pgdg and main.
+	Suite      string   `json:"suite,omitempty" yaml:"suite,omitempty"`
+	Components []string `json:"components,omitempty" yaml:"components,omitempty"`
+	// KeyURL is the signing key of the repository, packages are not verified if empty.
+	KeyURL string `json:"keyURL,omitempty" yaml:"keyURL,omitempty"`
+	// Trusted accepts an unsigned apt repository, like a local one.
+	Trusted bool `json:"trusted,omitempty" yaml:"trusted,omitempty"`
+	// Priority pins the packages of the repository, the apt Pin-Priority or the
+	// priority of dnf and zypper, unchanged if 0.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// Packages restricts the pinning of apt and the packages of dnf repositories to these.
+	Packages []string `json:"packages,omitempty" yaml:"packages,omitempty"`
+}
+
+// PGDG returns the PostgreSQL repository of the package manager, rpm repositories
+// are split by major version.
+func PGDG(manager, version string) *Repository {
+	major, _, _ := strings.Cut(version, ".")
+	switch manager {
+	case Apt:
+		return &Repository{
+			Name:       "pgdg",
+			URL:        "https://apt.postgresql.org/pub/repos/apt",
+			Suite:      codename + "-pgdg",
+			Components: []string{"main"},
+			KeyURL:     "https://www.postgresql.org/media/keys/ACCC4CF8.asc",
+			Priority:   600,
+		}
+	case Dnf, Yum:
+		return &Repository{
+			Name:   "pgdg" + major,
+			URL:    "https://download.postgresql.org/pub/repos/yum/" + major + "/redhat/rhel-$releasever-$basearch",
+			KeyURL: "https://download.postgresql.org/pub/repos/yum/keys/PGDG-RPM-GPG-KEY-RHEL",
+		}
+	case Zypper:
+		return &Repository{
+			Name:   "pgdg" + major,
+			URL:    "https://download.postgresql.org/pub/repos/zypp/" + major + "/suse/sles-$releasever-$basearch",
+			KeyURL: "https://download.postgresql.org/pub/repos/zypp/keys/PGDG-RPM-GPG-KEY-SLES15",
+		}
+	}
+	return nil
+}
+
+// file is a file of a repository on the host, a line is added to the content of
+// shared files instead of replacing it.
+type file struct {
+	path    string
+	content string
+	line    bool
+}
+
+func (m *manager) AddRepository(ctx context.Context, r *Repository) (bool, error) {
+	if !repositoryNameRegexp.MatchString(r.Name) {
+		return false, fmt.Errorf("invalid repository name %q", r.Name)
+	}
+	if r.URL == "" {
+		return false, fmt.Errorf("repository %s has no url", r.Name)
+	}
+
+	if strings.Contains(r.Suite, codename) {
+		out, err := m.run(ctx, `. /etc/os-release && echo "$VERSION_CODENAME"`)
+		if err != nil {
+			return false, err
+		}
+		resolved := *r
+		resolved.Suite = strings.ReplaceAll(r.Suite, codename, strings.TrimSpace(out))
+		r = &resolved
+	}
+
+	files, err := m.repository(r)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	if keyFile := m.keyFile(r); keyFile != "" {
+		if changed, err = m.downloadKey(ctx, r.KeyURL, keyFile); err != nil {
+			return false, err
+		}
+	}
+	for _, f := range files {
+		fileChanged, err := m.ensureFile(ctx, f)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || fileChanged
+	}
+
+	if changed {
+		_, err := m.run(ctx, m.refreshRepository(r.Name))
+		return true, err
+	}
+	return false, nil
+}
+
+// LocalRepository returns the repository of the packages in dir created by
+// CreateIndex, preferred over the other repositories.
+func LocalRepository(manager, name, dir string) *Repository {
+	r := &Repository{Name: name, URL: "file://" + dir, Priority: 1}
+	if manager == Apt {
+		r.Suite = "./"
+		r.Trusted = true
+		r.Priority = 1001
+	}
+	return r
+}
+
+// keyFile returns where the key of the repository is kept, empty if the package
+// manager imports it from the url.
+func (m *manager) keyFile(r *Repository) string {
+	if r.KeyURL == "" {
+		return ""
+	}
+	switch m.name {
+	case Apt:
+		return "/etc/apt/keyrings/" + r.Name + ".asc"
+	case Apk:
+		return "/etc/apk/keys/" + r.Name + ".rsa.pub"
+	}
+	return ""
+}
+
+// downloadKey downloads the key once, keys are rotated by removing the file.
+func (m *manager) downloadKey(ctx context.Context, keyURL, dst string) (bool, error) {
+	const changed = "__PETA_KEY_DOWNLOADED__"
+	cmd := fmt.Sprintf(`dst=%s; url=%s
+[ -s "$dst" ] && exit 0
+mkdir -p "$(dirname "$dst")"
+if command -v curl >/dev/null 2>&1; then curl -fsSL -o "$dst.tmp" "$url"; else wget -qO "$dst.tmp" "$url"; fi
+chmod 0644 "$dst.tmp" && mv "$dst.tmp" "$dst"
+echo %s`, shellutils.Quote(dst), shellutils.Quote(keyURL), changed)
+	out, err := m.run(ctx, cmd)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(out, changed), nil
+}
+
+// ensureFile writes f if its content differs.
+func (m *manager) ensureFile(ctx context.Context, f file) (bool, error) {
+	exists, err := m.run(ctx, fmt.Sprintf("[ ! -e %s ] || echo exists", shellutils.Quote(f.path)))
+	if err != nil {
+		return false, err
+	}
+	var current bytes.Buffer
+	if strings.TrimSpace(exists) == "exists" {
+		if err := m.e.Download(ctx, f.path, &current); err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", f.path, err)
+		}
+	}
+
+	content := f.content
+	if f.line {
+		for _, line := range strings.Split(current.String(), "\n") {
+			if strings.TrimSpace(line) == f.content {
+				return false, nil
+			}
+		}
+		content = strings.TrimRight(current.String(), "\n") + "\n" + f.content + "\n"
+		content = strings.TrimLeft(content, "\n")
+	} else if current.String() == content {
+		return false, nil
+	}
+
+	if _, err := m.run(ctx, "mkdir -p "+shellutils.Quote(path.Dir(f.path))); err != nil {
+		return false, err
+	}
+	if err := m.e.Upload(ctx, strings.NewReader(content), f.path, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	return true, nil
+}
+
+func aptRepository(r *Repository) ([]file, error) {
+	if r.Suite == "" {
+		return nil, fmt.Errorf("apt repository %s has no suite", r.Name)
+	}
+
+	options := ""
+	switch {
+	case r.KeyURL != "":
+		options = "[signed-by=/etc/apt/keyrings/" + r.Name + ".asc] "
+	case r.Trusted:
+		options = "[trusted=yes] "
+	}
+	source := fmt.Sprintf("deb %s%s %s", options, r.URL, r.Suite)
+	if len(r.Components) > 0 {
+		source += " " + strings.Join(r.Components, " ")
+	}
+	files := []file{{path: "/etc/apt/sources.list.d/" + r.Name + ".list", content: source + "\n"}}
+
+	if r.Priority != 0 {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil, err
+		}
+		pkgs := "*"
+		if len(r.Packages) > 0 {
+			pkgs = strings.Join(r.Packages, " ")
+		}
+		// local repositories have no origin host.
+		pin := "origin " + u.Hostname()
+		if u.Scheme == "file" {
+			pin = `origin ""`
+		}
+		files = append(files, file{
+			path:    "/etc/apt/preferences.d/" + r.Name,
+			content: fmt.Sprintf("Package: %s\nPin: %s\nPin-Priority: %d\n", pkgs, pin, r.Priority),
+		})
+	}
+
+	return files, nil
+}
+
+func yumRepository(r *Repository) ([]file, error) {
+	return []file{{path: "/etc/yum.repos.d/" + r.Name + ".repo", content: rpmRepo(r, "includepkgs")}}, nil
+}
+
+func zypperRepository(r *Repository) ([]file, error) {
+	if len(r.Packages) > 0 {
+		return nil, errors.New("zypper repositories can not be restricted to packages")
+	}
+	return []file{{path: "/etc/zypp/repos.d/" + r.Name + ".repo", content: rpmRepo(r, "")}}, nil
+}
+
+// rpmRepo returns the ini file of a dnf, yum or zypper repository.
+func rpmRepo(r *Repository, includeKey string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\nname=%s\nbaseurl=%s\nenabled=1\nautorefresh=1\n", r.Name, r.Name, r.URL)
+	if r.KeyURL != "" {
+		fmt.Fprintf(&b, "gpgcheck=1\ngpgkey=%s\n", r.KeyURL)
+	} else {
+		b.WriteString("gpgcheck=0\n")
+	}
+	if r.Priority != 0 {
+		fmt.Fprintf(&b, "priority=%d\n", r.Priority)
+	}
+	if includeKey != "" && len(r.Packages) > 0 {
+		fmt.Fprintf(&b, "%s=%s\n", includeKey, strings.Join(r.Packages, " "))
+	}
+	return b.String()
+}
+
+func apkRepository(r *Repository) ([]file, error) {
+	if r.Priority != 0 || len(r.Packages) > 0 {
+		return nil, errors.New("apk repositories can not be pinned")
+	}
+	return []file{{path: "/etc/apk/repositories", content: r.URL, line: true}}, nil
+}
@@ -0,0 +1,358 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// SupportedOS are the tested versions of each os ID, other versions of them only warn.
+var SupportedOS = map[string][]string{
+	"ubuntu":    {"20.04", "22.04", "24.04"},
+	"debian":    {"11", "12"},
+	"rhel":      {"8", "9"},
+	"rocky":     {"8", "9"},
+	"almalinux": {"8", "9"},
+	"centos":    {"7", "8", "9"},
+	"openEuler": {"20.03", "22.03", "24.03"},
+	"kylin":     {"V10"},
+}
+
+func checkSudo(ctx context.Context, e executor.Executor, r *HostReport) {
+	out, err := executor.Output(ctx, e, "id -u")
+	if err != nil {
+		r.add(CheckSudo, LevelFail, "failed to run as root: %s", message(out, err))
+		return
+	}
+	if uid := strings.TrimSpace(string(out)); uid != "0" {
+		r.add(CheckSudo, LevelFail, "commands run as uid %s, log in as root or configure become", uid)
+		return
+	}
+	r.add(CheckSudo, LevelPass, "commands run as root")
+}
+
+func checkOS(ctx context.Context, e executor.Executor, r *HostReport) {
+	f, err := e.Facts(ctx)
+	if err != nil {
+		r.add(CheckOS, LevelFail, "%v", err)
+		return
+	}
+	level, msg := osSupport(f)
+	r.add(CheckOS, level, "%s", msg)
+}
+
+// osSupport returns whether the os of the facts is supported, versions match by prefix
+// so that 9.3 is supported as 9.
+func osSupport(f *executor.Facts) (Level, string) {
+	name := f.OSName
+	if name == "" {
+		name = strings.TrimSpace(f.OS + " " + f.OSVersion)
+	}
+	name += " " + f.Arch
+
+	versions, ok := SupportedOS[f.OS]
+	if !ok {
+		return LevelFail, "unsupported os " + name
+	}
+	for _, v := range versions {
+		if f.OSVersion == v || strings.HasPrefix(f.OSVersion, v+".") {
+			return LevelPass, name
+		}
+	}
+	return LevelWarn, "untested version of " + name
+}
+
+func checkPorts(ctx context.Context, e executor.Executor, r *HostReport, ports []int) {
+	if len(ports) == 0 {
+		return
+	}
+	out, err := executor.Output(ctx, e, "ss -Hltn 2>/dev/null || netstat -ltn")
+	if err != nil {
+		r.add(CheckPorts, LevelFail, "failed to list listening ports: %s", message(out, err))
+		return
+	}
+
+	listening := parseListeningPorts(string(out))
+	var used, free []string
+	for _, port := range ports {
+		if listening[port] {
+			used = append(used, strconv.Itoa(port))
+		} else {
+			free = append(free, strconv.Itoa(port))
+		}
+	}
+	if len(used) > 0 {
+		r.add(CheckPorts, LevelFail, "ports in use: %s", strings.Join(used, ", "))
+		return
+	}
+	r.add(CheckPorts, LevelPass, "ports free: %s", strings.Join(free, ", "))
+}
+
+// parseListeningPorts returns the ports of the local address column of ss or netstat.
+func parseListeningPorts(out string) map[int]bool {
+	ports := map[int]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		i := strings.LastIndex(fields[3], ":")
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.Atoi(fields[3][i+1:]); err == nil {
+			ports[port] = true
+		}
+	}
+	return ports
+}
+
+func checkDisks(ctx context.Context, e executor.Executor, r *HostReport, disks map[string]int64) {
+	dirs := make([]string, 0, len(disks))
+	for dir := range disks {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		// df the nearest existing parent of directories to be created.
+		cmd := fmt.Sprintf(`d=%s; while [ ! -e "$d" ]; do d=$(dirname "$d"); done; df -Pk "$d" | awk 'NR==2 {print $4}'`,
+			shellutils.Quote(dir))
+		out, err := executor.Output(ctx, e, cmd)
+		if err != nil {
+			r.add(CheckDisk, LevelFail, "%s: %s", dir, message(out, err))
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			r.add(CheckDisk, LevelFail, "%s: unexpected df output %q", dir, strings.TrimSpace(string(out)))
+			continue
+		}
+
+		free := float64(kb) / (1 << 20)
+		if want := disks[dir]; free < float64(want) {
+			r.add(CheckDisk, LevelFail, "%s: %.1f GiB free, %d GiB required", dir, free, want)
+			continue
+		}
+		r.add(CheckDisk, LevelPass, "%s: %.1f GiB free", dir, free)
+	}
+}
+
+// clockOffset returns the difference between the clock of the host and the local one,
+// assuming the date is read in the middle of the round trip.
+func clockOffset(ctx context.Context, e executor.Executor) (time.Duration, error) {
+	start := time.Now()
+	out, err := executor.Output(ctx, e, "date +%s%N")
+	end := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the clock: %s", message(out, err))
+	}
+	ns, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected date output %q", strings.TrimSpace(string(out)))
+	}
+	return time.Unix(0, ns).Sub(start.Add(end.Sub(start) / 2)), nil
+}
+
+// checkClockSkew compares the clock of each reachable host to the median of all hosts.
+func checkClockSkew(probes []*probe, maxSkew time.Duration) {
+	var offsets []time.Duration
+	for _, p := range probes {
+		if p.reachable && !p.hasCheck(CheckClockSkew) {
+			offsets = append(offsets, p.offset)
+		}
+	}
+	if len(offsets) == 0 {
+		return
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	median := offsets[len(offsets)/2]
+
+	for _, p := range probes {
+		if !p.reachable || p.hasCheck(CheckClockSkew) {
+			continue
+		}
+		skew := (p.offset - median).Round(time.Millisecond)
+		switch abs := max(skew, -skew); {
+		case abs > maxSkew:
+			p.report.add(CheckClockSkew, LevelFail, "%s from the other hosts, configure time sync", skew)
+		case abs > maxSkew/2:
+			p.report.add(CheckClockSkew, LevelWarn, "%s from the other hosts", skew)
+		default:
+			p.report.add(CheckClockSkew, LevelPass, "%s from the other hosts", skew)
+		}
+	}
+}
+
+// peer is a host whose name should resolve to its address on the other hosts.
+type peer struct {
+	name    string
+	address string
+}
+
+func peersOf(hosts []component.Host) []peer {
+	var peers []peer
+	for i := range hosts {
+		h := &hosts[i]
+		addr := h.InternalAddress
+		if addr == "" {
+			addr = h.Address
+		}
+		if h.Name == "" || executor.IsLocal(h) || net.ParseIP(addr) == nil {
+			continue
+		}
+		peers = append(peers, peer{name: h.Name, address: addr})
+	}
+	return peers
+}
+
+func checkDNS(ctx context.Context, e executor.Executor, r *HostReport, h *component.Host, peers []peer) {
+	var names []string
+	for _, p := range peers {
+		if p.name != h.Name {
+			names = append(names, p.name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	cmd := fmt.Sprintf(`for n in %s; do printf '%%s' "$n"; getent ahosts "$n" | awk '{print $1}' | sort -u | tr '\n' ' ' | sed 's/^/ /'; echo; done`,
+		shellutils.Join(names...))
+	out, err := executor.Output(ctx, e, cmd)
+	if err != nil {
+		r.add(CheckDNS, LevelFail, "failed to resolve peers: %s", message(out, err))
+		return
+	}
+	resolved := parseResolved(string(out))
+
+	var unresolved, wrong []string
+	for _, p := range peers {
+		if p.name == h.Name {
+			continue
+		}
+		addrs, ok := resolved[p.name]
+		switch {
+		case !ok || len(addrs) == 0:
+			unresolved = append(unresolved, p.name)
+		case !slices.Contains(addrs, p.address):
+			wrong = append(wrong, fmt.Sprintf("%s resolves to %s instead of %s", p.name, strings.Join(addrs, ","), p.address))
+		}
+	}
+
+	switch {
+	case len(wrong) > 0:
+		r.add(CheckDNS, LevelFail, "%s", strings.Join(wrong, "; "))
+	case len(unresolved) > 0:
+		r.add(CheckDNS, LevelWarn, "unresolved peers: %s, run peta init os to add them to /etc/hosts", strings.Join(unresolved, ", "))
+	default:
+		r.add(CheckDNS, LevelPass, "%d peers resolved", len(names))
+	}
+}
+
+// parseResolved parses lines of a name followed by its addresses.
+func parseResolved(out string) map[string][]string {
+	resolved := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			resolved[fields[0]] = fields[1:]
+		}
+	}
+	return resolved
+}
+
+// mtuOf returns the interface of the internal address of the host and its MTU, the
+// interface of the default route is used if the address is not local, like behind NAT.
+func mtuOf(ctx context.Context, e executor.Executor, h *component.Host) (string, int, error) {
+	addr := h.InternalAddress
+	if addr == "" {
+		addr = h.Address
+	}
+	cmd := fmt.Sprintf(`i=$(ip -o addr show | awk -v a=%s '{split($4, p, "/"); if (p[1] == a) {print $2; exit}}')
+[ -n "$i" ] || i=$(ip route show default | awk '{for (n = 1; n < NF; n++) if ($n == "dev") {print $(n+1); exit}}')
+echo "$i $(cat /sys/class/net/$i/mtu)"`, shellutils.Quote(addr))
+	out, err := executor.Output(ctx, e, cmd)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read the mtu: %s", message(out, err))
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("unexpected mtu output %q", strings.TrimSpace(string(out)))
+	}
+	mtu, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected mtu output %q", strings.TrimSpace(string(out)))
+	}
+	return fields[0], mtu, nil
+}
+
+// checkMTU fails the hosts whose MTU differs from the most common one.
+func checkMTU(probes []*probe) {
+	count := map[int]int{}
+	for _, p := range probes {
+		if p.mtu > 0 {
+			count[p.mtu]++
+		}
+	}
+	common := 0
+	for mtu, n := range count {
+		if n > count[common] || (n == count[common] && mtu < common) {
+			common = mtu
+		}
+	}
+
+	for _, p := range probes {
+		if p.mtu == 0 {
+			continue
+		}
+		if p.mtu != common {
+			p.report.add(CheckMTU, LevelFail, "%s mtu %d, the other hosts use %d", p.iface, p.mtu, common)
+			continue
+		}
+		p.report.add(CheckMTU, LevelPass, "%s mtu %d", p.iface, p.mtu)
+	}
+}
+
+func (p *probe) hasCheck(name string) bool {
+	for _, c := range p.report.Checks {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// message returns the output of a failed command, or the error if there is none.
+func message(out []byte, err error) string {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
@@ -0,0 +1,254 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package preflight checks the hosts of a blueprint before anything is installed.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+// Level is the outcome of a check.
+type Level string
+
+const (
+	LevelPass Level = "pass"
+	LevelWarn Level = "warn"
+	LevelFail Level = "fail"
+)
+
+// Names of the checks.
+const (
+	CheckSSH       = "ssh"
+	CheckSudo      = "sudo"
+	CheckOS        = "os"
+	CheckPorts     = "ports"
+	CheckDisk      = "disk"
+	CheckClockSkew = "clock-skew"
+	CheckDNS       = "dns"
+	CheckMTU       = "mtu"
+)
+
+var (
+	// DefaultPorts must be free on every host.
+	DefaultPorts = []int{5432}
+	// DefaultDisks is the minimum free space in GiB of the target directories.
+	DefaultDisks = map[string]int64{"/var/lib/postgresql": 10}
+)
+
+// DefaultMaxClockSkew is the clock difference between hosts above which the check fails,
+// it warns above half of it.
+const DefaultMaxClockSkew = time.Second
+
+// Check is the result of a check on a host.
+type Check struct {
+	Name    string `json:"name"`
+	Level   Level  `json:"level"`
+	Message string `json:"message,omitempty"`
+}
+
+// HostReport holds the checks of a host.
+type HostReport struct {
+	Host    string  `json:"host"`
+	Address string  `json:"address"`
+	Checks  []Check `json:"checks"`
+}
+
+// Level returns the worst level of the checks of the host.
+func (r *HostReport) Level() Level {
+	level := LevelPass
+	for _, c := range r.Checks {
+		switch {
+		case c.Level == LevelFail:
+			return LevelFail
+		case c.Level == LevelWarn:
+			level = LevelWarn
+		}
+	}
+	return level
+}
+
+func (r *HostReport) add(name string, level Level, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// Report holds the checks of all hosts.
+type Report struct {
+	Hosts []HostReport `json:"hosts"`
+}
+
+// Failed returns true if a check failed on any host.
+func (r *Report) Failed() bool {
+	for i := range r.Hosts {
+		if r.Hosts[i].Level() == LevelFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of checks with the level.
+func (r *Report) Count(level Level) int {
+	n := 0
+	for _, h := range r.Hosts {
+		for _, c := range h.Checks {
+			if c.Level == level {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Options of the checks.
+type Options struct {
+	// Concurrency is the maximum number of hosts checked at the same time.
+	Concurrency int
+	// Timeout bounds the connection and all checks on each host.
+	Timeout time.Duration
+	// Ports must not be listened on.
+	Ports []int
+	// Disks is the minimum free space in GiB of directories, the nearest existing parent
+	// is checked if a directory does not exist yet.
+	Disks map[string]int64
+	// MaxClockSkew is the clock difference between hosts above which the check fails.
+	MaxClockSkew time.Duration
+}
+
+// NewOptions returns the default options.
+func NewOptions() Options {
+	return Options{
+		Concurrency:  runner.DefaultConcurrency,
+		Timeout:      time.Minute,
+		Ports:        DefaultPorts,
+		Disks:        DefaultDisks,
+		MaxClockSkew: DefaultMaxClockSkew,
+	}
+}
+
+// Run checks the hosts, the peers of DNS checks are all the hosts of the blueprint.
+func Run(ctx context.Context, b *types.Blueprint, hosts []component.Host, o Options) *Report {
+	if o.Timeout <= 0 {
+		o.Timeout = time.Minute
+	}
+	if o.MaxClockSkew <= 0 {
+		o.MaxClockSkew = DefaultMaxClockSkew
+	}
+
+	peers := peersOf(b.Hosts())
+	probes := make([]*probe, len(hosts))
+	runner.ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+		probes[i] = checkHost(ctx, h, peers, o)
+	})
+
+	checkClockSkew(probes, o.MaxClockSkew)
+	checkMTU(probes)
+
+	report := &Report{}
+	for _, p := range probes {
+		report.Hosts = append(report.Hosts, p.report)
+	}
+	return report
+}
+
+// probe is what is learned about a host, for the checks comparing hosts.
+type probe struct {
+	report    HostReport
+	reachable bool
+	// offset is the difference between the clock of the host and the local one.
+	offset time.Duration
+	mtu    int
+	iface  string
+}
+
+func checkHost(ctx context.Context, h *component.Host, peers []peer, o Options) *probe {
+	p := &probe{report: HostReport{Host: executor.Name(h), Address: h.Address}}
+	r := &p.report
+
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		r.add(CheckSSH, LevelFail, "%v", err)
+		return p
+	}
+	defer func() { _ = e.Close() }()
+
+	p.reachable = true
+	if executor.IsLocal(h) {
+		r.add(CheckSSH, LevelPass, "local host")
+	} else {
+		r.add(CheckSSH, LevelPass, "connected as %s", h.User)
+	}
+
+	checkSudo(ctx, e, r)
+	checkOS(ctx, e, r)
+	checkPorts(ctx, e, r, o.Ports)
+	checkDisks(ctx, e, r, o.Disks)
+	p.offset, err = clockOffset(ctx, e)
+	if err != nil {
+		r.add(CheckClockSkew, LevelFail, "%v", err)
+	}
+	checkDNS(ctx, e, r, h, peers)
+	p.iface, p.mtu, err = mtuOf(ctx, e, h)
+	if err != nil {
+		r.add(CheckMTU, LevelFail, "%v", err)
+	}
+
+	return p
+}
+
+// PrintReport writes a table of the checks of each host and a summary.
+func PrintReport(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tCHECK\tRESULT\tMESSAGE")
+	for _, h := range r.Hosts {
+		checks := append([]Check(nil), h.Checks...)
+		// keep the order of the checks of a host stable.
+		sort.SliceStable(checks, func(i, j int) bool { return order[checks[i].Name] < order[checks[j].Name] })
+		for _, c := range checks {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.Host, c.Name, c.Level, c.Message)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n",
+		r.Count(LevelPass), r.Count(LevelWarn), r.Count(LevelFail))
+	return err
+}
+
+var order = map[string]int{
+	CheckSSH:       0,
+	CheckSudo:      1,
+	CheckOS:        2,
+	CheckPorts:     3,
+	CheckDisk:      4,
+	CheckClockSkew: 5,
+	CheckDNS:       6,
+	CheckMTU:       7,
+}
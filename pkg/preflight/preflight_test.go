@@ -0,0 +1,78 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package preflight
+
+import (
+	"testing"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+)
+
+func TestOSSupport(t *testing.T) {
+	tests := []struct {
+		facts executor.Facts
+		want  Level
+	}{
+		{executor.Facts{OS: "rocky", OSVersion: "9.3"}, LevelPass},
+		{executor.Facts{OS: "ubuntu", OSVersion: "22.04"}, LevelPass},
+		{executor.Facts{OS: "ubuntu", OSVersion: "22.10"}, LevelWarn},
+		{executor.Facts{OS: "arch"}, LevelFail},
+	}
+	for _, tt := range tests {
+		if got, msg := osSupport(&tt.facts); got != tt.want {
+			t.Errorf("osSupport(%s %s) = %s (%s), want %s", tt.facts.OS, tt.facts.OSVersion, got, msg, tt.want)
+		}
+	}
+}
+
+func TestParseListeningPorts(t *testing.T) {
+	ss := `LISTEN 0      4096   127.0.0.53%lo:53        0.0.0.0:*
+LISTEN 0      128          0.0.0.0:22        0.0.0.0:*
+LISTEN 0      244             [::]:5432         [::]:*`
+	ports := parseListeningPorts(ss)
+	for _, port := range []int{53, 22, 5432} {
+		if !ports[port] {
+			t.Errorf("port %d not found in %v", port, ports)
+		}
+	}
+	if ports[8008] {
+		t.Error("port 8008 should be free")
+	}
+}
+
+func TestCrossHostChecks(t *testing.T) {
+	probes := []*probe{
+		{reachable: true, offset: 10 * time.Millisecond, mtu: 1500, iface: "eth0"},
+		{reachable: true, offset: 0, mtu: 1500, iface: "eth0"},
+		{reachable: true, offset: 3 * time.Second, mtu: 9000, iface: "eth1"},
+		{reachable: false},
+	}
+	checkClockSkew(probes, time.Second)
+	checkMTU(probes)
+
+	want := []Level{LevelPass, LevelPass, LevelFail, LevelPass}
+	for i, p := range probes {
+		if got := p.report.Level(); got != want[i] {
+			t.Errorf("probe %d: level %s, want %s: %+v", i, got, want[i], p.report.Checks)
+		}
+	}
+	if len(probes[3].report.Checks) != 0 {
+		t.Errorf("unreachable host should not be compared: %+v", probes[3].report.Checks)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/queue"
+)
+
+const (
+	DefaultConcurrency = 10
+	DefaultTimeout     = 10 * time.Minute
+)
+
+// Options of running a command across hosts.
+type Options struct {
+	// Concurrency is the maximum number of hosts running the command at the same time.
+	Concurrency int
+	// Timeout bounds the connection and the command on each host.
+	Timeout time.Duration
+	// Output receives the output of all hosts as it is produced, each line prefixed with the host name.
+	Output io.Writer
+	// KeepOutput keeps the output of each host in its result.
+	KeepOutput bool
+}
+
+// Result is the outcome of the command on a host.
+type Result struct {
+	Host     string    `json:"host"`
+	Address  string    `json:"address"`
+	ExitCode int       `json:"exitCode"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the command took on the host, including the connection.
+func (r *Result) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Succeeded returns true if the command exited with 0.
+func (r *Result) Succeeded() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+// Run runs cmd on every host through the queue worker pool and returns the results in the order of hosts.
+func Run(ctx context.Context, hosts []component.Host, cmd string, o Options) []Result {
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(hosts))
+	if len(hosts) == 0 {
+		return results
+	}
+
+	var output io.Writer = io.Discard
+	if o.Output != nil {
+		output = &syncWriter{w: o.Output}
+	}
+
+	ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		results[i] = runOnHost(ctx, h, cmd, o, output)
+	})
+
+	return results
+}
+
+// ForEach calls fn for every host through the queue worker pool, at most concurrency
+// hosts at the same time, and returns when all calls are done.
+func ForEach(hosts []component.Host, concurrency int, fn func(i int, h *component.Host)) {
+	if len(hosts) == 0 {
+		return
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	q := queue.NewQueue(len(hosts), min(concurrency, len(hosts)))
+	q.Run()
+	for i := range hosts {
+		q.Push(queue.NewJob(i, func(v interface{}) {
+			i := v.(int)
+			fn(i, &hosts[i])
+		}))
+	}
+	q.Terminate()
+}
+
+func runOnHost(ctx context.Context, h *component.Host, cmd string, o Options, output io.Writer) Result {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	r := Result{Host: executor.Name(h), Address: h.Address, Start: time.Now()}
+
+	pw := newPrefixWriter(output, r.Host)
+	var out bytes.Buffer
+	var w io.Writer = pw
+	if o.KeepOutput {
+		w = io.MultiWriter(pw, &out)
+	}
+
+	err := run(ctx, h, cmd, w)
+	pw.Flush()
+
+	r.ExitCode = executor.ExitStatus(err)
+	if err != nil && r.ExitCode < 0 {
+		r.Error = err.Error()
+	}
+	r.Output = out.String()
+	r.End = time.Now()
+
+	return r
+}
+
+func run(ctx context.Context, h *component.Host, cmd string, w io.Writer) error {
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = e.Close() }()
+
+	return e.Run(ctx, cmd, w)
+}
+
+// PrintSummary writes a table of the exit codes and durations of the results.
+func PrintSummary(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tADDRESS\tEXIT\tDURATION\tERROR")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n",
+			r.Host, r.Address, r.ExitCode, r.Duration().Round(time.Millisecond), r.Error)
+	}
+	return tw.Flush()
+}
+
+// Failed returns the number of results which did not succeed.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.Succeeded() {
+			n++
+		}
+	}
+	return n
+}
+
+// prefixWriter writes complete lines prefixed with the host name.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	pending []byte
+}
+
+func newPrefixWriter(w io.Writer, host string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: "[" + host + "] "}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.pending = append(p.pending, b...)
+	for {
+		i := bytes.IndexByte(p.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.pending[:i]); err != nil {
+			return 0, err
+		}
+		p.pending = p.pending[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes the last line which has no line break.
+func (p *prefixWriter) Flush() {
+	if len(p.pending) > 0 {
+		_ = p.writeLine(p.pending)
+		p.pending = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	// the output of a pty ends lines with \r\n.
+	_, err := io.WriteString(p.w, p.prefix+strings.TrimRight(string(line), "\r")+"\n")
+	return err
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
@@ -0,0 +1,263 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package auditing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"peta.io/peta/pkg/server/request"
+)
+
+const testPolicy = `
+rules:
+  - level: None
+    nonResourceURLs: [/healthz, /metrics*]
+  - level: RequestResponse
+    userGroups: [auditors]
+  - level: Request
+    apiGroups: [iam.peta.io]
+    resources: [roles, rolebindings/*]
+    verbs: [create, update]
+  - level: Metadata
+`
+
+func TestPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	if err := os.WriteFile(path, []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &request.User{Name: "alice"}
+	auditor := &request.User{Name: "bob", Groups: []string{"auditors"}}
+	resource := func(verb, group, resource, subresource string) *request.Info {
+		return &request.Info{IsResourceRequest: true, Verb: verb, APIGroup: group, Resource: resource, Subresource: subresource}
+	}
+
+	tests := []struct {
+		name string
+		info *request.Info
+		user *request.User
+		want Level
+	}{
+		{"non-resource path", &request.Info{Verb: "GET", Path: "/healthz"}, alice, LevelNone},
+		{"non-resource prefix", &request.Info{Verb: "GET", Path: "/metrics/x"}, auditor, LevelNone},
+		{"user group", resource("list", "host.peta.io", "hosts", ""), auditor, LevelRequestResponse},
+		{"resource and verb", resource("create", "iam.peta.io", "roles", ""), alice, LevelRequest},
+		{"subresource wildcard", resource("update", "iam.peta.io", "rolebindings", "status"), alice, LevelRequest},
+		{"other verb", resource("list", "iam.peta.io", "roles", ""), alice, LevelMetadata},
+		{"other group", resource("create", "host.peta.io", "roles", ""), alice, LevelMetadata},
+		{"anonymous", &request.Info{Verb: "GET", Path: "/version"}, nil, LevelMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.LevelOf(tt.info, tt.user); got != tt.want {
+				t.Errorf("LevelOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	invalid := &Policy{Rules: []PolicyRule{{Level: "Everything"}}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	mixed := &Policy{Rules: []PolicyRule{{Level: LevelMetadata, Resources: []string{"hosts"}, NonResourceURLs: []string{"/metrics"}}}}
+	if err := mixed.Validate(); err == nil {
+		t.Error("expected an error for resources mixed with non-resource urls")
+	}
+}
+
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]*Event
+	closed  bool
+	block   chan struct{}
+}
+
+func (f *fakeSink) Name() string {
+	return "fake"
+}
+
+func (f *fakeSink) Write(_ context.Context, events []*Event) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, events)
+	return nil
+}
+
+func (f *fakeSink) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeSink) sizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, b := range f.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestBackendBatches(t *testing.T) {
+	sink := &fakeSink{}
+	b := NewBackend(100, 3, time.Hour, time.Second, sink)
+	b.Start()
+	for i := 0; i < 7; i++ {
+		b.Log(&Event{})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.sizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.Shutdown()
+
+	sizes := sink.sizes()
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Errorf("batch sizes = %v, want [3 3 1]", sizes)
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+}
+
+func TestBackendMaxWait(t *testing.T) {
+	sink := &fakeSink{}
+	b := NewBackend(100, 10, 20*time.Millisecond, time.Second, sink)
+	b.Start()
+	defer b.Shutdown()
+	b.Log(&Event{})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sizes := sink.sizes(); len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("batch sizes = %v, want [1]", sizes)
+	}
+}
+
+func TestBackendDropsWhenFull(t *testing.T) {
+	sink := &fakeSink{block: make(chan struct{})}
+	b := NewBackend(2, 1, time.Hour, time.Second, sink)
+	b.Start()
+
+	// the first event is taken by the blocked sink, two fill the buffer.
+	b.Log(&Event{})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.events) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		b.Log(&Event{})
+	}
+	if got := b.dropped.Load(); got != 3 {
+		t.Errorf("dropped = %d, want 3", got)
+	}
+	close(sink.block)
+	b.Shutdown()
+	if got := len(sink.sizes()); got != 3 {
+		t.Errorf("written batches = %d, want 3", got)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	sink := NewFileSink(path, 1, 1, 1)
+	events := []*Event{
+		NewEvent(LevelMetadata, &request.Info{Verb: "list", Resource: "hosts"}, &request.User{Name: "alice"}, "/apis/host.peta.io/v1alpha2/hosts"),
+		NewEvent(LevelRequest, &request.Info{Verb: "create", Resource: "exec"}, nil, "/apis/host.peta.io/v1alpha2/exec"),
+	}
+	if err := sink.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 2 || got[0].User.Name != "alice" || got[1].Resource != "exec" || got[0].ID != events[0].ID {
+		t.Errorf("read events %+v", got)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body, want string
+	}{
+		{
+			body: `{"metadata":{"name":"m1"},"spec":{"endpoint":"https://10.0.0.2:9443","bearerToken":"abc"}}`,
+			want: `{"metadata":{"name":"m1"},"spec":{"endpoint":"https://10.0.0.2:9443","bearerToken":"******"}}`,
+		},
+		{
+			body: `{"user": "root", "password": "p\"w", "become": {"password":"s"}, "privateKey": "-----BEGIN"}`,
+			want: `{"user": "root", "password": "******", "become": {"password":"******"}, "privateKey": "******"}`,
+		},
+		{
+			body: `{"command":"echo password","Passphrase":"x"}`,
+			want: `{"command":"echo password","Passphrase":"******"}`,
+		},
+		// the body is truncated in the middle of the secret
+		{body: `{"becomePassword":"sec`, want: `{"becomePassword":"******"`},
+		{
+			body: `[{"op":"replace","path":"/spec/components/0/hosts/0/password","value":"s3cret"},{"op":"add","path":"/spec/components/0/hosts/0/user","value":"root"}]`,
+			want: `[{"op":"replace","path":"/spec/components/0/hosts/0/password","value":"******"},{"op":"add","path":"/spec/components/0/hosts/0/user","value":"root"}]`,
+		},
+		{
+			body: `[{"value": "-----BEGIN", "op": "add", "path": "/spec/privateKey"}]`,
+			want: `[{"value": "******", "op": "add", "path": "/spec/privateKey"}]`,
+		},
+		{
+			body: `{"blueprint":"b1","command":"PGPASSWORD=\"p w\" psql -c 'select 1'"}`,
+			want: `{"blueprint":"b1","command":"PGPASSWORD=****** psql -c 'select 1'"}`,
+		},
+		{body: `{"command":"mysql --password s3cret`, want: `{"command":"mysql --password ******`},
+	}
+	for _, tt := range tests {
+		if got := RedactBody(tt.body); got != tt.want {
+			t.Errorf("RedactBody(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package auditing
+
+import (
+	"fmt"
+
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/server/request"
+)
+
+// Auditor picks the level of requests and records their events.
+type Auditor struct {
+	policy      *Policy
+	backend     *Backend
+	maxBodySize int
+}
+
+// New returns the auditor of o, p is the database of the database sink.
+func New(o *Options, p persistence.Persister) (*Auditor, error) {
+	policy := DefaultPolicy()
+	if o.PolicyFile != "" {
+		var err error
+		if policy, err = LoadPolicy(o.PolicyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var sinks []Sink
+	if o.LogPath != "" {
+		sinks = append(sinks, NewFileSink(o.LogPath, o.LogMaxSize, o.LogMaxAge, o.LogMaxBackups))
+	}
+	if o.Database {
+		sinks = append(sinks, NewDatabaseSink(p))
+	}
+	if o.WebhookURL != "" {
+		sinks = append(sinks, NewWebhookSink(o.WebhookURL, o.WebhookTimeout))
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no audit sink is configured")
+	}
+
+	return &Auditor{
+		policy:      policy,
+		backend:     NewBackend(o.BufferSize, o.BatchSize, o.BatchMaxWait, o.WebhookTimeout, sinks...),
+		maxBodySize: o.MaxBodySize,
+	}, nil
+}
+
+// LevelOf returns the level of the request of info sent by user.
+func (a *Auditor) LevelOf(info *request.Info, user *request.User) Level {
+	return a.policy.LevelOf(info, user)
+}
+
+// MaxBodySize is the number of bytes of bodies kept in events.
+func (a *Auditor) MaxBodySize() int {
+	return a.maxBodySize
+}
+
+// Log records e without waiting for the sinks.
+func (a *Auditor) Log(e *Event) {
+	a.backend.Log(e)
+}
+
+// Start sends the events to the sinks in the background.
+func (a *Auditor) Start() {
+	a.backend.Start()
+}
+
+// Shutdown sends the pending events and closes the sinks.
+func (a *Auditor) Shutdown() {
+	a.backend.Shutdown()
+}
@@ -0,0 +1,140 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package auditing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"peta.io/peta/pkg/log"
+)
+
+// Backend buffers events and sends them to the sinks in batches, so the requests
+// never wait for the sinks. Events are dropped while the buffer is full.
+type Backend struct {
+	sinks     []Sink
+	events    chan *Event
+	batchSize int
+	batchWait time.Duration
+	timeout   time.Duration
+
+	dropped atomic.Int64
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewBackend returns the backend buffering up to bufferSize events, a batch is
+// sent once it has batchSize events or its first event waited batchWait.
+func NewBackend(bufferSize, batchSize int, batchWait, timeout time.Duration, sinks ...Sink) *Backend {
+	return &Backend{
+		sinks:     sinks,
+		events:    make(chan *Event, bufferSize),
+		batchSize: batchSize,
+		batchWait: batchWait,
+		timeout:   timeout,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Log queues e, it never blocks.
+func (b *Backend) Log(e *Event) {
+	select {
+	case b.events <- e:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// Start sends the batches of events in the background until Shutdown.
+func (b *Backend) Start() {
+	b.startOnce.Do(func() {
+		go b.run()
+	})
+}
+
+// Shutdown sends the queued events, closes the sinks and waits for them.
+func (b *Backend) Shutdown() {
+	b.Start()
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+	<-b.done
+}
+
+func (b *Backend) run() {
+	defer close(b.done)
+
+	batch := make([]*Event, 0, b.batchSize)
+	timer := time.NewTimer(b.batchWait)
+	timer.Stop()
+
+	flush := func() {
+		if len(batch) > 0 {
+			b.send(batch)
+			batch = make([]*Event, 0, b.batchSize)
+		}
+		timer.Stop()
+	}
+
+	for {
+		select {
+		case e := <-b.events:
+			if len(batch) == 0 {
+				timer.Reset(b.batchWait)
+			}
+			batch = append(batch, e)
+			if len(batch) >= b.batchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-b.stop:
+			for len(b.events) > 0 {
+				batch = append(batch, <-b.events)
+				if len(batch) >= b.batchSize {
+					flush()
+				}
+			}
+			flush()
+			for _, sink := range b.sinks {
+				if err := sink.Close(); err != nil {
+					log.Errorf("failed to close audit sink %s: %v", sink.Name(), err)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (b *Backend) send(batch []*Event) {
+	if n := b.dropped.Swap(0); n > 0 {
+		log.Warnf("dropped %d audit events, the buffer was full", n)
+	}
+	for _, sink := range b.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		if err := sink.Write(ctx, batch); err != nil {
+			log.Errorf("failed to write %d audit events to %s: %v", len(batch), sink.Name(), err)
+		}
+		cancel()
+	}
+}
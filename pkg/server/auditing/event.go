@@ -0,0 +1,166 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package auditing records the requests to the api server as audit events.
+package auditing
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/transcript"
+)
+
+// Level is how much of a request is recorded.
+type Level string
+
+const (
+	// LevelNone records nothing.
+	LevelNone Level = "None"
+	// LevelMetadata records the user, resource, verb and response status of the request.
+	LevelMetadata Level = "Metadata"
+	// LevelRequest records the metadata and the request body.
+	LevelRequest Level = "Request"
+	// LevelRequestResponse records the metadata and the request and response bodies.
+	LevelRequestResponse Level = "RequestResponse"
+)
+
+var levels = map[Level]int{
+	LevelNone:            0,
+	LevelMetadata:        1,
+	LevelRequest:         2,
+	LevelRequestResponse: 3,
+}
+
+// Validate returns an error if l is not a known level.
+func (l Level) Validate() error {
+	if _, ok := levels[l]; !ok {
+		return fmt.Errorf("unknown audit level %q", l)
+	}
+	return nil
+}
+
+// Less returns true if l records less than other.
+func (l Level) Less(other Level) bool {
+	return levels[l] < levels[other]
+}
+
+// Redacted replaces the values of the secret fields of the bodies of events.
+const Redacted = "******"
+
+// secretName matches the names of secret fields, like password, becomePassword,
+// privateKey and bearerToken.
+const secretName = `(?i:password|passwd|passphrase|secret|token|privatekey)`
+
+var (
+	// secretField matches the string values of the JSON fields named like secrets, the
+	// last value of truncated bodies may have no closing quote.
+	secretField = regexp.MustCompile(`("[^"]*` + secretName + `[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	// secretPatch matches the operations of JSON patches of the fields named like
+	// secrets, like {"op":"replace","path":"/spec/password","value":"x"}.
+	secretPatch = regexp.MustCompile(`\{[^{}]*"path"\s*:\s*"(?:[^"]*/)?[^"/]*` + secretName + `[^"/]*"[^{}]*\}?`)
+	// patchValue matches the string value of an operation of a JSON patch.
+	patchValue = regexp.MustCompile(`("value"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	// commandField matches the commands run on hosts, like the one of exec.
+	commandField = regexp.MustCompile(`("command"\s*:\s*")((?:[^"\\]|\\.)*)`)
+)
+
+// RedactBody replaces the values of the secret fields of a JSON request or response
+// body, the values of the JSON patch operations of the secret fields, and the secrets
+// of the commands run on hosts, so that they are not recorded.
+func RedactBody(body string) string {
+	body = secretField.ReplaceAllString(body, `${1}"`+Redacted+`"`)
+	body = secretPatch.ReplaceAllStringFunc(body, func(op string) string {
+		return patchValue.ReplaceAllString(op, `${1}"`+Redacted+`"`)
+	})
+	return commandField.ReplaceAllStringFunc(body, func(field string) string {
+		m := commandField.FindStringSubmatch(field)
+		var command string
+		if err := json.Unmarshal([]byte(`"`+m[2]+`"`), &command); err != nil {
+			// the command is truncated
+			return m[1] + transcript.Redact(m[2])
+		}
+		redacted := transcript.Redact(command)
+		if redacted == command {
+			return field
+		}
+		quoted, _ := json.Marshal(redacted)
+		return m[1] + string(quoted[1:len(quoted)-1])
+	})
+}
+
+// Event is the record of a request.
+type Event struct {
+	ID    uuid.UUID `json:"auditID"`
+	Level Level     `json:"level"`
+
+	RequestURI string        `json:"requestURI"`
+	Verb       string        `json:"verb"`
+	User       *request.User `json:"user,omitempty"`
+	SourceIP   string        `json:"sourceIP,omitempty"`
+	UserAgent  string        `json:"userAgent,omitempty"`
+
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Cluster     string `json:"cluster,omitempty"`
+	Workspace   string `json:"workspace,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+
+	ResponseCode int `json:"responseCode"`
+
+	RequestReceivedAt   time.Time `json:"requestReceivedAt"`
+	ResponseCompletedAt time.Time `json:"responseCompletedAt"`
+
+	// RequestBody and ResponseBody are kept up to the max body size of the
+	// options, Truncated is true if either was cut.
+	RequestBody  string `json:"requestBody,omitempty"`
+	ResponseBody string `json:"responseBody,omitempty"`
+	Truncated    bool   `json:"truncated,omitempty"`
+}
+
+// NewEvent returns the event of the request of info sent by user, received now.
+func NewEvent(level Level, info *request.Info, user *request.User, requestURI string) *Event {
+	return &Event{
+		ID:                uuid.Must(uuid.NewV4()),
+		Level:             level,
+		RequestURI:        requestURI,
+		Verb:              info.Verb,
+		User:              user,
+		SourceIP:          info.SourceIP,
+		UserAgent:         info.UserAgent,
+		APIGroup:          info.APIGroup,
+		APIVersion:        info.APIVersion,
+		Resource:          info.Resource,
+		Subresource:       info.Subresource,
+		Name:              info.Name,
+		Cluster:           info.Cluster,
+		Workspace:         info.Workspace,
+		Namespace:         info.Namespace,
+		RequestReceivedAt: time.Now(),
+	}
+}
+
+// Latency returns how long the request took.
+func (e *Event) Latency() time.Duration {
+	return e.ResponseCompletedAt.Sub(e.RequestReceivedAt)
+}
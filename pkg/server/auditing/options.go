@@ -14,36 +14,157 @@
  *  You should have received a copy of the GNU Affero General Public License
  *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
  */
-
 package auditing
 
 import (
+	"fmt"
+	"net/url"
+	"time"
+
 	"github.com/spf13/pflag"
 )
 
 const (
-	Enabled = "auditing-enabled"
+	Enabled       = "auditing-enabled"
+	PolicyFile    = "audit-policy-file"
+	LogPath       = "audit-log-path"
+	LogMaxSize    = "audit-log-maxsize"
+	LogMaxAge     = "audit-log-maxage"
+	LogMaxBackups = "audit-log-maxbackup"
+	Database      = "audit-database"
+	WebhookURL    = "audit-webhook-url"
+	BufferSize    = "audit-buffer-size"
+	BatchSize     = "audit-batch-size"
+	BatchMaxWait  = "audit-batch-max-wait"
+	MaxBodySize   = "audit-max-body-size"
 )
 
 type Options struct {
-	Enable bool `json:"enable" yaml:"enable"`
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// PolicyFile is a YAML file of the rules picking the level of requests, the
+	// metadata of all requests is recorded without it.
+	PolicyFile string `json:"policyFile,omitempty" yaml:"policyFile,omitempty" mapstructure:"policyFile"`
+
+	// LogPath is the file events are written to as JSON lines, rotated once larger
+	// than LogMaxSize megabytes.
+	LogPath       string `json:"logPath,omitempty" yaml:"logPath,omitempty" mapstructure:"logPath"`
+	LogMaxSize    int    `json:"logMaxSize,omitempty" yaml:"logMaxSize,omitempty" mapstructure:"logMaxSize"`
+	LogMaxAge     int    `json:"logMaxAge,omitempty" yaml:"logMaxAge,omitempty" mapstructure:"logMaxAge"`
+	LogMaxBackups int    `json:"logMaxBackups,omitempty" yaml:"logMaxBackups,omitempty" mapstructure:"logMaxBackups"`
+
+	// Database saves events to the audit_events table.
+	Database bool `json:"database,omitempty" yaml:"database,omitempty" mapstructure:"database"`
+
+	// WebhookURL receives batches of events as a JSON array.
+	WebhookURL     string        `json:"webhookURL,omitempty" yaml:"webhookURL,omitempty" mapstructure:"webhookURL"`
+	WebhookTimeout time.Duration `json:"webhookTimeout,omitempty" yaml:"webhookTimeout,omitempty" mapstructure:"webhookTimeout"`
+
+	// BufferSize is the number of events waiting for the sinks, more are dropped.
+	BufferSize int `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty" mapstructure:"bufferSize"`
+	// BatchSize is the maximum number of events sent at once, a batch is sent
+	// after BatchMaxWait even if smaller.
+	BatchSize    int           `json:"batchSize,omitempty" yaml:"batchSize,omitempty" mapstructure:"batchSize"`
+	BatchMaxWait time.Duration `json:"batchMaxWait,omitempty" yaml:"batchMaxWait,omitempty" mapstructure:"batchMaxWait"`
+
+	// MaxBodySize is the number of bytes of the request and response bodies kept.
+	MaxBodySize int `json:"maxBodySize,omitempty" yaml:"maxBodySize,omitempty" mapstructure:"maxBodySize"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		// 100MB
+		LogMaxSize: 100,
+		// 30 days
+		LogMaxAge:      30,
+		LogMaxBackups:  10,
+		WebhookTimeout: 10 * time.Second,
+		BufferSize:     10000,
+		BatchSize:      100,
+		BatchMaxWait:   time.Second,
+		MaxBodySize:    64 * 1024,
+	}
 }
 
 func (o *Options) Merge(fs *pflag.FlagSet, conf *Options) {
 	if f := fs.Lookup(Enabled); f != nil && !f.Changed {
 		o.Enable = conf.Enable
 	}
-}
-
-func NewOptions() *Options {
-	return &Options{}
+	if f := fs.Lookup(PolicyFile); f != nil && !f.Changed && conf.PolicyFile != "" {
+		o.PolicyFile = conf.PolicyFile
+	}
+	if f := fs.Lookup(LogPath); f != nil && !f.Changed && conf.LogPath != "" {
+		o.LogPath = conf.LogPath
+	}
+	if f := fs.Lookup(LogMaxSize); f != nil && !f.Changed && conf.LogMaxSize > 0 {
+		o.LogMaxSize = conf.LogMaxSize
+	}
+	if f := fs.Lookup(LogMaxAge); f != nil && !f.Changed && conf.LogMaxAge > 0 {
+		o.LogMaxAge = conf.LogMaxAge
+	}
+	if f := fs.Lookup(LogMaxBackups); f != nil && !f.Changed && conf.LogMaxBackups > 0 {
+		o.LogMaxBackups = conf.LogMaxBackups
+	}
+	if f := fs.Lookup(Database); f != nil && !f.Changed {
+		o.Database = conf.Database
+	}
+	if f := fs.Lookup(WebhookURL); f != nil && !f.Changed && conf.WebhookURL != "" {
+		o.WebhookURL = conf.WebhookURL
+	}
+	if f := fs.Lookup(BufferSize); f != nil && !f.Changed && conf.BufferSize > 0 {
+		o.BufferSize = conf.BufferSize
+	}
+	if f := fs.Lookup(BatchSize); f != nil && !f.Changed && conf.BatchSize > 0 {
+		o.BatchSize = conf.BatchSize
+	}
+	if f := fs.Lookup(BatchMaxWait); f != nil && !f.Changed && conf.BatchMaxWait > 0 {
+		o.BatchMaxWait = conf.BatchMaxWait
+	}
+	if f := fs.Lookup(MaxBodySize); f != nil && !f.Changed && conf.MaxBodySize > 0 {
+		o.MaxBodySize = conf.MaxBodySize
+	}
+	if conf.WebhookTimeout > 0 {
+		o.WebhookTimeout = conf.WebhookTimeout
+	}
 }
 
 func (o *Options) Validate() []error {
 	var errs []error
+	if !o.Enable {
+		return errs
+	}
+	if o.LogPath == "" && !o.Database && o.WebhookURL == "" {
+		errs = append(errs, fmt.Errorf("* one of %s, %s or %s is required while auditing is enabled", LogPath, Database, WebhookURL))
+	}
+	if o.PolicyFile != "" {
+		if _, err := LoadPolicy(o.PolicyFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.WebhookURL != "" {
+		if u, err := url.Parse(o.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("* %s must be an http or https url", WebhookURL))
+		}
+	}
+	if o.BufferSize <= 0 || o.BatchSize <= 0 || o.BatchMaxWait <= 0 {
+		errs = append(errs, fmt.Errorf("* audit buffer size, batch size and batch max wait must be positive"))
+	}
+	if o.MaxBodySize < 0 {
+		errs = append(errs, fmt.Errorf("* %s must not be negative", MaxBodySize))
+	}
 	return errs
 }
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&o.Enable, Enabled, o.Enable, "enable auditing component or not")
+	fs.StringVar(&o.PolicyFile, PolicyFile, o.PolicyFile, "YAML file of the rules picking the audit level of requests, metadata of all requests is recorded without it")
+	fs.StringVar(&o.LogPath, LogPath, o.LogPath, "file audit events are written to as JSON lines")
+	fs.IntVar(&o.LogMaxSize, LogMaxSize, o.LogMaxSize, "size in megabytes of the audit log file before it is rotated")
+	fs.IntVar(&o.LogMaxAge, LogMaxAge, o.LogMaxAge, "days rotated audit log files are kept")
+	fs.IntVar(&o.LogMaxBackups, LogMaxBackups, o.LogMaxBackups, "number of rotated audit log files kept")
+	fs.BoolVar(&o.Database, Database, o.Database, "save audit events to the database")
+	fs.StringVar(&o.WebhookURL, WebhookURL, o.WebhookURL, "url receiving batches of audit events as a JSON array")
+	fs.IntVar(&o.BufferSize, BufferSize, o.BufferSize, "number of audit events waiting for the sinks, more are dropped")
+	fs.IntVar(&o.BatchSize, BatchSize, o.BatchSize, "maximum number of audit events sent at once")
+	fs.DurationVar(&o.BatchMaxWait, BatchMaxWait, o.BatchMaxWait, "maximum time an audit event waits for its batch to fill")
+	fs.IntVar(&o.MaxBodySize, MaxBodySize, o.MaxBodySize, "bytes of the request and response bodies kept in audit events")
 }
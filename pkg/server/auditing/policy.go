@@ -0,0 +1,154 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package auditing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/request"
+)
+
+// Policy picks the level of requests, the first rule matching a request decides
+// it, requests no rule matches are not recorded.
+type Policy struct {
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// PolicyRule matches requests, empty fields match everything.
+type PolicyRule struct {
+	Level Level `json:"level" yaml:"level"`
+
+	Users      []string `json:"users,omitempty" yaml:"users,omitempty"`
+	UserGroups []string `json:"userGroups,omitempty" yaml:"userGroups,omitempty"`
+	Verbs      []string `json:"verbs,omitempty" yaml:"verbs,omitempty"`
+
+	// APIGroups, Resources and Namespaces only match resource requests, resources
+	// are names like hosts, or resource/subresource.
+	APIGroups  []string `json:"apiGroups,omitempty" yaml:"apiGroups,omitempty"`
+	Resources  []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+
+	// NonResourceURLs only match non-resource requests, a trailing * matches a prefix.
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty" yaml:"nonResourceURLs,omitempty"`
+}
+
+// DefaultPolicy records the metadata of all requests.
+func DefaultPolicy() *Policy {
+	return &Policy{Rules: []PolicyRule{{Level: LevelMetadata}}}
+}
+
+// LoadPolicy reads the YAML policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse audit policy %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid audit policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Validate returns an error if a rule is invalid.
+func (p *Policy) Validate() error {
+	for i, rule := range p.Rules {
+		if err := rule.Level.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		resourceRule := len(rule.APIGroups) > 0 || len(rule.Resources) > 0 || len(rule.Namespaces) > 0
+		if resourceRule && len(rule.NonResourceURLs) > 0 {
+			return fmt.Errorf("rule %d: non-resource urls can not be mixed with resources", i)
+		}
+	}
+	return nil
+}
+
+// LevelOf returns the level of the request of info sent by user, which is nil for
+// anonymous requests.
+func (p *Policy) LevelOf(info *request.Info, user *request.User) Level {
+	for _, rule := range p.Rules {
+		if rule.matches(info, user) {
+			return rule.Level
+		}
+	}
+	return LevelNone
+}
+
+func (r *PolicyRule) matches(info *request.Info, user *request.User) bool {
+	if len(r.Users) > 0 && (user == nil || !contains(r.Users, user.Name)) {
+		return false
+	}
+	if len(r.UserGroups) > 0 && (user == nil || !containsAny(r.UserGroups, user.Groups)) {
+		return false
+	}
+	if len(r.Verbs) > 0 && !contains(r.Verbs, strings.ToLower(info.Verb)) {
+		return false
+	}
+
+	if !info.IsResourceRequest {
+		if len(r.APIGroups) > 0 || len(r.Resources) > 0 || len(r.Namespaces) > 0 {
+			return false
+		}
+		return len(r.NonResourceURLs) == 0 || authentication.PathMatcher(r.NonResourceURLs).Matches(info.Path)
+	}
+
+	if len(r.NonResourceURLs) > 0 {
+		return false
+	}
+	if len(r.APIGroups) > 0 && !contains(r.APIGroups, info.APIGroup) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, info.Namespace) {
+		return false
+	}
+	if len(r.Resources) > 0 {
+		resource := info.Resource
+		if info.Subresource != "" {
+			resource += "/" + info.Subresource
+		}
+		if !contains(r.Resources, resource) && !contains(r.Resources, info.Resource+"/*") && !contains(r.Resources, "*") {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values, others []string) bool {
+	for _, other := range others {
+		if contains(values, other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package auditing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/natefinch/lumberjack"
+	"peta.io/peta/pkg/persistence"
+)
+
+// Sink stores batches of events.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+type fileSink struct {
+	logger *lumberjack.Logger
+}
+
+// NewFileSink returns the sink writing events as JSON lines to the file of path,
+// rotated once larger than maxSize megabytes. Rotated files older than maxAge days
+// or beyond the maxBackups latest are removed.
+func NewFileSink(path string, maxSize, maxAge, maxBackups int) Sink {
+	return &fileSink{logger: &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+		LocalTime:  true,
+		Compress:   true,
+	}}
+}
+
+func (f *fileSink) Name() string {
+	return "file"
+}
+
+func (f *fileSink) Write(_ context.Context, events []*Event) error {
+	// a batch is written at once so lines are never split by a rotation.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	_, err := f.logger.Write(buf.Bytes())
+	return err
+}
+
+func (f *fileSink) Close() error {
+	return f.logger.Close()
+}
+
+// record is the row of an event in the database.
+type record struct {
+	ID                  uuid.UUID `db:"id"`
+	Level               string    `db:"level"`
+	Username            string    `db:"username"`
+	UserUID             string    `db:"user_uid"`
+	UserGroups          string    `db:"user_groups"`
+	Verb                string    `db:"verb"`
+	RequestURI          string    `db:"request_uri"`
+	SourceIP            string    `db:"source_ip"`
+	UserAgent           string    `db:"user_agent"`
+	APIGroup            string    `db:"api_group"`
+	APIVersion          string    `db:"api_version"`
+	Resource            string    `db:"resource"`
+	Subresource         string    `db:"subresource"`
+	Name                string    `db:"name"`
+	Cluster             string    `db:"cluster"`
+	Workspace           string    `db:"workspace"`
+	Namespace           string    `db:"namespace"`
+	ResponseCode        int       `db:"response_code"`
+	RequestReceivedAt   time.Time `db:"request_received_at"`
+	ResponseCompletedAt time.Time `db:"response_completed_at"`
+	RequestBody         string    `db:"request_body"`
+	ResponseBody        string    `db:"response_body"`
+	Truncated           bool      `db:"truncated"`
+}
+
+// TableName overrides the table name used by pop.
+func (r record) TableName() string {
+	return "audit_events"
+}
+
+type databaseSink struct {
+	persister persistence.Persister
+}
+
+// NewDatabaseSink returns the sink saving events to the audit_events table of the database of p.
+func NewDatabaseSink(p persistence.Persister) Sink {
+	return &databaseSink{persister: p}
+}
+
+func (d *databaseSink) Name() string {
+	return "database"
+}
+
+func (d *databaseSink) Write(ctx context.Context, events []*Event) error {
+	records := make([]record, 0, len(events))
+	for _, e := range events {
+		r := record{
+			ID:                  e.ID,
+			Level:               string(e.Level),
+			Verb:                e.Verb,
+			RequestURI:          e.RequestURI,
+			SourceIP:            e.SourceIP,
+			UserAgent:           e.UserAgent,
+			APIGroup:            e.APIGroup,
+			APIVersion:          e.APIVersion,
+			Resource:            e.Resource,
+			Subresource:         e.Subresource,
+			Name:                e.Name,
+			Cluster:             e.Cluster,
+			Workspace:           e.Workspace,
+			Namespace:           e.Namespace,
+			ResponseCode:        e.ResponseCode,
+			RequestReceivedAt:   e.RequestReceivedAt,
+			ResponseCompletedAt: e.ResponseCompletedAt,
+			RequestBody:         e.RequestBody,
+			ResponseBody:        e.ResponseBody,
+			Truncated:           e.Truncated,
+		}
+		if e.User != nil {
+			r.Username, r.UserUID, r.UserGroups = e.User.Name, e.User.UID, strings.Join(e.User.Groups, ",")
+		}
+		records = append(records, r)
+	}
+	return d.persister.Transaction(func(tx *pop.Connection) error {
+		return tx.WithContext(ctx).Create(&records)
+	})
+}
+
+func (d *databaseSink) Close() error {
+	return nil
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns the sink posting batches of events as a JSON array to url.
+func NewWebhookSink(url string, timeout time.Duration) Sink {
+	return &webhookSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (w *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *webhookSink) Write(ctx context.Context, events []*Event) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		line, _ := bufio.NewReader(io.LimitReader(resp.Body, 512)).ReadString('\n')
+		return fmt.Errorf("webhook %s returned %s: %s", w.url, resp.Status, strings.TrimSpace(line))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (w *webhookSink) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
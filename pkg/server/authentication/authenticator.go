@@ -0,0 +1,128 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package authentication verifies the bearer tokens and client certificates of the
+// requests to the api server.
+package authentication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"peta.io/peta/pkg/server/request"
+)
+
+// ErrInvalidToken is returned for tokens no authenticator accepts.
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// TokenAuthenticator returns the user of a token. ok is false with a nil error if
+// the token is not of the kind of the authenticator.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (user *request.User, ok bool, err error)
+}
+
+// New returns the authenticator of the static tokens and signed tokens of o, it
+// accepts no token if o only verifies client certificates.
+func New(o *Options) (TokenAuthenticator, error) {
+	var authenticators Union
+	if o.TokenAuthFile != "" {
+		a, err := NewStaticTokens(o.TokenAuthFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if len(o.JWTSigningKeys) > 0 {
+		keys := make([]interface{}, 0, len(o.JWTSigningKeys))
+		for _, f := range o.JWTSigningKeys {
+			key, err := LoadSigningKey(f)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		authenticators = append(authenticators, NewJWT(keys, o.JWTIssuer, o.JWTAudience, o.JWTLeeway))
+	}
+	if len(authenticators) == 0 && o.ClientCAFile == "" {
+		return nil, errors.New("no token authenticator or client CA is configured")
+	}
+	return authenticators, nil
+}
+
+// Union tries the authenticators in order until one accepts the token.
+type Union []TokenAuthenticator
+
+func (u Union) AuthenticateToken(ctx context.Context, token string) (*request.User, bool, error) {
+	var errs []error
+	for _, a := range u {
+		user, ok, err := a.AuthenticateToken(ctx, token)
+		if ok {
+			return user, true, nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, false, ErrInvalidToken
+	}
+	return nil, false, errors.Join(errs...)
+}
+
+// TokenFrom returns the bearer token of the Authorization header of req.
+func TokenFrom(req *http.Request) (string, bool) {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// CertificateUser returns the user of the verified client certificate of req, its
+// common name is the user name and its organizations are the groups.
+func CertificateUser(req *http.Request) (*request.User, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	subject := req.TLS.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil, false
+	}
+	return &request.User{Name: subject.CommonName, Groups: append([]string(nil), subject.Organization...)}, true
+}
+
+// PathMatcher matches the paths served without authentication.
+type PathMatcher []string
+
+// Matches returns true if path is one of the paths, or starts with the prefix of
+// a path ending with *.
+func (m PathMatcher) Matches(path string) bool {
+	for _, p := range m {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package authentication
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	input := segment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	input := segment(t, map[string]string{"alg": "ES256"}) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func segment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestJWT(t *testing.T) {
+	dir := t.TempDir()
+	secret := []byte(strings.Repeat("s", 32))
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, append(secret, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubFile := filepath.Join(dir, "ec.pub")
+	if err := os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(&Options{JWTSigningKeys: []string{secretFile, pubFile}, JWTIssuer: "peta", JWTAudience: "api", JWTLeeway: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := map[string]interface{}{"iss": "peta", "aud": []string{"api"}, "sub": "u-1", "preferred_username": "alice", "groups": []string{"dba"}, "exp": exp}
+	for name, token := range map[string]string{
+		"HS256": signHS256(t, secret, valid),
+		"ES256": signES256(t, ecKey, valid),
+	} {
+		user, ok, err := a.AuthenticateToken(context.Background(), token)
+		if !ok || err != nil || user.Name != "alice" || user.UID != "u-1" || len(user.Groups) != 1 {
+			t.Errorf("%s: AuthenticateToken() = %v, %t, %v", name, user, ok, err)
+		}
+	}
+
+	for name, claims := range map[string]map[string]interface{}{
+		"expired":    {"iss": "peta", "aud": "api", "sub": "u-1", "exp": time.Now().Add(-time.Minute).Unix()},
+		"no exp":     {"iss": "peta", "aud": "api", "sub": "u-1"},
+		"issuer":     {"iss": "other", "aud": "api", "sub": "u-1", "exp": exp},
+		"audience":   {"iss": "peta", "aud": "other", "sub": "u-1", "exp": exp},
+		"not before": {"iss": "peta", "aud": "api", "sub": "u-1", "exp": exp, "nbf": time.Now().Add(time.Minute).Unix()},
+	} {
+		if _, ok, err := a.AuthenticateToken(context.Background(), signHS256(t, secret, claims)); ok || err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+
+	if _, ok, _ := a.AuthenticateToken(context.Background(), signHS256(t, []byte(strings.Repeat("x", 32)), valid)); ok {
+		t.Error("token of another secret was accepted")
+	}
+
+	// a token of the none algorithm has no signature.
+	none := segment(t, map[string]string{"alg": "none"}) + "." + segment(t, valid) + "."
+	if _, ok, _ := a.AuthenticateToken(context.Background(), none); ok {
+		t.Error("unsigned token was accepted")
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "tokens.csv")
+	content := "# token,user,uid,groups\nt0ken,admin,1,\"system:masters,dba\"\nother,bob\n"
+	if err := os.WriteFile(f, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewStaticTokens(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, ok, err := a.AuthenticateToken(context.Background(), "t0ken")
+	if !ok || err != nil || user.Name != "admin" || user.UID != "1" || len(user.Groups) != 2 || user.Groups[1] != "dba" {
+		t.Errorf("AuthenticateToken() = %v, %t, %v", user, ok, err)
+	}
+	if _, ok, _ := a.AuthenticateToken(context.Background(), "unknown"); ok {
+		t.Error("unknown token was accepted")
+	}
+
+	if err := os.WriteFile(f, []byte("t0ken,admin\nt0ken,bob\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStaticTokens(f); err == nil {
+		t.Error("duplicate tokens were accepted")
+	}
+}
+
+func TestPathMatcher(t *testing.T) {
+	m := PathMatcher{"/healthz", "/apis/public/*"}
+	for path, want := range map[string]bool{
+		"/healthz":            true,
+		"/healthz/ping":       false,
+		"/apis/public/info":   true,
+		"/apis/v1alpha2/host": false,
+	} {
+		if got := m.Matches(path); got != want {
+			t.Errorf("Matches(%q) = %t, want %t", path, got, want)
+		}
+	}
+}
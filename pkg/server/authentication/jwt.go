@@ -0,0 +1,258 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"peta.io/peta/pkg/server/request"
+)
+
+// minHMACKeySize is the minimum size of HMAC secrets, 256 bits.
+const minHMACKeySize = 32
+
+// jwtAuthenticator verifies the signature and claims of JSON Web Tokens.
+type jwtAuthenticator struct {
+	keys     []interface{}
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewJWT returns the authenticator of the tokens signed by one of the keys, which
+// are []byte HMAC secrets or RSA, ECDSA or Ed25519 public keys.
+func NewJWT(keys []interface{}, issuer, audience string, leeway time.Duration) TokenAuthenticator {
+	return &jwtAuthenticator{keys: keys, issuer: issuer, audience: audience, leeway: leeway, now: time.Now}
+}
+
+// LoadSigningKey reads the PEM encoded public key or certificate, or the HMAC
+// secret in the file.
+func LoadSigningKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := bytes.TrimSpace(data)
+		if len(secret) < minHMACKeySize {
+			return nil, fmt.Errorf("signing key %s: HMAC secret must have at least %d bytes", path, minHMACKeySize)
+		}
+		return secret, nil
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+		return cert.PublicKey, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %s: unsupported PEM block %q", path, block.Type)
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Username  string   `json:"preferred_username"`
+	Groups    []string `json:"groups"`
+}
+
+// audience is a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = l
+	return nil
+}
+
+func (a *jwtAuthenticator) AuthenticateToken(_ context.Context, token string) (*request.User, bool, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		// not a jwt, another authenticator may accept it.
+		return nil, false, nil
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, false, fmt.Errorf("invalid jwt header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid jwt signature: %w", err)
+	}
+	if err := a.verify(header.Algorithm, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, false, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, false, fmt.Errorf("invalid jwt claims: %w", err)
+	}
+	if err := a.validate(&claims); err != nil {
+		return nil, false, err
+	}
+
+	user := &request.User{Name: claims.Username, UID: claims.Subject, Groups: claims.Groups}
+	if user.Name == "" {
+		user.Name = claims.Subject
+	}
+	if user.Name == "" {
+		return nil, false, errors.New("jwt has neither sub nor preferred_username")
+	}
+	return user, true, nil
+}
+
+func (a *jwtAuthenticator) validate(c *jwtClaims) error {
+	now := a.now()
+	if c.ExpiresAt == nil {
+		return errors.New("jwt has no exp")
+	}
+	if now.After(unixTime(*c.ExpiresAt).Add(a.leeway)) {
+		return errors.New("jwt is expired")
+	}
+	if c.NotBefore != nil && now.Add(a.leeway).Before(unixTime(*c.NotBefore)) {
+		return errors.New("jwt is not valid yet")
+	}
+	if a.issuer != "" && c.Issuer != a.issuer {
+		return fmt.Errorf("jwt issuer %q is not %q", c.Issuer, a.issuer)
+	}
+	if a.audience != "" {
+		found := false
+		for _, aud := range c.Audience {
+			found = found || aud == a.audience
+		}
+		if !found {
+			return fmt.Errorf("jwt audience %v does not contain %q", []string(c.Audience), a.audience)
+		}
+	}
+	return nil
+}
+
+// verify checks the signature with the keys of the algorithm.
+func (a *jwtAuthenticator) verify(alg string, input, signature []byte) error {
+	hash, ok := map[string]crypto.Hash{
+		"HS256": crypto.SHA256, "RS256": crypto.SHA256, "PS256": crypto.SHA256, "ES256": crypto.SHA256,
+		"HS384": crypto.SHA384, "RS384": crypto.SHA384, "PS384": crypto.SHA384, "ES384": crypto.SHA384,
+		"HS512": crypto.SHA512, "RS512": crypto.SHA512, "PS512": crypto.SHA512, "ES512": crypto.SHA512,
+		"EdDSA": 0,
+	}[alg]
+	if !ok {
+		return fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+
+	for _, key := range a.keys {
+		switch k := key.(type) {
+		case []byte:
+			if strings.HasPrefix(alg, "HS") {
+				mac := hmac.New(hash.New, k)
+				mac.Write(input)
+				if hmac.Equal(mac.Sum(nil), signature) {
+					return nil
+				}
+			}
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil {
+				return nil
+			}
+			if strings.HasPrefix(alg, "PS") &&
+				rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			size := (k.Curve.Params().BitSize + 7) / 8
+			if strings.HasPrefix(alg, "ES") && len(signature) == 2*size &&
+				ecdsa.Verify(k, digest, new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if alg == "EdDSA" && ed25519.Verify(k, input, signature) {
+				return nil
+			}
+		}
+	}
+	return errors.New("jwt signature is invalid")
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func unixTime(seconds float64) time.Time {
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
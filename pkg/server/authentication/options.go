@@ -0,0 +1,126 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package authentication
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	Enabled       = "authentication-enabled"
+	JWTSigningKey = "jwt-signing-key"
+	JWTIssuer     = "jwt-issuer"
+	JWTAudience   = "jwt-audience"
+	TokenAuthFile = "token-auth-file"
+	ClientCAFile  = "client-ca-file"
+	AllowedPaths  = "authentication-allowed-paths"
+
+	defaultLeeway = 30 * time.Second
+	maxLeeway     = 5 * time.Minute
+)
+
+type Options struct {
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// JWTSigningKeys are files of the keys verifying the signature of tokens, PEM
+	// encoded RSA, ECDSA or Ed25519 public keys or certificates, or HMAC secrets.
+	JWTSigningKeys []string `json:"jwtSigningKeys,omitempty" yaml:"jwtSigningKeys,omitempty" mapstructure:"jwtSigningKeys"`
+	// JWTIssuer must be the iss claim of tokens if not empty.
+	JWTIssuer string `json:"jwtIssuer,omitempty" yaml:"jwtIssuer,omitempty" mapstructure:"jwtIssuer"`
+	// JWTAudience must be in the aud claim of tokens if not empty.
+	JWTAudience string `json:"jwtAudience,omitempty" yaml:"jwtAudience,omitempty" mapstructure:"jwtAudience"`
+	// JWTLeeway is the clock skew allowed when checking the times of tokens.
+	JWTLeeway time.Duration `json:"jwtLeeway,omitempty" yaml:"jwtLeeway,omitempty" mapstructure:"jwtLeeway"`
+	// TokenAuthFile is a CSV file of static tokens.
+	TokenAuthFile string `json:"tokenAuthFile,omitempty" yaml:"tokenAuthFile,omitempty" mapstructure:"tokenAuthFile"`
+	// ClientCAFile is a PEM bundle verifying client certificates on the secure port,
+	// the common name of a certificate is its user and the organizations its groups.
+	ClientCAFile string `json:"clientCAFile,omitempty" yaml:"clientCAFile,omitempty" mapstructure:"clientCAFile"`
+	// AllowedPaths are served without authentication, a path ending with * matches
+	// all paths with its prefix.
+	AllowedPaths []string `json:"allowedPaths,omitempty" yaml:"allowedPaths,omitempty" mapstructure:"allowedPaths"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		JWTLeeway:    defaultLeeway,
+		AllowedPaths: []string{"/healthz", "/livez", "/readyz"},
+	}
+}
+
+func (o *Options) Merge(fs *pflag.FlagSet, conf *Options) {
+	if f := fs.Lookup(Enabled); f != nil && !f.Changed {
+		o.Enable = conf.Enable
+	}
+	if f := fs.Lookup(JWTSigningKey); f != nil && !f.Changed && len(conf.JWTSigningKeys) > 0 {
+		o.JWTSigningKeys = conf.JWTSigningKeys
+	}
+	if f := fs.Lookup(JWTIssuer); f != nil && !f.Changed && conf.JWTIssuer != "" {
+		o.JWTIssuer = conf.JWTIssuer
+	}
+	if f := fs.Lookup(JWTAudience); f != nil && !f.Changed && conf.JWTAudience != "" {
+		o.JWTAudience = conf.JWTAudience
+	}
+	if f := fs.Lookup(TokenAuthFile); f != nil && !f.Changed && conf.TokenAuthFile != "" {
+		o.TokenAuthFile = conf.TokenAuthFile
+	}
+	if f := fs.Lookup(ClientCAFile); f != nil && !f.Changed && conf.ClientCAFile != "" {
+		o.ClientCAFile = conf.ClientCAFile
+	}
+	if f := fs.Lookup(AllowedPaths); f != nil && !f.Changed && len(conf.AllowedPaths) > 0 {
+		o.AllowedPaths = conf.AllowedPaths
+	}
+	if conf.JWTLeeway > 0 {
+		o.JWTLeeway = conf.JWTLeeway
+	}
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+	if !o.Enable {
+		return errs
+	}
+	if len(o.JWTSigningKeys) == 0 && o.TokenAuthFile == "" && o.ClientCAFile == "" {
+		errs = append(errs, fmt.Errorf("* %s, %s or %s is required while authentication is enabled", JWTSigningKey, TokenAuthFile, ClientCAFile))
+	}
+	for _, f := range append(append([]string(nil), o.JWTSigningKeys...), o.TokenAuthFile, o.ClientCAFile) {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.JWTLeeway < 0 || o.JWTLeeway > maxLeeway {
+		errs = append(errs, fmt.Errorf("* jwt leeway must be within [0, %s]", maxLeeway))
+	}
+	return errs
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, Enabled, o.Enable, "enable authentication of api requests or not")
+	fs.StringSliceVar(&o.JWTSigningKeys, JWTSigningKey, o.JWTSigningKeys, "files of the keys verifying jwt signatures, PEM public keys or certificates, or HMAC secrets")
+	fs.StringVar(&o.JWTIssuer, JWTIssuer, o.JWTIssuer, "issuer required in jwt")
+	fs.StringVar(&o.JWTAudience, JWTAudience, o.JWTAudience, "audience required in jwt")
+	fs.StringVar(&o.TokenAuthFile, TokenAuthFile, o.TokenAuthFile, `file of static tokens, a token,user,uid,"group1,group2" line each`)
+	fs.StringVar(&o.ClientCAFile, ClientCAFile, o.ClientCAFile, "PEM bundle verifying client certificates, their common name is the user and organizations the groups")
+	fs.StringSliceVar(&o.AllowedPaths, AllowedPaths, o.AllowedPaths, "paths served without authentication, a trailing * matches a prefix")
+}
@@ -0,0 +1,90 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"peta.io/peta/pkg/server/request"
+)
+
+// staticTokens are the users of static tokens by the sha256 of the token.
+type staticTokens map[[sha256.Size]byte]*request.User
+
+// NewStaticTokens reads the tokens of a CSV file, with a token,user,uid,"group1,group2"
+// line each. The uid and groups are optional, lines starting with # are ignored.
+func NewStaticTokens(path string) (TokenAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	tokens := staticTokens{}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid token file %s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("invalid token file %s: line %d: token and user are required", path, line)
+		}
+
+		user := &request.User{Name: record[1]}
+		if len(record) > 2 {
+			user.UID = record[2]
+		}
+		if len(record) > 3 && record[3] != "" {
+			for _, g := range strings.Split(record[3], ",") {
+				user.Groups = append(user.Groups, strings.TrimSpace(g))
+			}
+		}
+
+		key := sha256.Sum256([]byte(record[0]))
+		if _, ok := tokens[key]; ok {
+			return nil, fmt.Errorf("invalid token file %s: line %d: duplicate token", path, line)
+		}
+		tokens[key] = user
+	}
+	return tokens, nil
+}
+
+func (t staticTokens) AuthenticateToken(_ context.Context, token string) (*request.User, bool, error) {
+	user, ok := t[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, false, nil
+	}
+	u := *user
+	return &u, true, nil
+}
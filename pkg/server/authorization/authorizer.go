@@ -0,0 +1,211 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package authorization decides whether the user of a request may perform it.
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/utils/sets"
+)
+
+// Decision is the outcome of an authorizer.
+type Decision int
+
+const (
+	// DecisionDeny rejects the request, no other authorizer is asked.
+	DecisionDeny Decision = iota
+	// DecisionAllow permits the request, no other authorizer is asked.
+	DecisionAllow
+	// DecisionNoOpinion leaves the request to the next authorizer, it is denied
+	// if no authorizer allows it.
+	DecisionNoOpinion
+)
+
+// Attributes are the facts of a request authorizers decide on.
+type Attributes struct {
+	User *request.User
+
+	// Verb is a resource verb like get or list for resource requests, and the
+	// lowercase http method for non-resource requests.
+	Verb string
+
+	IsResourceRequest bool
+
+	// Path is the URL path of non-resource requests.
+	Path string
+
+	APIGroup    string
+	APIVersion  string
+	Resource    string
+	Subresource string
+	Name        string
+
+	Cluster   string
+	Workspace string
+	Namespace string
+
+	// Scope is one of request.GlobalScope, ClusterScope, WorkspaceScope and NamespaceScope.
+	Scope string
+}
+
+// AttributesFrom returns the attributes of the request of info sent by user, which
+// is nil for anonymous requests.
+func AttributesFrom(user *request.User, info *request.Info) Attributes {
+	a := Attributes{
+		User:              user,
+		Verb:              info.Verb,
+		IsResourceRequest: info.IsResourceRequest,
+		Path:              info.Path,
+		APIGroup:          info.APIGroup,
+		APIVersion:        info.APIVersion,
+		Resource:          info.Resource,
+		Subresource:       info.Subresource,
+		Name:              info.Name,
+		Cluster:           info.Cluster,
+		Workspace:         info.Workspace,
+		Namespace:         info.Namespace,
+		Scope:             info.ResourceScope,
+	}
+	if !a.IsResourceRequest {
+		a.Verb = strings.ToLower(a.Verb)
+	}
+	return a
+}
+
+// Authorizer makes a decision on a request, the reason explains it.
+type Authorizer interface {
+	Authorize(ctx context.Context, a Attributes) (decision Decision, reason string, err error)
+}
+
+// New returns the authorizer of o, asking rbac after the allowed paths and groups.
+func New(o *Options, rbac Authorizer) Authorizer {
+	return Union{
+		NewPathAuthorizer(o.AllowedPaths),
+		NewGroupAuthorizer(o.AdminGroups...),
+		rbac,
+	}
+}
+
+// Union asks the authorizers in order until one allows or denies the request.
+type Union []Authorizer
+
+func (u Union) Authorize(ctx context.Context, a Attributes) (Decision, string, error) {
+	var reasons []string
+	var errs []error
+	for _, authorizer := range u {
+		decision, reason, err := authorizer.Authorize(ctx, a)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if reason != "" {
+			reasons = append(reasons, reason)
+		}
+		switch decision {
+		case DecisionAllow, DecisionDeny:
+			return decision, reason, err
+		}
+	}
+	return DecisionNoOpinion, strings.Join(reasons, "\n"), errors.Join(errs...)
+}
+
+type pathAuthorizer authentication.PathMatcher
+
+// NewPathAuthorizer allows the requests of the paths to everyone,
+// a path ending with * matches all paths with its prefix.
+func NewPathAuthorizer(paths []string) Authorizer {
+	return pathAuthorizer(paths)
+}
+
+func (p pathAuthorizer) Authorize(_ context.Context, a Attributes) (Decision, string, error) {
+	if authentication.PathMatcher(p).Matches(a.Path) {
+		return DecisionAllow, "", nil
+	}
+	return DecisionNoOpinion, "", nil
+}
+
+type groupAuthorizer struct {
+	groups sets.Set[string]
+}
+
+// NewGroupAuthorizer allows all requests of the users in one of the groups.
+func NewGroupAuthorizer(groups ...string) Authorizer {
+	return &groupAuthorizer{groups: sets.New(groups...)}
+}
+
+func (g *groupAuthorizer) Authorize(_ context.Context, a Attributes) (Decision, string, error) {
+	if a.User == nil {
+		return DecisionNoOpinion, "", nil
+	}
+	for _, group := range a.User.Groups {
+		if g.groups.Has(group) {
+			return DecisionAllow, "", nil
+		}
+	}
+	return DecisionNoOpinion, "", nil
+}
+
+// Forbidden returns the error reported to the user of a request that is not allowed.
+func Forbidden(a Attributes, reason string) error {
+	user := "system:anonymous"
+	if a.User != nil {
+		user = a.User.Name
+	}
+
+	var msg, resource string
+	if !a.IsResourceRequest {
+		msg = fmt.Sprintf("user %q cannot %s path %q", user, a.Verb, a.Path)
+	} else {
+		resource = a.Resource
+		if a.Subresource != "" {
+			resource += "/" + a.Subresource
+		}
+		msg = fmt.Sprintf("user %q cannot %s resource %q in API group %q", user, a.Verb, resource, a.APIGroup)
+		if a.Name != "" {
+			msg += fmt.Sprintf(" named %q", a.Name)
+		}
+		switch {
+		case a.Namespace != "":
+			msg += fmt.Sprintf(" in the namespace %q", a.Namespace)
+		case a.Workspace != "":
+			msg += fmt.Sprintf(" in the workspace %q", a.Workspace)
+		case a.Cluster != "":
+			msg += fmt.Sprintf(" in the cluster %q", a.Cluster)
+		}
+	}
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return apis.NewForbidden(resource, a.Name, errors.New(msg))
+}
+
+type alwaysAllow struct{}
+
+// NewAlwaysAllowAuthorizer allows every request, it is used while authorization is disabled.
+func NewAlwaysAllowAuthorizer() Authorizer {
+	return alwaysAllow{}
+}
+
+func (alwaysAllow) Authorize(context.Context, Attributes) (Decision, string, error) {
+	return DecisionAllow, "authorization is disabled", nil
+}
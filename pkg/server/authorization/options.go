@@ -0,0 +1,82 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package authorization
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	Enabled      = "authorization-enabled"
+	AdminGroups  = "authorization-admin-groups"
+	AllowedPaths = "authorization-allowed-paths"
+
+	// SelfAccessReviewPath is where users check their own permissions, it is
+	// allowed to everyone by default.
+	SelfAccessReviewPath = "/apis/iam.peta.io/v1alpha2/can-i"
+)
+
+type Options struct {
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// AdminGroups are the groups whose users may perform any request.
+	AdminGroups []string `json:"adminGroups,omitempty" yaml:"adminGroups,omitempty" mapstructure:"adminGroups"`
+	// AllowedPaths are allowed to every user, a path ending with * matches all
+	// paths with its prefix.
+	AllowedPaths []string `json:"allowedPaths,omitempty" yaml:"allowedPaths,omitempty" mapstructure:"allowedPaths"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		AdminGroups:  []string{"system:masters"},
+		AllowedPaths: []string{"/healthz", "/livez", "/readyz", SelfAccessReviewPath},
+	}
+}
+
+func (o *Options) Merge(fs *pflag.FlagSet, conf *Options) {
+	if f := fs.Lookup(Enabled); f != nil && !f.Changed {
+		o.Enable = conf.Enable
+	}
+	if f := fs.Lookup(AdminGroups); f != nil && !f.Changed && len(conf.AdminGroups) > 0 {
+		o.AdminGroups = conf.AdminGroups
+	}
+	if f := fs.Lookup(AllowedPaths); f != nil && !f.Changed && len(conf.AllowedPaths) > 0 {
+		o.AllowedPaths = conf.AllowedPaths
+	}
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+	if !o.Enable {
+		return errs
+	}
+	for _, group := range o.AdminGroups {
+		if strings.TrimSpace(group) == "" {
+			errs = append(errs, fmt.Errorf("* admin groups must not be empty"))
+			break
+		}
+	}
+	return errs
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, Enabled, o.Enable, "enable role-based authorization of api requests or not")
+	fs.StringSliceVar(&o.AdminGroups, AdminGroups, o.AdminGroups, "groups whose users may perform any request")
+	fs.StringSliceVar(&o.AllowedPaths, AllowedPaths, o.AllowedPaths, "paths allowed to every user, a trailing * matches a prefix")
+}
@@ -0,0 +1,157 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/authorization"
+)
+
+// Lister returns the bindings and roles deciding on requests.
+type Lister interface {
+	// BindingsFor returns the role bindings of l and of the locations containing it.
+	BindingsFor(ctx context.Context, l Location) ([]RoleBinding, error)
+	// RolesNamed returns the roles of any location with one of the names.
+	RolesNamed(ctx context.Context, names []string) ([]Role, error)
+}
+
+type authorizer struct {
+	lister Lister
+}
+
+// New returns the authorizer allowing the requests granted by a role bound to
+// their user, it has no opinion on other requests.
+func New(l Lister) authorization.Authorizer {
+	return &authorizer{lister: l}
+}
+
+func (r *authorizer) Authorize(ctx context.Context, a authorization.Attributes) (authorization.Decision, string, error) {
+	if a.User == nil {
+		return authorization.DecisionNoOpinion, "", nil
+	}
+
+	l := Location{Cluster: a.Cluster, Workspace: a.Workspace, Namespace: a.Namespace}
+	if !a.IsResourceRequest {
+		// non-resource urls are only granted by cluster role bindings.
+		l = Location{}
+	}
+
+	bindings, err := r.lister.BindingsFor(ctx, l)
+	if err != nil {
+		return authorization.DecisionNoOpinion, "", err
+	}
+
+	var bound []RoleBinding
+	var names []string
+	for _, b := range bindings {
+		if b.Location.Contains(l) && b.Binds(a.User) && (a.IsResourceRequest || b.Location.IsGlobal()) {
+			bound = append(bound, b)
+			names = append(names, b.RoleName)
+		}
+	}
+	if len(bound) == 0 {
+		return authorization.DecisionNoOpinion, "", nil
+	}
+
+	roles, err := r.lister.RolesNamed(ctx, names)
+	if err != nil {
+		return authorization.DecisionNoOpinion, "", err
+	}
+
+	for _, b := range bound {
+		role := findRole(roles, b.RoleLocation(), b.RoleName)
+		if role == nil {
+			continue
+		}
+		for _, rule := range role.Rules {
+			if RuleAllows(rule, a) {
+				return authorization.DecisionAllow, fmt.Sprintf("allowed by role binding %q of %s to %s %q", b.Name, b.Location, b.RoleKind, b.RoleName), nil
+			}
+		}
+	}
+	return authorization.DecisionNoOpinion, "", nil
+}
+
+func findRole(roles []Role, l Location, name string) *Role {
+	for i := range roles {
+		if roles[i].Name == name && roles[i].Location == l {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+// RuleAllows returns true if rule grants the request of a.
+func RuleAllows(rule PolicyRule, a authorization.Attributes) bool {
+	if !matches(rule.Verbs, a.Verb) {
+		return false
+	}
+
+	if !a.IsResourceRequest {
+		return authentication.PathMatcher(rule.NonResourceURLs).Matches(a.Path)
+	}
+
+	if !matches(rule.APIGroups, a.APIGroup) || !resourceMatches(rule.Resources, a.Resource, a.Subresource) {
+		return false
+	}
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+	return a.Name != "" && contains(rule.ResourceNames, a.Name)
+}
+
+func matches(values []string, value string) bool {
+	return contains(values, All) || contains(values, value)
+}
+
+// resourceMatches returns true if resources have the resource, or resource/subresource
+// for subresources. resource/* matches all subresources and */subresource the
+// subresource of all resources.
+func resourceMatches(resources []string, resource, subresource string) bool {
+	want := resource
+	if subresource != "" {
+		want = resource + "/" + subresource
+	}
+	for _, r := range resources {
+		if r == All || r == want {
+			return true
+		}
+		if subresource == "" {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(r, "/*"); ok && prefix == resource {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(r, "*/"); ok && suffix == subresource {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"peta.io/peta/pkg/server/authorization"
+	"peta.io/peta/pkg/server/request"
+)
+
+type fakeLister struct {
+	roles    []Role
+	bindings []RoleBinding
+}
+
+func (f *fakeLister) BindingsFor(_ context.Context, l Location) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	for _, b := range f.bindings {
+		if b.Location.Contains(l) {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings, nil
+}
+
+func (f *fakeLister) RolesNamed(_ context.Context, names []string) ([]Role, error) {
+	var roles []Role
+	for _, r := range f.roles {
+		if contains(names, r.Name) {
+			roles = append(roles, r)
+		}
+	}
+	return roles, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	dev := Location{Workspace: "team", Namespace: "dev"}
+	lister := &fakeLister{
+		roles: []Role{
+			{Name: "viewer", Rules: Rules{{Verbs: []string{"get", "list"}, APIGroups: []string{All}, Resources: []string{All}}}},
+			{Name: "metrics", Rules: Rules{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}}}},
+			{Name: "operator", Location: dev, Rules: Rules{
+				{Verbs: []string{"create"}, APIGroups: []string{"host.peta.io"}, Resources: []string{"exec"}},
+				{Verbs: []string{"update"}, APIGroups: []string{"host.peta.io"}, Resources: []string{"hosts/*"}, ResourceNames: []string{"db1"}},
+			}},
+		},
+		bindings: []RoleBinding{
+			{Name: "monitoring", RoleKind: KindClusterRole, RoleName: "metrics", Subjects: Subjects{{Kind: KindGroup, Name: "monitoring"}}},
+			{Name: "team-viewers", Location: Location{Workspace: "team"}, RoleKind: KindClusterRole, RoleName: "viewer",
+				Subjects: Subjects{{Kind: KindGroup, Name: "team"}}},
+			{Name: "operators", Location: dev, RoleKind: KindRole, RoleName: "operator", Subjects: Subjects{{Kind: KindUser, Name: "alice"}}},
+			{Name: "dangling", Location: dev, RoleKind: KindRole, RoleName: "viewer", Subjects: Subjects{{Kind: KindUser, Name: "bob"}}},
+		},
+	}
+	alice := &request.User{Name: "alice", Groups: []string{"team"}}
+	bob := &request.User{Name: "bob", Groups: []string{"monitoring"}}
+
+	resource := func(user *request.User, verb, group, resource, subresource, name string, l Location) authorization.Attributes {
+		return authorization.Attributes{User: user, Verb: verb, IsResourceRequest: true, APIGroup: group,
+			Resource: resource, Subresource: subresource, Name: name,
+			Cluster: l.Cluster, Workspace: l.Workspace, Namespace: l.Namespace}
+	}
+
+	tests := []struct {
+		name  string
+		attrs authorization.Attributes
+		want  authorization.Decision
+	}{
+		{"cluster role bound in workspace", resource(alice, "list", "host.peta.io", "hosts", "", "", Location{Workspace: "team"}), authorization.DecisionAllow},
+		{"workspace binding covers its namespaces", resource(alice, "get", "host.peta.io", "hosts", "", "db1", dev), authorization.DecisionAllow},
+		{"workspace binding does not leak", resource(alice, "list", "host.peta.io", "hosts", "", "", Location{Workspace: "other"}), authorization.DecisionNoOpinion},
+		{"verb not granted", resource(alice, "delete", "host.peta.io", "hosts", "", "db1", dev), authorization.DecisionNoOpinion},
+		{"role of namespace", resource(alice, "create", "host.peta.io", "exec", "", "", dev), authorization.DecisionAllow},
+		{"role not granted elsewhere", resource(alice, "create", "host.peta.io", "exec", "", "", Location{Namespace: "prod"}), authorization.DecisionNoOpinion},
+		{"subresource of resource name", resource(alice, "update", "host.peta.io", "hosts", "labels", "db1", dev), authorization.DecisionAllow},
+		{"other resource name", resource(alice, "update", "host.peta.io", "hosts", "labels", "db2", dev), authorization.DecisionNoOpinion},
+		{"resource without subresource", resource(alice, "update", "host.peta.io", "hosts", "", "db1", dev), authorization.DecisionNoOpinion},
+		{"role kind of binding is checked", resource(bob, "get", "host.peta.io", "hosts", "", "db1", dev), authorization.DecisionNoOpinion},
+		{"non-resource url", authorization.Attributes{User: bob, Verb: "get", Path: "/metrics"}, authorization.DecisionAllow},
+		{"non-resource verb", authorization.Attributes{User: bob, Verb: "post", Path: "/metrics"}, authorization.DecisionNoOpinion},
+		{"non-resource url of other user", authorization.Attributes{User: alice, Verb: "get", Path: "/metrics"}, authorization.DecisionNoOpinion},
+		{"anonymous", resource(nil, "list", "host.peta.io", "hosts", "", "", Location{Workspace: "team"}), authorization.DecisionNoOpinion},
+	}
+
+	a := New(lister)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := a.Authorize(context.Background(), tt.attrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorize() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnion(t *testing.T) {
+	o := authorization.NewOptions()
+	a := authorization.New(o, New(&fakeLister{}))
+
+	admin := authorization.Attributes{User: &request.User{Name: "root", Groups: []string{"system:masters"}}, Verb: "delete", IsResourceRequest: true, Resource: "hosts"}
+	if got, _, _ := a.Authorize(context.Background(), admin); got != authorization.DecisionAllow {
+		t.Errorf("admin group: got %v, want allow", got)
+	}
+
+	review := authorization.Attributes{Verb: "list", IsResourceRequest: true, Resource: "can-i", Path: authorization.SelfAccessReviewPath}
+	if got, _, _ := a.Authorize(context.Background(), review); got != authorization.DecisionAllow {
+		t.Errorf("allowed path: got %v, want allow", got)
+	}
+
+	user := authorization.Attributes{User: &request.User{Name: "alice"}, Verb: "list", IsResourceRequest: true, Resource: "hosts"}
+	if got, _, _ := a.Authorize(context.Background(), user); got != authorization.DecisionNoOpinion {
+		t.Errorf("user without roles: got %v, want no opinion", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalidRoles := []Role{
+		{},
+		{Name: "r", Rules: Rules{{Resources: []string{"hosts"}}}},
+		{Name: "r", Rules: Rules{{Verbs: []string{"get"}}}},
+		{Name: "r", Location: Location{Namespace: "dev"}, Rules: Rules{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}}}},
+		{Name: "r", Rules: Rules{{Verbs: []string{"get"}, Resources: []string{"hosts"}, NonResourceURLs: []string{"/metrics"}}}},
+	}
+	for i, r := range invalidRoles {
+		if err := r.Validate(); err == nil {
+			t.Errorf("role %d: expected an error", i)
+		}
+	}
+
+	subjects := Subjects{{Kind: KindUser, Name: "alice"}}
+	invalidBindings := []RoleBinding{
+		{RoleKind: KindClusterRole, RoleName: "viewer", Subjects: subjects},
+		{Name: "b", RoleKind: KindRole, RoleName: "viewer", Subjects: subjects},
+		{Name: "b", RoleKind: KindClusterRole, Subjects: subjects},
+		{Name: "b", RoleKind: KindClusterRole, RoleName: "viewer"},
+		{Name: "b", RoleKind: KindClusterRole, RoleName: "viewer", Subjects: Subjects{{Kind: "Robot", Name: "r2"}}},
+	}
+	for i, b := range invalidBindings {
+		if err := b.Validate(); err == nil {
+			t.Errorf("binding %d: expected an error", i)
+		}
+	}
+
+	valid := RoleBinding{Name: "b", Location: Location{Namespace: "dev"}, RoleKind: KindRole, RoleName: "viewer", Subjects: subjects}
+	if err := valid.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRulesScan(t *testing.T) {
+	rules := Rules{{Verbs: []string{"get"}, Resources: []string{"hosts"}}}
+	v, err := rules.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Rules
+	if err := got.Scan([]byte(v.(string))); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Verbs[0] != "get" || got[0].Resources[0] != "hosts" {
+		t.Errorf("Scan() = %+v", got)
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"peta.io/peta/pkg/persistence"
+	"peta.io/peta/pkg/watch"
+)
+
+var (
+	// ErrNotFound is returned for roles and bindings that do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when creating a role or binding whose name is taken.
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+var _ Lister = &Store{}
+
+// Store keeps roles and role bindings in the database, and broadcasts their changes.
+type Store struct {
+	persister persistence.Persister
+	roles     *watch.Broadcaster
+	bindings  *watch.Broadcaster
+}
+
+// NewStore returns a store of roles and role bindings in the database of p.
+func NewStore(p persistence.Persister) *Store {
+	return &Store{
+		persister: p,
+		roles:     watch.NewBroadcaster(watch.DefaultHistorySize),
+		bindings:  watch.NewBroadcaster(watch.DefaultHistorySize),
+	}
+}
+
+// RoleBroadcaster returns the broadcaster of the changes of the roles made through s.
+func (s *Store) RoleBroadcaster() *watch.Broadcaster {
+	return s.roles
+}
+
+// RoleBindingBroadcaster returns the broadcaster of the changes of the role bindings
+// made through s.
+func (s *Store) RoleBindingBroadcaster() *watch.Broadcaster {
+	return s.bindings
+}
+
+// ListRoles returns the roles of the location l.
+func (s *Store) ListRoles(ctx context.Context, l Location) ([]Role, error) {
+	var roles []Role
+	if err := at(s.conn(ctx).Q(), l).Order("name").All(&roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetRole returns the role of the location l with the name.
+func (s *Store) GetRole(ctx context.Context, l Location, name string) (*Role, error) {
+	role := &Role{}
+	if err := first(at(s.conn(ctx).Where("name = ?", name), l), role); err != nil {
+		return nil, fmt.Errorf("role %q of %s: %w", name, l, err)
+	}
+	return role, nil
+}
+
+// CreateRole saves the new role r.
+func (s *Store) CreateRole(ctx context.Context, r *Role) error {
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		exists, err := at(tx.WithContext(ctx).Where("name = ?", r.Name), r.Location).Exists(&Role{})
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("role %q of %s: %w", r.Name, r.Location, ErrAlreadyExists)
+		}
+		return tx.WithContext(ctx).Create(r)
+	})
+	if err != nil {
+		return err
+	}
+	s.roles.Action(watch.Added, r)
+	return nil
+}
+
+// UpdateRole replaces the rules of the role of the location and name of r.
+func (s *Store) UpdateRole(ctx context.Context, r *Role) error {
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		old := &Role{}
+		if err := first(at(tx.WithContext(ctx).Where("name = ?", r.Name), r.Location), old); err != nil {
+			return fmt.Errorf("role %q of %s: %w", r.Name, r.Location, err)
+		}
+		r.ID, r.CreatedAt = old.ID, old.CreatedAt
+		return tx.WithContext(ctx).Update(r)
+	})
+	if err != nil {
+		return err
+	}
+	s.roles.Action(watch.Modified, r)
+	return nil
+}
+
+// DeleteRole deletes and returns the role of the location l with the name, the
+// bindings of the role are left and grant nothing until it is created again.
+func (s *Store) DeleteRole(ctx context.Context, l Location, name string) (*Role, error) {
+	role, err := s.GetRole(ctx, l, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.conn(ctx).Destroy(role); err != nil {
+		return nil, err
+	}
+	s.roles.Action(watch.Deleted, role)
+	return role, nil
+}
+
+// ListRoleBindings returns the role bindings of the location l.
+func (s *Store) ListRoleBindings(ctx context.Context, l Location) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	if err := at(s.conn(ctx).Q(), l).Order("name").All(&bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// GetRoleBinding returns the role binding of the location l with the name.
+func (s *Store) GetRoleBinding(ctx context.Context, l Location, name string) (*RoleBinding, error) {
+	binding := &RoleBinding{}
+	if err := first(at(s.conn(ctx).Where("name = ?", name), l), binding); err != nil {
+		return nil, fmt.Errorf("role binding %q of %s: %w", name, l, err)
+	}
+	return binding, nil
+}
+
+// CreateRoleBinding saves the new role binding b.
+func (s *Store) CreateRoleBinding(ctx context.Context, b *RoleBinding) error {
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		exists, err := at(tx.WithContext(ctx).Where("name = ?", b.Name), b.Location).Exists(&RoleBinding{})
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("role binding %q of %s: %w", b.Name, b.Location, ErrAlreadyExists)
+		}
+		return tx.WithContext(ctx).Create(b)
+	})
+	if err != nil {
+		return err
+	}
+	s.bindings.Action(watch.Added, b)
+	return nil
+}
+
+// UpdateRoleBinding replaces the role and subjects of the role binding of the
+// location and name of b.
+func (s *Store) UpdateRoleBinding(ctx context.Context, b *RoleBinding) error {
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		old := &RoleBinding{}
+		if err := first(at(tx.WithContext(ctx).Where("name = ?", b.Name), b.Location), old); err != nil {
+			return fmt.Errorf("role binding %q of %s: %w", b.Name, b.Location, err)
+		}
+		b.ID, b.CreatedAt = old.ID, old.CreatedAt
+		return tx.WithContext(ctx).Update(b)
+	})
+	if err != nil {
+		return err
+	}
+	s.bindings.Action(watch.Modified, b)
+	return nil
+}
+
+// DeleteRoleBinding deletes and returns the role binding of the location l with the name.
+func (s *Store) DeleteRoleBinding(ctx context.Context, l Location, name string) (*RoleBinding, error) {
+	binding, err := s.GetRoleBinding(ctx, l, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.conn(ctx).Destroy(binding); err != nil {
+		return nil, err
+	}
+	s.bindings.Action(watch.Deleted, binding)
+	return binding, nil
+}
+
+// BindingsFor returns the role bindings of l and of the locations containing it.
+func (s *Store) BindingsFor(ctx context.Context, l Location) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	err := s.conn(ctx).
+		Where("(cluster = '' OR cluster = ?)", l.Cluster).
+		Where("(workspace = '' OR workspace = ?)", l.Workspace).
+		Where("(namespace = '' OR namespace = ?)", l.Namespace).
+		All(&bindings)
+	if err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// RolesNamed returns the roles of any location with one of the names.
+func (s *Store) RolesNamed(ctx context.Context, names []string) ([]Role, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		args = append(args, name)
+	}
+	var roles []Role
+	if err := s.conn(ctx).Where("name in (?)", args...).All(&roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// DeleteWithin deletes the roles and role bindings of the local locations within
+// the workspace or namespace of l, like the namespaces of a workspace with the
+// workspace. The ones of member clusters are kept.
+func (s *Store) DeleteWithin(ctx context.Context, l Location) error {
+	_, err := s.deleteWithin(ctx, l)
+	return err
+}
+
+// Resource returns what the store keeps, for the tenancy deleting the roles and role
+// bindings of the deleted namespaces.
+func (s *Store) Resource() string {
+	return "roles and role bindings"
+}
+
+// ListNamespaces returns the local namespaces having roles or role bindings.
+func (s *Store) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	err := s.conn(ctx).RawQuery("SELECT namespace FROM " + Role{}.TableName() + " WHERE cluster = '' AND namespace <> ''" +
+		" UNION SELECT namespace FROM " + RoleBinding{}.TableName() + " WHERE cluster = '' AND namespace <> ''" +
+		" ORDER BY namespace").All(&namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// ListWorkspaces returns the local workspaces having roles or role bindings.
+func (s *Store) ListWorkspaces(ctx context.Context) ([]string, error) {
+	var workspaces []string
+	err := s.conn(ctx).RawQuery("SELECT workspace FROM " + Role{}.TableName() + " WHERE cluster = '' AND workspace <> ''" +
+		" UNION SELECT workspace FROM " + RoleBinding{}.TableName() + " WHERE cluster = '' AND workspace <> ''" +
+		" ORDER BY workspace").All(&workspaces)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// DeleteNamespace deletes the roles and role bindings of the local namespace, and
+// returns how many were deleted.
+func (s *Store) DeleteNamespace(ctx context.Context, namespace string) (int, error) {
+	return s.deleteWithin(ctx, Location{Namespace: namespace})
+}
+
+func (s *Store) deleteWithin(ctx context.Context, l Location) (int, error) {
+	if l.Workspace == "" && l.Namespace == "" {
+		return 0, errors.New("no workspace or namespace to delete the roles and role bindings of")
+	}
+	where, args := "cluster = ''", []interface{}{}
+	if l.Workspace != "" {
+		where, args = where+" AND workspace = ?", append(args, l.Workspace)
+	}
+	if l.Namespace != "" {
+		where, args = where+" AND namespace = ?", append(args, l.Namespace)
+	}
+	// the deleted ones are listed first, for their watchers
+	var roles []Role
+	var bindings []RoleBinding
+	err := s.persister.Transaction(func(tx *pop.Connection) error {
+		for _, deleted := range []interface{}{&roles, &bindings} {
+			if err := tx.WithContext(ctx).Where(where, args...).All(deleted); err != nil {
+				return err
+			}
+		}
+		if len(roles) > 0 {
+			if err := tx.WithContext(ctx).Destroy(&roles); err != nil {
+				return err
+			}
+		}
+		if len(bindings) > 0 {
+			return tx.WithContext(ctx).Destroy(&bindings)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := range roles {
+		s.roles.Action(watch.Deleted, &roles[i])
+	}
+	for i := range bindings {
+		s.bindings.Action(watch.Deleted, &bindings[i])
+	}
+	return len(roles) + len(bindings), nil
+}
+
+func (s *Store) conn(ctx context.Context) *pop.Connection {
+	return s.persister.GetConnection().WithContext(ctx)
+}
+
+// at restricts q to the objects of the location l.
+func at(q *pop.Query, l Location) *pop.Query {
+	return q.Where("cluster = ?", l.Cluster).
+		Where("workspace = ?", l.Workspace).
+		Where("namespace = ?", l.Namespace)
+}
+
+func first(q *pop.Query, model interface{}) error {
+	err := q.First(model)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,272 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package rbac authorizes requests with the roles bound to their users.
+//
+// A Role grants its rules within its location, a cluster, workspace or
+// namespace. A ClusterRole is a Role of the global location, bound by a
+// RoleBinding of the global location (a ClusterRoleBinding) it grants its rules
+// everywhere, bound by a RoleBinding of another location only there.
+package rbac
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"peta.io/peta/pkg/server/request"
+)
+
+const (
+	KindUser        = "User"
+	KindGroup       = "Group"
+	KindRole        = "Role"
+	KindClusterRole = "ClusterRole"
+
+	// All matches every verb, API group, resource or non-resource URL in a rule.
+	All = "*"
+)
+
+// PolicyRule grants the verbs on resources, or on non-resource URLs.
+type PolicyRule struct {
+	Verbs []string `json:"verbs"`
+	// APIGroups of the resources, "" is the group of the core resources.
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// Resources are resource names like hosts, or resource/subresource.
+	Resources []string `json:"resources,omitempty"`
+	// ResourceNames restrict the rule to the objects of the names if not empty.
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	// NonResourceURLs are paths like /metrics, a trailing * matches a prefix. They
+	// are only granted by the roles of ClusterRoleBindings.
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+}
+
+// Rules is the list of rules of a role.
+type Rules []PolicyRule
+
+// Subject is a user or group a role is bound to.
+type Subject struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Subjects is the list of subjects of a binding.
+type Subjects []Subject
+
+// Location is where roles and bindings apply, the global location has no field set.
+type Location struct {
+	Cluster   string `db:"cluster" json:"cluster,omitempty"`
+	Workspace string `db:"workspace" json:"workspace,omitempty"`
+	Namespace string `db:"namespace" json:"namespace,omitempty"`
+}
+
+// Role is a set of rules, a ClusterRole if its location is global.
+type Role struct {
+	ID   uuid.UUID `db:"id" json:"-"`
+	Name string    `db:"name" json:"name"`
+	Location
+	Rules     Rules     `db:"rules" json:"rules"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt,omitempty"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt,omitempty"`
+}
+
+// TableName overrides the table name used by pop.
+func (r Role) TableName() string {
+	return "roles"
+}
+
+// RoleBinding grants the rules of a role to the subjects within its location, a
+// ClusterRoleBinding if its location is global.
+type RoleBinding struct {
+	ID   uuid.UUID `db:"id" json:"-"`
+	Name string    `db:"name" json:"name"`
+	Location
+	// RoleKind is Role for the role of the same location, ClusterRole for a global role.
+	RoleKind  string    `db:"role_kind" json:"roleKind"`
+	RoleName  string    `db:"role_name" json:"roleName"`
+	Subjects  Subjects  `db:"subjects" json:"subjects"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt,omitempty"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt,omitempty"`
+}
+
+// TableName overrides the table name used by pop.
+func (b RoleBinding) TableName() string {
+	return "role_bindings"
+}
+
+// IsGlobal returns true for the location of ClusterRoles and ClusterRoleBindings.
+func (l Location) IsGlobal() bool {
+	return l == Location{}
+}
+
+// Contains returns true if l is other or one of its parents, like the workspace of
+// a namespace in it. Unset fields of l match everything.
+func (l Location) Contains(other Location) bool {
+	return (l.Cluster == "" || l.Cluster == other.Cluster) &&
+		(l.Workspace == "" || l.Workspace == other.Workspace) &&
+		(l.Namespace == "" || l.Namespace == other.Namespace)
+}
+
+// Scope returns the request scope of l.
+func (l Location) Scope() string {
+	switch {
+	case l.Namespace != "":
+		return request.NamespaceScope
+	case l.Workspace != "":
+		return request.WorkspaceScope
+	case l.Cluster != "":
+		return request.ClusterScope
+	}
+	return request.GlobalScope
+}
+
+func (l Location) String() string {
+	switch {
+	case l.Namespace != "":
+		return fmt.Sprintf("namespace %q", l.Namespace)
+	case l.Workspace != "":
+		return fmt.Sprintf("workspace %q", l.Workspace)
+	case l.Cluster != "":
+		return fmt.Sprintf("cluster %q", l.Cluster)
+	}
+	return "global"
+}
+
+// Validate returns an error if r is incomplete.
+func (r *Role) Validate() error {
+	if r.Name == "" {
+		return errors.New("role name is required")
+	}
+	for i, rule := range r.Rules {
+		if len(rule.Verbs) == 0 {
+			return fmt.Errorf("rule %d: verbs are required", i)
+		}
+		if len(rule.NonResourceURLs) > 0 {
+			if !r.Location.IsGlobal() {
+				return fmt.Errorf("rule %d: non-resource urls are only allowed in cluster roles", i)
+			}
+			if len(rule.Resources) > 0 || len(rule.APIGroups) > 0 || len(rule.ResourceNames) > 0 {
+				return fmt.Errorf("rule %d: non-resource urls can not be mixed with resources", i)
+			}
+			continue
+		}
+		if len(rule.Resources) == 0 {
+			return fmt.Errorf("rule %d: resources or non-resource urls are required", i)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if b is incomplete.
+func (b *RoleBinding) Validate() error {
+	if b.Name == "" {
+		return errors.New("role binding name is required")
+	}
+	switch b.RoleKind {
+	case KindClusterRole:
+	case KindRole:
+		if b.Location.IsGlobal() {
+			return fmt.Errorf("cluster role bindings can only bind a %s", KindClusterRole)
+		}
+	default:
+		return fmt.Errorf("role kind must be %s or %s", KindRole, KindClusterRole)
+	}
+	if b.RoleName == "" {
+		return errors.New("role name is required")
+	}
+	if len(b.Subjects) == 0 {
+		return errors.New("subjects are required")
+	}
+	for _, s := range b.Subjects {
+		if s.Kind != KindUser && s.Kind != KindGroup {
+			return fmt.Errorf("subject kind must be %s or %s", KindUser, KindGroup)
+		}
+		if s.Name == "" {
+			return errors.New("subject name is required")
+		}
+	}
+	return nil
+}
+
+// RoleLocation returns the location of the role bound by b.
+func (b *RoleBinding) RoleLocation() Location {
+	if b.RoleKind == KindClusterRole {
+		return Location{}
+	}
+	return b.Location
+}
+
+// Binds returns true if user is one of the subjects of b.
+func (b *RoleBinding) Binds(user *request.User) bool {
+	for _, s := range b.Subjects {
+		switch s.Kind {
+		case KindUser:
+			if s.Name == user.Name {
+				return true
+			}
+		case KindGroup:
+			for _, group := range user.Groups {
+				if s.Name == group {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Value stores the rules as JSON.
+func (r Rules) Value() (driver.Value, error) {
+	return jsonValue(r)
+}
+
+// Scan reads the rules from JSON.
+func (r *Rules) Scan(src interface{}) error {
+	return jsonScan(src, r)
+}
+
+// Value stores the subjects as JSON.
+func (s Subjects) Value() (driver.Value, error) {
+	return jsonValue(s)
+}
+
+// Scan reads the subjects from JSON.
+func (s *Subjects) Scan(src interface{}) error {
+	return jsonScan(src, s)
+}
+
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func jsonScan(src interface{}, v interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), v)
+	case []byte:
+		return json.Unmarshal(src, v)
+	}
+	return fmt.Errorf("unsupported type %T of a JSON column", src)
+}
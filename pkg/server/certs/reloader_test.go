@@ -0,0 +1,190 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"peta.io/peta/pkg/server/authentication"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newKeyPair returns a certificate of the template signed by parent, self-signed
+// if parent is nil.
+func newKeyPair(t *testing.T, template *x509.Certificate, parent *keyPair) *keyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore, template.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &keyPair{cert: cert, key: key, der: der}
+}
+
+func (p *keyPair) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.der})
+}
+
+func (p *keyPair) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the stamps of files written at once may be equal on coarse clocks
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	ca := newKeyPair(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "peta-ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	serverCert := func(serial int64) *keyPair {
+		return newKeyPair(t, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "peta"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca)
+	}
+	client := newKeyPair(t, &x509.Certificate{
+		SerialNumber: big.NewInt(100),
+		Subject:      pkix.Name{CommonName: "alice", Organization: []string{"ops", "dev"}},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	mtime := time.Now().Add(-time.Minute)
+	first := serverCert(2)
+	writeFile(t, certFile, first.certPEM(), mtime)
+	writeFile(t, keyFile, first.keyPEM(t), mtime)
+	writeFile(t, caFile, ca.certPEM(), mtime)
+
+	r, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, ok := authentication.CertificateUser(req)
+		if !ok {
+			_, _ = w.Write([]byte("anonymous"))
+			return
+		}
+		_, _ = w.Write([]byte(user.Name + ":" + strings.Join(user.Groups, ",")))
+	}))
+	server.TLS = r.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(withCert bool) (serial int64, body string) {
+		t.Helper()
+		config := &tls.Config{RootCAs: roots}
+		if withCert {
+			config.Certificates = []tls.Certificate{{Certificate: [][]byte{client.der}, PrivateKey: client.key}}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := c.Get(server.URL)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), string(data)
+	}
+
+	if serial, body := get(true); serial != 2 || body != "alice:dev,ops" {
+		t.Errorf("GET with a client certificate = %d, %q, want 2, %q", serial, body, "alice:dev,ops")
+	}
+	if _, body := get(false); body != "anonymous" {
+		t.Errorf("GET without a client certificate = %q, want anonymous", body)
+	}
+
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v, want false, nil", reloaded, err)
+	}
+
+	// a certificate written before its key is not loaded
+	second := serverCert(3)
+	mtime = mtime.Add(time.Second)
+	writeFile(t, certFile, second.certPEM(), mtime)
+	if reloaded, err := r.Reload(); reloaded || err == nil {
+		t.Errorf("Reload() of a mismatched key = %v, %v, want false and an error", reloaded, err)
+	}
+	if serial, _ := get(false); serial != 2 {
+		t.Errorf("serial after a failed reload = %d, want 2", serial)
+	}
+
+	writeFile(t, keyFile, second.keyPEM(t), mtime)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Errorf("Reload() of new files = %v, %v, want true, nil", reloaded, err)
+	}
+	if serial, body := get(true); serial != 3 || body != "alice:dev,ops" {
+		t.Errorf("GET after reload = %d, %q, want 3, %q", serial, body, "alice:dev,ops")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package filters
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"peta.io/peta/pkg/server/auditing"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/server/responsewriter"
+)
+
+// WithAuditing records the requests with the level the auditor picks, it runs after
+// WithAuthentication so the user is known, and before WithAuthorization so denied
+// requests are recorded too.
+func WithAuditing(next http.Handler, auditor *auditing.Auditor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := request.InfoFrom(req.Context())
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		user, _ := request.UserFrom(req.Context())
+		level := auditor.LevelOf(info, user)
+		if level == auditing.LevelNone {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		event := auditing.NewEvent(level, info, user, req.RequestURI)
+		if !level.Less(auditing.LevelRequest) && req.Body != nil && req.Body != http.NoBody {
+			body, truncated, err := peekBody(req, auditor.MaxBodySize())
+			if err == nil {
+				event.RequestBody, event.Truncated = auditing.RedactBody(body), truncated
+			}
+		}
+
+		wrapper := &auditResponseWriter{
+			MetaResponseWriter: responsewriter.NewMetaResponseWriter(w),
+			keepBody:           level == auditing.LevelRequestResponse,
+			maxBodySize:        auditor.MaxBodySize(),
+		}
+		defer func() {
+			event.ResponseCode = wrapper.StatusCode
+			event.ResponseCompletedAt = time.Now()
+			if wrapper.keepBody {
+				event.ResponseBody = auditing.RedactBody(wrapper.body.String())
+				event.Truncated = event.Truncated || wrapper.truncated
+			}
+			auditor.Log(event)
+		}()
+
+		next.ServeHTTP(responsewriter.WrapForHTTP1Or2(wrapper), req)
+	})
+}
+
+// peekBody returns up to limit bytes of the body of req, leaving the body for the handler.
+func peekBody(req *http.Request, limit int) (string, bool, error) {
+	buf := make([]byte, limit+1)
+	n, err := io.ReadFull(req.Body, buf)
+	buf = buf[:n]
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", false, err
+	}
+	if n > limit {
+		return string(buf[:limit]), true, nil
+	}
+	return string(buf), false, nil
+}
+
+// auditResponseWriter keeps the status and, if keepBody, the beginning of the body of responses.
+type auditResponseWriter struct {
+	*responsewriter.MetaResponseWriter
+	keepBody    bool
+	maxBodySize int
+	body        bytes.Buffer
+	truncated   bool
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.keepBody {
+		if room := w.maxBodySize - w.body.Len(); room < len(b) {
+			w.body.Write(b[:max(room, 0)])
+			w.truncated = true
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.MetaResponseWriter.Write(b)
+}
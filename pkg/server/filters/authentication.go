@@ -0,0 +1,74 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package filters
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/request"
+)
+
+// WithAuthentication attaches the user of the verified client certificate, or else
+// of the bearer token, to the request context. Requests without either are rejected
+// unless their path is allowed.
+func WithAuthentication(next http.Handler, auth authentication.TokenAuthenticator, allowed authentication.PathMatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, ok := authentication.CertificateUser(req); ok {
+			next.ServeHTTP(w, withUser(req, user))
+			return
+		}
+
+		token, ok := authentication.TokenFrom(req)
+		if !ok {
+			if allowed.Matches(req.URL.Path) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			unauthorized(w, req, errors.New("bearer token is required"))
+			return
+		}
+
+		user, ok, err := auth.AuthenticateToken(req.Context(), token)
+		if !ok {
+			if err == nil {
+				err = authentication.ErrInvalidToken
+			}
+			unauthorized(w, req, err)
+			return
+		}
+
+		next.ServeHTTP(w, withUser(req, user))
+	})
+}
+
+// withUser returns a copy of req with the user in its context, the headers of req
+// are copied too, so that the token is not passed on, like to proxied requests.
+func withUser(req *http.Request, user *request.User) *http.Request {
+	req = req.Clone(request.WithUser(req.Context(), user))
+	req.Header.Del("Authorization")
+	return req
+}
+
+func unauthorized(w http.ResponseWriter, req *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="peta"`)
+	apis.HandleUnauthorized(restful.NewResponse(w), restful.NewRequest(req), err)
+}
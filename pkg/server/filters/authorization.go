@@ -0,0 +1,56 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/authorization"
+	"peta.io/peta/pkg/server/request"
+)
+
+// WithAuthorization rejects the requests the authorizer does not allow to their user,
+// it runs after WithRequestInfo and WithAuthentication.
+func WithAuthorization(next http.Handler, authorizer authorization.Authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		resp, r := restful.NewResponse(w), restful.NewRequest(req)
+
+		info, ok := request.InfoFrom(req.Context())
+		if !ok {
+			apis.HandleInternalError(resp, r, errors.New("no request info found in context"))
+			return
+		}
+
+		user, _ := request.UserFrom(req.Context())
+		attributes := authorization.AttributesFrom(user, info)
+		decision, reason, err := authorizer.Authorize(req.Context(), attributes)
+		if decision == authorization.DecisionAllow {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if err != nil {
+			apis.HandleInternalError(resp, r, fmt.Errorf("failed to authorize the request: %w", err))
+			return
+		}
+
+		apis.HandleForbidden(resp, r, authorization.Forbidden(attributes, reason))
+	})
+}
@@ -0,0 +1,38 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package filters
+
+import (
+	"net/http"
+
+	"peta.io/peta/pkg/server/multicluster"
+	"peta.io/peta/pkg/server/request"
+)
+
+// WithMultiCluster forwards the requests under /clusters/{name} to the member
+// cluster with the proxy, it runs after WithAuthorization so the requests are
+// authorized in the cluster before they leave.
+func WithMultiCluster(next http.Handler, proxy *multicluster.Proxy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := request.InfoFrom(req.Context())
+		if !ok || info.Cluster == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		proxy.Forward(w, req, info.Cluster, info.Path)
+	})
+}
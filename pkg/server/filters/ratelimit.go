@@ -0,0 +1,111 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package filters
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/ratelimit"
+	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/utils/iputils"
+	"peta.io/peta/pkg/utils/sets"
+)
+
+// mutatingMethods are the http methods of mutating non-resource requests.
+var mutatingMethods = sets.New(http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)
+
+// WithMaxInFlight rejects the read-only or mutating requests beyond their limit of
+// requests served at once, watches are long-running and not limited. It runs after
+// WithRequestInfo.
+func WithMaxInFlight(next http.Handler, readOnly, mutating *ratelimit.InFlight, metrics *ratelimit.Metrics, exempt authentication.PathMatcher) http.Handler {
+	if readOnly == nil && mutating == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := request.InfoFrom(req.Context())
+		if !ok || info.Verb == request.VerbWatch || exempt.Matches(req.URL.Path) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		kind, limit := ratelimit.ReadOnly, readOnly
+		if isMutating(info, req) {
+			kind, limit = ratelimit.Mutating, mutating
+		}
+		if !limit.Acquire() {
+			metrics.InFlightRejected(kind)
+			tooManyRequests(w, req, time.Second, fmt.Errorf("too many %s requests in flight, please try again later", kind))
+			return
+		}
+		defer limit.Release()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// WithRateLimit rejects the requests of clients beyond their rate limit, clients are
+// users, or source ips of anonymous requests. The source ips are the addresses of the
+// connections, the forwarding headers are only honored for the trusted proxies. It runs
+// after WithAuthentication.
+func WithRateLimit(next http.Handler, limiter *ratelimit.Limiter, metrics *ratelimit.Metrics, exempt authentication.PathMatcher, trustedProxies []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if exempt.Matches(req.URL.Path) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		var group string
+		if info, ok := request.InfoFrom(req.Context()); ok {
+			group = info.APIGroup
+		}
+		kind, client := ratelimit.ClientIP, iputils.ClientIP(req, trustedProxies)
+		if user, ok := request.UserFrom(req.Context()); ok {
+			kind, client = ratelimit.ClientUser, user.Name
+		}
+
+		if ok, retryAfter := limiter.Allow(group, kind+":"+client); !ok {
+			metrics.RateLimited(group, kind)
+			tooManyRequests(w, req, retryAfter, fmt.Errorf("rate limit of %s %q exceeded, please try again later", kind, client))
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func isMutating(info *request.Info, req *http.Request) bool {
+	if !info.IsResourceRequest {
+		return mutatingMethods.Has(req.Method)
+	}
+	switch info.Verb {
+	case request.VerbCreate, request.VerbUpdate, request.VerbPatch, request.VerbDelete, "delete_collection":
+		return true
+	}
+	return false
+}
+
+func tooManyRequests(w http.ResponseWriter, req *http.Request, retryAfter time.Duration, err error) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	apis.HandleTooManyRequests(restful.NewResponse(w), restful.NewRequest(req), apis.NewTooManyRequests(err.Error(), seconds))
+}
@@ -0,0 +1,78 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package multicluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	Enabled       = "multicluster-enabled"
+	ProbeInterval = "cluster-probe-interval"
+	ProbeTimeout  = "cluster-probe-timeout"
+)
+
+type Options struct {
+	// Enable serves the clusters API and forwards the requests of /clusters/{name}
+	// to the member clusters.
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// ProbeInterval is how often the members are probed, and ProbeTimeout how long
+	// a probe waits for the member to answer.
+	ProbeInterval time.Duration `json:"probeInterval,omitempty" yaml:"probeInterval,omitempty" mapstructure:"probeInterval"`
+	ProbeTimeout  time.Duration `json:"probeTimeout,omitempty" yaml:"probeTimeout,omitempty" mapstructure:"probeTimeout"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		ProbeInterval: 30 * time.Second,
+		ProbeTimeout:  5 * time.Second,
+	}
+}
+
+func (o *Options) Merge(fs *pflag.FlagSet, conf *Options) {
+	if f := fs.Lookup(Enabled); f != nil && !f.Changed {
+		o.Enable = conf.Enable
+	}
+	if f := fs.Lookup(ProbeInterval); f != nil && !f.Changed && conf.ProbeInterval > 0 {
+		o.ProbeInterval = conf.ProbeInterval
+	}
+	if f := fs.Lookup(ProbeTimeout); f != nil && !f.Changed && conf.ProbeTimeout > 0 {
+		o.ProbeTimeout = conf.ProbeTimeout
+	}
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+	if !o.Enable {
+		return errs
+	}
+	if o.ProbeInterval <= 0 || o.ProbeTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("* %s and %s must be positive", ProbeInterval, ProbeTimeout))
+	} else if o.ProbeTimeout > o.ProbeInterval {
+		errs = append(errs, fmt.Errorf("* %s must not be longer than %s", ProbeTimeout, ProbeInterval))
+	}
+	return errs
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, Enabled, o.Enable, "serve the clusters api and forward the requests of member clusters or not")
+	fs.DurationVar(&o.ProbeInterval, ProbeInterval, o.ProbeInterval, "how often the health of member clusters is probed")
+	fs.DurationVar(&o.ProbeTimeout, ProbeTimeout, o.ProbeTimeout, "how long a probe of a member cluster waits for its answer")
+}
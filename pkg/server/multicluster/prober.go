@@ -0,0 +1,125 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	clusterv1alpha2 "peta.io/peta/pkg/apis/cluster/v1alpha2"
+	"peta.io/peta/pkg/log"
+)
+
+// maxProbeMessage is the maximum length of the body of failed probes kept in the status.
+const maxProbeMessage = 256
+
+// Prober probes the readyz of the member clusters of a proxy, and records their
+// phase in their status when it changes, so every server fronting them sees it.
+type Prober struct {
+	proxy    *Proxy
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewProber(proxy *Proxy, o *Options) *Prober {
+	return &Prober{proxy: proxy, interval: o.ProbeInterval, timeout: o.ProbeTimeout}
+}
+
+// Run probes the members every interval until ctx is done.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.ProbeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll probes the members at once and waits for them.
+func (p *Prober) ProbeAll(ctx context.Context) {
+	clusters, err := p.proxy.store.List(ctx, "")
+	if err != nil {
+		log.Errorf("failed to list member clusters: %v", err)
+		return
+	}
+	p.proxy.prune(clusters)
+
+	var wg sync.WaitGroup
+	for _, c := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			phase, message := p.probe(ctx, c)
+			p.record(ctx, c, phase, message)
+		}()
+	}
+	wg.Wait()
+}
+
+// probe returns the phase of the member, and why it is not ready if it isn't.
+func (p *Prober) probe(ctx context.Context, c *clusterv1alpha2.Cluster) (clusterv1alpha2.ClusterPhase, string) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	rt, err := p.proxy.transport(c)
+	if err != nil {
+		return clusterv1alpha2.ClusterNotReady, err.Error()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.Spec.Endpoint, "/")+"/readyz", nil)
+	if err != nil {
+		return clusterv1alpha2.ClusterNotReady, err.Error()
+	}
+	if c.Spec.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Spec.BearerToken)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return clusterv1alpha2.ClusterNotReady, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxProbeMessage))
+		return clusterv1alpha2.ClusterNotReady, fmt.Sprintf("readyz returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return clusterv1alpha2.ClusterReady, ""
+}
+
+// record saves the phase of the member if it changed. The update conflicts if the
+// cluster changed since it was listed, the next probe records it then.
+func (p *Prober) record(ctx context.Context, c *clusterv1alpha2.Cluster, phase clusterv1alpha2.ClusterPhase, message string) {
+	if c.Status.Phase == phase && c.Status.Message == message {
+		return
+	}
+	if c.Status.Phase != phase {
+		now := time.Now()
+		c.Status.LastTransitionTime = &now
+		log.Infof("cluster %q is %s", c.Name, phase)
+	}
+	c.Status.Phase, c.Status.Message = phase, message
+	if err := p.proxy.store.Update(ctx, c); err != nil {
+		log.Warnf("failed to record the health of cluster %q: %v", c.Name, err)
+	}
+}
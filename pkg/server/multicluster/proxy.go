@@ -0,0 +1,152 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package multicluster fronts member PETA servers: it forwards the requests of
+// /clusters/{name}/... to the members and keeps their health.
+package multicluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"peta.io/peta/pkg/apis"
+	clusterv1alpha2 "peta.io/peta/pkg/apis/cluster/v1alpha2"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/log"
+)
+
+// Proxy forwards requests to the member clusters of a store with their
+// credentials. The requests must be authenticated and authorized before, the
+// members only see the credentials of the clusters.
+type Proxy struct {
+	store *rest.Store[clusterv1alpha2.Cluster, *clusterv1alpha2.Cluster]
+
+	mu         sync.Mutex
+	transports map[string]*transport
+}
+
+// transport is the transport of a member, built again when its spec changes.
+type transport struct {
+	spec clusterv1alpha2.ClusterSpec
+	rt   *http.Transport
+}
+
+func NewProxy(store *rest.Store[clusterv1alpha2.Cluster, *clusterv1alpha2.Cluster]) *Proxy {
+	return &Proxy{store: store, transports: map[string]*transport{}}
+}
+
+// Store returns the store of the member clusters.
+func (p *Proxy) Store() *rest.Store[clusterv1alpha2.Cluster, *clusterv1alpha2.Cluster] {
+	return p.store
+}
+
+// Forward sends the request to the member cluster with the path, the one of the
+// request without /clusters/{name}, and copies back its response. Watches are
+// streamed as the member writes them.
+func (p *Proxy) Forward(w http.ResponseWriter, req *http.Request, cluster, path string) {
+	c, err := p.store.Get(req.Context(), "", cluster)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if c.Status.Phase == clusterv1alpha2.ClusterNotReady {
+		writeError(w, apis.NewServiceUnavailable(fmt.Sprintf("cluster %q is not ready: %s", cluster, c.Status.Message)))
+		return
+	}
+	target, err := url.Parse(c.Spec.Endpoint)
+	if err != nil {
+		writeError(w, apis.NewInternalError(fmt.Errorf("invalid endpoint of cluster %q: %w", cluster, err)))
+		return
+	}
+	rt, err := p.transport(c)
+	if err != nil {
+		writeError(w, apis.NewInternalError(err))
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Path, r.Out.URL.RawPath = path, ""
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Header.Del("Authorization")
+			if c.Spec.BearerToken != "" {
+				r.Out.Header.Set("Authorization", "Bearer "+c.Spec.BearerToken)
+			}
+		},
+		Transport: rt,
+		// flush at once, so the events of watches are not held back
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Warnf("failed to forward %s %s to cluster %q: %v", r.Method, path, cluster, err)
+			writeError(w, apis.NewServiceUnavailable(fmt.Sprintf("cluster %q is unavailable: %v", cluster, err)))
+		},
+	}
+	proxy.ServeHTTP(w, req)
+}
+
+// transport returns the transport of the cluster, the one of its spec if built before.
+func (p *Proxy) transport(c *clusterv1alpha2.Cluster) (*http.Transport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, ok := p.transports[c.Name]; ok {
+		if t.spec == c.Spec {
+			return t.rt, nil
+		}
+		t.rt.CloseIdleConnections()
+		delete(p.transports, c.Name)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Spec.InsecureSkipTLSVerify}
+	if c.Spec.CAData != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.Spec.CAData)) {
+			return nil, fmt.Errorf("no certificates in the CA data of cluster %q", c.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+	rt.TLSClientConfig = tlsConfig
+	p.transports[c.Name] = &transport{spec: c.Spec, rt: rt}
+	return rt, nil
+}
+
+// prune drops the transports of the clusters no longer registered.
+func (p *Proxy) prune(clusters []*clusterv1alpha2.Cluster) {
+	names := make(map[string]bool, len(clusters))
+	for _, c := range clusters {
+		names[c.Name] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, t := range p.transports {
+		if !names[name] {
+			t.rt.CloseIdleConnections()
+			delete(p.transports, name)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apis.WriteStatus(w, apis.StatusFor(http.StatusInternalServerError, err))
+}
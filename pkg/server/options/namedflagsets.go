@@ -117,11 +117,30 @@ func SetUsageAndHelpFunc(cmd *cobra.Command, nfs *NamedFlagSets) {
 	cols, _, _ := term.Size(cmd.OutOrStdout())
 	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
 		_, _ = fmt.Fprintf(cmd.OutOrStderr(), usageFmt, cmd.UseLine())
+		PrintSections(cmd.OutOrStderr(), commandFlags(cmd, nfs), cols)
 		PrintSections(cmd.OutOrStderr(), nfs, cols)
 		return nil
 	})
 	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
 		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s\n\n"+usageFmt, cmd.Long, cmd.UseLine())
+		PrintSections(cmd.OutOrStdout(), commandFlags(cmd, nfs), cols)
 		PrintSections(cmd.OutOrStdout(), nfs, cols)
 	})
 }
+
+// commandFlags returns the flags of subcommands which are not in the named flag sets.
+func commandFlags(cmd *cobra.Command, nfs *NamedFlagSets) *NamedFlagSets {
+	named := map[string]struct{}{}
+	for _, fs := range nfs.FlagSets {
+		fs.VisitAll(func(f *pflag.Flag) { named[f.Name] = struct{}{} })
+	}
+
+	local := &NamedFlagSets{}
+	fs := local.FlagSet("command")
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if _, ok := named[f.Name]; !ok && f.Name != "help" {
+			fs.AddFlag(f)
+		}
+	})
+	return local
+}
@@ -27,7 +27,11 @@ import (
 	"peta.io/peta/pkg/log"
 	"peta.io/peta/pkg/persistence"
 	"peta.io/peta/pkg/server/auditing"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/authorization"
 	"peta.io/peta/pkg/server/metrics"
+	"peta.io/peta/pkg/server/multicluster"
+	"peta.io/peta/pkg/server/ratelimit"
 	"peta.io/peta/pkg/utils/iputils"
 )
 
@@ -45,20 +49,28 @@ const (
 )
 
 type APIServerOptions struct {
-	ConfigFile        string
-	DebugMode         bool
-	*ServerRunOptions `json:"server,omitempty" yaml:"server,omitempty" mapstructure:"server"`
-	AuditingOptions   *auditing.Options    `json:"auditing,omitempty" yaml:"auditing,omitempty" mapstructure:"auditing"`
-	MetricsOptions    *metrics.Options     `json:"metrics,omitempty" yaml:"metrics,omitempty" mapstructure:"metrics"`
-	DatabaseOptions   *persistence.Options `json:"database,omitempty" yaml:"database,omitempty" mapstructure:"database"`
+	ConfigFile            string
+	DebugMode             bool
+	*ServerRunOptions     `json:"server,omitempty" yaml:"server,omitempty" mapstructure:"server"`
+	AuditingOptions       *auditing.Options       `json:"auditing,omitempty" yaml:"auditing,omitempty" mapstructure:"auditing"`
+	MetricsOptions        *metrics.Options        `json:"metrics,omitempty" yaml:"metrics,omitempty" mapstructure:"metrics"`
+	DatabaseOptions       *persistence.Options    `json:"database,omitempty" yaml:"database,omitempty" mapstructure:"database"`
+	AuthenticationOptions *authentication.Options `json:"authentication,omitempty" yaml:"authentication,omitempty" mapstructure:"authentication"`
+	AuthorizationOptions  *authorization.Options  `json:"authorization,omitempty" yaml:"authorization,omitempty" mapstructure:"authorization"`
+	RateLimitOptions      *ratelimit.Options      `json:"ratelimit,omitempty" yaml:"ratelimit,omitempty" mapstructure:"ratelimit"`
+	MultiClusterOptions   *multicluster.Options   `json:"multicluster,omitempty" yaml:"multicluster,omitempty" mapstructure:"multicluster"`
 }
 
 func NewAPIServerOptions() *APIServerOptions {
 	o := &APIServerOptions{
-		ServerRunOptions: NewServerRunOptions(),
-		AuditingOptions:  auditing.NewOptions(),
-		MetricsOptions:   metrics.NewOptions(),
-		DatabaseOptions:  persistence.NewOptions(),
+		ServerRunOptions:      NewServerRunOptions(),
+		AuditingOptions:       auditing.NewOptions(),
+		MetricsOptions:        metrics.NewOptions(),
+		DatabaseOptions:       persistence.NewOptions(),
+		AuthenticationOptions: authentication.NewOptions(),
+		AuthorizationOptions:  authorization.NewOptions(),
+		RateLimitOptions:      ratelimit.NewOptions(),
+		MultiClusterOptions:   multicluster.NewOptions(),
 	}
 	return o
 }
@@ -68,6 +80,10 @@ func (s *APIServerOptions) Merge(fs *pflag.FlagSet, conf *APIServerOptions) {
 	s.ServerRunOptions.Merge(fs, conf.ServerRunOptions)
 	s.MetricsOptions.Merge(fs, conf.MetricsOptions)
 	s.DatabaseOptions.Merge(fs, conf.DatabaseOptions)
+	s.AuthenticationOptions.Merge(fs, conf.AuthenticationOptions)
+	s.AuthorizationOptions.Merge(fs, conf.AuthorizationOptions)
+	s.RateLimitOptions.Merge(fs, conf.RateLimitOptions)
+	s.MultiClusterOptions.Merge(fs, conf.MultiClusterOptions)
 }
 
 func (s *APIServerOptions) Flags() *NamedFlagSets {
@@ -92,6 +108,10 @@ func (s *APIServerOptions) AddFlags(nfs *NamedFlagSets) {
 	s.AuditingOptions.AddFlags(nfs.Insert("auditing", 1))
 	s.MetricsOptions.AddFlags(nfs.Insert("metrics", 1))
 	s.DatabaseOptions.AddFlags(nfs.Insert("database", 1))
+	s.AuthenticationOptions.AddFlags(nfs.Insert("authentication", 1))
+	s.AuthorizationOptions.AddFlags(nfs.Insert("authorization", 1))
+	s.RateLimitOptions.AddFlags(nfs.Insert("ratelimit", 1))
+	s.MultiClusterOptions.AddFlags(nfs.Insert("multicluster", 1))
 }
 
 type ServerRunOptions struct {
@@ -17,6 +17,8 @@
 
 package options
 
+import "fmt"
+
 // Validate validates api server options, to find
 // options' misconfiguration
 func (s *APIServerOptions) Validate() []error {
@@ -25,5 +27,19 @@ func (s *APIServerOptions) Validate() []error {
 	errs = append(errs, s.MetricsOptions.Validate()...)
 	errs = append(errs, s.AuditingOptions.Validate()...)
 	errs = append(errs, s.DatabaseOptions.Validate()...)
+	errs = append(errs, s.AuthenticationOptions.Validate()...)
+	errs = append(errs, s.AuthorizationOptions.Validate()...)
+	errs = append(errs, s.RateLimitOptions.Validate()...)
+	errs = append(errs, s.MultiClusterOptions.Validate()...)
+	if s.AuthenticationOptions.ClientCAFile != "" && s.SecurePort == 0 {
+		errs = append(errs, fmt.Errorf("* client certificates are only verified on the secure port"))
+	}
+	if s.AuthorizationOptions.Enable && !s.AuthenticationOptions.Enable {
+		errs = append(errs, fmt.Errorf("* authorization requires authentication to be enabled"))
+	}
+	// the members are sent the requests with their bearer token instead of the client's
+	if s.MultiClusterOptions.Enable && !(s.AuthenticationOptions.Enable && s.AuthorizationOptions.Enable) {
+		errs = append(errs, fmt.Errorf("* multicluster requires authentication and authorization to be enabled"))
+	}
 	return errs
 }
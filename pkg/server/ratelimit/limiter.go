@@ -0,0 +1,173 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package ratelimit protects the api server from clients sending too many requests.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets of idle clients are dropped.
+const sweepInterval = time.Minute
+
+// Limit is the rate of a token bucket, it holds up to Burst tokens and gains QPS
+// tokens per second.
+type Limit struct {
+	QPS   float64
+	Burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type bucketKey struct {
+	group  string
+	client string
+}
+
+// Limiter keeps a token bucket for each client of each limited API group.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	groups    map[string]Limit
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter returns the limiter of o.
+func NewLimiter(o *Options) *Limiter {
+	l := &Limiter{
+		limit:   Limit{QPS: o.QPS, Burst: o.Burst},
+		groups:  map[string]Limit{},
+		buckets: map[bucketKey]*bucket{},
+		now:     time.Now,
+	}
+	for _, g := range o.Groups {
+		l.groups[g.Group] = Limit{QPS: g.QPS, Burst: g.Burst}
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+// Allow takes a token of the bucket of the client for the requests of the API
+// group. If there is none it returns false and how long until there is one.
+func (l *Limiter) Allow(group, client string) (bool, time.Duration) {
+	limit, ok := l.groups[group]
+	if !ok {
+		// groups without their own limit share the bucket of the client.
+		group, limit = "", l.limit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	key := bucketKey{group: group, client: client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.QPS)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.QPS * float64(time.Second))
+}
+
+// Clients returns the number of clients with a bucket.
+func (l *Limiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep drops the buckets which are full again, a new bucket is the same.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		limit, ok := l.groups[key.group]
+		if !ok {
+			limit = l.limit
+		}
+		if b.tokens+now.Sub(b.last).Seconds()*limit.QPS >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// InFlight limits the requests served at once.
+type InFlight struct {
+	slots chan struct{}
+}
+
+// NewInFlight returns the limit of max requests at once, nil if max is 0.
+func NewInFlight(max int) *InFlight {
+	if max <= 0 {
+		return nil
+	}
+	return &InFlight{slots: make(chan struct{}, max)}
+}
+
+// Acquire takes a slot without waiting, it returns false if all are taken. A nil
+// InFlight has no limit.
+func (f *InFlight) Acquire() bool {
+	if f == nil {
+		return true
+	}
+	select {
+	case f.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns a slot taken by Acquire.
+func (f *InFlight) Release() {
+	if f != nil {
+		<-f.slots
+	}
+}
+
+// Len returns the number of requests being served.
+func (f *InFlight) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.slots)
+}
+
+// Cap returns the limit, 0 if there is none.
+func (f *InFlight) Cap() int {
+	if f == nil {
+		return 0
+	}
+	return cap(f.slots)
+}
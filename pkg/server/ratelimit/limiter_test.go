@@ -0,0 +1,172 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter() (*Limiter, *fakeClock) {
+	o := NewOptions()
+	o.QPS, o.Burst = 2, 3
+	o.Groups = []GroupLimit{{Group: "host.peta.io", QPS: 1, Burst: 1}}
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := NewLimiter(o)
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("", "user:alice"); !ok {
+			t.Fatalf("request %d of the burst was rejected", i)
+		}
+	}
+	ok, retryAfter := l.Allow("", "user:alice")
+	if ok {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("retry after = %s, want 500ms", retryAfter)
+	}
+
+	// other clients have their own bucket.
+	if ok, _ := l.Allow("", "user:bob"); !ok {
+		t.Error("request of another client was rejected")
+	}
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("", "user:alice"); !ok {
+		t.Error("request after the refill was rejected")
+	}
+	if ok, _ := l.Allow("", "user:alice"); ok {
+		t.Error("only one token was refilled")
+	}
+}
+
+func TestLimiterGroups(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	if ok, _ := l.Allow("host.peta.io", "ip:10.0.0.1"); !ok {
+		t.Fatal("first request of the group was rejected")
+	}
+	if ok, retryAfter := l.Allow("host.peta.io", "ip:10.0.0.1"); ok || retryAfter != time.Second {
+		t.Errorf("second request of the group: allowed %v, retry after %s", ok, retryAfter)
+	}
+	// groups without a limit share the default bucket, apart from limited groups.
+	if ok, _ := l.Allow("iam.peta.io", "ip:10.0.0.1"); !ok {
+		t.Error("request of a group without limit was rejected")
+	}
+	if got := l.Clients(); got != 2 {
+		t.Errorf("clients = %d, want 2", got)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter()
+	l.Allow("", "user:alice")
+	l.Allow("host.peta.io", "user:alice")
+
+	clock.now = clock.now.Add(sweepInterval)
+	l.Allow("", "user:bob")
+	if got := l.Clients(); got != 1 {
+		t.Errorf("clients after sweep = %d, want 1", got)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	f := NewInFlight(2)
+	if !f.Acquire() || !f.Acquire() {
+		t.Fatal("slots within the limit were not acquired")
+	}
+	if f.Acquire() {
+		t.Fatal("slot beyond the limit was acquired")
+	}
+	f.Release()
+	if !f.Acquire() {
+		t.Error("released slot was not acquired")
+	}
+	if f.Len() != 2 || f.Cap() != 2 {
+		t.Errorf("len %d cap %d, want 2 2", f.Len(), f.Cap())
+	}
+
+	unlimited := NewInFlight(0)
+	if !unlimited.Acquire() || unlimited.Cap() != 0 {
+		t.Error("nil in-flight limit should not limit")
+	}
+	unlimited.Release()
+}
+
+func TestMetrics(t *testing.T) {
+	l, _ := newTestLimiter()
+	l.Allow("", "user:alice")
+	m := NewMetrics(l, NewInFlight(4), nil)
+	m.RateLimited("host.peta.io", ClientUser)
+	m.InFlightRejected(Mutating)
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(m); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range metric.GetLabel() {
+				name += "," + label.GetValue()
+			}
+			values[name] = metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+		}
+	}
+
+	// rejections of both kinds, clients, and requests and limit of both kinds.
+	if len(values) != 7 {
+		t.Errorf("collected %d metrics, want 7: %v", len(values), values)
+	}
+	for name, v := range values {
+		switch {
+		case strings.Contains(name, "ratelimit_clients"), strings.Contains(name, "rejected"):
+			if v != 1 {
+				t.Errorf("%s = %v, want 1", name, v)
+			}
+		case strings.Contains(name, "inflight_limit") && strings.HasSuffix(name, ","+ReadOnly):
+			if v != 4 {
+				t.Errorf("%s = %v, want 4", name, v)
+			}
+		}
+	}
+}
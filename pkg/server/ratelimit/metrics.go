@@ -0,0 +1,97 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package ratelimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ClientUser and ClientIP are the kinds of clients of rate limits.
+	ClientUser = "user"
+	ClientIP   = "ip"
+
+	// ReadOnly and Mutating are the kinds of requests of in-flight limits.
+	ReadOnly = "readonly"
+	Mutating = "mutating"
+)
+
+var _ prometheus.Collector = &Metrics{}
+
+// Metrics exports the state of the limiters and the requests they rejected.
+type Metrics struct {
+	limiter  *Limiter
+	inFlight map[string]*InFlight
+
+	rateLimited      *prometheus.CounterVec
+	inFlightRejected *prometheus.CounterVec
+	clients          *prometheus.Desc
+	inFlightRequests *prometheus.Desc
+	inFlightLimit    *prometheus.Desc
+}
+
+// NewMetrics returns the metrics of the limiter and of the in-flight limits.
+func NewMetrics(limiter *Limiter, readOnly, mutating *InFlight) *Metrics {
+	return &Metrics{
+		limiter:  limiter,
+		inFlight: map[string]*InFlight{ReadOnly: readOnly, Mutating: mutating},
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "peta",
+			Name:      "ratelimit_rejected_requests_total",
+			Help:      "Requests rejected by the rate limit of their client, partitioned by API group and kind of client.",
+		}, []string{"group", "client"}),
+		inFlightRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "peta",
+			Name:      "inflight_rejected_requests_total",
+			Help:      "Requests rejected by the max-in-flight limit, partitioned by kind of request.",
+		}, []string{"kind"}),
+		clients: prometheus.NewDesc("peta_ratelimit_clients",
+			"Clients with a rate limit bucket, those which sent requests recently.", nil, nil),
+		inFlightRequests: prometheus.NewDesc("peta_inflight_requests",
+			"Requests being served, partitioned by kind of request.", []string{"kind"}, nil),
+		inFlightLimit: prometheus.NewDesc("peta_inflight_limit",
+			"Maximum requests served at once, 0 is unlimited.", []string{"kind"}, nil),
+	}
+}
+
+// RateLimited counts a request of the API group rejected by the rate limit of its client.
+func (m *Metrics) RateLimited(group, client string) {
+	m.rateLimited.WithLabelValues(group, client).Inc()
+}
+
+// InFlightRejected counts a request of the kind rejected by the in-flight limit.
+func (m *Metrics) InFlightRejected(kind string) {
+	m.inFlightRejected.WithLabelValues(kind).Inc()
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.rateLimited.Describe(ch)
+	m.inFlightRejected.Describe(ch)
+	ch <- m.clients
+	ch <- m.inFlightRequests
+	ch <- m.inFlightLimit
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.rateLimited.Collect(ch)
+	m.inFlightRejected.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(m.clients, prometheus.GaugeValue, float64(m.limiter.Clients()))
+	for kind, f := range m.inFlight {
+		ch <- prometheus.MustNewConstMetric(m.inFlightRequests, prometheus.GaugeValue, float64(f.Len()), kind)
+		ch <- prometheus.MustNewConstMetric(m.inFlightLimit, prometheus.GaugeValue, float64(f.Cap()), kind)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package ratelimit
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"peta.io/peta/pkg/utils/iputils"
+)
+
+const (
+	Enabled                     = "ratelimit-enabled"
+	QPS                         = "ratelimit-qps"
+	Burst                       = "ratelimit-burst"
+	MaxRequestsInFlight         = "max-requests-inflight"
+	MaxMutatingRequestsInFlight = "max-mutating-requests-inflight"
+)
+
+type Options struct {
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// QPS and Burst limit the requests of each user, or of each source ip for
+	// anonymous requests.
+	QPS   float64 `json:"qps,omitempty" yaml:"qps,omitempty" mapstructure:"qps"`
+	Burst int     `json:"burst,omitempty" yaml:"burst,omitempty" mapstructure:"burst"`
+	// Groups override QPS and Burst for the requests of API groups, each group
+	// has its own buckets.
+	Groups []GroupLimit `json:"groups,omitempty" yaml:"groups,omitempty" mapstructure:"groups"`
+	// MaxRequestsInFlight and MaxMutatingRequestsInFlight limit the read-only and
+	// mutating requests served at once, 0 is unlimited.
+	MaxRequestsInFlight         int `json:"maxRequestsInFlight,omitempty" yaml:"maxRequestsInFlight,omitempty" mapstructure:"maxRequestsInFlight"`
+	MaxMutatingRequestsInFlight int `json:"maxMutatingRequestsInFlight,omitempty" yaml:"maxMutatingRequestsInFlight,omitempty" mapstructure:"maxMutatingRequestsInFlight"`
+	// ExemptPaths are never limited, a path ending with * matches all paths with its prefix.
+	ExemptPaths []string `json:"exemptPaths,omitempty" yaml:"exemptPaths,omitempty" mapstructure:"exemptPaths"`
+	// TrustedProxies are the IP addresses or CIDRs of the proxies whose X-Forwarded-For
+	// and X-Real-IP headers are honored, anonymous requests are limited by the address
+	// of their connection otherwise.
+	TrustedProxies []string `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty" mapstructure:"trustedProxies"`
+}
+
+// GroupLimit is the limit of each client of the requests of an API group.
+type GroupLimit struct {
+	Group string  `json:"group" yaml:"group" mapstructure:"group"`
+	QPS   float64 `json:"qps" yaml:"qps" mapstructure:"qps"`
+	Burst int     `json:"burst" yaml:"burst" mapstructure:"burst"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		QPS:                         50,
+		Burst:                       100,
+		MaxRequestsInFlight:         400,
+		MaxMutatingRequestsInFlight: 200,
+		ExemptPaths:                 []string{"/healthz", "/livez", "/readyz"},
+	}
+}
+
+func (o *Options) Merge(fs *pflag.FlagSet, conf *Options) {
+	if f := fs.Lookup(Enabled); f != nil && !f.Changed {
+		o.Enable = conf.Enable
+	}
+	if f := fs.Lookup(QPS); f != nil && !f.Changed && conf.QPS > 0 {
+		o.QPS = conf.QPS
+	}
+	if f := fs.Lookup(Burst); f != nil && !f.Changed && conf.Burst > 0 {
+		o.Burst = conf.Burst
+	}
+	if f := fs.Lookup(MaxRequestsInFlight); f != nil && !f.Changed && conf.MaxRequestsInFlight > 0 {
+		o.MaxRequestsInFlight = conf.MaxRequestsInFlight
+	}
+	if f := fs.Lookup(MaxMutatingRequestsInFlight); f != nil && !f.Changed && conf.MaxMutatingRequestsInFlight > 0 {
+		o.MaxMutatingRequestsInFlight = conf.MaxMutatingRequestsInFlight
+	}
+	if len(conf.Groups) > 0 {
+		o.Groups = conf.Groups
+	}
+	if len(conf.ExemptPaths) > 0 {
+		o.ExemptPaths = conf.ExemptPaths
+	}
+	if len(conf.TrustedProxies) > 0 {
+		o.TrustedProxies = conf.TrustedProxies
+	}
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+	if !o.Enable {
+		return errs
+	}
+	if o.QPS <= 0 || o.Burst <= 0 {
+		errs = append(errs, fmt.Errorf("* %s and %s must be positive", QPS, Burst))
+	}
+	seen := map[string]bool{}
+	for _, g := range o.Groups {
+		if g.QPS <= 0 || g.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("* qps and burst of API group %q must be positive", g.Group))
+		}
+		if seen[g.Group] {
+			errs = append(errs, fmt.Errorf("* API group %q is limited twice", g.Group))
+		}
+		seen[g.Group] = true
+	}
+	if o.MaxRequestsInFlight < 0 || o.MaxMutatingRequestsInFlight < 0 {
+		errs = append(errs, fmt.Errorf("* %s and %s must not be negative", MaxRequestsInFlight, MaxMutatingRequestsInFlight))
+	}
+	if _, err := iputils.ParseNetworks(o.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("* trusted proxy %w", err))
+	}
+	return errs
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, Enabled, o.Enable, "enable rate limiting and max-in-flight protection of api requests or not")
+	fs.Float64Var(&o.QPS, QPS, o.QPS, "requests per second of each user, or of each source ip of anonymous requests")
+	fs.IntVar(&o.Burst, Burst, o.Burst, "requests each user, or source ip of anonymous requests, may send at once")
+	fs.IntVar(&o.MaxRequestsInFlight, MaxRequestsInFlight, o.MaxRequestsInFlight, "read-only requests served at once, 0 is unlimited")
+	fs.IntVar(&o.MaxMutatingRequestsInFlight, MaxMutatingRequestsInFlight, o.MaxMutatingRequestsInFlight, "mutating requests served at once, 0 is unlimited")
+}
@@ -239,6 +239,14 @@ func (ri *InfoFactory) NewRequestInfo(req *http.Request) (*Info, error) {
 		info.Verb = VerbList
 	}
 
+	// lists with ?watch=true are watches
+	if info.Verb == VerbList {
+		switch strings.ToLower(req.URL.Query().Get("watch")) {
+		case "true", "1":
+			info.Verb = VerbWatch
+		}
+	}
+
 	if len(info.Name) == 0 && info.Verb == VerbDelete {
 		info.Verb = "delete_collection"
 	}
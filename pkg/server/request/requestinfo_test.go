@@ -82,3 +82,37 @@ func TestRequestInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestInfoWatch(t *testing.T) {
+	tests := []struct {
+		url       string
+		verb      string
+		resource  string
+		namespace string
+	}{
+		{url: "/apis/iam.peta.io/v1alpha2/clusterroles", verb: VerbList, resource: "clusterroles"},
+		{url: "/apis/iam.peta.io/v1alpha2/clusterroles?watch=true", verb: VerbWatch, resource: "clusterroles"},
+		{url: "/apis/iam.peta.io/v1alpha2/clusterroles?watch=false", verb: VerbList, resource: "clusterroles"},
+		{url: "/apis/iam.peta.io/v1alpha2/watch/clusterroles", verb: VerbWatch, resource: "clusterroles"},
+		{url: "/apis/iam.peta.io/v1alpha2/watch/namespaces/dev/roles", verb: VerbWatch, resource: "roles", namespace: "dev"},
+		{url: "/apis/iam.peta.io/v1alpha2/namespaces/dev/roles?watch=1", verb: VerbWatch, resource: "roles", namespace: "dev"},
+		{url: "/apis/iam.peta.io/v1alpha2/namespaces/dev/roles/viewer?watch=true", verb: VerbGet, resource: "roles", namespace: "dev"},
+	}
+
+	resolver := newTestRequestInfoResolver()
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := resolver.NewRequestInfo(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Verb != tt.verb || info.Resource != tt.resource || info.Namespace != tt.namespace {
+				t.Errorf("got verb %q resource %q namespace %q", info.Verb, info.Resource, info.Namespace)
+			}
+		})
+	}
+}
@@ -20,31 +20,53 @@ package server
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	rt "runtime"
+	"strconv"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
 	"peta.io/peta/pkg/apis"
+	blueprintv1alpha2 "peta.io/peta/pkg/apis/blueprint/v1alpha2"
+	clusterv1alpha2 "peta.io/peta/pkg/apis/cluster/v1alpha2"
 	configv1alpha2 "peta.io/peta/pkg/apis/config/v1alpha2"
 	healthzhandler "peta.io/peta/pkg/apis/healthz"
+	hostv1alpha2 "peta.io/peta/pkg/apis/host/v1alpha2"
 	iamv1alpha2 "peta.io/peta/pkg/apis/iam/v1alpha2"
+	"peta.io/peta/pkg/apis/rest"
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
 	versionhandler "peta.io/peta/pkg/apis/version"
 	"peta.io/peta/pkg/log"
 	"peta.io/peta/pkg/persistence"
 	urlruntime "peta.io/peta/pkg/runtime"
+	"peta.io/peta/pkg/server/auditing"
+	"peta.io/peta/pkg/server/authentication"
+	"peta.io/peta/pkg/server/authorization"
+	"peta.io/peta/pkg/server/authorization/rbac"
+	"peta.io/peta/pkg/server/certs"
 	"peta.io/peta/pkg/server/filters"
 	"peta.io/peta/pkg/server/metrics"
+	"peta.io/peta/pkg/server/multicluster"
 	"peta.io/peta/pkg/server/options"
+	"peta.io/peta/pkg/server/ratelimit"
 	"peta.io/peta/pkg/server/request"
+	"peta.io/peta/pkg/server/tenancy"
+	"peta.io/peta/pkg/transcript"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/utils/iputils"
 	"peta.io/peta/pkg/utils/sets"
 	"peta.io/peta/pkg/version"
 )
 
 // APIServer is PETA server
 type APIServer struct {
-	Server *http.Server
+	// InsecureServer and SecureServer listen on the bind address, each is nil if
+	// its port is 0.
+	InsecureServer *http.Server
+	SecureServer   *http.Server
 
 	*options.APIServerOptions
 
@@ -53,32 +75,44 @@ type APIServer struct {
 	Storage persistence.Storage
 
 	VersionInfo *version.Info
+
+	authorizer authorization.Authorizer
+
+	auditor *auditing.Auditor
+
+	certificates *certs.Reloader
+
+	clusters *multicluster.Proxy
+
+	tenancy *tenancy.Tenancy
+
+	blueprints *rest.Store[types.Blueprint, *types.Blueprint]
 }
 
 func NewAPIServer(ctx context.Context, o *options.APIServerOptions) (*APIServer, error) {
-	server := &http.Server{
-		Addr: fmt.Sprintf(":%d", o.InsecurePort),
+	apiServer := &APIServer{
+		VersionInfo:      version.Get(),
+		APIServerOptions: o,
 	}
 
+	if o.InsecurePort != 0 {
+		apiServer.InsecureServer = &http.Server{
+			Addr: net.JoinHostPort(o.BindAddress, strconv.Itoa(o.InsecurePort)),
+		}
+	}
+
+	var err error
 	if o.SecurePort != 0 {
-		certificate, err := tls.LoadX509KeyPair(o.TLSCertFile, o.TLSPrivateKey)
+		apiServer.certificates, err = certs.NewReloader(o.TLSCertFile, o.TLSPrivateKey, o.AuthenticationOptions.ClientCAFile)
 		if err != nil {
 			return nil, err
 		}
-		server.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{certificate},
+		apiServer.SecureServer = &http.Server{
+			Addr:      net.JoinHostPort(o.BindAddress, strconv.Itoa(o.SecurePort)),
+			TLSConfig: apiServer.certificates.TLSConfig(),
 		}
-		server.Addr = fmt.Sprintf(":%d", o.SecurePort)
 	}
 
-	apiServer := &APIServer{
-		Server:           server,
-		VersionInfo:      version.Get(),
-		APIServerOptions: o,
-	}
-
-	var err error
-
 	if apiServer.Storage, err = persistence.New(ctx, o.DatabaseOptions); err != nil {
 		return nil, fmt.Errorf("unable to initialize storage: %w", err)
 	}
@@ -90,9 +124,39 @@ func (s *APIServer) PreRun() error {
 
 	s.container = restful.NewContainer()
 	s.container.Router(restful.CurlyRouter{})
+	// recover from the panics of handlers with an internal error, go-restful does not by default
+	s.container.DoNotRecover(false)
 	s.container.RecoverHandler(func(panicReason interface{}, httpWriter http.ResponseWriter) {
 		logStackOnRecover(panicReason, httpWriter)
 	})
+	s.container.ServiceErrorHandler(func(serviceError restful.ServiceError, req *restful.Request, resp *restful.Response) {
+		apis.HandleRestError(resp, req, serviceError)
+	})
+
+	roles := rbac.NewStore(s.Storage)
+	s.tenancy = tenancy.New(tenantv1alpha2.NewWorkspaceStore(s.Storage), tenantv1alpha2.NewNamespaceStore(s.Storage), roles)
+	// the namespaced resources are admitted in existing namespaces, and deleted with them
+	s.blueprints = blueprintv1alpha2.NewStore(s.Storage).WithAdmission(s.tenancy.AdmitNamespaced)
+	s.tenancy.AddCollection(s.blueprints)
+	// the transcripts are recorded by exec in the namespaces of existing blueprints
+	s.tenancy.AddCollection(transcript.NewStore(s.Storage))
+
+	if s.AuthorizationOptions.Enable {
+		s.authorizer = authorization.New(s.AuthorizationOptions, tenancy.NewAuthorizer(s.tenancy, rbac.New(roles)))
+	} else {
+		s.authorizer = authorization.NewAlwaysAllowAuthorizer()
+	}
+
+	if s.AuditingOptions.Enable {
+		var err error
+		if s.auditor, err = auditing.New(s.AuditingOptions, s.Storage); err != nil {
+			return fmt.Errorf("failed to create auditor: %w", err)
+		}
+	}
+
+	if s.MultiClusterOptions.Enable {
+		s.clusters = multicluster.NewProxy(clusterv1alpha2.NewStore(s.Storage))
+	}
 
 	// install APIs
 	s.installPETAAPIs()
@@ -112,36 +176,75 @@ func (s *APIServer) PreRun() error {
 		return fmt.Errorf("failed to build handler chain: %w", err)
 	}
 
-	s.Server.Handler = combinedHandler
+	for _, server := range s.servers() {
+		server.Handler = combinedHandler
+	}
 
 	return nil
 }
 
-func (s *APIServer) Run(ctx context.Context) (err error) {
+func (s *APIServer) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	if s.auditor != nil {
+		s.auditor.Start()
+	}
+
+	if s.certificates != nil {
+		go s.certificates.Run(ctx)
+	}
+
+	go s.tenancy.Run(ctx)
+
+	if s.clusters != nil {
+		go multicluster.NewProber(s.clusters, s.MultiClusterOptions).Run(ctx)
+	}
+
 	go func() {
 		<-ctx.Done()
+		if s.auditor != nil {
+			log.Infof("Audit events flushing...")
+			s.auditor.Shutdown()
+		}
 		log.Infof("Database connections closing...")
 		if err := s.Storage.Close(); err != nil {
 			log.Errorf("failed to close database connections: %v", err)
 		}
 		log.Infof("Server shutting down...")
-		if err := s.Server.Shutdown(ctx); err != nil {
-			log.Errorf("failed to shutdown server: %v", err)
+		for _, server := range s.servers() {
+			if err := server.Shutdown(ctx); err != nil {
+				log.Errorf("failed to shutdown server on %s: %v", server.Addr, err)
+			}
 		}
 	}()
 
-	log.Infof("Start listening on %s", s.Server.Addr)
-	if s.Server.TLSConfig != nil {
-		// TLSConfig not nil, no need to pass certFile & keyFile.
-		err = s.Server.ListenAndServeTLS("", "")
-	} else {
-		err = s.Server.ListenAndServe()
+	// the first listener to stop stops the others, the error is the one of its stop
+	errs := make(chan error, 2)
+	for _, server := range s.servers() {
+		go func() {
+			log.Infof("Start listening on %s", server.Addr)
+			if server.TLSConfig != nil {
+				// the certificates are of TLSConfig, no need to pass certFile & keyFile.
+				errs <- server.ListenAndServeTLS("", "")
+			} else {
+				errs <- server.ListenAndServe()
+			}
+		}()
 	}
 
-	return err
+	return <-errs
+}
+
+// servers returns the servers of the ports not 0.
+func (s *APIServer) servers() []*http.Server {
+	var servers []*http.Server
+	for _, server := range []*http.Server{s.InsecureServer, s.SecureServer} {
+		if server != nil {
+			servers = append(servers, server)
+		}
+	}
+	return servers
 }
 
 func logStackOnRecover(panicReason interface{}, w http.ResponseWriter) {
@@ -156,20 +259,54 @@ func logStackOnRecover(panicReason interface{}, w http.ResponseWriter) {
 	}
 	log.Errorln(buffer.String())
 
-	headers := http.Header{}
-	if ct := w.Header().Get("Content-Type"); len(ct) > 0 {
-		headers.Set("Accept", ct)
-	}
-
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	apis.WriteStatus(w, apis.NewInternalError(errors.New("the server panicked handling the request")).Status())
 }
 
 func (s *APIServer) buildHandlerChain(handler http.Handler) (http.Handler, error) {
 	requestInfoResolver := &request.InfoFactory{APIPrefixes: sets.New("apis")}
 
-	// TODO: Auditing
-	// TODO: Authorization
-	// TODO: Authentication
+	if s.clusters != nil {
+		handler = filters.WithMultiCluster(handler, s.clusters)
+	}
+
+	if s.AuthorizationOptions.Enable {
+		handler = filters.WithAuthorization(handler, s.authorizer)
+	}
+
+	if s.auditor != nil {
+		handler = filters.WithAuditing(handler, s.auditor)
+	}
+
+	var limiter *ratelimit.Limiter
+	var readOnly, mutating *ratelimit.InFlight
+	var limiterMetrics *ratelimit.Metrics
+	if o := s.RateLimitOptions; o.Enable {
+		limiter = ratelimit.NewLimiter(o)
+		readOnly, mutating = ratelimit.NewInFlight(o.MaxRequestsInFlight), ratelimit.NewInFlight(o.MaxMutatingRequestsInFlight)
+		limiterMetrics = ratelimit.NewMetrics(limiter, readOnly, mutating)
+		if s.MetricsOptions.Enable {
+			if err := prometheus.Register(limiterMetrics); err != nil {
+				return nil, fmt.Errorf("failed to register rate limit metrics: %w", err)
+			}
+		}
+		trustedProxies, err := iputils.ParseNetworks(o.TrustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		handler = filters.WithRateLimit(handler, limiter, limiterMetrics, o.ExemptPaths, trustedProxies)
+	}
+
+	if s.AuthenticationOptions.Enable {
+		authenticator, err := authentication.New(s.AuthenticationOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create authenticator: %w", err)
+		}
+		handler = filters.WithAuthentication(handler, authenticator, s.AuthenticationOptions.AllowedPaths)
+	}
+
+	if s.RateLimitOptions.Enable {
+		handler = filters.WithMaxInFlight(handler, readOnly, mutating, limiterMetrics, s.RateLimitOptions.ExemptPaths)
+	}
 
 	handler = filters.WithRequestInfo(handler, requestInfoResolver)
 	if s.MetricsOptions.Enable {
@@ -179,10 +316,21 @@ func (s *APIServer) buildHandlerChain(handler http.Handler) (http.Handler, error
 }
 
 func (s *APIServer) installPETAAPIs() {
+	// commands are run on the hosts for the authenticated and authorized clients only
+	exec := s.AuthenticationOptions.Enable && s.AuthorizationOptions.Enable
+	if !exec {
+		log.Warnf("the exec route of hosts is not installed, it requires authentication and authorization to be enabled")
+	}
 	handlers := []apis.Handler{
 		versionhandler.NewHandler(s.VersionInfo),
 		configv1alpha2.NewHandler(s.APIServerOptions),
-		iamv1alpha2.NewHandler(s.Storage),
+		iamv1alpha2.NewHandler(s.Storage, s.authorizer, s.tenancy.Roles()),
+		hostv1alpha2.NewHandler(s.Storage, s.blueprints, exec),
+		tenantv1alpha2.NewHandler(s.tenancy.Workspaces(), s.tenancy.Namespaces()),
+		blueprintv1alpha2.NewHandler(s.blueprints),
+	}
+	if s.clusters != nil {
+		handlers = append(handlers, clusterv1alpha2.NewHandler(s.clusters.Store()))
 	}
 
 	for _, handler := range handlers {
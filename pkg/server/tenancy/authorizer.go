@@ -0,0 +1,96 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package tenancy
+
+import (
+	"context"
+	"fmt"
+
+	"peta.io/peta/pkg/apis"
+	iamv1alpha2 "peta.io/peta/pkg/apis/iam/v1alpha2"
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
+	"peta.io/peta/pkg/server/authorization"
+	"peta.io/peta/pkg/server/request"
+)
+
+type authorizer struct {
+	tenancy *Tenancy
+	next    authorization.Authorizer
+}
+
+// NewAuthorizer returns the authorizer allowing the members of workspaces what
+// their role grants in their workspace, it asks next about the other requests.
+// The requests of a namespace are in the workspace of the namespace, so the roles
+// bound in the workspace apply to them too, and the ones naming another workspace
+// are denied.
+func NewAuthorizer(t *Tenancy, next authorization.Authorizer) authorization.Authorizer {
+	return &authorizer{tenancy: t, next: next}
+}
+
+func (a *authorizer) Authorize(ctx context.Context, attributes authorization.Attributes) (authorization.Decision, string, error) {
+	// member clusters have their own tenants
+	if !attributes.IsResourceRequest || attributes.Cluster != "" {
+		return a.next.Authorize(ctx, attributes)
+	}
+
+	if attributes.Namespace != "" {
+		n, err := a.tenancy.namespaces.Get(ctx, "", attributes.Namespace)
+		switch {
+		case apis.IsNotFound(err):
+			// the objects of missing namespaces are not found, or not admitted
+		case err != nil:
+			return authorization.DecisionNoOpinion, "", err
+		case attributes.Workspace != "" && attributes.Workspace != n.Spec.Workspace:
+			return authorization.DecisionDeny, fmt.Sprintf("namespace %q is not in workspace %q", n.Name, attributes.Workspace), nil
+		default:
+			attributes.Workspace = n.Spec.Workspace
+		}
+	}
+
+	if attributes.User != nil && attributes.Workspace != "" {
+		w, err := a.tenancy.workspaces.Get(ctx, "", attributes.Workspace)
+		if err != nil && !apis.IsNotFound(err) {
+			return authorization.DecisionNoOpinion, "", err
+		}
+		if err == nil {
+			if role, ok := w.RoleOf(attributes.User); ok && roleAllows(role, attributes) {
+				return authorization.DecisionAllow, fmt.Sprintf("allowed as %s of workspace %q", role, w.Name), nil
+			}
+		}
+	}
+	return a.next.Authorize(ctx, attributes)
+}
+
+// roleAllows returns true if the role grants the request in the workspace. Admins
+// may do anything but delete the workspace, members may change the objects of
+// the namespaces but not the namespaces or the roles in them, and viewers read.
+func roleAllows(role tenantv1alpha2.WorkspaceRole, a authorization.Attributes) bool {
+	switch a.Verb {
+	case request.VerbGet, request.VerbList, request.VerbWatch:
+		return true
+	}
+	switch role {
+	case tenantv1alpha2.WorkspaceAdmin:
+		deletesWorkspace := a.APIGroup == tenantv1alpha2.GroupName && a.Resource == "workspaces" &&
+			(a.Verb == request.VerbDelete || a.Verb == "delete_collection")
+		return !deletesWorkspace
+	case tenantv1alpha2.WorkspaceMember:
+		return a.Namespace != "" && a.APIGroup != iamv1alpha2.GroupName &&
+			!(a.APIGroup == tenantv1alpha2.GroupName && a.Resource == "namespaces")
+	}
+	return false
+}
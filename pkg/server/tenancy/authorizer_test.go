@@ -0,0 +1,56 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package tenancy
+
+import (
+	"testing"
+
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
+	"peta.io/peta/pkg/server/authorization"
+)
+
+func TestRoleAllows(t *testing.T) {
+	var (
+		getBlueprint    = authorization.Attributes{Verb: "get", APIGroup: "blueprint.peta.io", Resource: "blueprints", Namespace: "dev", Workspace: "team-a"}
+		createBlueprint = authorization.Attributes{Verb: "create", APIGroup: "blueprint.peta.io", Resource: "blueprints", Namespace: "dev", Workspace: "team-a"}
+		deleteNamespace = authorization.Attributes{Verb: "delete", APIGroup: "tenant.peta.io", Resource: "namespaces", Name: "dev", Namespace: "dev", Workspace: "team-a"}
+		createBinding   = authorization.Attributes{Verb: "create", APIGroup: "iam.peta.io", Resource: "rolebindings", Namespace: "dev", Workspace: "team-a"}
+		updateWorkspace = authorization.Attributes{Verb: "update", APIGroup: "tenant.peta.io", Resource: "workspaces", Name: "team-a", Workspace: "team-a"}
+		deleteWorkspace = authorization.Attributes{Verb: "delete", APIGroup: "tenant.peta.io", Resource: "workspaces", Name: "team-a", Workspace: "team-a"}
+	)
+	tests := []struct {
+		role       tenantv1alpha2.WorkspaceRole
+		attributes authorization.Attributes
+		want       bool
+	}{
+		{role: tenantv1alpha2.WorkspaceViewer, attributes: getBlueprint, want: true},
+		{role: tenantv1alpha2.WorkspaceViewer, attributes: createBlueprint},
+		{role: tenantv1alpha2.WorkspaceMember, attributes: createBlueprint, want: true},
+		{role: tenantv1alpha2.WorkspaceMember, attributes: deleteNamespace},
+		{role: tenantv1alpha2.WorkspaceMember, attributes: createBinding},
+		{role: tenantv1alpha2.WorkspaceMember, attributes: updateWorkspace},
+		{role: tenantv1alpha2.WorkspaceAdmin, attributes: deleteNamespace, want: true},
+		{role: tenantv1alpha2.WorkspaceAdmin, attributes: createBinding, want: true},
+		{role: tenantv1alpha2.WorkspaceAdmin, attributes: updateWorkspace, want: true},
+		{role: tenantv1alpha2.WorkspaceAdmin, attributes: deleteWorkspace},
+	}
+	for _, tt := range tests {
+		if got := roleAllows(tt.role, tt.attributes); got != tt.want {
+			t.Errorf("roleAllows(%s, %s %s/%s) = %v, want %v", tt.role, tt.attributes.Verb, tt.attributes.APIGroup, tt.attributes.Resource, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/rest"
+	"peta.io/peta/pkg/log"
+	"peta.io/peta/pkg/server/authorization/rbac"
+	"peta.io/peta/pkg/watch"
+)
+
+// sweepInterval is how often what is left of deleted workspaces and namespaces is
+// looked for, besides when they are deleted.
+const sweepInterval = 5 * time.Minute
+
+// Run deletes what is left of deleted workspaces and namespaces until ctx is done:
+// the namespaces of the workspaces, the objects of the namespaces, and the roles
+// and role bindings of both. They are swept as soon as they are deleted, they can't
+// be created again before.
+func (t *Tenancy) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	var workspaces, namespaces watch.Interface
+	defer func() {
+		for _, w := range []watch.Interface{workspaces, namespaces} {
+			if w != nil {
+				w.Stop()
+			}
+		}
+	}()
+
+	for {
+		if workspaces == nil {
+			workspaces = watchDeletions(ctx, t.workspaces)
+		}
+		if namespaces == nil {
+			namespaces = watchDeletions(ctx, t.namespaces)
+		}
+		if err := t.Sweep(ctx); err != nil {
+			log.Errorf("failed to delete what is left of deleted workspaces and namespaces: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-resultChan(workspaces):
+			// the roles of the workspace are swept with its namespaces
+			if !ok {
+				workspaces = nil
+			}
+		case _, ok := <-resultChan(namespaces):
+			// the roles of the namespace are swept with its objects
+			if !ok {
+				namespaces = nil
+			}
+		}
+	}
+}
+
+// Sweep deletes the namespaces, roles and role bindings of missing workspaces, and the
+// objects, roles and role bindings of missing namespaces. The objects and roles are
+// listed before the namespaces and workspaces, so the ones of the namespaces and
+// workspaces created meanwhile are kept.
+func (t *Tenancy) Sweep(ctx context.Context) error {
+	roles, err := t.roles.ListWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	used := make([][]string, len(t.collections))
+	for i, c := range t.collections {
+		namespaces, err := c.ListNamespaces(ctx)
+		if err != nil {
+			return err
+		}
+		used[i] = namespaces
+	}
+	namespaces, err := t.namespaces.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	workspaces, err := t.workspaces.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(workspaces))
+	for _, w := range workspaces {
+		existing[w.Name] = true
+	}
+	var errs []error
+	kept := make(map[string]bool, len(namespaces))
+	for _, n := range namespaces {
+		if existing[n.Spec.Workspace] {
+			kept[n.Name] = true
+			continue
+		}
+		if _, err := t.namespaces.Delete(ctx, "", n.Name); err != nil && !apis.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		log.Infof("deleted namespace %q of the deleted workspace %q", n.Name, n.Spec.Workspace)
+	}
+
+	for _, workspace := range roles {
+		if existing[workspace] {
+			continue
+		}
+		if err := t.roles.DeleteWithin(ctx, rbac.Location{Workspace: workspace}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Infof("deleted the roles and role bindings of the deleted workspace %q", workspace)
+	}
+
+	for i, c := range t.collections {
+		for _, namespace := range used[i] {
+			if kept[namespace] {
+				continue
+			}
+			deleted, err := c.DeleteNamespace(ctx, namespace)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if deleted > 0 {
+				log.Infof("deleted %d %s of the deleted namespace %q", deleted, c.Resource(), namespace)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchDeletions watches the deletions of the objects of store, it returns nil if
+// the watch can't start.
+func watchDeletions[T any, PT interface {
+	*T
+	rest.Object
+}](ctx context.Context, store *rest.Store[T, PT]) watch.Interface {
+	b, err := store.Broadcaster(ctx)
+	if err != nil {
+		log.Errorf("failed to watch the deletions of %s: %v", store.Resource(), err)
+		return nil
+	}
+	w, err := b.Watch("", func(e watch.Event) bool { return e.Type == watch.Deleted })
+	if err != nil {
+		log.Errorf("failed to watch the deletions of %s: %v", store.Resource(), err)
+		return nil
+	}
+	return w
+}
+
+// resultChan returns the events of w, a nil channel that never receives if w is nil.
+func resultChan(w watch.Interface) <-chan watch.Event {
+	if w == nil {
+		return nil
+	}
+	return w.ResultChan()
+}
@@ -0,0 +1,167 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package tenancy isolates the teams of workspaces from each other: it admits the
+// namespaces of existing workspaces and the objects of existing namespaces,
+// authorizes the members of workspaces, and deletes what is left of deleted
+// workspaces and namespaces.
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"peta.io/peta/pkg/apis"
+	"peta.io/peta/pkg/apis/rest"
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
+	"peta.io/peta/pkg/server/authorization/rbac"
+)
+
+// Collection is a store of namespaced objects, like the rest.Store of a namespaced
+// resource. Its objects are deleted with their namespace.
+type Collection interface {
+	Resource() string
+	// ListNamespaces returns the namespaces having objects.
+	ListNamespaces(ctx context.Context) ([]string, error)
+	// DeleteNamespace deletes the objects of the namespace.
+	DeleteNamespace(ctx context.Context, namespace string) (int, error)
+}
+
+// Tenancy keeps the workspaces and namespaces, and the collections of the objects
+// in the namespaces.
+type Tenancy struct {
+	workspaces  *rest.Store[tenantv1alpha2.Workspace, *tenantv1alpha2.Workspace]
+	namespaces  *rest.Store[tenantv1alpha2.Namespace, *tenantv1alpha2.Namespace]
+	roles       *rbac.Store
+	collections []Collection
+}
+
+// New returns the tenancy of the workspaces and namespaces of the stores, the
+// namespaces of missing workspaces are not admitted. The roles and role bindings
+// of the deleted workspaces and namespaces are deleted from roles.
+func New(workspaces *rest.Store[tenantv1alpha2.Workspace, *tenantv1alpha2.Workspace],
+	namespaces *rest.Store[tenantv1alpha2.Namespace, *tenantv1alpha2.Namespace], roles *rbac.Store) *Tenancy {
+	t := &Tenancy{workspaces: workspaces, namespaces: namespaces, roles: roles, collections: []Collection{roles}}
+	workspaces.WithAdmission(t.admitWorkspace)
+	namespaces.WithAdmission(t.admitNamespace)
+	return t
+}
+
+// Workspaces returns the store of the workspaces.
+func (t *Tenancy) Workspaces() *rest.Store[tenantv1alpha2.Workspace, *tenantv1alpha2.Workspace] {
+	return t.workspaces
+}
+
+// Namespaces returns the store of the namespaces.
+func (t *Tenancy) Namespaces() *rest.Store[tenantv1alpha2.Namespace, *tenantv1alpha2.Namespace] {
+	return t.namespaces
+}
+
+// Roles returns the store of the roles and role bindings.
+func (t *Tenancy) Roles() *rbac.Store {
+	return t.roles
+}
+
+// AddCollection deletes the objects of c with their namespace, the store of c
+// should admit its objects with AdmitNamespaced too.
+func (t *Tenancy) AddCollection(c Collection) {
+	t.collections = append(t.collections, c)
+}
+
+// AdmitNamespaced is the rest.AdmitFunc of the stores of namespaced objects, it
+// admits the objects of existing namespaces only.
+func (t *Tenancy) AdmitNamespaced(ctx context.Context, obj, old rest.Object) error {
+	if old != nil {
+		// objects don't change their namespace
+		return nil
+	}
+	_, err := t.namespaces.Get(ctx, "", obj.GetObjectMeta().Namespace)
+	return err
+}
+
+// admitWorkspace admits the workspaces once what is left of a deleted workspace with
+// their name is deleted, so they can't inherit its namespaces, roles and role bindings.
+func (t *Tenancy) admitWorkspace(ctx context.Context, obj, old rest.Object) error {
+	if old != nil {
+		return nil
+	}
+	namespaces, err := t.namespaces.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	roles, err := t.roles.ListWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	return checkWorkspaceDeleted(obj.GetObjectMeta().Name, namespaces, roles)
+}
+
+// checkWorkspaceDeleted refuses the workspace while the namespaces or the roles of a
+// deleted workspace with its name are left, roles are the workspaces having roles.
+func checkWorkspaceDeleted(name string, namespaces []*tenantv1alpha2.Namespace, roles []string) error {
+	for _, n := range namespaces {
+		if n.Spec.Workspace == name {
+			return apis.NewConflict("workspaces", name,
+				errors.New("the namespaces of the deleted workspace are being deleted, try again later"))
+		}
+	}
+	if slices.Contains(roles, name) {
+		return apis.NewConflict("workspaces", name,
+			errors.New("the roles and role bindings of the deleted workspace are being deleted, try again later"))
+	}
+	return nil
+}
+
+// admitNamespace admits the namespaces of existing workspaces, and keeps the
+// workspace of namespaces, so they can't be moved into the workspaces of others.
+// Deleted namespaces are created again once what is left of them is deleted.
+func (t *Tenancy) admitNamespace(ctx context.Context, obj, old rest.Object) error {
+	n := obj.(*tenantv1alpha2.Namespace)
+	if old != nil {
+		if workspace := old.(*tenantv1alpha2.Namespace).Spec.Workspace; n.Spec.Workspace != workspace {
+			return apis.NewInvalid("namespaces", n.Name, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid,
+				Field: "spec.workspace", Message: fmt.Sprintf("can't be changed from %q", workspace)})
+		}
+		return nil
+	}
+	if _, err := t.workspaces.Get(ctx, "", n.Spec.Workspace); err != nil {
+		if apis.IsNotFound(err) {
+			return apis.NewInvalid("namespaces", n.Name, apis.StatusCause{Type: apis.CauseTypeFieldValueInvalid,
+				Field: "spec.workspace", Message: fmt.Sprintf("workspace %q not found", n.Spec.Workspace)})
+		}
+		return err
+	}
+	return t.checkDeleted(ctx, n.Name)
+}
+
+// checkDeleted refuses the namespace while the objects or the roles of a deleted
+// namespace with its name are left, so it can't inherit them, like when it is
+// created in the workspace of others.
+func (t *Tenancy) checkDeleted(ctx context.Context, name string) error {
+	for _, c := range t.collections {
+		namespaces, err := c.ListNamespaces(ctx)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(namespaces, name) {
+			return apis.NewConflict("namespaces", name,
+				fmt.Errorf("the %s of the deleted namespace are being deleted, try again later", c.Resource()))
+		}
+	}
+	return nil
+}
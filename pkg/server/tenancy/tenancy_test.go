@@ -0,0 +1,66 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2024 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package tenancy
+
+import (
+	"context"
+	"testing"
+
+	"peta.io/peta/pkg/apis"
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
+)
+
+type fakeCollection struct {
+	resource   string
+	namespaces []string
+}
+
+func (c fakeCollection) Resource() string { return c.resource }
+
+func (c fakeCollection) ListNamespaces(context.Context) ([]string, error) { return c.namespaces, nil }
+
+func (c fakeCollection) DeleteNamespace(context.Context, string) (int, error) { return 0, nil }
+
+func TestCheckDeleted(t *testing.T) {
+	tenancy := &Tenancy{collections: []Collection{
+		fakeCollection{resource: "roles and role bindings", namespaces: []string{"dev"}},
+		fakeCollection{resource: "blueprints", namespaces: []string{"dev", "prod"}},
+	}}
+	for _, name := range []string{"dev", "prod"} {
+		if err := tenancy.checkDeleted(context.Background(), name); !apis.IsConflict(err) {
+			t.Errorf("checkDeleted(%q) = %v, want a conflict", name, err)
+		}
+	}
+	if err := tenancy.checkDeleted(context.Background(), "test"); err != nil {
+		t.Errorf("checkDeleted(test) = %v, want nil", err)
+	}
+}
+
+func TestCheckWorkspaceDeleted(t *testing.T) {
+	namespace := &tenantv1alpha2.Namespace{}
+	namespace.Name, namespace.Spec.Workspace = "dev", "team-a"
+	namespaces, roles := []*tenantv1alpha2.Namespace{namespace}, []string{"team-b"}
+
+	for _, name := range []string{"team-a", "team-b"} {
+		if err := checkWorkspaceDeleted(name, namespaces, roles); !apis.IsConflict(err) {
+			t.Errorf("checkWorkspaceDeleted(%q) = %v, want a conflict", name, err)
+		}
+	}
+	if err := checkWorkspaceDeleted("team-c", namespaces, roles); err != nil {
+		t.Errorf("checkWorkspaceDeleted(team-c) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package transcript
+
+import (
+	"context"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"peta.io/peta/pkg/persistence"
+)
+
+// DefaultListLimit is the number of transcripts returned when no limit is given.
+const DefaultListLimit = 100
+
+var _ Recorder = &Store{}
+
+// Store keeps transcripts in the database.
+type Store struct {
+	persister persistence.Persister
+}
+
+// ListOptions filters the listed transcripts, empty fields match everything.
+type ListOptions struct {
+	Namespace   string
+	Host        string
+	OperationID string
+	Since       time.Time
+	Limit       int
+	// Ascending lists the oldest transcripts first instead of the latest.
+	Ascending bool
+	// After lists the transcripts following the one of the cursor in the order of the
+	// list, for the next page of a list.
+	After *Cursor
+}
+
+// Cursor is the position of a transcript in the lists of transcripts, which are
+// ordered by start time and id.
+type Cursor struct {
+	StartedAt time.Time
+	ID        uuid.UUID
+}
+
+// NewStore returns a store of transcripts in the database of p.
+func NewStore(p persistence.Persister) *Store {
+	return &Store{persister: p}
+}
+
+// Record saves t.
+func (s *Store) Record(ctx context.Context, t *Transcript) error {
+	return s.persister.GetConnection().WithContext(ctx).Create(t)
+}
+
+// Get returns the transcript with the id.
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Transcript, error) {
+	t := &Transcript{}
+	if err := s.persister.GetConnection().WithContext(ctx).Find(t, id); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Resource returns the name of the transcripts in the API.
+func (s *Store) Resource() string {
+	return "transcripts"
+}
+
+// ListNamespaces returns the namespaces having transcripts.
+func (s *Store) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	err := s.persister.GetConnection().WithContext(ctx).
+		RawQuery("SELECT DISTINCT namespace FROM command_transcripts WHERE namespace <> '' ORDER BY namespace").All(&namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// DeleteNamespace deletes the transcripts of the namespace.
+func (s *Store) DeleteNamespace(ctx context.Context, namespace string) (int, error) {
+	return s.persister.GetConnection().WithContext(ctx).
+		RawQuery("DELETE FROM command_transcripts WHERE namespace = ?", namespace).ExecWithCount()
+}
+
+// List returns the latest transcripts matching o, the output is left out.
+func (s *Store) List(ctx context.Context, o ListOptions) ([]Transcript, error) {
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+
+	order := "started_at desc, id desc"
+	if o.Ascending {
+		order = "started_at asc, id asc"
+	}
+	q := s.where(ctx, o).
+		Select("id", "operation_id", "namespace", "host", "address", "username", "become_user", "command",
+			"started_at", "finished_at", "exit_code", "truncated", "error", "created_at", "updated_at")
+
+	var transcripts []Transcript
+	if err := q.Order(order).Limit(o.Limit).All(&transcripts); err != nil {
+		return nil, err
+	}
+	return transcripts, nil
+}
+
+// Count returns the number of transcripts matching o, regardless of its limit.
+func (s *Store) Count(ctx context.Context, o ListOptions) (int, error) {
+	return s.where(ctx, o).Count(&Transcript{})
+}
+
+func (s *Store) where(ctx context.Context, o ListOptions) *pop.Query {
+	q := s.persister.GetConnection().WithContext(ctx).Q()
+	if o.Namespace != "" {
+		q = q.Where("namespace = ?", o.Namespace)
+	}
+	if o.Host != "" {
+		q = q.Where("host = ?", o.Host)
+	}
+	if o.OperationID != "" {
+		q = q.Where("operation_id = ?", o.OperationID)
+	}
+	if !o.Since.IsZero() {
+		q = q.Where("started_at >= ?", o.Since)
+	}
+	if o.After != nil {
+		next := "<"
+		if o.Ascending {
+			next = ">"
+		}
+		q = q.Where("(started_at "+next+" ? OR (started_at = ? AND id "+next+" ?))", o.After.StartedAt, o.After.StartedAt, o.After.ID)
+	}
+	return q
+}
@@ -0,0 +1,101 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package transcript records the commands run on managed hosts for audit.
+package transcript
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// MaxOutputSize is the number of bytes of output kept in a transcript, the
+// beginning of longer outputs is dropped since failures are usually reported last.
+const MaxOutputSize = 64 * 1024
+
+// Transcript is the record of a command run on a host.
+type Transcript struct {
+	ID uuid.UUID `db:"id" json:"id"`
+	// OperationID links the commands run by the same operation, like a blueprint apply.
+	OperationID string `db:"operation_id" json:"operationId,omitempty"`
+	// Namespace is the namespace of the blueprint of the host, empty for the commands
+	// run with the CLI.
+	Namespace string `db:"namespace" json:"namespace,omitempty"`
+	Host      string `db:"host" json:"host"`
+	Address   string `db:"address" json:"address"`
+	// User is the login user, BecomeUser the user the command ran as if escalated.
+	User       string    `db:"username" json:"user"`
+	BecomeUser string    `db:"become_user" json:"becomeUser,omitempty"`
+	Command    string    `db:"command" json:"command"`
+	StartedAt  time.Time `db:"started_at" json:"startedAt"`
+	FinishedAt time.Time `db:"finished_at" json:"finishedAt"`
+	// ExitCode is -1 if the command did not exit, like when the connection failed.
+	ExitCode  int       `db:"exit_code" json:"exitCode"`
+	Output    string    `db:"output" json:"output,omitempty"`
+	Truncated bool      `db:"truncated" json:"truncated,omitempty"`
+	Error     string    `db:"error" json:"error,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"-"`
+}
+
+// TableName overrides the table name used by pop.
+func (t Transcript) TableName() string {
+	return "command_transcripts"
+}
+
+// Duration returns how long the command took.
+func (t *Transcript) Duration() time.Duration {
+	return t.FinishedAt.Sub(t.StartedAt)
+}
+
+// Recorder saves transcripts.
+type Recorder interface {
+	Record(ctx context.Context, t *Transcript) error
+}
+
+type operationKey struct{}
+
+type recorderKey struct{}
+
+// NewOperationID returns a new unique operation id.
+func NewOperationID() string {
+	return uuid.Must(uuid.NewV4()).String()
+}
+
+// WithOperation returns a copy of ctx linking the commands run with it to the operation id.
+func WithOperation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, operationKey{}, id)
+}
+
+// OperationFrom returns the operation id of ctx, empty if there is none.
+func OperationFrom(ctx context.Context) string {
+	id, _ := ctx.Value(operationKey{}).(string)
+	return id
+}
+
+// WithRecorder returns a copy of ctx whose executors record every command with r.
+func WithRecorder(ctx context.Context, r Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// RecorderFrom returns the recorder of ctx, nil if commands are not recorded.
+func RecorderFrom(ctx context.Context) Recorder {
+	r, _ := ctx.Value(recorderKey{}).(Recorder)
+	return r
+}
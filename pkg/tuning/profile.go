@@ -0,0 +1,238 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package tuning
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+)
+
+// SysctlFile persists the sysctl values of the profiles.
+const SysctlFile = "/etc/sysctl.d/90-peta-tuning.conf"
+
+// Files persisting the settings of the profiles on the hosts, the sysctl file is
+// read before the one of peta init os.
+var (
+	sysctlFile    = SysctlFile
+	limitsFile    = "/etc/security/limits.d/90-peta-tuning.conf"
+	thpFile       = "/etc/tmpfiles.d/peta-tuning-thp.conf"
+	schedulerFile = "/etc/udev/rules.d/60-peta-tuning-scheduler.rules"
+	modulesFile   = "/etc/modules-load.d/peta-tuning.conf"
+)
+
+// managedFiles are removed from the hosts when no profile sets them.
+var managedFiles = []string{sysctlFile, limitsFile, thpFile, schedulerFile, modulesFile}
+
+// ManagedFiles returns the files the profiles may write on the hosts.
+func ManagedFiles() []string {
+	return append([]string(nil), managedFiles...)
+}
+
+// thpPath is the transparent hugepage setting of the kernel.
+const thpPath = "/sys/kernel/mm/transparent_hugepage/enabled"
+
+// diskPatterns are the disks the io scheduler is set on, as shell globs under
+// /sys/block and as the udev kernel match.
+var (
+	diskPatterns = []string{"sd*", "vd*", "xvd*", "nvme*n*"}
+	diskKernel   = "sd[a-z]*|vd[a-z]*|xvd[a-z]*|nvme[0-9]*n[0-9]*"
+)
+
+var (
+	nameRegexp  = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	limitRegexp = regexp.MustCompile(`^[@%*:a-zA-Z0-9_.-]+$`)
+	thpValues   = map[string]bool{"always": true, "madvise": true, "never": true}
+)
+
+// PostgresHost is the built-in profile of hosts dedicated to postgres.
+const PostgresHost = "postgres-host"
+
+var builtinProfiles = []types.TuningProfile{
+	{
+		Name: PostgresHost,
+		Sysctl: map[string]string{
+			"vm.swappiness":             "1",
+			"vm.overcommit_memory":      "2",
+			"vm.overcommit_ratio":       "90",
+			"vm.dirty_background_ratio": "5",
+			"vm.dirty_ratio":            "10",
+			"net.core.somaxconn":        "4096",
+			"fs.file-max":               "1048576",
+		},
+		Limits: []types.Limit{
+			{Domain: "postgres", Type: "-", Item: "nofile", Value: "65536"},
+			{Domain: "postgres", Type: "-", Item: "nproc", Value: "65536"},
+			{Domain: "postgres", Type: "-", Item: "memlock", Value: "unlimited"},
+		},
+		TransparentHugepage: "never",
+		IOScheduler:         "mq-deadline",
+	},
+}
+
+// Profiles returns the built-in profiles and those of the blueprint by name.
+func Profiles(b *types.Blueprint) map[string]types.TuningProfile {
+	profiles := map[string]types.TuningProfile{}
+	for _, p := range builtinProfiles {
+		profiles[p.Name] = p
+	}
+	if b.Spec.Tuning != nil {
+		for _, p := range b.Spec.Tuning.Profiles {
+			profiles[p.Name] = p
+		}
+	}
+	return profiles
+}
+
+// HostProfiles returns the names of the profiles the blueprint applies to h.
+func HostProfiles(b *types.Blueprint, h *component.Host) ([]string, error) {
+	if b.Spec.Tuning == nil {
+		return nil, nil
+	}
+	var names []string
+	for _, t := range b.Spec.Tuning.Apply {
+		selector, err := labels.Parse(t.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of tuning profiles %v: %w", t.Profiles, err)
+		}
+		if selector.Matches(h.Labels) {
+			names = append(names, t.Profiles...)
+		}
+	}
+	return names, nil
+}
+
+// Merge returns the settings of the named profiles, those of later profiles win.
+func Merge(profiles map[string]types.TuningProfile, names ...string) (*types.TuningProfile, error) {
+	merged := &types.TuningProfile{Name: strings.Join(names, ","), Sysctl: map[string]string{}}
+	limits := map[string]int{}
+	modules := map[string]bool{}
+	for _, name := range names {
+		p, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("tuning profile %q not found", name)
+		}
+		if err := validate(&p); err != nil {
+			return nil, err
+		}
+
+		for k, v := range p.Sysctl {
+			merged.Sysctl[k] = v
+		}
+		for _, l := range p.Limits {
+			if l.Type == "" {
+				l.Type = "-"
+			}
+			key := l.Domain + " " + l.Type + " " + l.Item
+			if i, ok := limits[key]; ok {
+				merged.Limits[i] = l
+				continue
+			}
+			limits[key] = len(merged.Limits)
+			merged.Limits = append(merged.Limits, l)
+		}
+		if p.TransparentHugepage != "" {
+			merged.TransparentHugepage = p.TransparentHugepage
+		}
+		if p.IOScheduler != "" {
+			merged.IOScheduler = p.IOScheduler
+		}
+		for _, m := range p.KernelModules {
+			if !modules[m] {
+				modules[m] = true
+				merged.KernelModules = append(merged.KernelModules, m)
+			}
+		}
+	}
+	return merged, nil
+}
+
+func validate(p *types.TuningProfile) error {
+	for k, v := range p.Sysctl {
+		if !nameRegexp.MatchString(k) || strings.ContainsAny(v, "\n") {
+			return fmt.Errorf("invalid sysctl %q of tuning profile %s", k, p.Name)
+		}
+	}
+	for _, l := range p.Limits {
+		for _, f := range []string{l.Domain, l.Item, l.Value} {
+			if !limitRegexp.MatchString(f) {
+				return fmt.Errorf("invalid limit %q of tuning profile %s", l.Domain+" "+l.Item+" "+l.Value, p.Name)
+			}
+		}
+		if l.Type != "" && l.Type != "soft" && l.Type != "hard" && l.Type != "-" {
+			return fmt.Errorf("invalid limit type %q of tuning profile %s", l.Type, p.Name)
+		}
+	}
+	if p.TransparentHugepage != "" && !thpValues[p.TransparentHugepage] {
+		return fmt.Errorf("invalid transparent hugepage %q of tuning profile %s", p.TransparentHugepage, p.Name)
+	}
+	if p.IOScheduler != "" && !nameRegexp.MatchString(p.IOScheduler) {
+		return fmt.Errorf("invalid io scheduler %q of tuning profile %s", p.IOScheduler, p.Name)
+	}
+	for _, m := range p.KernelModules {
+		if !nameRegexp.MatchString(m) {
+			return fmt.Errorf("invalid kernel module %q of tuning profile %s", m, p.Name)
+		}
+	}
+	return nil
+}
+
+// Files returns the content of the managed files persisting the settings of p,
+// those not in the result must not exist.
+func Files(p *types.TuningProfile) map[string]string {
+	header := "# Managed by peta, tuning profiles: " + p.Name + "\n"
+	files := map[string]string{}
+
+	if len(p.Sysctl) > 0 {
+		keys := make([]string, 0, len(p.Sysctl))
+		for k := range p.Sysctl {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString(header)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s = %s\n", k, p.Sysctl[k])
+		}
+		files[sysctlFile] = b.String()
+	}
+	if len(p.Limits) > 0 {
+		var b strings.Builder
+		b.WriteString(header)
+		for _, l := range p.Limits {
+			fmt.Fprintf(&b, "%s %s %s %s\n", l.Domain, l.Type, l.Item, l.Value)
+		}
+		files[limitsFile] = b.String()
+	}
+	if p.TransparentHugepage != "" {
+		files[thpFile] = fmt.Sprintf("%sw %s - - - - %s\n", header, thpPath, p.TransparentHugepage)
+	}
+	if p.IOScheduler != "" {
+		files[schedulerFile] = fmt.Sprintf("%sACTION==\"add|change\", KERNEL==\"%s\", ATTR{queue/scheduler}=\"%s\"\n",
+			header, diskKernel, p.IOScheduler)
+	}
+	if len(p.KernelModules) > 0 {
+		files[modulesFile] = header + strings.Join(p.KernelModules, "\n") + "\n"
+	}
+	return files
+}
@@ -0,0 +1,448 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package tuning applies named tuning profiles to hosts and reports where the
+// hosts drifted from them.
+package tuning
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/runner"
+	"peta.io/peta/pkg/types"
+	"peta.io/peta/pkg/types/component"
+	"peta.io/peta/pkg/utils/shellutils"
+	"peta.io/peta/pkg/utils/sysctl"
+)
+
+// Kinds of drift.
+const (
+	KindFile      = "file"
+	KindSysctl    = "sysctl"
+	KindTHP       = "thp"
+	KindScheduler = "scheduler"
+	KindModule    = "module"
+)
+
+// Values of drifts which are not settings.
+const (
+	present     = "present"
+	absent      = "absent"
+	upToDate    = "up to date"
+	differs     = "differs"
+	loaded      = "loaded"
+	unsupported = "unsupported"
+)
+
+// Drift is a setting of a host which differs from its profiles.
+type Drift struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	Want string `json:"want"`
+	Got  string `json:"got"`
+}
+
+// Result of checking or tuning a host.
+type Result struct {
+	Host     string   `json:"host"`
+	Profiles []string `json:"profiles,omitempty"`
+	// Changed is true if the host was tuned.
+	Changed bool `json:"changed,omitempty"`
+	// Drifts are those found by a check, or left after tuning.
+	Drifts []Drift `json:"drifts,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Options of checking and tuning hosts.
+type Options struct {
+	// Profiles replace the profiles the blueprint applies to the hosts.
+	Profiles []string
+	// Concurrency is the maximum number of hosts handled at the same time.
+	Concurrency int
+	// Timeout bounds the connection and all commands on each host.
+	Timeout time.Duration
+	// Output receives a line for each host when it is done, nil discards them.
+	Output io.Writer
+}
+
+// Check returns the drifts of the hosts from their profiles in the order of hosts.
+func Check(ctx context.Context, b *types.Blueprint, hosts []component.Host, o Options) []Result {
+	return forEach(ctx, b, hosts, o, false)
+}
+
+// Apply tunes the hosts which drifted from their profiles and returns the results
+// in the order of hosts. Limits apply to the sessions started after.
+func Apply(ctx context.Context, b *types.Blueprint, hosts []component.Host, o Options) []Result {
+	return forEach(ctx, b, hosts, o, true)
+}
+
+func forEach(ctx context.Context, b *types.Blueprint, hosts []component.Host, o Options, apply bool) []Result {
+	if o.Timeout <= 0 {
+		o.Timeout = runner.DefaultTimeout
+	}
+	var output io.Writer = io.Discard
+	if o.Output != nil {
+		output = &syncWriter{w: o.Output}
+	}
+
+	profiles := Profiles(b)
+	results := make([]Result, len(hosts))
+	runner.ForEach(hosts, o.Concurrency, func(i int, h *component.Host) {
+		r := Result{Host: executor.Name(h), Profiles: o.Profiles}
+		if err := runOnHost(ctx, b, h, profiles, o.Timeout, apply, &r); err != nil {
+			r.Error = err.Error()
+			_, _ = fmt.Fprintf(output, "[%s] %v\n", r.Host, err)
+		} else if len(r.Profiles) > 0 {
+			_, _ = fmt.Fprintf(output, "[%s] %s: changed: %t, drifts: %d\n",
+				r.Host, strings.Join(r.Profiles, ","), r.Changed, len(r.Drifts))
+		}
+		results[i] = r
+	})
+	return results
+}
+
+func runOnHost(ctx context.Context, b *types.Blueprint, h *component.Host,
+	profiles map[string]types.TuningProfile, timeout time.Duration, apply bool, r *Result) error {
+	if len(r.Profiles) == 0 {
+		names, err := HostProfiles(b, h)
+		if err != nil {
+			return err
+		}
+		r.Profiles = names
+	}
+	if len(r.Profiles) == 0 {
+		return nil
+	}
+	p, err := Merge(profiles, r.Profiles...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	e, err := executor.NewContext(ctx, h)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = e.Close() }()
+
+	s, err := probe(ctx, e, p)
+	if err != nil {
+		return err
+	}
+	r.Drifts = Drifts(p, s)
+	if !apply || len(r.Drifts) == 0 {
+		return nil
+	}
+
+	r.Changed = true
+	fixErr := fix(ctx, e, p, r.Drifts)
+	if s, err = probe(ctx, e, p); err != nil {
+		return err
+	}
+	r.Drifts = Drifts(p, s)
+	return fixErr
+}
+
+// State is the part of a host the profiles set.
+type State struct {
+	// Files are the contents of the managed files which exist.
+	Files map[string]string
+	// Sysctl are the values of the variables of the profiles which exist.
+	Sysctl map[string]string
+	// THP is the content of the transparent hugepage setting, like always madvise [never].
+	THP string
+	// Schedulers are the contents of the io scheduler settings by disk.
+	Schedulers map[string]string
+	// Modules are the loaded modules of the profiles.
+	Modules map[string]bool
+}
+
+func probe(ctx context.Context, e executor.Executor, p *types.TuningProfile) (*State, error) {
+	var script strings.Builder
+	fmt.Fprintf(&script, "for f in %s; do [ ! -e \"$f\" ] || printf 'file\\t%%s\\n' \"$f\"; done\n", shellutils.Join(managedFiles...))
+	fmt.Fprintf(&script, "[ ! -r %[1]s ] || printf 'thp\\t%%s\\n' \"$(cat %[1]s)\"\n", thpPath)
+	globs := make([]string, 0, len(diskPatterns))
+	for _, d := range diskPatterns {
+		globs = append(globs, "/sys/block/"+d+"/queue/scheduler")
+	}
+	fmt.Fprintf(&script, `for f in %s; do
+	[ -r "$f" ] || continue
+	d=${f#/sys/block/}
+	printf 'scheduler\t%%s\t%%s\n' "${d%%%%/*}" "$(cat "$f")"
+done
+`, strings.Join(globs, " "))
+	for _, m := range p.KernelModules {
+		fmt.Fprintf(&script, "[ ! -d /sys/module/%s ] || printf 'module\\t%%s\\n' %s\n", moduleDir(m), m)
+	}
+
+	out, err := executor.Output(ctx, e, script.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the settings: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	s := &State{Files: map[string]string{}, Schedulers: map[string]string{}, Modules: map[string]bool{}}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		switch {
+		case fields[0] == "file" && len(fields) == 2:
+			var content bytes.Buffer
+			if err := e.Download(ctx, fields[1], &content); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", fields[1], err)
+			}
+			s.Files[fields[1]] = content.String()
+		case fields[0] == "thp" && len(fields) == 2:
+			s.THP = fields[1]
+		case fields[0] == "scheduler" && len(fields) == 3:
+			s.Schedulers[fields[1]] = fields[2]
+		case fields[0] == "module" && len(fields) == 2:
+			s.Modules[fields[1]] = true
+		}
+	}
+
+	names := make([]string, 0, len(p.Sysctl))
+	for k := range p.Sysctl {
+		names = append(names, k)
+	}
+	if s.Sysctl, err = sysctl.GetAll(ctx, e, names...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Drifts returns the settings of s which differ from p.
+func Drifts(p *types.TuningProfile, s *State) []Drift {
+	var drifts []Drift
+
+	want := Files(p)
+	for _, f := range managedFiles {
+		content, wanted := want[f]
+		current, exists := s.Files[f]
+		switch {
+		case wanted && !exists:
+			drifts = append(drifts, Drift{Kind: KindFile, Key: f, Want: present, Got: absent})
+		case wanted && current != content:
+			drifts = append(drifts, Drift{Kind: KindFile, Key: f, Want: upToDate, Got: differs})
+		case !wanted && exists:
+			drifts = append(drifts, Drift{Kind: KindFile, Key: f, Want: absent, Got: present})
+		}
+	}
+
+	keys := make([]string, 0, len(p.Sysctl))
+	for k := range p.Sysctl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got, ok := s.Sysctl[k]
+		if !ok {
+			got = unsupported
+		}
+		if !ok || !sysctl.Equal(got, p.Sysctl[k]) {
+			drifts = append(drifts, Drift{Kind: KindSysctl, Key: k, Want: p.Sysctl[k], Got: got})
+		}
+	}
+
+	if p.TransparentHugepage != "" {
+		got := unsupported
+		if s.THP != "" {
+			got, _ = selected(s.THP)
+		}
+		if got != p.TransparentHugepage {
+			drifts = append(drifts, Drift{Kind: KindTHP, Key: "enabled", Want: p.TransparentHugepage, Got: got})
+		}
+	}
+
+	if p.IOScheduler != "" {
+		disks := make([]string, 0, len(s.Schedulers))
+		for d := range s.Schedulers {
+			disks = append(disks, d)
+		}
+		sort.Strings(disks)
+		for _, d := range disks {
+			// disks without the scheduler are left as they are.
+			got, available := selected(s.Schedulers[d])
+			if available[p.IOScheduler] && got != p.IOScheduler {
+				drifts = append(drifts, Drift{Kind: KindScheduler, Key: d, Want: p.IOScheduler, Got: got})
+			}
+		}
+	}
+
+	for _, m := range p.KernelModules {
+		if !s.Modules[m] {
+			drifts = append(drifts, Drift{Kind: KindModule, Key: m, Want: loaded, Got: absent})
+		}
+	}
+
+	return drifts
+}
+
+// selected returns the value in brackets of a setting listing the available
+// values, like always madvise [never], and the available values.
+func selected(setting string) (string, map[string]bool) {
+	current := ""
+	available := map[string]bool{}
+	for _, v := range strings.Fields(setting) {
+		if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+			v = strings.Trim(v, "[]")
+			current = v
+		}
+		available[v] = true
+	}
+	return current, available
+}
+
+func fix(ctx context.Context, e executor.Executor, p *types.TuningProfile, drifts []Drift) error {
+	files := Files(p)
+	values := map[string]string{}
+	var unsupportedKeys, cmds []string
+	for _, d := range drifts {
+		switch d.Kind {
+		case KindFile:
+			if d.Want == absent {
+				cmds = append(cmds, "rm -f "+shellutils.Quote(d.Key))
+				continue
+			}
+			if out, err := executor.Output(ctx, e, "mkdir -p "+shellutils.Quote(path.Dir(d.Key))); err != nil {
+				return fmt.Errorf("failed to create %s: %w: %s", path.Dir(d.Key), err, bytes.TrimSpace(out))
+			}
+			if err := e.Upload(ctx, strings.NewReader(files[d.Key]), d.Key, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", d.Key, err)
+			}
+		case KindSysctl:
+			if d.Got == unsupported {
+				unsupportedKeys = append(unsupportedKeys, d.Key)
+				continue
+			}
+			values[d.Key] = d.Want
+		case KindTHP:
+			if d.Got != unsupported {
+				cmds = append(cmds, fmt.Sprintf("printf '%%s\\n' %s > %s", d.Want, thpPath))
+			}
+		case KindScheduler:
+			cmds = append(cmds, fmt.Sprintf("printf '%%s\\n' %s > /sys/block/%s/queue/scheduler",
+				shellutils.Quote(d.Want), shellutils.Quote(d.Key)))
+		case KindModule:
+			cmds = append(cmds, "modprobe "+shellutils.Quote(d.Key))
+		}
+	}
+
+	// the values before the first change are kept for peta init reset.
+	originals := map[string]string{}
+	for _, d := range drifts {
+		if _, ok := values[d.Key]; ok && d.Kind == KindSysctl {
+			originals[d.Key] = d.Got
+		}
+	}
+	if err := sysctl.Backup(ctx, e, originals); err != nil {
+		return err
+	}
+	if err := sysctl.SetAll(ctx, e, values); err != nil {
+		return err
+	}
+	if len(cmds) > 0 {
+		if out, err := executor.Output(ctx, e, strings.Join(cmds, " && ")); err != nil {
+			return fmt.Errorf("failed to apply the settings: %w: %s", err, bytes.TrimSpace(out))
+		}
+	}
+	if len(unsupportedKeys) > 0 {
+		return fmt.Errorf("sysctl %s not supported by the kernel", strings.Join(unsupportedKeys, ", "))
+	}
+	return nil
+}
+
+// moduleDir returns the name of a module under /sys/module, where dashes are underscores.
+func moduleDir(m string) string {
+	return strings.ReplaceAll(m, "-", "_")
+}
+
+// PrintDrifts writes the drifts of the hosts as a table.
+func PrintDrifts(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tPROFILES\tKIND\tKEY\tWANT\tGOT\tERROR")
+	for _, r := range results {
+		profiles := profileNames(r)
+		if len(r.Drifts) == 0 {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t%s\n", r.Host, profiles, r.Error)
+		}
+		for _, d := range r.Drifts {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Host, profiles, d.Kind, d.Key, d.Want, d.Got, r.Error)
+		}
+	}
+	return tw.Flush()
+}
+
+// PrintSummary writes a line for each host tuned.
+func PrintSummary(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "HOST\tPROFILES\tCHANGED\tDRIFTS\tERROR")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%s\n", r.Host, profileNames(r), r.Changed, len(r.Drifts), r.Error)
+	}
+	return tw.Flush()
+}
+
+func profileNames(r Result) string {
+	if len(r.Profiles) == 0 {
+		return "-"
+	}
+	return strings.Join(r.Profiles, ",")
+}
+
+// Failed returns the number of hosts which could not be checked or tuned.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Drifted returns the number of hosts which drifted from their profiles.
+func Drifted(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if len(r.Drifts) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(b)
+}
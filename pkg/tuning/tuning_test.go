@@ -0,0 +1,106 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package tuning
+
+import (
+	"reflect"
+	"testing"
+
+	"peta.io/peta/pkg/types"
+)
+
+func TestMerge(t *testing.T) {
+	profiles := map[string]types.TuningProfile{
+		"base": {
+			Name:          "base",
+			Sysctl:        map[string]string{"vm.swappiness": "10", "net.core.somaxconn": "1024"},
+			Limits:        []types.Limit{{Domain: "postgres", Item: "nofile", Value: "1024"}},
+			KernelModules: []string{"br_netfilter"},
+		},
+		"db": {
+			Name:                "db",
+			Sysctl:              map[string]string{"vm.swappiness": "1"},
+			Limits:              []types.Limit{{Domain: "postgres", Type: "-", Item: "nofile", Value: "65536"}},
+			TransparentHugepage: "never",
+			KernelModules:       []string{"br_netfilter", "8021q"},
+		},
+		"bad": {Name: "bad", TransparentHugepage: "sometimes"},
+	}
+
+	p, err := Merge(profiles, "base", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"vm.swappiness": "1", "net.core.somaxconn": "1024"}; !reflect.DeepEqual(p.Sysctl, want) {
+		t.Errorf("Sysctl = %v, want %v", p.Sysctl, want)
+	}
+	if len(p.Limits) != 1 || p.Limits[0].Value != "65536" {
+		t.Errorf("Limits = %v", p.Limits)
+	}
+	if !reflect.DeepEqual(p.KernelModules, []string{"br_netfilter", "8021q"}) || p.TransparentHugepage != "never" {
+		t.Errorf("Merge() = %+v", p)
+	}
+
+	if _, err := Merge(profiles, "base", "missing"); err == nil {
+		t.Error("Merge() of a missing profile succeeded")
+	}
+	if _, err := Merge(profiles, "bad"); err == nil {
+		t.Error("Merge() of an invalid profile succeeded")
+	}
+}
+
+func TestDrifts(t *testing.T) {
+	p, err := Merge(Profiles(&types.Blueprint{}), PostgresHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := Files(p)
+
+	s := &State{
+		Files: map[string]string{
+			sysctlFile:  files[sysctlFile],
+			limitsFile:  "postgres - nofile 1024\n",
+			modulesFile: "8021q\n",
+		},
+		Sysctl: map[string]string{},
+		THP:    "always madvise [never]",
+		Schedulers: map[string]string{
+			"sda":     "[none] mq-deadline kyber",
+			"nvme0n1": "[mq-deadline] none",
+			"vda":     "[none]",
+		},
+	}
+	for k, v := range p.Sysctl {
+		s.Sysctl[k] = v
+	}
+	s.Sysctl["vm.swappiness"] = "60"
+	delete(s.Sysctl, "fs.file-max")
+
+	want := []Drift{
+		{Kind: KindFile, Key: limitsFile, Want: upToDate, Got: differs},
+		{Kind: KindFile, Key: thpFile, Want: present, Got: absent},
+		{Kind: KindFile, Key: schedulerFile, Want: present, Got: absent},
+		{Kind: KindFile, Key: modulesFile, Want: absent, Got: present},
+		{Kind: KindSysctl, Key: "fs.file-max", Want: "1048576", Got: unsupported},
+		{Kind: KindSysctl, Key: "vm.swappiness", Want: "1", Got: "60"},
+		{Kind: KindScheduler, Key: "sda", Want: "mq-deadline", Got: "none"},
+	}
+	if got := Drifts(p, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("Drifts() = %+v, want %+v", got, want)
+	}
+}
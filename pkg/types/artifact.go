@@ -0,0 +1,44 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import "peta.io/peta/pkg/types/component"
+
+// ArtifactsConfig is what peta artifact export puts into offline bundles in addition
+// to what the components need.
+type ArtifactsConfig struct {
+	// Packages are installed from the bundle with their dependencies.
+	Packages []string `json:"packages,omitempty" yaml:"packages,omitempty"`
+	// Binaries are downloaded for each architecture.
+	Binaries []Binary `json:"binaries,omitempty" yaml:"binaries,omitempty"`
+	// Builders download the packages, they need internet access and the same
+	// distribution as the hosts. The current machine builds the bundle of its
+	// architecture if no builder has it.
+	Builders []component.Host `json:"builders,omitempty" yaml:"builders,omitempty"`
+}
+
+// Binary is a file downloaded for each architecture, the url is a template of
+// the fields Name, Version and Arch, like
+// https://example.com/{{.Name}}-{{.Version}}.linux-{{.Arch}}.tar.gz.
+type Binary struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	URL     string `json:"url" yaml:"url"`
+	// SHA256 verifies the download of each architecture if set.
+	SHA256 map[string]string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+}
@@ -0,0 +1,86 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+	"peta.io/peta/pkg/labels"
+	"peta.io/peta/pkg/types/component"
+)
+
+const KindBlueprint = "Blueprint"
+
+// LoadBlueprint reads a blueprint file.
+func LoadBlueprint(path string) (*Blueprint, error) {
+	fp, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the given blueprint file: %w", err)
+	}
+
+	b := &Blueprint{}
+	if err := yaml.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("unable to parse the given blueprint file %s: %w", fp, err)
+	}
+
+	if b.Kind != "" && b.Kind != KindBlueprint {
+		return nil, fmt.Errorf("unexpected kind %q in blueprint file %s", b.Kind, fp)
+	}
+
+	return b, nil
+}
+
+// Hosts returns the hosts of the enabled components, a host shared by several
+// components is returned once.
+func (b *Blueprint) Hosts() []component.Host {
+	var hosts []component.Host
+	seen := map[string]struct{}{}
+	for _, c := range b.Spec.Components {
+		if !c.Enabled {
+			continue
+		}
+		for _, h := range c.Hosts {
+			key := h.Name + "/" + h.Address
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// SelectHosts returns the hosts of the blueprint whose labels match the selector.
+func (b *Blueprint) SelectHosts(selector labels.Selector) []component.Host {
+	var hosts []component.Host
+	for _, h := range b.Hosts() {
+		if selector.Matches(h.Labels) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
@@ -27,17 +27,47 @@ type Component struct {
 }
 
 type Host struct {
-	Name            string            `json:"name,omitempty" yaml:"name,omitempty"`
-	Address         string            `json:"address,omitempty" yaml:"address,omitempty"`
-	InternalAddress string            `json:"internalAddress,omitempty" yaml:"internalAddress,omitempty"`
-	Port            int               `json:"port,omitempty" yaml:"port,omitempty"`
-	User            string            `json:"user,omitempty" yaml:"user,omitempty"`
-	Password        string            `json:"password,omitempty" yaml:"password,omitempty"`
-	PrivateKey      string            `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
-	PrivateKeyPath  string            `json:"privateKeyPath,omitempty" yaml:"privateKeyPath,omitempty"`
-	Arch            string            `json:"arch,omitempty" yaml:"arch,omitempty"`
-	Timeout         *int64            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
-	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name                string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Address             string            `json:"address,omitempty" yaml:"address,omitempty"`
+	InternalAddress     string            `json:"internalAddress,omitempty" yaml:"internalAddress,omitempty"`
+	Port                int               `json:"port,omitempty" yaml:"port,omitempty"`
+	Connection          string            `json:"connection,omitempty" yaml:"connection,omitempty"`
+	User                string            `json:"user,omitempty" yaml:"user,omitempty"`
+	Password            string            `json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey          string            `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	PrivateKeyPath      string            `json:"privateKeyPath,omitempty" yaml:"privateKeyPath,omitempty"`
+	CertificatePath     string            `json:"certificatePath,omitempty" yaml:"certificatePath,omitempty"`
+	AgentSocket         string            `json:"agentSocket,omitempty" yaml:"agentSocket,omitempty"`
+	Arch                string            `json:"arch,omitempty" yaml:"arch,omitempty"`
+	Timeout             *int64            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Become              *Become           `json:"become,omitempty" yaml:"become,omitempty"`
+	JumpHosts           []JumpHost        `json:"jumpHosts,omitempty" yaml:"jumpHosts,omitempty"`
+	HostKeyPolicy       string            `json:"hostKeyPolicy,omitempty" yaml:"hostKeyPolicy,omitempty"`
+	HostKeyFingerprints []string          `json:"hostKeyFingerprints,omitempty" yaml:"hostKeyFingerprints,omitempty"`
+}
+
+// JumpHost is a bastion host used to reach hosts which are not directly reachable.
+type JumpHost struct {
+	Address             string   `json:"address,omitempty" yaml:"address,omitempty"`
+	Port                int      `json:"port,omitempty" yaml:"port,omitempty"`
+	User                string   `json:"user,omitempty" yaml:"user,omitempty"`
+	Password            string   `json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey          string   `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+	PrivateKeyPath      string   `json:"privateKeyPath,omitempty" yaml:"privateKeyPath,omitempty"`
+	CertificatePath     string   `json:"certificatePath,omitempty" yaml:"certificatePath,omitempty"`
+	HostKeyFingerprints []string `json:"hostKeyFingerprints,omitempty" yaml:"hostKeyFingerprints,omitempty"`
+	// AgentSocket is the ssh-agent socket of the jump host, the one of its host is not
+	// used for it. Default is the value of SSH_AUTH_SOCK.
+	AgentSocket string `json:"agentSocket,omitempty" yaml:"agentSocket,omitempty"`
+}
+
+// Become is the privilege escalation used on hosts which do not allow to log in as root.
+type Become struct {
+	// Method is sudo or su, default is sudo.
+	Method   string `json:"method,omitempty" yaml:"method,omitempty"`
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
 }
 
 type Config interface {
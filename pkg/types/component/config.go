@@ -0,0 +1,74 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package component
+
+import (
+	"fmt"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// configs creates an empty config for each component type.
+var configs = map[string]func() Config{
+	"postgres": func() Config { return &PostgresConfig{} },
+}
+
+// NewConfig returns an empty config of the component type.
+func NewConfig(componentType string) (Config, error) {
+	newConfig, ok := configs[componentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported component type: %q", componentType)
+	}
+	return newConfig(), nil
+}
+
+// UnmarshalYAML decodes the config of the component by its type.
+func (c *Component) UnmarshalYAML(value *yaml.Node) error {
+	type plain Component
+
+	// decode everything but the config as usual.
+	var config *yaml.Node
+	node := *value
+	node.Content = nil
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "config" {
+			config = value.Content[i+1]
+			continue
+		}
+		node.Content = append(node.Content, value.Content[i], value.Content[i+1])
+	}
+
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	if config == nil || (config.Kind == yaml.MappingNode && len(config.Content) == 0) {
+		return nil
+	}
+
+	cfg, err := NewConfig(c.Type)
+	if err != nil {
+		return fmt.Errorf("component %s: %w", c.Name, err)
+	}
+	if err := config.Decode(cfg); err != nil {
+		return fmt.Errorf("component %s: %w", c.Name, err)
+	}
+	c.Config = cfg
+
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+// OSConfig is how peta init os prepares the hosts of a blueprint.
+type OSConfig struct {
+	// ManageHostname sets the hostname of each host to its name, default is true.
+	ManageHostname *bool `json:"manageHostname,omitempty" yaml:"manageHostname,omitempty"`
+	// ManageHosts adds the internal address and name of every host to /etc/hosts, default is true.
+	ManageHosts *bool `json:"manageHosts,omitempty" yaml:"manageHosts,omitempty"`
+	// DisableSwap turns swap off now and on boot, default is true.
+	DisableSwap *bool `json:"disableSwap,omitempty" yaml:"disableSwap,omitempty"`
+	// NTPServers are used by chrony or systemd-timesyncd, the time sync daemon
+	// is only enabled if empty.
+	NTPServers []string `json:"ntpServers,omitempty" yaml:"ntpServers,omitempty"`
+	// Timezone like Asia/Shanghai, unchanged if empty.
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	// KernelModules are loaded now and on boot.
+	KernelModules []string `json:"kernelModules,omitempty" yaml:"kernelModules,omitempty"`
+	// Sysctl are kernel parameters applied now and on boot.
+	Sysctl map[string]string `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`
+	// Users are the service users created on every host.
+	Users []ServiceUser `json:"users,omitempty" yaml:"users,omitempty"`
+}
+
+// ServiceUser is a user running a service, it has no password.
+type ServiceUser struct {
+	Name string `json:"name" yaml:"name"`
+	// UID keeps the owner of data files the same across hosts, allocated by the host if 0.
+	UID int `json:"uid,omitempty" yaml:"uid,omitempty"`
+	// Group is the primary group, default is the user name.
+	Group string `json:"group,omitempty" yaml:"group,omitempty"`
+	GID   int    `json:"gid,omitempty" yaml:"gid,omitempty"`
+	Home  string `json:"home,omitempty" yaml:"home,omitempty"`
+	Shell string `json:"shell,omitempty" yaml:"shell,omitempty"`
+}
+
+// DefaultOSConfig returns the settings used for blueprints without an os section.
+func DefaultOSConfig() *OSConfig {
+	return &OSConfig{
+		KernelModules: []string{"br_netfilter", "8021q"},
+		Sysctl: map[string]string{
+			"net.ipv4.ip_forward": "1",
+			"net.core.somaxconn":  "4096",
+			"vm.swappiness":       "1",
+			"fs.file-max":         "1048576",
+		},
+		Users: []ServiceUser{
+			{Name: "postgres", Home: "/var/lib/postgresql", Shell: "/bin/bash"},
+		},
+	}
+}
+
+// OS returns the os settings of the blueprint.
+func (b *Blueprint) OS() *OSConfig {
+	if b.Spec.OS == nil {
+		return DefaultOSConfig()
+	}
+	return b.Spec.OS
+}
+
+// Enabled returns the value of an optional switch which is on by default.
+func Enabled(b *bool) bool {
+	return b == nil || *b
+}
@@ -0,0 +1,59 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+// TuningConfig is how peta host tune tunes the hosts of a blueprint.
+type TuningConfig struct {
+	// Profiles are added to the built-in profiles, or replace those of the same name.
+	Profiles []TuningProfile `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	// Apply are the profiles of the hosts, a host matching several entries gets
+	// all their profiles in order.
+	Apply []TuningTarget `json:"apply,omitempty" yaml:"apply,omitempty"`
+}
+
+// TuningProfile is a named set of kernel and limits settings, the settings of later
+// profiles of a host win over those of earlier ones.
+type TuningProfile struct {
+	Name string `json:"name" yaml:"name"`
+	// Sysctl are kernel parameters applied now and on boot.
+	Sysctl map[string]string `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`
+	// Limits are written to /etc/security/limits.d and apply to new sessions.
+	Limits []Limit `json:"limits,omitempty" yaml:"limits,omitempty"`
+	// TransparentHugepage is always, madvise or never, unchanged if empty.
+	TransparentHugepage string `json:"transparentHugepage,omitempty" yaml:"transparentHugepage,omitempty"`
+	// IOScheduler is set on all disks supporting it, like mq-deadline, unchanged if empty.
+	IOScheduler string `json:"ioScheduler,omitempty" yaml:"ioScheduler,omitempty"`
+	// KernelModules are loaded now and on boot.
+	KernelModules []string `json:"kernelModules,omitempty" yaml:"kernelModules,omitempty"`
+}
+
+// Limit is a line of limits.conf, like postgres soft nofile 65536.
+type Limit struct {
+	Domain string `json:"domain" yaml:"domain"`
+	// Type is soft, hard or -, both if empty.
+	Type  string `json:"type,omitempty" yaml:"type,omitempty"`
+	Item  string `json:"item" yaml:"item"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// TuningTarget selects the hosts of profiles.
+type TuningTarget struct {
+	// Selector is a label selector of the hosts, all hosts if empty.
+	Selector string   `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Profiles []string `json:"profiles" yaml:"profiles"`
+}
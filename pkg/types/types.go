@@ -17,7 +17,11 @@
 
 package types
 
-import "peta.io/peta/pkg/types/component"
+import (
+	"time"
+
+	"peta.io/peta/pkg/types/component"
+)
 
 type TypeMeta struct {
 	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
@@ -29,15 +33,32 @@ type ObjectMeta struct {
 	Namespace   string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	// UID, ResourceVersion and CreationTimestamp are set by the server for stored objects.
+	UID string `json:"uid,omitempty" yaml:"uid,omitempty"`
+	// ResourceVersion changes with every write of the object, updates of an older
+	// version conflict.
+	ResourceVersion   string     `json:"resourceVersion,omitempty" yaml:"resourceVersion,omitempty"`
+	CreationTimestamp *time.Time `json:"creationTimestamp,omitempty" yaml:"creationTimestamp,omitempty"`
+}
+
+// GetObjectMeta returns the metadata of the objects embedding it.
+func (m *ObjectMeta) GetObjectMeta() *ObjectMeta {
+	return m
 }
 
 type Spec struct {
 	Components []component.Component `json:"components,omitempty" yaml:"components,omitempty"`
+	// OS is how the hosts are prepared, the defaults are used if nil.
+	OS *OSConfig `json:"os,omitempty" yaml:"os,omitempty"`
+	// Artifacts are the extra contents of offline bundles.
+	Artifacts *ArtifactsConfig `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+	// Tuning are the tuning profiles of the hosts.
+	Tuning *TuningConfig `json:"tuning,omitempty" yaml:"tuning,omitempty"`
 }
 
 type Blueprint struct {
-	TypeMeta `json:",inline" yaml:",inline"`
-	//ObjectMeta `json:"metadata,omitempty" yaml:"metadata"`
+	TypeMeta   `json:",inline" yaml:",inline"`
+	ObjectMeta `json:"metadata,omitempty" yaml:"metadata"`
 
-	//Spec Spec `json:"spec,omitempty" yaml:"spec,omitempty"`
+	Spec Spec `json:"spec,omitempty" yaml:"spec,omitempty"`
 }
@@ -38,6 +38,7 @@ func fatal(msg string, code int) {
 		}
 		_, _ = fmt.Fprint(os.Stderr, msg)
 	}
+	os.Exit(code)
 }
 
 var fatalErrHandler = fatal
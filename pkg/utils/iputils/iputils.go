@@ -18,9 +18,11 @@
 package iputils
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
 const (
@@ -61,6 +63,70 @@ func RemoteIP(req *http.Request) string {
 	return remoteAddr
 }
 
+// ParseNetworks parses CIDRs and IP addresses, an address is a network of its own.
+func ParseNetworks(addrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or a CIDR", addr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP returns the IP address of the client of req, the address of its connection.
+// The X-Forwarded-For and X-Real-IP headers are only honored for the connections of
+// the trusted proxies, the client is the last forwarding address not of a proxy.
+func ClientIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	if !contains(trustedProxies, ip) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, value := range req.Header.Values(XForwardedFor) {
+		for _, addr := range strings.Split(value, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(addr))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if net.ParseIP(forwarded[i]) == nil {
+			break
+		}
+		if ip = forwarded[i]; !contains(trustedProxies, ip) {
+			return ip
+		}
+	}
+	if real := req.Header.Get(XRealIP); len(forwarded) == 0 && net.ParseIP(real) != nil {
+		return real
+	}
+	return ip
+}
+
+// contains returns true if ip is in one of the networks.
+func contains(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, n := range networks {
+		if parsed != nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
 func IsValidPort(port int) bool {
 	return port > 0 && port < 65535
 }
@@ -18,6 +18,7 @@
 package iputils
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -62,6 +63,36 @@ func TestIsValidDomain(t *testing.T) {
 	}
 }
 
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks([]string{"10.0.0.1", "192.168.0.0/16"})
+	assert.NoError(t, err)
+
+	for name, test := range map[string]struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		"remote":            {remoteAddr: "1.2.3.4:5678", expected: "1.2.3.4"},
+		"untrusted forward": {remoteAddr: "1.2.3.4:5678", headers: map[string]string{XForwardedFor: "5.6.7.8", XRealIP: "5.6.7.8", XClientIP: "5.6.7.8"}, expected: "1.2.3.4"},
+		"trusted forward":   {remoteAddr: "10.0.0.1:5678", headers: map[string]string{XForwardedFor: "5.6.7.8"}, expected: "5.6.7.8"},
+		"spoofed forward":   {remoteAddr: "10.0.0.1:5678", headers: map[string]string{XForwardedFor: "9.9.9.9, 5.6.7.8, 192.168.1.1"}, expected: "5.6.7.8"},
+		"trusted real ip":   {remoteAddr: "192.168.1.1:5678", headers: map[string]string{XRealIP: "5.6.7.8"}, expected: "5.6.7.8"},
+		"client ip":         {remoteAddr: "10.0.0.1:5678", headers: map[string]string{XClientIP: "5.6.7.8"}, expected: "10.0.0.1"},
+		"invalid forward":   {remoteAddr: "10.0.0.1:5678", headers: map[string]string{XForwardedFor: "unknown"}, expected: "10.0.0.1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := &http.Request{RemoteAddr: test.remoteAddr, Header: http.Header{}}
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, test.expected, ClientIP(req, trusted))
+		})
+	}
+
+	_, err = ParseNetworks([]string{"10.0.0"})
+	assert.Error(t, err)
+}
+
 func TestIPUtils(t *testing.T) {
 	t.Run("TestIsValidIP", TestIsValidIP)
 	t.Run("TestIsValidDomain", TestIsValidDomain)
@@ -0,0 +1,154 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sysctl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"peta.io/peta/pkg/executor"
+	"peta.io/peta/pkg/utils/shellutils"
+)
+
+// BackupFile keeps the values variables had before peta first changed them, one
+// "name = value" line each, peta init reset restores them.
+const BackupFile = "/var/lib/peta/sysctl.orig"
+
+// GetAll reads the variables of a host with a single command, it is the remote
+// counterpart of Sysctl. Variables which do not exist on the host are not returned.
+func GetAll(ctx context.Context, e executor.Executor, names ...string) (map[string]string, error) {
+	var script strings.Builder
+	for _, name := range names {
+		p, err := procPath(name)
+		if err != nil {
+			return nil, err
+		}
+		// tabs separate the values of some variables, like net.ipv4.tcp_rmem.
+		fmt.Fprintf(&script, "[ ! -r %[1]s ] || printf '%%s\\t%%s\\n' %[2]s \"$(tr '\\t' ' ' < %[1]s)\"\n",
+			shellutils.Quote(p), shellutils.Quote(name))
+	}
+	if script.Len() == 0 {
+		return map[string]string{}, nil
+	}
+
+	out, err := executor.Output(ctx, e, script.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sysctl: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	values := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, value, ok := strings.Cut(strings.TrimRight(line, "\r"), "\t"); ok {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// SetAll writes the variables of a host with a single command, it stops at the
+// first variable which cannot be written.
+func SetAll(ctx context.Context, e executor.Executor, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmds := make([]string, 0, len(names))
+	for _, name := range names {
+		p, err := procPath(name)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf("printf '%%s\\n' %s > %s", shellutils.Quote(values[name]), shellutils.Quote(p)))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	if out, err := executor.Output(ctx, e, strings.Join(cmds, " && ")); err != nil {
+		return fmt.Errorf("failed to write sysctl: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Backup adds the values of variables which are not in BackupFile yet, values
+// are those before the first change.
+func Backup(ctx context.Context, e executor.Executor, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmds := []string{"set -e", "mkdir -p " + path.Dir(BackupFile)}
+	for _, name := range names {
+		cmds = append(cmds, fmt.Sprintf("awk -F ' = ' -v k=%[1]s '$1 == k { f = 1 } END { exit !f }' %[2]s 2>/dev/null || printf '%%s = %%s\\n' %[1]s %[3]s >> %[2]s",
+			shellutils.Quote(name), BackupFile, shellutils.Quote(strings.Join(strings.Fields(values[name]), " "))))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	if out, err := executor.Output(ctx, e, strings.Join(cmds, "\n")); err != nil {
+		return fmt.Errorf("failed to back up sysctl: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Equal reports whether two values of a variable are the same, ignoring the
+// whitespace between fields.
+func Equal(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+func procPath(name string) (string, error) {
+	p := path.Join("/proc/sys", toNormalName(name))
+	if name == "" || !strings.HasPrefix(p, "/proc/sys/") || strings.ContainsAny(name, " \t\n") {
+		return "", fmt.Errorf("invalid sysctl name %q", name)
+	}
+	return p, nil
+}
+
+// Normalize names by using slash as separator
+// Sysctl names can use dots or slashes as separator:
+// - if dots are used, dots and slashes are interchanged.
+// - if slashes are used, slashes and dots are left intact.
+// Separator in use is determined by first occurrence.
+func toNormalName(name string) string {
+	interchange := false
+	for _, c := range name {
+		if c == '.' {
+			interchange = true
+			break
+		}
+		if c == '/' {
+			break
+		}
+	}
+
+	if interchange {
+		r := strings.NewReplacer(".", "/", "/", ".")
+		return r.Replace(name)
+	}
+	return name
+}
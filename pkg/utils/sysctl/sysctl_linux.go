@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // Sysctl provides a method to set/get values from /proc/sys - in linux systems
@@ -55,27 +54,3 @@ func setSysctl(name, value string) (string, error) {
 
 	return getSysctl(name)
 }
-
-// Normalize names by using slash as separator
-// Sysctl names can use dots or slashes as separator:
-// - if dots are used, dots and slashes are interchanged.
-// - if slashes are used, slashes and dots are left intact.
-// Separator in use is determined by first occurrence.
-func toNormalName(name string) string {
-	interchange := false
-	for _, c := range name {
-		if c == '.' {
-			interchange = true
-			break
-		}
-		if c == '/' {
-			break
-		}
-	}
-
-	if interchange {
-		r := strings.NewReplacer(".", "/", "/", ".")
-		return r.Replace(name)
-	}
-	return name
-}
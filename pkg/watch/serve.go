@@ -0,0 +1,157 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"peta.io/peta/pkg/apis"
+)
+
+// DefaultTimeout is how long a watch lasts if the client does not ask for less.
+const DefaultTimeout = 30 * time.Minute
+
+// ListFunc returns the objects sent as ADDED events first to the watches started
+// without a resource version.
+type ListFunc func(ctx context.Context) ([]interface{}, error)
+
+// ConvertFunc returns what is sent of the object of an event. The objects of the
+// broadcaster are shared by the watches, it returns a changed copy rather than
+// changing them.
+type ConvertFunc func(obj interface{}) interface{}
+
+// Parameters returns the query parameters of the list and watch routes.
+func Parameters(ws *restful.WebService) []*restful.Parameter {
+	return []*restful.Parameter{
+		ws.QueryParameter("watch", "Watch for changes instead of listing, same as the watch route").DataType("boolean"),
+		ws.QueryParameter("resourceVersion", "Resume the watch after the resource version of the last event received"),
+		ws.QueryParameter("timeoutSeconds", "Timeout of the watch in seconds").DataType("integer"),
+	}
+}
+
+// IsWatch returns true for the list requests asking to watch instead.
+func IsWatch(request *restful.Request) bool {
+	switch strings.ToLower(request.QueryParameter("watch")) {
+	case "true", "1":
+		return true
+	}
+	return false
+}
+
+// Serve streams the events of b matching filter to the client as chunked JSON, an
+// event per line, until the client goes away or the timeout passes. The watch
+// resumes after the resourceVersion query parameter, or starts with the objects of
+// list without it.
+func Serve(request *restful.Request, response *restful.Response, b *Broadcaster, filter FilterFunc, list ListFunc) {
+	ServeConverted(request, response, b, filter, nil, list)
+}
+
+// ServeConverted is Serve sending the objects of the events converted by convert,
+// like without their secrets.
+func ServeConverted(request *restful.Request, response *restful.Response, b *Broadcaster, filter FilterFunc, convert ConvertFunc, list ListFunc) {
+	timeout := DefaultTimeout
+	if s := request.QueryParameter("timeoutSeconds"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 {
+			apis.HandleBadRequest(response, request, errors.New("timeoutSeconds must be a non-negative integer"))
+			return
+		}
+		if seconds > 0 && time.Duration(seconds)*time.Second < timeout {
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	resourceVersion := request.QueryParameter("resourceVersion")
+	// the watch starts before the list, changes in between are sent twice rather than
+	// lost, and the listed objects are of the version before the watch.
+	version := b.ResourceVersion()
+	w, err := b.Watch(resourceVersion, filter)
+	if errors.Is(err, ErrResourceVersionTooOld) {
+		apis.HandleGone(response, request, err)
+		return
+	}
+	if err != nil {
+		apis.HandleBadRequest(response, request, err)
+		return
+	}
+	defer w.Stop()
+
+	var initial []interface{}
+	if resourceVersion == "" || resourceVersion == "0" {
+		if initial, err = list(request.Request.Context()); err != nil {
+			apis.HandleInternalError(response, request, err)
+			return
+		}
+	}
+
+	flusher, ok := response.ResponseWriter.(http.Flusher)
+	if !ok {
+		apis.HandleInternalError(response, request, errors.New("streaming is not supported"))
+		return
+	}
+
+	response.Header().Set("Content-Type", restful.MIME_JSON)
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(response)
+
+	for _, obj := range initial {
+		e := Event{Type: Added, Object: obj, ResourceVersion: version}
+		if filter != nil && !filter(e) {
+			continue
+		}
+		if convert != nil {
+			e.Object = convert(e.Object)
+		}
+		if err := enc.Encode(e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-request.Request.Context().Done():
+			return
+		case <-timer.C:
+			return
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				// the watcher was too slow, the client resumes from its last event.
+				return
+			}
+			if convert != nil {
+				e.Object = convert(e.Object)
+			}
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			if len(w.ResultChan()) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
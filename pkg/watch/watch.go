@@ -0,0 +1,213 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+// Package watch streams the changes of API objects to clients.
+package watch
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// EventType is the kind of change of an event.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+	// Error events end a watch, their object describes the error.
+	Error EventType = "ERROR"
+)
+
+const (
+	// DefaultHistorySize is the number of events kept to resume watches.
+	DefaultHistorySize = 1000
+
+	// watcherBuffer is the number of events a watcher may lag behind before it is
+	// stopped, the client resumes from the last version it got.
+	watcherBuffer = 100
+)
+
+// ErrResourceVersionTooOld is returned for resource versions whose events are no
+// longer kept, clients list the objects again.
+var ErrResourceVersionTooOld = errors.New("too old resource version")
+
+// Event is a change of an object.
+type Event struct {
+	Type   EventType   `json:"type"`
+	Object interface{} `json:"object"`
+	// ResourceVersion orders the events, a watch resumes after it.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	version uint64
+}
+
+// Interface is a watch of events.
+type Interface interface {
+	// ResultChan returns the events, it is closed when the watch stops.
+	ResultChan() <-chan Event
+	// Stop stops the watch, it may be called more than once.
+	Stop()
+}
+
+// FilterFunc returns true for the events a watcher gets.
+type FilterFunc func(e Event) bool
+
+// Broadcaster sends the events of a kind of objects to its watchers, and keeps the
+// latest ones so watches can resume.
+type Broadcaster struct {
+	mu      sync.Mutex
+	version uint64
+	// floor is the version of the latest event no longer kept.
+	floor    uint64
+	history  []Event
+	size     int
+	watchers map[*watcher]struct{}
+}
+
+// NewBroadcaster returns a broadcaster keeping the latest historySize events.
+func NewBroadcaster(historySize int) *Broadcaster {
+	return &Broadcaster{
+		size:     historySize,
+		watchers: map[*watcher]struct{}{},
+	}
+}
+
+// Action sends the change of obj to the watchers and returns its resource version.
+// Watchers too slow to take it are stopped.
+func (b *Broadcaster) Action(t EventType, obj interface{}) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.actionLocked(t, obj, b.version+1)
+}
+
+// ActionAt is Action for objects whose versions are kept elsewhere, like in the
+// database, the versions of the changes must increase but may skip numbers.
+func (b *Broadcaster) ActionAt(t EventType, obj interface{}, version uint64) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.actionLocked(t, obj, max(version, b.version+1))
+}
+
+func (b *Broadcaster) actionLocked(t EventType, obj interface{}, version uint64) string {
+	b.version = version
+	e := Event{Type: t, Object: obj, ResourceVersion: strconv.FormatUint(version, 10), version: version}
+
+	if len(b.history) == b.size {
+		b.floor = b.history[0].version
+		copy(b.history, b.history[1:])
+		b.history = b.history[:b.size-1]
+	}
+	b.history = append(b.history, e)
+
+	for w := range b.watchers {
+		if !w.send(e) {
+			b.stopLocked(w)
+		}
+	}
+	return e.ResourceVersion
+}
+
+// Advance moves the resource version forward to version without an event, the
+// watches can no longer resume from older versions. It is for broadcasters of
+// versions kept elsewhere, which start at the version they are at.
+func (b *Broadcaster) Advance(version uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if version > b.version {
+		b.version, b.floor, b.history = version, version, nil
+	}
+}
+
+// ResourceVersion returns the version of the latest event.
+func (b *Broadcaster) ResourceVersion() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strconv.FormatUint(b.version, 10)
+}
+
+// Watch returns a watch of the events after resourceVersion matching filter, a nil
+// filter matches all. An empty or 0 resourceVersion starts at the next event.
+func (b *Broadcaster) Watch(resourceVersion string, filter FilterFunc) (Interface, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if resourceVersion != "" && resourceVersion != "0" {
+		from, err := strconv.ParseUint(resourceVersion, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid resource version " + strconv.Quote(resourceVersion))
+		}
+		// versions start again after a restart, so newer versions are unknown too.
+		if from < b.floor || from > b.version {
+			return nil, ErrResourceVersionTooOld
+		}
+		for _, e := range b.history {
+			if e.version > from {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	w := &watcher{
+		broadcaster: b,
+		filter:      filter,
+		result:      make(chan Event, len(replay)+watcherBuffer),
+	}
+	for _, e := range replay {
+		w.send(e)
+	}
+	b.watchers[w] = struct{}{}
+	return w, nil
+}
+
+func (b *Broadcaster) stopLocked(w *watcher) {
+	if _, ok := b.watchers[w]; ok {
+		delete(b.watchers, w)
+		close(w.result)
+	}
+}
+
+type watcher struct {
+	broadcaster *Broadcaster
+	filter      FilterFunc
+	result      chan Event
+}
+
+func (w *watcher) ResultChan() <-chan Event {
+	return w.result
+}
+
+func (w *watcher) Stop() {
+	w.broadcaster.mu.Lock()
+	defer w.broadcaster.mu.Unlock()
+	w.broadcaster.stopLocked(w)
+}
+
+// send queues e if it matches, it returns false if the watcher is too slow.
+func (w *watcher) send(e Event) bool {
+	if w.filter != nil && !w.filter(e) {
+		return true
+	}
+	select {
+	case w.result <- e:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ *  This file is part of PETA.
+ *  Copyright (C) 2025 The PETA Authors.
+ *  PETA is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  PETA is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with PETA. If not, see <https://www.gnu.org/licenses/>.
+ */
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+func receive(t *testing.T, w Interface, n int) []Event {
+	t.Helper()
+	var events []Event
+	for i := 0; i < n; i++ {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				t.Fatalf("watch stopped after %d events", i)
+			}
+			events = append(events, e)
+		default:
+			t.Fatalf("got %d events, want %d", i, n)
+		}
+	}
+	return events
+}
+
+func TestBroadcasterWatch(t *testing.T) {
+	b := NewBroadcaster(10)
+	w, err := b.Watch("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	b.Action(Added, "a")
+	b.Action(Modified, "a")
+	b.Action(Deleted, "a")
+
+	events := receive(t, w, 3)
+	for i, want := range []EventType{Added, Modified, Deleted} {
+		if events[i].Type != want {
+			t.Errorf("event %d is %s, want %s", i, events[i].Type, want)
+		}
+	}
+	if events[2].ResourceVersion != "3" || b.ResourceVersion() != "3" {
+		t.Errorf("got resource version %s, broadcaster %s", events[2].ResourceVersion, b.ResourceVersion())
+	}
+}
+
+func TestBroadcasterResume(t *testing.T) {
+	b := NewBroadcaster(3)
+	for _, obj := range []string{"a", "b", "c", "d"} {
+		b.Action(Added, obj)
+	}
+
+	w, err := b.Watch("2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	events := receive(t, w, 2)
+	if events[0].Object != "c" || events[1].Object != "d" {
+		t.Errorf("replayed %v", events)
+	}
+
+	for _, rv := range []string{"0", "1", "4"} {
+		w, err := b.Watch(rv, nil)
+		if err != nil {
+			t.Errorf("watch from %s: %v", rv, err)
+			continue
+		}
+		w.Stop()
+	}
+	if _, err := b.Watch("5", nil); !errors.Is(err, ErrResourceVersionTooOld) {
+		t.Errorf("watch from a future version: got %v", err)
+	}
+	if _, err := b.Watch("-1", nil); err == nil || errors.Is(err, ErrResourceVersionTooOld) {
+		t.Errorf("watch from an invalid version: got %v", err)
+	}
+	if _, err := NewBroadcaster(3).Watch("1", nil); !errors.Is(err, ErrResourceVersionTooOld) {
+		t.Errorf("watch from a version of before a restart: got %v", err)
+	}
+}
+
+func TestBroadcasterFilter(t *testing.T) {
+	b := NewBroadcaster(10)
+	w, err := b.Watch("", func(e Event) bool { return e.Object == "b" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	b.Action(Added, "a")
+	b.Action(Added, "b")
+	if events := receive(t, w, 1); events[0].Object != "b" || events[0].ResourceVersion != "2" {
+		t.Errorf("got %v", events)
+	}
+	if len(w.ResultChan()) != 0 {
+		t.Errorf("got unexpected events")
+	}
+}
+
+func TestBroadcasterSlowWatcher(t *testing.T) {
+	b := NewBroadcaster(10)
+	w, err := b.Watch("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= watcherBuffer; i++ {
+		b.Action(Added, i)
+	}
+
+	n := 0
+	for range w.ResultChan() {
+		n++
+	}
+	if n != watcherBuffer {
+		t.Errorf("got %d events before the watch stopped, want %d", n, watcherBuffer)
+	}
+	// stopping a stopped watch is fine
+	w.Stop()
+}
+
+func TestBroadcasterActionAt(t *testing.T) {
+	b := NewBroadcaster(2)
+	b.Advance(10)
+	if _, err := b.Watch("9", nil); !errors.Is(err, ErrResourceVersionTooOld) {
+		t.Errorf("watch from before the broadcaster started: got %v", err)
+	}
+
+	b.ActionAt(Added, "a", 12)
+	b.ActionAt(Modified, "a", 15)
+	w, err := b.Watch("10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if events := receive(t, w, 2); events[0].ResourceVersion != "12" || events[1].ResourceVersion != "15" {
+		t.Errorf("replayed %v", events)
+	}
+
+	b.ActionAt(Deleted, "a", 20)
+	if _, err := b.Watch("10", nil); !errors.Is(err, ErrResourceVersionTooOld) {
+		t.Errorf("watch from a dropped version: got %v", err)
+	}
+	if _, err := b.Watch("12", nil); err != nil {
+		t.Errorf("watch from the latest dropped version: %v", err)
+	}
+}
+
+func TestServeConverted(t *testing.T) {
+	b := NewBroadcaster(10)
+	b.Action(Added, "a")
+	b.Action(Added, "b")
+
+	recorder := httptest.NewRecorder()
+	request := restful.NewRequest(httptest.NewRequest("GET", "/watch?resourceVersion=1&timeoutSeconds=1", nil))
+	ServeConverted(request, restful.NewResponse(recorder), b, nil,
+		func(obj interface{}) interface{} { return strings.ToUpper(obj.(string)) }, nil)
+
+	var e Event
+	if err := json.Unmarshal(recorder.Body.Bytes(), &e); err != nil {
+		t.Fatalf("got %q: %v", recorder.Body.String(), err)
+	}
+	if e.Object != "B" || e.ResourceVersion != "2" {
+		t.Errorf("got the event %+v, want the object B of version 2", e)
+	}
+}
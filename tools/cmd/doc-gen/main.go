@@ -24,6 +24,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"reflect"
+	"regexp"
+	"strings"
 
 	restfulspec "github.com/emicklei/go-restful-openapi/v2"
 	"github.com/go-openapi/loads"
@@ -31,9 +34,13 @@ import (
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/validate"
 	"peta.io/peta/pkg/apis"
+	blueprintv1alpha2 "peta.io/peta/pkg/apis/blueprint/v1alpha2"
+	clusterv1alpha2 "peta.io/peta/pkg/apis/cluster/v1alpha2"
 	configv1alpha2 "peta.io/peta/pkg/apis/config/v1alpha2"
 	"peta.io/peta/pkg/apis/healthz"
+	hostv1alpha2 "peta.io/peta/pkg/apis/host/v1alpha2"
 	iamv1alpha2 "peta.io/peta/pkg/apis/iam/v1alpha2"
+	tenantv1alpha2 "peta.io/peta/pkg/apis/tenant/v1alpha2"
 	"peta.io/peta/pkg/apis/version"
 	"peta.io/peta/pkg/log"
 	urlruntime "peta.io/peta/pkg/runtime"
@@ -94,6 +101,10 @@ func generateSwaggerJSON() []byte {
 		healthz.NewFakeHandler(),
 		configv1alpha2.NewFakeHandler(),
 		iamv1alpha2.NewFakeHandler(),
+		hostv1alpha2.NewFakeHandler(),
+		clusterv1alpha2.NewFakeHandler(),
+		tenantv1alpha2.NewFakeHandler(),
+		blueprintv1alpha2.NewFakeHandler(),
 	}
 
 	for _, h := range handlers {
@@ -103,6 +114,7 @@ func generateSwaggerJSON() []byte {
 	config := restfulspec.Config{
 		WebServices:                   container.RegisteredWebServices(),
 		PostBuildSwaggerObjectHandler: enrichSwaggerObject,
+		ModelTypeNameHandler:          modelTypeName,
 	}
 
 	data, _ := json.MarshalIndent(restfulspec.BuildSwagger(config), "", "  ")
@@ -113,6 +125,19 @@ func generateSwaggerJSON() []byte {
 	return data
 }
 
+// typeParameterPackage matches the import paths of the type parameters of generic types.
+var typeParameterPackage = regexp.MustCompile(`[\w.-]+/(?:[\w.-]+/)*`)
+
+// modelTypeName leaves the import paths out of the names of generic types, like
+// query.List[rbac.Role] for query.List[peta.io/peta/pkg/server/authorization/rbac.Role].
+func modelTypeName(t reflect.Type) (string, bool) {
+	name := t.String()
+	if !strings.Contains(name, "[") {
+		return "", false
+	}
+	return typeParameterPackage.ReplaceAllString(name, ""), true
+}
+
 func enrichSwaggerObject(swo *spec.Swagger) {
 	swo.Info = &spec.Info{
 		InfoProps: spec.InfoProps{
@@ -151,6 +176,16 @@ func enrichSwaggerObject(swo *spec.Swagger) {
 				Name: apis.TagNonResourceAPI,
 			},
 		},
+		{
+			TagProps: spec.TagProps{
+				Name: apis.TagHostOperations,
+			},
+		},
+		{
+			TagProps: spec.TagProps{
+				Name: apis.TagAccessControl,
+			},
+		},
 	}
 }
 